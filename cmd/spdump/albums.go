@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/albums"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runAlbums reports, for each album a playlist has tracks of, how many of
+// the album's tracks it holds. --complete-albums adds the missing ones to
+// the end of the playlist, album by album, printing the changes unless
+// --apply is given.
+//
+//	spdump albums 37i9dQZF1DXcBWIGoYBM5M --min-tracks 3
+//	spdump albums <playlist> --complete-albums --apply
+func runAlbums(args []string) error {
+	fs := flag.NewFlagSet("albums", flag.ExitOnError)
+	minTracks := fs.Int("min-tracks", 1, "leave out albums with fewer tracks in the playlist")
+	complete := fs.Bool("complete-albums", false, "add the missing tracks of the albums to the playlists")
+	apply := fs.Bool("apply", false, "with --complete-albums, add the tracks instead of printing the changes")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token, with the "+modifyScopes+" scopes to complete albums (or set SPOTIFY_TOKEN)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump albums <playlist>... [--min-tracks 1] [--complete-albums [--apply]]")
+	}
+	if *apply && !*complete {
+		return errors.New("--apply only goes with --complete-albums")
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	playlists := make([]spotify.MusicPlaylist, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		playlist, err := sp.PlaylistFromID(ctx, id)
+		if err != nil {
+			return err
+		}
+		playlists = append(playlists, spotify.ConvertToMusicPlaylist(playlist))
+	}
+	full, err := fetchFullAlbums(ctx, sp, playlists)
+	if err != nil {
+		return err
+	}
+	reports := make([]albums.Report, len(playlists))
+	for i, mp := range playlists {
+		reports[i] = albums.Check(mp, full, *minTracks)
+	}
+
+	t := table.New("PLAYLIST", "ALBUM", "ARTIST", "TRACKS", "SHARE", "MISSING", "ID").Fixed("ID")
+	for _, report := range reports {
+		for _, album := range report.Albums {
+			t.Add(report.Name, album.Name, album.Artists, fmt.Sprintf("%d/%d", album.Present, album.Total),
+				percent(album.Present, album.Total), len(album.Missing), album.AlbumID)
+		}
+	}
+	if err := output.print(reports, t); err != nil {
+		return err
+	}
+	if !*complete {
+		return nil
+	}
+
+	changes := 0
+	for _, report := range reports {
+		missing := report.Missing()
+		if len(missing) == 0 {
+			continue
+		}
+		changes++
+		err := modifyPlaylist(ctx, sp, report.PlaylistID, func(playlist spotify.SpotifyPlaylist) ([]writeback.Op, error) {
+			return []writeback.Op{{Kind: writeback.Add, PlaylistID: playlist.IntegrationID, Name: playlist.Name, Tracks: missing}}, nil
+		}, !*apply)
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case changes == 0:
+		slog.Info("albums already complete")
+	case !*apply:
+		slog.Info("dry run, pass --apply to add the tracks", "playlists", changes)
+	}
+	return nil
+}
+
+// fetchFullAlbums fetches the albums of the playlists' tracks with all
+// their tracks, by ID. The albums are fetched in batches, and those too
+// long for a batch to include every track once more on their own.
+func fetchFullAlbums(ctx context.Context, sp *spotify.Client, playlists []spotify.MusicPlaylist) (map[string]spotify.SpotifyAlbum, error) {
+	planner := sp.NewPlanner()
+	var ids []string
+	seen := map[string]bool{}
+	for _, mp := range playlists {
+		planner.AddTrackAlbums(mp.Tracks)
+		for _, track := range mp.Tracks {
+			if track.AlbumID != "" && !seen[track.AlbumID] {
+				seen[track.AlbumID] = true
+				ids = append(ids, track.AlbumID)
+			}
+		}
+	}
+	if err := planner.Fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	full := make(map[string]spotify.SpotifyAlbum, len(ids))
+	for _, id := range ids {
+		album, ok := planner.Album(id)
+		if !ok {
+			continue
+		}
+		if album.TracksCollection.Next != "" {
+			var err error
+			if album, err = sp.AlbumFromID(ctx, id); err != nil {
+				return nil, err
+			}
+		}
+		full[id] = album
+	}
+	return full, nil
+}
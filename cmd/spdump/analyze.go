@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	"github.com/pyrat/spd/pkg/stats"
+	flag "github.com/spf13/pflag"
+)
+
+// runAnalyze reports duplicates, running time and the artist, label and
+// decade breakdown of dumped playlists.
+//
+//	spdump analyze library.json --labels --audio-features
+func runAnalyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	labels := fs.Bool("labels", false, "look up the record labels of the albums")
+	features := fs.Bool("audio-features", false, "look up and average the audio features of the tracks")
+	top := fs.Int("top", 10, "artists and labels to list, 0 for all")
+	retryQueue := fs.String("retry-queue", "", "file queuing the albums and tracks which couldn't be looked up, to retry on the next run (defaults to that of spdump retries)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump analyze <dump.json>... [--labels] [--audio-features]")
+	}
+
+	// the dumps are streamed, a first time for the IDs to look up
+	opts := stats.Options{}
+	if *labels || *features {
+		for _, path := range fs.Args() {
+			if path == "-" {
+				return errors.New("--labels and --audio-features read the dumps twice and need files, not stdin")
+			}
+		}
+		sp, err := newSpotifyFromConfig()
+		if err != nil {
+			return err
+		}
+		ctx := commandContext()
+		var albumIDs, ids []string
+		seen := map[string]bool{}
+		err = dump.StreamFiles(fs.Args(), nil, func(track dump.Track) error {
+			if track.Type == spotify.TypeEpisode || track.Source == spotify.SourceLocal {
+				return nil
+			}
+			if *labels && track.AlbumID != "" && !seen["album:"+track.AlbumID] {
+				seen["album:"+track.AlbumID] = true
+				albumIDs = append(albumIDs, track.AlbumID)
+			}
+			if *features && track.IntegrationID != "" && !seen["track:"+track.IntegrationID] {
+				seen["track:"+track.IntegrationID] = true
+				ids = append(ids, track.IntegrationID)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// albums and tracks which can't be looked up are queued to
+		// retry, rather than failing the whole pass
+		pass := newRetryPass(*retryQueue)
+		var planner *spotify.Planner
+		if *labels {
+			planner = sp.NewPlanner()
+			pass.plan(planner, spotify.TypeAlbum)
+			planner.AddAlbums(albumIDs...)
+			if err := planner.Fetch(ctx); err != nil {
+				return err
+			}
+			opts.Labels = map[string]string{}
+			for _, id := range albumIDs {
+				if album, ok := planner.Album(id); ok {
+					opts.Labels[id] = album.Label
+				}
+			}
+		}
+		if *features {
+			opts.Features = make(map[string]spotify.SpotifyAudioFeatures, len(ids))
+			err := pass.audioFeatures(ctx, sp, ids, func(f spotify.SpotifyAudioFeatures) {
+				opts.Features[f.IntegrationID] = f
+			})
+			if err != nil {
+				return err
+			}
+			slog.Info("looked up audio features", "tracks", len(ids), "found", len(opts.Features))
+		}
+		pass.settle(planner)
+	}
+
+	a := stats.New(opts)
+	err := dump.StreamFiles(fs.Args(), func(mp spotify.MusicPlaylist) error {
+		a.AddPlaylist(mp)
+		return nil
+	}, func(track dump.Track) error {
+		a.AddTrack(track.PlaylistName, track.Position, track.MusicTrack)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	report := a.Report()
+	return output.print(report, analysisTables(report, *top, *labels)...)
+}
+
+// printAnalysis prints the report as aligned tables, listing the first
+// top artists and labels.
+func printAnalysis(w io.Writer, report stats.Report, top int, labels bool) error {
+	for i, t := range analysisTables(report, top, labels) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := writeTable(w, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// analysisTables returns the tables of the report: the totals, the
+// duplicates and the counts, listing the first top artists and labels.
+func analysisTables(report stats.Report, top int, labels bool) []*table.Table {
+	totals := table.New("TOTAL", "")
+	totals.Add("Playlists", report.Playlists)
+	totals.Add("Tracks", report.Tracks)
+	totals.Add("Duration", (time.Duration(report.DurationMS) * time.Millisecond).Round(time.Second))
+	totals.Add("Duplicates", len(report.Duplicates))
+	tables := []*table.Table{totals}
+
+	if len(report.Duplicates) > 0 {
+		t := table.New("DUPLICATE", "PLAYLIST", "POSITION", "TRACK", "MATCHED BY")
+		for i, dup := range report.Duplicates {
+			for _, o := range dup.Occurrences {
+				t.Add(i+1, o.PlaylistName, o.Position, o.Artists+" - "+o.Name, o.MatchedBy)
+			}
+		}
+		tables = append(tables, t)
+	}
+	tables = appendCounts(tables, "ARTIST", report.Artists, top, report.Tracks)
+	if labels {
+		tables = appendCounts(tables, "LABEL", report.Labels, top, report.Tracks)
+	}
+	tables = appendCounts(tables, "DECADE", report.Decades, 0, report.Tracks)
+
+	if f := report.AudioFeatures; f != nil {
+		t := table.New("AUDIO FEATURE", fmt.Sprintf("%d TRACKS", f.Tracks))
+		t.Add("Danceability", fmt.Sprintf("%.2f", f.Danceability))
+		t.Add("Energy", fmt.Sprintf("%.2f", f.Energy))
+		t.Add("Valence", fmt.Sprintf("%.2f", f.Valence))
+		t.Add("Acousticness", fmt.Sprintf("%.2f", f.Acousticness))
+		t.Add("Instrumentalness", fmt.Sprintf("%.2f", f.Instrumentalness))
+		t.Add("Speechiness", fmt.Sprintf("%.2f", f.Speechiness))
+		t.Add("Liveness", fmt.Sprintf("%.2f", f.Liveness))
+		t.Add("Tempo", fmt.Sprintf("%.0f BPM", f.Tempo))
+		t.Add("Loudness", fmt.Sprintf("%.1f dB", f.Loudness))
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// appendCounts appends a table of the first top counts, all of them when
+// top is 0.
+func appendCounts(tables []*table.Table, heading string, counts []stats.Count, top int, total int) []*table.Table {
+	if len(counts) == 0 {
+		return tables
+	}
+	t := table.New(heading, "TRACKS", "SHARE")
+	for i, c := range counts {
+		if top > 0 && i == top {
+			t.Add(fmt.Sprintf("(%d more)", len(counts)-top), "", "")
+			break
+		}
+		t.Add(c.Name, c.Count, percent(c.Count, total))
+	}
+	return append(tables, t)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// artistDump is the output of the artist command.
+type artistDump struct {
+	Artist    spotify.MusicArtist
+	Albums    []spotify.MusicAlbum `json:",omitempty"`
+	TopTracks []spotify.MusicTrack `json:",omitempty"`
+}
+
+// artistOptions selects what the artist command dumps.
+type artistOptions struct {
+	Albums      bool
+	AlbumTracks bool
+	Groups      []string
+	TopTracks   bool
+	Market      string
+	KeepQuery   bool
+	// Sort is "chronological" to list the discography oldest release
+	// first, empty for Spotify's order.
+	Sort   string
+	Fields exportFields
+}
+
+// runArtist dumps an artist's profile and optionally their discography
+// and top tracks. Several artists, or a file of artist IDs, are dumped as
+// ndjson with one artist per line.
+//
+//	spdump artist <id> --albums --top-tracks
+//	spdump artist --from-file artists.txt --top-tracks --market SE
+func runArtist(args []string) error {
+	fs := flag.NewFlagSet("artist", flag.ExitOnError)
+	opts := artistOptions{}
+	fs.BoolVar(&opts.Albums, "albums", false, "include the artist's discography")
+	fs.BoolVar(&opts.AlbumTracks, "album-tracks", false, "include the tracks of every album, implies --albums")
+	fs.StringSliceVar(&opts.Groups, "groups", spotify.AlbumGroups, "album groups in the discography")
+	fs.BoolVar(&opts.TopTracks, "top-tracks", false, "include the artist's top tracks")
+	fs.StringVar(&opts.Market, "market", "", "market (country code) for availability, relinking and top tracks (US for top tracks when unset)")
+	fs.BoolVar(&opts.KeepQuery, "keep-query", false, "keep query strings (si= share tokens) on external URLs")
+	fs.StringVar(&opts.Sort, "sort", "", "chronological to order the discography by release date, and album tracks by disc and track number")
+	fromFile := fs.String("from-file", "", "file of artist IDs, URIs or links, one per line")
+	fields := registerExportFlags(fs)
+	parseFlags(fs, args)
+	if err := fields.loadRedaction(); err != nil {
+		return err
+	}
+	opts.Fields = *fields
+
+	ids := fs.Args()
+	if *fromFile != "" {
+		fileIDs, err := readIDFile(*fromFile)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, fileIDs...)
+	}
+
+	if len(ids) == 0 {
+		return errors.New("usage: spdump artist <artist_id>... [--from-file artists.txt] [--albums] [--top-tracks]")
+	}
+	if opts.Sort != "" && opts.Sort != "chronological" {
+		return fmt.Errorf("--sort must be chronological, not %q", opts.Sort)
+	}
+
+	var clientOpts []spotify.Option
+	if opts.Market != "" {
+		clientOpts = append(clientOpts, spotify.WithMarket(opts.Market))
+	}
+	sp, err := newSpotifyFromConfig(clientOpts...)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, id := range ids {
+		dump, err := dumpArtist(commandContext(), sp, id, opts)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(dump); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpArtist fetches what opts asks for about a single artist.
+func dumpArtist(ctx context.Context, sp *spotify.Client, id string, opts artistOptions) (artistDump, error) {
+	dump := artistDump{}
+
+	artist, err := sp.ArtistFromID(ctx, id)
+	if err != nil {
+		return dump, err
+	}
+	dump.Artist = spotify.ConvertToMusicArtist(artist)
+
+	if opts.Albums || opts.AlbumTracks {
+		discography, err := sp.ArtistAlbums(ctx, id, opts.Groups)
+		if err != nil {
+			return dump, err
+		}
+		for _, album := range discography {
+			if opts.AlbumTracks {
+				album, err = sp.AlbumFromID(ctx, album.IntegrationID)
+				if err != nil {
+					return dump, err
+				}
+			}
+			ma := spotify.ConvertToMusicAlbum(album)
+			for i := range ma.Tracks {
+				ma.Tracks[i].NormalizeURLs(opts.KeepQuery)
+				opts.Fields.applyTrack(&ma.Tracks[i])
+			}
+			if opts.Fields.NoArt {
+				ma.AlbumArt = nil
+			}
+			dump.Albums = append(dump.Albums, ma)
+		}
+		if opts.Sort == "chronological" {
+			spotify.SortAlbumsChronological(dump.Albums)
+		}
+	}
+
+	if opts.TopTracks {
+		tracks, err := sp.ArtistTopTracks(ctx, id, firstNonEmpty(opts.Market, "US"))
+		if err != nil {
+			return dump, err
+		}
+		for _, track := range tracks {
+			mt := spotify.ConvertToMusicTrack(track)
+			mt.NormalizeURLs(opts.KeepQuery)
+			opts.Fields.applyTrack(&mt)
+			dump.TopTracks = append(dump.TopTracks, mt)
+		}
+	}
+
+	if opts.Fields.NoArt {
+		dump.Artist.ArtistArt = nil
+	}
+	dump.Artist.ExternalURL = spotify.NormalizeURL(dump.Artist.ExternalURL, opts.KeepQuery)
+
+	return dump, nil
+}
+
+// readIDFile reads IDs, URIs or links one per line, skipping blank lines
+// and # comments, from standard input when path is -.
+func readIDFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, scanner.Err()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/pyrat/spd/internal/blocklist"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runBlock manages the blocklist of artists, tracks and labels which
+// commands writing playlists leave out.
+//
+//	spdump block add artist spotify:artist:0OdUWJ0sBjDrqHygGUXeCF
+//	spdump block add label "Some Records"
+//	spdump block remove track 4uLU6hMCjMI75M1A2tKUQC
+//	spdump block list
+func runBlock(args []string) error {
+	fs := flag.NewFlagSet("block", flag.ExitOnError)
+	file := fs.String("file", blocklist.DefaultFile, "blocklist file")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	usage := errors.New("usage: spdump block add|remove artist|track|label <value> | spdump block list")
+	if fs.NArg() == 0 {
+		return usage
+	}
+
+	list, err := blocklist.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		t := table.New("KIND", "VALUE")
+		for _, artist := range list.Artists {
+			t.Add(blocklist.KindArtist, artist)
+		}
+		for _, track := range list.Tracks {
+			t.Add(blocklist.KindTrack, track)
+		}
+		for _, label := range list.Labels {
+			t.Add(blocklist.KindLabel, label)
+		}
+		return output.print(list, t)
+
+	case "add", "remove":
+		if fs.NArg() < 3 {
+			return usage
+		}
+		kind := fs.Arg(1)
+		changed := 0
+		for _, value := range fs.Args()[2:] {
+			var ok bool
+			if fs.Arg(0) == "add" {
+				ok, err = list.Add(kind, value)
+			} else {
+				ok, err = list.Remove(kind, value)
+			}
+			if err != nil {
+				return err
+			}
+			if !ok {
+				slog.Warn("blocklist unchanged", "action", fs.Arg(0), kind, value)
+				continue
+			}
+			changed++
+		}
+		if changed == 0 {
+			return nil
+		}
+		return list.Save(*file)
+	}
+	return usage
+}
+
+// filterBlocked drops the tracks the blocklist excludes, logging how many
+// were left out of the playlist. Blocked labels need the tracks' albums
+// fetched through the planner, which may already hold them when the job
+// planned the albums of all its playlists up front.
+func filterBlocked(ctx context.Context, planner *spotify.Planner, list *blocklist.List, name string, tracks []spotify.MusicTrack) ([]spotify.MusicTrack, error) {
+	if list.Empty() {
+		return tracks, nil
+	}
+
+	var labels map[string]string
+	if len(list.Labels) > 0 {
+		planner.AddTrackAlbums(tracks)
+		if err := planner.Fetch(ctx); err != nil {
+			return nil, err
+		}
+		labels = map[string]string{}
+		for _, track := range tracks {
+			if album, ok := planner.Album(track.AlbumID); ok {
+				labels[album.IntegrationID] = album.Label
+			}
+		}
+	}
+
+	kept, blocked := list.Filter(tracks, labels)
+	if blocked > 0 {
+		slog.Info("left out blocked tracks", "playlist", name, "tracks", blocked)
+	}
+	return kept, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runBrowse lists browse categories and archives their editorial playlists,
+// or without a subcommand explores playlists in a terminal UI.
+//
+//	spdump browse categories
+//	spdump browse category <id>... --dump-playlists --archive archive/ --every 24h
+//	spdump browse --archive archive/
+func runBrowse(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "categories":
+			return runBrowseCategories(args[1:])
+		case "category":
+			return runBrowseCategory(args[1:])
+		}
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runBrowseTUI(args)
+	}
+	return errors.New("usage: spdump browse categories | spdump browse category <id>... [--dump-playlists] | spdump browse --archive <dir>")
+}
+
+// runBrowseCategories lists the browse categories.
+func runBrowseCategories(args []string) error {
+	fs := flag.NewFlagSet("browse categories", flag.ExitOnError)
+	market := fs.String("market", "", "market (country code) to list the categories of")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	sp, err := newSpotifyFromConfig()
+	if err != nil {
+		return err
+	}
+
+	categories, err := sp.Categories(commandContext(), *market)
+	if err != nil {
+		return err
+	}
+
+	t := table.New("ID", "NAME").Fixed("ID")
+	for _, category := range categories {
+		t.Add(category.IntegrationID, category.Name)
+	}
+	return output.print(categories, t)
+}
+
+// runBrowseCategory lists the playlists of a category, or archives those
+// of one or more categories as a snapshot each, optionally repeating on a
+// schedule. A category failing to archive is logged and the others are
+// archived still; the run fails at the end, with --every once interrupted,
+// naming the categories which failed last.
+func runBrowseCategory(args []string) error {
+	fs := flag.NewFlagSet("browse category", flag.ExitOnError)
+	market := fs.String("market", "", "market (country code) of the category")
+	dumpPlaylists := fs.Bool("dump-playlists", false, "archive every playlist of the category with its tracks")
+	archiveDir := fs.String("archive", "archive", "archive directory snapshots are written to")
+	every := fs.Duration("every", 0, "repeat the archiving at this interval, e.g. 24h, until interrupted")
+	concurrency := registerConcurrencyFlags(fs)
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 || (fs.NArg() > 1 && !*dumpPlaylists) {
+		return errors.New("usage: spdump browse category <category_id> | spdump browse category <category_id>... --dump-playlists [--every 24h]")
+	}
+	categoryID := fs.Arg(0)
+
+	sp, err := newSpotifyFromConfig(concurrency.options()...)
+	if err != nil {
+		return err
+	}
+
+	if !*dumpPlaylists {
+		playlists, err := sp.CategoryPlaylists(commandContext(), categoryID, *market)
+		if err != nil {
+			return err
+		}
+		t := table.New("ID", "NAME").Fixed("ID")
+		for _, playlist := range playlists {
+			t.Add(playlist.IntegrationID, playlist.Name)
+		}
+		return output.print(playlists, t)
+	}
+
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+
+	redaction, err := redactionRules(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := commandContext()
+
+	opts := dumpOptions{Concurrency: concurrency.playlists(), Fields: exportFields{Redact: redaction}}
+	for {
+		var failed []error
+		for _, categoryID := range fs.Args() {
+			if err := archiveCategory(ctx, sp, arc, categoryID, *market, opts); err != nil {
+				if ctx.Err() != nil {
+					return errors.Join(append(failed, err)...)
+				}
+				slog.Error("can't archive category", "category", categoryID, "err", err)
+				failed = append(failed, fmt.Errorf("category %s: %w", categoryID, err))
+			}
+		}
+		if *every <= 0 {
+			return errors.Join(failed...)
+		}
+
+		select {
+		case <-time.After(*every):
+		case <-ctx.Done():
+			return errors.Join(failed...)
+		}
+	}
+}
+
+// archiveCategory writes a snapshot of all playlists in the category.
+func archiveCategory(ctx context.Context, sp *spotify.Client, arc *archive.Archive, categoryID string, market string, opts dumpOptions) error {
+	playlists, err := sp.CategoryPlaylists(ctx, categoryID, market)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(playlists))
+	for _, playlist := range playlists {
+		ids = append(ids, playlist.IntegrationID)
+	}
+
+	w, err := arc.NewSnapshot("category-"+categoryID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(ctx, playlist)
+		if err != nil {
+			return err
+		}
+		return w.WritePlaylist(mp)
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := w.Close()
+	if err != nil {
+		return err
+	}
+	slog.Info("archived category", "category", categoryID, "playlists", len(snapshot.Playlists), "dir", snapshot.Dir)
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/tui"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// browseHelp is the status line of the terminal browser.
+const browseHelp = "enter open  / search  esc back  q quit"
+
+// browseEntry is a playlist listed by the terminal browser, read from the
+// archive or fetched from the API when opened.
+type browseEntry struct {
+	ID     string
+	Name   string
+	Owner  string
+	Tracks int
+	// snapshot and entry locate the playlist in the archive.
+	snapshot *archive.Snapshot
+	entry    archive.Entry
+}
+
+// browseSource lists the playlists to browse and reads them in full.
+type browseSource struct {
+	list func(ctx context.Context) ([]browseEntry, error)
+	read func(ctx context.Context, entry browseEntry) (spotify.MusicPlaylist, error)
+}
+
+// runBrowseTUI explores playlists in a terminal UI, from the archive or
+// live from the API: a list of playlists, opened into their tracks, both
+// narrowed down as a search is typed, and previews opened in the browser.
+//
+//	spdump browse --archive archive
+//	spdump browse --token $SPOTIFY_TOKEN
+func runBrowseTUI(args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	archiveDir := fs.String("archive", "", "browse the latest snapshots in this archive directory")
+	collection := fs.String("collection", "", "with --archive, only browse this collection, e.g. me")
+	user := fs.String("user", "", "browse the public playlists of this user live from the API")
+	token := fs.String("token", "", "browse the library of the token's owner live from the API")
+	parseFlags(fs, args)
+
+	var source browseSource
+	var err error
+	switch {
+	case *archiveDir != "":
+		source, err = archiveBrowseSource(*archiveDir, *collection)
+	case *user != "" || *token != "":
+		source, err = liveBrowseSource(*user, *token)
+	default:
+		return errors.New("usage: spdump browse --archive <dir> | --user <id> | --token <token>")
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := commandContext()
+	playlists, err := source.list(ctx)
+	if err != nil {
+		return err
+	}
+	if len(playlists) == 0 {
+		return errors.New("no playlists to browse")
+	}
+
+	term, err := tui.Open()
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+	return browseLoop(ctx, term, source, playlists)
+}
+
+// browseScreen is a list on the browser's stack, of playlists or of the
+// tracks of one.
+type browseScreen struct {
+	list      *tui.List
+	playlists []browseEntry
+	tracks    []spotify.MusicTrack
+}
+
+// browseLoop runs the browser until it is quit.
+func browseLoop(ctx context.Context, term *tui.Terminal, source browseSource, playlists []browseEntry) error {
+	items := make([]string, len(playlists))
+	for i, entry := range playlists {
+		items[i] = fmt.Sprintf("%s  \x1b[2m%d tracks  %s\x1b[0m", entry.Name, entry.Tracks, entry.Owner)
+	}
+	stack := []*browseScreen{{
+		list:      tui.NewList(fmt.Sprintf("%d playlists", len(playlists)), items, browseSearch(playlists)),
+		playlists: playlists,
+	}}
+
+	status := browseHelp
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+		if err := term.Resize(); err != nil {
+			return err
+		}
+		screen := stack[len(stack)-1]
+		if err := term.Draw(screen.list.Lines(term.Rows, status)); err != nil {
+			return err
+		}
+		key, err := term.ReadKey()
+		if err != nil {
+			return err
+		}
+		status = browseHelp
+
+		switch {
+		case key.Name == tui.KeyInterrupt || (key.Rune == 'q' && !screen.list.Searching):
+			return nil
+		case screen.list.Handle(key, term.Rows-2):
+		case key.Name == tui.KeyEnter || key.Name == tui.KeyRight:
+			i, ok := screen.list.Selected()
+			if !ok {
+				continue
+			}
+			if screen.playlists == nil {
+				status = openTrack(screen.tracks[i])
+				continue
+			}
+			term.Draw(screen.list.Lines(term.Rows, "loading "+screen.playlists[i].Name+"..."))
+			mp, err := source.read(ctx, screen.playlists[i])
+			if err != nil {
+				status = "error: " + err.Error()
+				continue
+			}
+			stack = append(stack, trackScreen(mp))
+		case key.Name == tui.KeyEscape || key.Name == tui.KeyLeft || key.Name == tui.KeyBackspace:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// trackScreen lists the tracks of a playlist.
+func trackScreen(mp spotify.MusicPlaylist) *browseScreen {
+	items := make([]string, len(mp.Tracks))
+	search := make([]string, len(mp.Tracks))
+	for i, track := range mp.Tracks {
+		var marks []string
+		if track.Explicit {
+			marks = append(marks, "E")
+		}
+		if track.Dead() {
+			marks = append(marks, "unavailable")
+		}
+		if track.Source == spotify.SourceLocal {
+			marks = append(marks, "local")
+		}
+		items[i] = fmt.Sprintf("%s  \x1b[2m%s  %s  %s  %s\x1b[0m", track.Name, track.Artists,
+			firstNonEmpty(track.AlbumName, track.ShowName), spotify.FormatDuration(track.Length()), strings.Join(marks, " "))
+		search[i] = strings.Join([]string{track.Name, track.Artists, track.AlbumName, track.ShowName}, " ")
+	}
+	return &browseScreen{
+		list:   tui.NewList(fmt.Sprintf("%s  %d tracks", mp.Name, len(mp.Tracks)), items, search),
+		tracks: mp.Tracks,
+	}
+}
+
+// openTrack opens the preview of a track, or the track in Spotify when it
+// has none, returning the status to show.
+func openTrack(track spotify.MusicTrack) string {
+	url, what := track.PreviewURL, "preview"
+	if url == "" {
+		url, what = track.ExternalURL, "track"
+	}
+	if err := tui.OpenURL(url); err != nil {
+		return "can't open " + track.Name + ": " + err.Error()
+	}
+	return "opened the " + what + " of " + track.Name
+}
+
+func browseSearch(playlists []browseEntry) []string {
+	search := make([]string, len(playlists))
+	for i, entry := range playlists {
+		search[i] = entry.Name + " " + entry.Owner
+	}
+	return search
+}
+
+// archiveBrowseSource browses the latest snapshot of every collection in
+// the archive, or of the one collection given.
+func archiveBrowseSource(dir string, collection string) (browseSource, error) {
+	arc, err := archive.Open(dir)
+	if err != nil {
+		return browseSource{}, err
+	}
+	list := func(ctx context.Context) ([]browseEntry, error) {
+		var snapshots []archive.Snapshot
+		var err error
+		if collection != "" {
+			snapshot, ok, err := arc.Latest(collection)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("no snapshots of %s in %s", collection, dir)
+			}
+			snapshots = []archive.Snapshot{snapshot}
+		} else if snapshots, err = arc.LatestSnapshots(); err != nil {
+			return nil, err
+		}
+
+		var entries []browseEntry
+		for i := range snapshots {
+			for _, entry := range snapshots[i].Playlists {
+				entries = append(entries, browseEntry{
+					ID:       entry.ID,
+					Name:     entry.Name,
+					Owner:    snapshots[i].Collection,
+					Tracks:   entry.Tracks,
+					snapshot: &snapshots[i],
+					entry:    entry,
+				})
+			}
+		}
+		return entries, nil
+	}
+	read := func(ctx context.Context, entry browseEntry) (spotify.MusicPlaylist, error) {
+		return entry.snapshot.ReadPlaylist(entry.entry)
+	}
+	return browseSource{list: list, read: read}, nil
+}
+
+// liveBrowseSource browses the public playlists of a user, or the library
+// of the token's owner, fetching each playlist as it is opened.
+func liveBrowseSource(user string, token string) (browseSource, error) {
+	sp, err := newUserSpotify(token)
+	if err != nil {
+		return browseSource{}, err
+	}
+	list := func(ctx context.Context) ([]browseEntry, error) {
+		var listed []spotify.SpotifyPlaylist
+		var err error
+		if user != "" {
+			listed, err = sp.UserPublicPlaylists(ctx, user, false)
+		} else {
+			listed, err = sp.UserPlaylists(ctx, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]browseEntry, len(listed))
+		for i, playlist := range listed {
+			entries[i] = browseEntry{
+				ID:     playlist.IntegrationID,
+				Name:   playlist.Name,
+				Owner:  firstNonEmpty(playlist.Owner.DisplayName, playlist.Owner.IntegrationID),
+				Tracks: playlist.TracksCollection.Total,
+			}
+		}
+		return entries, nil
+	}
+	read := func(ctx context.Context, entry browseEntry) (spotify.MusicPlaylist, error) {
+		playlist, err := sp.PlaylistFromID(ctx, entry.ID)
+		if err != nil {
+			return spotify.MusicPlaylist{}, err
+		}
+		return spotify.ConvertToMusicPlaylist(playlist), nil
+	}
+	return browseSource{list: list, read: read}, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pyrat/spd/internal/budget"
+	"github.com/pyrat/spd/internal/hook"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// budgetInterval is how often the error budget is checked.
+const budgetInterval = time.Minute
+
+// budgetMinRequests is how many requests a window needs for its error
+// rate to count.
+const budgetMinRequests = 20
+
+// budgetFlags configure the error budget of long running commands.
+type budgetFlags struct {
+	MaxRate float64
+	Window  time.Duration
+}
+
+// registerBudgetFlags adds --error-budget and --error-window to fs.
+func registerBudgetFlags(fs *flag.FlagSet) *budgetFlags {
+	flags := &budgetFlags{}
+	fs.Float64Var(&flags.MaxRate, "error-budget", 0.1, "alert through the hooks when more than this fraction of spotify requests fail within --error-window, 0 to never alert")
+	fs.DurationVar(&flags.Window, "error-window", 24*time.Hour, "sliding window the error budget is taken over")
+	return flags
+}
+
+// watch checks the client's error rate against the budget until ctx is
+// done, logging and firing the hooks when the budget is exceeded and
+// again when the errors are back within it.
+func (o *budgetFlags) watch(ctx context.Context, sp *spotify.Client, hooks hook.Hooks) {
+	if o.MaxRate <= 0 || o.Window <= 0 {
+		return
+	}
+	b := &budget.Budget{MaxRate: o.MaxRate, Window: o.Window, MinRequests: budgetMinRequests}
+
+	ticker := time.NewTicker(budgetInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		stats := sp.Stats()
+		status, changed := b.Observe(time.Now(), stats.Requests, stats.Errors)
+		if !changed {
+			continue
+		}
+		kind := "error_budget_recovered"
+		if status.Exceeded {
+			kind = "error_budget_exceeded"
+			slog.Error(status.String(), "errors", status.Errors, "requests", status.Requests)
+		} else {
+			slog.Info(status.String(), "errors", status.Errors, "requests", status.Requests)
+		}
+		alert := hook.Alert{Kind: kind, At: time.Now().UTC(), Message: "spdump: " + status.String(), Details: status}
+		if err := hooks.Notify(ctx, alert); err != nil {
+			slog.Error("firing alert hooks", "err", err)
+		}
+	}
+}
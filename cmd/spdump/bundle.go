@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pyrat/spd/internal/bundle"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// writeCompressed dumps the playlists to w like writePlaylists, through
+// the compression.
+func writeCompressed(ctx context.Context, w io.Writer, c bundle.Compression, sp *spotify.Client, ids []string, opts dumpOptions) error {
+	cw, err := bundle.NewWriter(w, c)
+	if err != nil {
+		return err
+	}
+	if err := writePlaylists(ctx, cw, sp, ids, opts); err != nil {
+		cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+// writeBundle packs the playlists into a tar archive at path, - for
+// stdout, compressed as its extension says unless c is given.
+func writeBundle(ctx context.Context, path string, c bundle.Compression, collection string, sp *spotify.Client, ids []string, opts dumpOptions) error {
+	if c == bundle.None {
+		c = bundle.CompressionFor(path)
+	}
+
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	bw, err := bundle.NewBundle(out, c, collection, time.Now())
+	if err != nil {
+		return err
+	}
+	err = fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(ctx, playlist)
+		if err != nil {
+			return err
+		}
+		return bw.WritePlaylist(mp)
+	})
+	if err != nil {
+		return err
+	}
+	index, err := bw.Close()
+	if err != nil {
+		return err
+	}
+	slog.Info("wrote bundle", "file", path, "compression", string(c), "playlists", len(index.Playlists))
+	if path != "-" {
+		return out.Close()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// checkedPlaylist lists the dead and relinked tracks of a playlist.
+type checkedPlaylist struct {
+	PlaylistID string
+	Name       string
+	Tracks     int
+	Dead       []checkedTrack
+	Relinked   []checkedTrack
+}
+
+// checkedTrack is a track found by check, with its position from 1.
+type checkedTrack struct {
+	Position int
+	spotify.MusicTrack
+}
+
+// runCheck lists the tracks of playlists which are greyed out on Spotify:
+// taken down, restricted or not playable in the market. They are the
+// tracks which would be lost for good on leaving Spotify, nothing but the
+// dump remembers them. Tracks Spotify relinked to another version are
+// listed too.
+//
+//	spdump check <playlist-id>... --market GB
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	market := fs.String("market", "", "market (country code) to check playability in, without one only removed tracks and those available nowhere are found")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump check <playlist-id>... [--market GB] [--output json]")
+	}
+
+	var opts []spotify.Option
+	if *market != "" {
+		opts = append(opts, spotify.WithMarket(*market))
+	} else {
+		slog.Warn("no --market, tracks restricted in a market or relinked can't be told")
+	}
+	sp, err := newSpotifyFromConfig(opts...)
+	if err != nil {
+		return err
+	}
+
+	var checked []checkedPlaylist
+	for _, id := range fs.Args() {
+		playlist, err := sp.PlaylistFromID(commandContext(), id)
+		if err != nil {
+			return err
+		}
+		cp := checkedPlaylist{
+			PlaylistID: playlist.IntegrationID,
+			Name:       playlist.Name,
+			Tracks:     len(playlist.TracksCollection.Items),
+		}
+		for i, item := range playlist.TracksCollection.Items {
+			track := checkedTrack{Position: i + 1, MusicTrack: spotify.ConvertToMusicPlaylistTrack(item)}
+			if track.Dead() {
+				cp.Dead = append(cp.Dead, track)
+			} else if track.LinkedFrom != "" {
+				cp.Relinked = append(cp.Relinked, track)
+			}
+		}
+		checked = append(checked, cp)
+	}
+
+	t := table.New("PLAYLIST", "POS", "STATUS", "TRACK", "ARTISTS", "ID").Fixed("ID")
+	for _, cp := range checked {
+		for _, track := range cp.Dead {
+			t.Add(cp.Name, track.Position, track.Unavailable, track.Name, track.Artists, track.IntegrationID)
+		}
+		for _, track := range cp.Relinked {
+			t.Add(cp.Name, track.Position, "relinked", track.Name, track.Artists, track.IntegrationID+" <- "+track.LinkedFrom)
+		}
+	}
+	if err := output.print(checked, t); err != nil {
+		return err
+	}
+	// the totals would break csv
+	if output.Format != table.Text || output.json() {
+		return nil
+	}
+	for _, cp := range checked {
+		fmt.Printf("%s: %d of %d tracks unavailable, %d relinked\n", cp.Name, len(cp.Dead), cp.Tracks, len(cp.Relinked))
+	}
+	return nil
+}
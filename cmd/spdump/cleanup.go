@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/cleanup"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runCleanup finds the followed playlists which were deleted, emptied or
+// left unchanged for years, judged by the sync archive's history of them,
+// and unfollows them when --apply is given.
+//
+//	spdump cleanup --archive archive --years 3 --apply
+func runCleanup(args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	archiveDir := fs.String("archive", "archive", "archive directory holding the syncs of the library")
+	years := fs.Int("years", 2, "count playlists unchanged for this many years as dead, 0 to only look for deleted and empty ones")
+	apply := fs.Bool("apply", false, "unfollow the playlists instead of listing them")
+	output := registerOutputFlags(fs)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if *years < 0 {
+		return errors.New("--years can't be negative")
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+
+	ctx := commandContext()
+	user, err := sp.CurrentUser(ctx)
+	if err != nil {
+		return scopeError(err)
+	}
+	listed, err := sp.UserPlaylists(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	var followed []spotify.SpotifyPlaylist
+	current := map[string]string{}
+	deleted := map[string]bool{}
+	for _, playlist := range listed {
+		if playlist.Owner.IntegrationID == user.IntegrationID {
+			continue
+		}
+		followed = append(followed, playlist)
+		current[playlist.IntegrationID] = playlist.SnapshotID
+		// playlists deleted by their owner stay listed for followers
+		if _, err := sp.PlaylistSummaryFromID(ctx, playlist.IntegrationID); errors.Is(err, spotify.ErrNotFound) {
+			deleted[playlist.IntegrationID] = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	snapshots, err := arc.Snapshots(syncCollection(""))
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 && *years > 0 {
+		slog.Warn("the archive holds no syncs of the library, only deleted and empty playlists are found", "archive", *archiveDir)
+	}
+	since, err := cleanup.History(snapshots, current)
+	if err != nil {
+		return err
+	}
+	var cutoff time.Time
+	if *years > 0 {
+		cutoff = time.Now().AddDate(-*years, 0, 0)
+	}
+	candidates := cleanup.Find(followed, deleted, since, cutoff)
+
+	if *apply {
+		for _, candidate := range candidates {
+			op := writeback.Op{Kind: writeback.Unfollow, PlaylistID: candidate.PlaylistID, Name: candidate.Name}
+			if err := applyOp(ctx, sp, user.IntegrationID, op); err != nil {
+				return scopeError(err)
+			}
+		}
+		return nil
+	}
+
+	t := table.New("REASON", "PLAYLIST", "OWNER", "UNCHANGED SINCE", "ID").Fixed("ID")
+	for _, candidate := range candidates {
+		unchanged := "-"
+		if candidate.UnchangedSince != nil {
+			unchanged = candidate.UnchangedSince.Format("2006-01-02")
+		}
+		t.Add(candidate.Reason, candidate.Name, candidate.OwnerID, unchanged, candidate.PlaylistID)
+	}
+	if err := output.print(candidates, t); err != nil {
+		return err
+	}
+	if len(candidates) > 0 && !output.json() {
+		slog.Info("dry run, pass --apply to unfollow them", "playlists", len(candidates), "followed", len(followed))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pyrat/spd/internal/clipboard"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// clipboardPlaylists returns the playlists and user whose links or URIs
+// are on the clipboard, e.g. a playlist link copied from the Spotify app
+// with Share > Copy link. Any other text around them is ignored.
+func clipboardPlaylists() ([]string, string, error) {
+	text, err := clipboard.Read()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var playlists []string
+	var user string
+	for _, field := range strings.Fields(text) {
+		// bare words could be anything, only links and URIs count
+		if !strings.HasPrefix(field, "spotify:") && !strings.Contains(field, "spotify.com/") {
+			continue
+		}
+		resource, err := spotify.ParseResource(field)
+		if err != nil {
+			continue
+		}
+		switch resource.Type {
+		case spotify.TypePlaylist:
+			playlists = append(playlists, resource.ID)
+		case spotify.TypeUser:
+			if user != "" && user != resource.ID {
+				return nil, "", fmt.Errorf("the clipboard links to users %s and %s, copy one", user, resource.ID)
+			}
+			user = resource.ID
+		default:
+			return nil, "", fmt.Errorf("the clipboard links to a spotify %s, expected a playlist or user", resource.Type)
+		}
+	}
+	if len(playlists) == 0 && user == "" {
+		return nil, "", errors.New("no spotify playlist or user link on the clipboard")
+	}
+	return playlists, user, nil
+}
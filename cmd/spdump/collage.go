@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pyrat/spd/internal/collage"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runCollage builds a cover collage from the most frequent album covers of
+// a playlist and optionally uploads it as the playlist's cover.
+//
+//	spdump collage --playlist <id> --grid 3x3 --out cover.jpg
+func runCollage(args []string) error {
+	fs := flag.NewFlagSet("collage", flag.ExitOnError)
+	playlistID := fs.StringP("playlist", "p", "", "playlist ID, URI or link to build the collage for")
+	gridSpec := fs.String("grid", "3x3", "columns x rows of album covers")
+	tileSize := fs.Int("tile-size", 300, "size of each cover in pixels")
+	out := fs.StringP("out", "o", "cover.jpg", "file to write the collage to")
+	upload := fs.Bool("upload", false, "also upload the collage as the playlist cover (needs --token)")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the ugc-image-upload scope (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if *playlistID == "" {
+		return errors.New("usage: spdump collage --playlist <playlist_id> [--grid 3x3] [--out cover.jpg]")
+	}
+
+	grid, err := collage.ParseGrid(*gridSpec)
+	if err != nil {
+		return err
+	}
+
+	var sp *spotify.Client
+	if *upload {
+		if *token == "" {
+			return errors.New("uploading a cover needs a user access token, pass --token or set SPOTIFY_TOKEN")
+		}
+		sp, err = newUserSpotify(*token)
+	} else {
+		sp, err = newSpotifyFromConfig()
+	}
+	if err != nil {
+		return err
+	}
+
+	playlist, err := sp.PlaylistFromID(commandContext(), *playlistID)
+	if err != nil {
+		return err
+	}
+
+	covers := collage.TopAlbumCovers(spotify.ConvertToMusicPlaylist(playlist), grid.Tiles(), *tileSize)
+	if len(covers) == 0 {
+		return errors.New("playlist has no album covers")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	images, err := collage.Fetch(commandContext(), client, covers)
+	if err != nil {
+		return err
+	}
+
+	img := collage.Compose(images, grid, *tileSize)
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	slog.Info("wrote collage", "path", *out)
+
+	if !*upload {
+		return nil
+	}
+
+	// spotify caps covers at 256KB base64 encoded, step the
+	// quality down until the collage fits.
+	for quality := 80; buf.Len()*4/3 > spotify.MaxCoverImageSize && quality >= 20; quality -= 10 {
+		buf.Reset()
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return err
+		}
+	}
+
+	if err := sp.UploadPlaylistCover(commandContext(), *playlistID, buf.Bytes()); err != nil {
+		return err
+	}
+	slog.Info("uploaded cover", "playlist", *playlistID)
+	return nil
+}
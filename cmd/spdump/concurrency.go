@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// concurrencyFlags control how many playlists and requests are in flight.
+type concurrencyFlags struct {
+	Concurrency     int
+	PageConcurrency int
+	Adaptive        bool
+	MaxConcurrency  int
+
+	limiter *spotify.Adaptive
+}
+
+// registerConcurrencyFlags adds --concurrency, --page-concurrency,
+// --adaptive and --max-concurrency to fs.
+func registerConcurrencyFlags(fs *flag.FlagSet) *concurrencyFlags {
+	flags := &concurrencyFlags{}
+	fs.IntVarP(&flags.Concurrency, "concurrency", "c", 4, "number of playlists fetched in parallel")
+	fs.IntVar(&flags.PageConcurrency, "page-concurrency", 4, "number of track pages of a large playlist fetched in parallel, 1 to page one after another")
+	fs.BoolVar(&flags.Adaptive, "adaptive", false, "find the most requests the API allows in flight, backing off on rate limits and slow responses")
+	fs.IntVar(&flags.MaxConcurrency, "max-concurrency", 32, "with --adaptive, the most requests ever in flight")
+	return flags
+}
+
+// playlists returns how many playlists are fetched in parallel. Adaptive
+// fetches start as many as they may, the limiter holds back the requests.
+func (o *concurrencyFlags) playlists() int {
+	if o.Adaptive {
+		return o.MaxConcurrency
+	}
+	return o.Concurrency
+}
+
+// options returns the client options applying the flags.
+func (o *concurrencyFlags) options() []spotify.Option {
+	opts := []spotify.Option{spotify.WithPagePrefetch(o.PageConcurrency)}
+	if o.Adaptive {
+		o.limiter = spotify.NewAdaptive(1, o.MaxConcurrency)
+		opts = append(opts, spotify.WithAdaptiveConcurrency(o.limiter))
+	}
+	return opts
+}
+
+// report logs the concurrency the adaptive limiter settled on.
+func (o *concurrencyFlags) report() {
+	if o.limiter != nil {
+		slog.Info("adaptive concurrency", "limit", o.limiter.Limit(), "max", o.MaxConcurrency)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pyrat/spd/internal/secret"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+var (
+	configOnce   sync.Once
+	loadedConfig *toml.Tree
+	configErr    error
+)
+
+// loadConfig returns config.toml from the working directory, decrypting it
+// with sops first when it was encrypted with SOPS, with the --profile
+// section applied. It is read once per run, after the command's flags are
+// parsed, so an encrypted config runs sops once however many settings the
+// command looks up; a daemon picks up changes when restarted. Callers
+// share the tree and must not change it.
+func loadConfig() (*toml.Tree, error) {
+	configOnce.Do(func() {
+		loadedConfig, configErr = readConfig()
+	})
+	return loadedConfig, configErr
+}
+
+// readConfig reads and decrypts config.toml and applies the profile.
+func readConfig() (*toml.Tree, error) {
+	// Read the TOML file
+	tomlData, err := ioutil.ReadFile("config.toml")
+	if err != nil {
+		return nil, err
+	}
+	if secret.IsSOPS(tomlData) {
+		if tomlData, err = secret.DecryptSOPS(commandContext(), "config.toml"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse the TOML data
+	config, err := toml.Load(string(tomlData))
+	if err != nil {
+		return nil, err
+	}
+	return config, applyProfile(config, profile)
+}
+
+// newSpotifyFromConfig reads the client credentials from config.toml
+// and initialises a client with them. opts are applied after
+// the options set in the config file.
+func newSpotifyFromConfig(opts ...spotify.Option) (*spotify.Client, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the Spotify client ID and secret
+	clientID, err := configSecret(config, "spotify.client_id")
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := configSecret(config, "spotify.client_secret")
+	if err != nil {
+		return nil, err
+	}
+
+	configOpts, err := transport.spotifyOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenProviderFromConfig(config, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if tokens != nil {
+		configOpts = append(configOpts, spotify.WithTokenProvider(tokens))
+	}
+	if dir, ok := config.Get("cache.dir").(string); ok && dir != "" {
+		configOpts = append(configOpts, spotify.WithCache(dir))
+	}
+
+	sp, err := spotify.NewClient(commandContext(), clientID, clientSecret, append(configOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return trackUsage(sp), nil
+}
+
+// newUserSpotify returns a client for commands acting on the user's own
+// library: one using token when given, otherwise one configured from
+// config.toml, which needs a user token provider under [auth].
+func newUserSpotify(token string, opts ...spotify.Option) (*spotify.Client, error) {
+	if token != "" {
+		transportOpts, err := userTransportOptions()
+		if err != nil {
+			return nil, err
+		}
+		return trackUsage(spotify.NewClientWithToken(token, append(transportOpts, opts...)...)), nil
+	}
+	return newSpotifyFromConfig(opts...)
+}
+
+// configSecret returns the config value of key, or when key_cmd or
+// key_vault is set instead, what that command prints or the field of the
+// Vault secret, e.g.
+//
+//	client_secret_cmd = "pass show spotify/secret"
+//	client_secret_vault = "secret/data/spotify#client_secret"
+func configSecret(config *toml.Tree, key string) (string, error) {
+	if value, _ := config.Get(key).(string); value != "" {
+		return value, nil
+	}
+	if command, _ := config.Get(key + "_cmd").(string); command != "" {
+		return secret.Command(commandContext(), command)
+	}
+	if ref, _ := config.Get(key + "_vault").(string); ref != "" {
+		address, _ := config.Get("vault.address").(string)
+		vault, err := secret.VaultFromEnv(address)
+		if err != nil {
+			return "", err
+		}
+		return vault.Read(commandContext(), ref)
+	}
+	return "", nil
+}
+
+// tokenProviderFromConfig returns the token provider chosen under [auth]
+// in config.toml, or nil for the default client credentials flow.
+func tokenProviderFromConfig(config *toml.Tree, clientID string, clientSecret string) (spotify.TokenProvider, error) {
+	provider, _ := config.Get("auth.provider").(string)
+	switch provider {
+	case "", "client_credentials":
+		return nil, nil
+
+	case "refresh_token":
+		refreshToken, err := configSecret(config, "auth.refresh_token")
+		if err != nil {
+			return nil, err
+		}
+		if refreshToken == "" {
+			return nil, errors.New("auth.provider refresh_token needs auth.refresh_token")
+		}
+		return &spotify.RefreshToken{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RefreshToken: refreshToken,
+		}, nil
+
+	case "env":
+		name, _ := config.Get("auth.env").(string)
+		if name == "" {
+			name = "SPOTIFY_TOKEN"
+		}
+		return spotify.EnvToken(name), nil
+
+	case "command":
+		command, _ := config.Get("auth.command").(string)
+		if command == "" {
+			return nil, errors.New("auth.provider command needs auth.command")
+		}
+		provider := &spotify.CommandToken{Command: command}
+		if ttl, _ := config.Get("auth.command_ttl").(string); ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				return nil, fmt.Errorf("auth.command_ttl: %w", err)
+			}
+			provider.TTL = d
+		}
+		return provider, nil
+	}
+	return nil, fmt.Errorf("unknown auth.provider %q", provider)
+}
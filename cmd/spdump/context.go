@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// timeout is the --timeout of the running command, none when zero.
+var timeout time.Duration
+
+// commandSignals are the signals cancelling the command's context. The
+// REPL leaves SIGINT out, to cancel only the command it is running.
+var commandSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+var (
+	commandOnce sync.Once
+	commandCtx  context.Context
+	// stopCommand releases the context's signal handler and timer.
+	stopCommand context.CancelFunc = func() {}
+	// interruptCommand cancels the context as SIGTERM does, for the
+	// Windows service manager which stops services without signals.
+	interruptCommand context.CancelFunc = func() {}
+)
+
+// commandContext returns the context the running command works in. It is
+// cancelled on commandSignals, and once --timeout has passed, so runs from
+// cron can't hang.
+func commandContext() context.Context {
+	commandOnce.Do(func() {
+		ctx, interrupt := context.WithCancel(context.Background())
+		ctx, stopSignals := signal.NotifyContext(ctx, commandSignals...)
+		cancel := context.CancelFunc(func() {})
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		commandCtx = ctx
+		interruptCommand = interrupt
+		stopCommand = func() {
+			cancel()
+			stopSignals()
+			interrupt()
+		}
+	})
+	return commandCtx
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/digest"
+	"github.com/pyrat/spd/internal/mail"
+	flag "github.com/spf13/pflag"
+)
+
+// defaultDigestEvery is how often sync emails a digest, weekly.
+const defaultDigestEvery = 7 * 24 * time.Hour
+
+// digestOptions are where digests are emailed and through which server,
+// from the flags and [digest] and [smtp] in config.toml.
+type digestOptions struct {
+	To     []string
+	From   string
+	Every  time.Duration
+	Server mail.Server
+}
+
+// registerDigestFlags adds the digest flags to fs, --digest-every only for
+// sync, which sends digests on its own.
+func registerDigestFlags(fs *flag.FlagSet, every bool) *digestOptions {
+	opts := &digestOptions{}
+	fs.StringSliceVar(&opts.To, "digest-to", nil, "email a digest of the changes to these addresses (defaults to digest.to in config.toml)")
+	if every {
+		fs.DurationVar(&opts.Every, "digest-every", 0, "how often to email the digest (defaults to digest.every in config.toml, or 168h)")
+	}
+	return opts
+}
+
+// load fills in what the flags left out from config.toml. Without
+// recipients the digest is off and nothing else is needed.
+func (o *digestOptions) load(config *toml.Tree) error {
+	if len(o.To) == 0 {
+		switch to := config.Get("digest.to").(type) {
+		case string:
+			o.To = []string{to}
+		case []interface{}:
+			for _, address := range to {
+				o.To = append(o.To, fmt.Sprint(address))
+			}
+		}
+	}
+	if len(o.To) == 0 {
+		return nil
+	}
+	if o.Every == 0 {
+		o.Every = defaultDigestEvery
+		if value, _ := config.Get("digest.every").(string); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("digest.every: %w", err)
+			}
+			o.Every = d
+		}
+	}
+
+	o.Server.Host, _ = config.Get("smtp.host").(string)
+	if o.Server.Host == "" {
+		return errors.New("emailing a digest needs smtp.host in config.toml")
+	}
+	if port, ok := config.Get("smtp.port").(int64); ok {
+		o.Server.Port = int(port)
+	}
+	o.Server.Username, _ = config.Get("smtp.username").(string)
+	password, err := configSecret(config, "smtp.password")
+	if err != nil {
+		return err
+	}
+	o.Server.Password = password
+	o.From, _ = config.Get("smtp.from").(string)
+	if o.From == "" {
+		o.From = o.Server.Username
+	}
+	if o.From == "" {
+		return errors.New("emailing a digest needs smtp.from in config.toml")
+	}
+	return nil
+}
+
+// enabled reports whether digests are emailed.
+func (o *digestOptions) enabled() bool {
+	return len(o.To) > 0
+}
+
+// runDigest emails the digest of a collection's changes now, or prints
+// it.
+//
+//	spdump digest --user spotifyuser --digest-to me@example.com
+//	spdump digest --since 720h --print > digest.eml
+func runDigest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	user := fs.String("user", "", "user whose synced playlists are summed up (defaults to sync.user in config.toml, or the token's owner)")
+	archiveDir := fs.String("archive", "archive", "archive directory sync writes to")
+	since := fs.Duration("since", defaultDigestEvery, "sum up the changes over this long")
+	print := fs.Bool("print", false, "print the email instead of sending it")
+	opts := registerDigestFlags(fs, false)
+	parseFlags(fs, args)
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *user == "" {
+		*user, _ = config.Get("sync.user").(string)
+	}
+	if *print && len(opts.To) == 0 {
+		opts.To = []string{"you@example.com"}
+		opts.From = "spdump@example.com"
+	} else if err := opts.load(config); err != nil {
+		return err
+	}
+	if !opts.enabled() {
+		return errors.New("usage: spdump digest --digest-to <address>... [--user <id>] [--since 168h] [--print]")
+	}
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+
+	msg, err := digestMessage(arc, syncCollection(*user), time.Now().Add(-*since), opts)
+	if err != nil {
+		return err
+	}
+	if *print {
+		data, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return sendDigest(commandContext(), arc, syncCollection(*user), msg, opts)
+}
+
+// digestMessage builds the email of the collection's changes since then.
+func digestMessage(arc *archive.Archive, collection string, since time.Time, opts *digestOptions) (mail.Message, error) {
+	d, err := digest.Build(arc, collection, since)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write(csvHeader)
+	dump := dumpOptions{Location: time.UTC}
+	for _, mp := range d.Playlists {
+		for _, track := range mp.Tracks {
+			cw.Write(dump.csvRecord(mp, track))
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return mail.Message{}, err
+	}
+	return d.Message(opts.From, opts.To, buf.Bytes())
+}
+
+// sendDigest sends the digest and records when.
+func sendDigest(ctx context.Context, arc *archive.Archive, collection string, msg mail.Message, opts *digestOptions) error {
+	if err := opts.Server.Send(ctx, msg); err != nil {
+		return err
+	}
+	slog.Info("emailed digest", "collection", collection, "to", opts.To)
+	return digest.MarkSent(arc, collection, time.Now())
+}
+
+// maybeSendDigest emails the collection's digest when the last one is
+// older than the interval. The first is sent an interval after the first
+// sync, rather than whenever sync starts.
+func maybeSendDigest(ctx context.Context, arc *archive.Archive, collection string, opts *digestOptions) error {
+	last, err := digest.LastSent(arc, collection)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if last.IsZero() {
+		return digest.MarkSent(arc, collection, now)
+	}
+	if now.Sub(last) < opts.Every {
+		return nil
+	}
+	msg, err := digestMessage(arc, collection, last, opts)
+	if err != nil {
+		return err
+	}
+	return sendDigest(ctx, arc, collection, msg, opts)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+
+	"github.com/pyrat/spd/internal/explicit"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// planDump prints what dumping the playlists would fetch, looking up only
+// their details and track counts: the requests each playlist takes and
+// how many in total, to judge whether a dump will run into rate limits.
+func planDump(ctx context.Context, w io.Writer, sp *spotify.Client, ids []string, opts dumpOptions) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLAYLIST\tNAME\tTRACKS\tREQUESTS")
+
+	tracks, requests := 0, 0
+	for _, id := range ids {
+		playlist, err := sp.PlaylistSummaryFromID(ctx, id)
+		if err != nil {
+			return err
+		}
+		total := playlist.TracksCollection.Total
+		n := spotify.PlaylistRequests(total)
+		if opts.Format == formatNDJSONTracks {
+			// the details and the tracks are fetched separately
+			n++
+		}
+		tracks += total
+		requests += n
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", playlist.IntegrationID, playlist.Name, total, n)
+	}
+	fmt.Fprintf(tw, "total\t%d playlists\t%d\t%d\n", len(ids), tracks, requests)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if opts.Format == formatPortable {
+		fmt.Fprintf(w, "plus up to %d requests for album UPCs, one per %d distinct albums\n", (tracks+spotify.MaxAlbumsPerRequest-1)/spotify.MaxAlbumsPerRequest, spotify.MaxAlbumsPerRequest)
+	}
+	if opts.Policy != explicit.Any {
+		fmt.Fprintln(w, "plus a search for every track breaking the explicit content policy")
+	}
+	return nil
+}
+
+// reportUsage logs the API calls a command made, so their cost can be
+// weighed against the rate limits.
+func reportUsage(sp *spotify.Client) {
+	stats := sp.Stats()
+	slog.Info("spotify api usage",
+		"requests", stats.Requests,
+		"cache_hits", stats.CacheHits,
+		"retries", stats.Retries,
+		"errors", stats.Errors,
+		"bytes_sent", stats.BytesSent,
+		"bytes_received", stats.BytesReceived)
+}
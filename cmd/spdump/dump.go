@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	"golang.org/x/sync/errgroup"
+)
+
+// fetchPlaylists fetches the playlists with up to concurrency requests in
+// flight and hands them to fn in the order of ids. The first error from
+// a fetch or from fn cancels all outstanding requests.
+//
+// A fetch slot is only released once its playlist has been handed to fn,
+// so no more than concurrency playlists are ever held in memory.
+func fetchPlaylists(ctx context.Context, sp *spotify.Client, ids []string, concurrency int, fn func(spotify.SpotifyPlaylist) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	results := make([]chan spotify.SpotifyPlaylist, len(ids))
+	for i := range results {
+		results[i] = make(chan spotify.SpotifyPlaylist, 1)
+	}
+	slots := make(chan struct{}, concurrency)
+
+	// producer, starts a fetch whenever a slot is free
+	g.Go(func() error {
+		for i, id := range ids {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			i, id := i, id
+			g.Go(func() error {
+				playlist, err := sp.PlaylistFromID(ctx, id)
+				if err != nil {
+					return err
+				}
+				results[i] <- playlist
+				return nil
+			})
+		}
+		return nil
+	})
+
+	// consumer, hands the playlists over in order
+	g.Go(func() error {
+		for i := range ids {
+			select {
+			case playlist := <-results[i]:
+				<-slots
+				if err := fn(playlist); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	return g.Wait()
+}
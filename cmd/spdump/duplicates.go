@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/overlap"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runDuplicates finds near-duplicate playlists in dumps of a library,
+// pairs sharing most of their tracks, and suggests which to keep. --merge
+// adds the tracks only the other one has to the one kept and unfollows
+// the other, printing the changes unless --apply is given.
+//
+//	spdump duplicates library.json --threshold 0.9
+//	spdump duplicates library.json --merge --apply
+func runDuplicates(args []string) error {
+	fs := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	opts := overlap.Options{}
+	fs.Float64Var(&opts.Threshold, "threshold", 0.8, "least share of the smaller playlist's tracks the other must hold, from 0 to 1")
+	fs.IntVar(&opts.MinTracks, "min-tracks", 5, "leave out playlists with fewer tracks, which overlap by chance")
+	merge := fs.Bool("merge", false, "merge each pair into the playlist kept")
+	apply := fs.Bool("apply", false, "with --merge, make the changes instead of printing them")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes, to merge (or set SPOTIFY_TOKEN)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump duplicates <dump.json>... [--threshold 0.8] [--merge [--apply]]")
+	}
+	if opts.Threshold <= 0 || opts.Threshold > 1 {
+		return errors.New("--threshold must be above 0 and at most 1")
+	}
+	if *apply && !*merge {
+		return errors.New("--apply only goes with --merge")
+	}
+	playlists, err := dump.ReadFiles(fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	// merging needs to know whose library it is, to keep the user's own
+	// playlists and check what they may change
+	var sp *spotify.Client
+	var access map[string]writeback.Access
+	ctx := commandContext()
+	if *merge {
+		if sp, err = newUserSpotify(*token); err != nil {
+			return err
+		}
+		user, listed, err := libraryAccess(ctx, sp)
+		if err != nil {
+			return scopeError(err)
+		}
+		opts.UserID = user.IntegrationID
+		access = accessByID(listed)
+	}
+
+	pairs := overlap.Find(playlists, opts)
+	t := table.New("OVERLAP", "SHARED", "KEEP", "TRACKS", "MERGE", "TRACKS", "MISSING", "KEEP ID", "MERGE ID").Fixed("KEEP ID", "MERGE ID")
+	for _, pair := range pairs {
+		t.Add(fmt.Sprintf("%.0f%%", pair.Overlap*100), pair.Shared, pair.Keep.Name, pair.Keep.Tracks,
+			pair.Merge.Name, pair.Merge.Tracks, len(pair.Missing), pair.Keep.PlaylistID, pair.Merge.PlaylistID)
+	}
+	if err := output.print(pairs, t); err != nil {
+		return err
+	}
+	if !*merge {
+		return nil
+	}
+
+	// a playlist is merged away once, and one given tracks isn't merged
+	// away after, as the dump no longer tells what it holds
+	gone, grown := map[string]bool{}, map[string]bool{}
+	merged := 0
+	for _, pair := range pairs {
+		if gone[pair.Keep.PlaylistID] || gone[pair.Merge.PlaylistID] || grown[pair.Merge.PlaylistID] {
+			continue
+		}
+		var ops []writeback.Op
+		if len(pair.Missing) > 0 {
+			ops = append(ops, writeback.Op{Kind: writeback.Add, PlaylistID: pair.Keep.PlaylistID, Name: pair.Keep.Name, Tracks: pair.Missing})
+		}
+		ops = append(ops, writeback.Op{Kind: writeback.Unfollow, PlaylistID: pair.Merge.PlaylistID, Name: pair.Merge.Name})
+		// the other playlist isn't dropped unless its tracks are kept
+		if _, denied := writeback.Check(ops, access); len(denied) > 0 {
+			slog.Warn("can't merge", "keep", pair.Keep.Name, "merge", pair.Merge.Name, "reason", denied[0].Reason)
+			continue
+		}
+		gone[pair.Merge.PlaylistID], grown[pair.Keep.PlaylistID] = true, true
+		merged++
+		for _, op := range ops {
+			if !*apply {
+				fmt.Println(op)
+				continue
+			}
+			if err := applyOp(ctx, sp, opts.UserID, op); err != nil {
+				return scopeError(err)
+			}
+		}
+	}
+	if merged > 0 && !*apply {
+		slog.Info("dry run, pass --apply to merge", "pairs", merged)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Exit codes, so scripts can tell why a run failed without parsing logs.
+const (
+	exitError        = 1 // any other failure
+	exitUnauthorized = 3 // 401, bad credentials or expired token
+	exitForbidden    = 4 // 403, token lacks the required scope
+	exitNotFound     = 5 // 404, unknown playlist/track/album
+	exitRateLimited  = 6 // 429, rate limited by spotify
+	exitTimeout      = 7 // --timeout passed
+)
+
+// exitCode maps err to the process exit code.
+func exitCode(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exitTimeout
+	}
+	switch {
+	case errors.Is(err, spotify.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, spotify.ErrForbidden):
+		return exitForbidden
+	case errors.Is(err, spotify.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, spotify.ErrRateLimited):
+		return exitRateLimited
+	}
+	return exitError
+}
+
+// fatal logs err and exits with the matching exit code.
+func fatal(err error) {
+	stopCommand()
+	slog.Error(err.Error())
+	reportRunUsage()
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pyrat/spd/internal/redact"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// exportFields selects which nested objects end up in the output.
+type exportFields struct {
+	NoArt             bool
+	NoAlbum           bool
+	NoPreview         bool
+	NoTracks          bool
+	ArtistsStructured bool
+	// Redact are the redaction rules applied last, nil for none.
+	Redact *redact.Rules
+
+	redactSpecs []string
+}
+
+// registerExportFlags adds the include/exclude toggles to fs.
+func registerExportFlags(fs *flag.FlagSet) *exportFields {
+	fields := &exportFields{}
+	fs.BoolVar(&fields.NoArt, "no-art", false, "leave out playlist and album images")
+	fs.BoolVar(&fields.NoAlbum, "no-album", false, "leave out album name, art and release date")
+	fs.BoolVar(&fields.NoPreview, "no-preview", false, "leave out track preview URLs")
+	fs.BoolVar(&fields.NoTracks, "no-tracks", false, "only dump playlist details, without tracks")
+	fs.BoolVar(&fields.ArtistsStructured, "artists-structured", false, "add the artists as a list of name/ID objects")
+	fs.StringArrayVar(&fields.redactSpecs, "redact", nil, "drop this field from the output, or replace it with a keyed hash written field:hash, e.g. owner.id:hash (repeatable; replaces [redact] in config.toml), one of "+strings.Join(redact.Fields(), ", "))
+	return fields
+}
+
+// loadRedaction sets up the redaction rules of --redact or config.toml,
+// once the flags are parsed.
+func (o *exportFields) loadRedaction() error {
+	var err error
+	o.Redact, err = redactionRules(o.redactSpecs)
+	return err
+}
+
+// applyPlaylist strips the excluded objects from a playlist.
+func (o exportFields) applyPlaylist(mp *spotify.MusicPlaylist) {
+	if o.NoArt {
+		mp.PlaylistArt = nil
+	}
+	if o.NoTracks {
+		mp.Tracks = nil
+	}
+	o.Redact.Playlist(mp)
+	for i := range mp.Tracks {
+		o.applyTrack(&mp.Tracks[i])
+	}
+}
+
+// applyTrack strips the excluded objects from a track.
+func (o exportFields) applyTrack(mt *spotify.MusicTrack) {
+	if o.NoArt || o.NoAlbum {
+		mt.AlbumArt = nil
+	}
+	if o.NoAlbum {
+		mt.AlbumName = ""
+		mt.AlbumReleaseDate = ""
+		if mt.Type != spotify.TypeEpisode {
+			mt.Released = nil
+		}
+	}
+	if o.NoPreview {
+		mt.PreviewURL = ""
+	}
+	if !o.ArtistsStructured {
+		mt.ArtistList = nil
+	}
+	o.Redact.Track(mt)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/pyrat/spd/internal/fixtures"
+	flag "github.com/spf13/pflag"
+)
+
+// runGenFixtures writes a synthetic library shaped like real dumps, the
+// same for the same flags, to test parsers of the dump format against.
+//
+//	spdump gen-fixtures --playlists 10 --tracks 200 --out testdata
+func runGenFixtures(args []string) error {
+	fs := flag.NewFlagSet("gen-fixtures", flag.ExitOnError)
+	playlists := fs.Int("playlists", 5, "number of playlists, the second is always empty")
+	tracks := fs.Int("tracks", 50, "number of tracks per playlist, 20 or more cover every edge case")
+	seed := fs.Int64("seed", 1, "seed picking the library, the same seed generates the same one")
+	format := fs.StringP("format", "f", formatJSON, "output format: json or ndjson (one playlist per line)")
+	out := fs.String("out", "", "write every playlist to <id>.json in this directory, with the dump's JSON Schema in schema.json")
+	schema := fs.Bool("schema", false, "only print the JSON Schema of a playlist in a json dump")
+	parseFlags(fs, args)
+
+	enc := json.NewEncoder(os.Stdout)
+	if *schema {
+		enc.SetIndent("", "  ")
+		return enc.Encode(fixtures.Schema())
+	}
+
+	library := fixtures.Generate(fixtures.Options{Playlists: *playlists, Tracks: *tracks, Seed: *seed})
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+		for _, mp := range library {
+			if err := writeJSONFile(filepath.Join(*out, mp.IntegrationID+".json"), mp); err != nil {
+				return err
+			}
+		}
+		if err := writeJSONFile(filepath.Join(*out, "schema.json"), fixtures.Schema()); err != nil {
+			return err
+		}
+		slog.Info("generated fixtures", "dir", *out, "playlists", len(library))
+		return nil
+	}
+
+	switch *format {
+	case formatJSON:
+		return enc.Encode(library)
+	case formatNDJSON:
+		for _, mp := range library {
+			if err := enc.Encode(mp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", *format)
+}
+
+// writeJSONFile writes v to path as indented JSON.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
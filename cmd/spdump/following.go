@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// followingDump is the follow graph of an account: the artists it follows
+// and the playlists of others it follows, without their tracks.
+type followingDump struct {
+	Artists   []spotify.MusicArtist   `json:",omitempty"`
+	Playlists []spotify.MusicPlaylist `json:",omitempty"`
+}
+
+// runFollowing dumps the artists and the playlists of other users the
+// owner of a user token follows, so a full account export keeps who it
+// follows along with its own playlists.
+//
+//	spdump following > following.json
+//	spdump following --type artists
+func runFollowing(args []string) error {
+	fs := flag.NewFlagSet("following", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the user-follow-read and playlist-read-private scopes (or set SPOTIFY_TOKEN)")
+	kind := fs.String("type", "all", "artists, playlists or all")
+	keepQuery := fs.Bool("keep-query", false, "keep query strings (si= share tokens) on external URLs")
+	fields := registerExportFlags(fs)
+	parseFlags(fs, args)
+	if err := fields.loadRedaction(); err != nil {
+		return err
+	}
+
+	if *kind != "all" && *kind != "artists" && *kind != "playlists" {
+		return fmt.Errorf("unknown --type %q, expected artists, playlists or all", *kind)
+	}
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	dump := followingDump{}
+	if *kind != "playlists" {
+		artists, err := sp.FollowedArtists(ctx)
+		if err != nil {
+			return err
+		}
+		for _, artist := range artists {
+			ma := spotify.ConvertToMusicArtist(artist)
+			ma.ExternalURL = spotify.NormalizeURL(ma.ExternalURL, *keepQuery)
+			if fields.NoArt {
+				ma.ArtistArt = nil
+			}
+			dump.Artists = append(dump.Artists, ma)
+		}
+	}
+	if *kind != "artists" {
+		playlists, err := sp.FollowedPlaylists(ctx)
+		if err != nil {
+			return err
+		}
+		for _, playlist := range playlists {
+			mp := spotify.ConvertToMusicPlaylist(playlist)
+			fields.applyPlaylist(&mp)
+			dump.Playlists = append(dump.Playlists, mp)
+		}
+	}
+	return json.NewEncoder(os.Stdout).Encode(dump)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/sampler"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runGenerate builds playlists from what the API knows rather than from
+// the user's own, printing them as JSON or publishing them to Spotify.
+//
+//	spdump generate sampler --artist <id> --per-album 2 --publish
+func runGenerate(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "sampler":
+			return runGenerateSampler(args[1:])
+		}
+	}
+	return errors.New("usage: spdump generate sampler")
+}
+
+// runGenerateSampler builds a chronological sampler of an artist's
+// discography, dumped as spdump artist --album-tracks does or read from
+// such a dump.
+func runGenerateSampler(args []string) error {
+	fs := flag.NewFlagSet("generate sampler", flag.ExitOnError)
+	artistID := fs.String("artist", "", "artist ID, URI or link")
+	from := fs.String("from", "", "build the sampler from a dump of spdump artist --album-tracks instead of fetching the discography")
+	opts := sampler.Options{}
+	fs.IntVar(&opts.PerAlbum, "per-album", 2, "tracks from each release")
+	fs.StringVar(&opts.Name, "name", "", "name of the playlist (defaults to \"<artist> sampler\")")
+	groups := fs.StringSlice("groups", []string{"album", "single"}, "album groups in the sampler")
+	market := fs.String("market", "", "market (country code) for availability, relinking and top tracks (US for top tracks when unset)")
+	publish := fs.Bool("publish", false, "create the playlist in the user's account instead of printing it")
+	public := fs.Bool("public", false, "with --publish, make the playlist public")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes, to publish (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if (*artistID == "" && *from == "") || fs.NArg() > 0 {
+		return errors.New("usage: spdump generate sampler --artist <id> [--from artist.json] [--per-album 2] [--publish]")
+	}
+	if opts.PerAlbum < 1 {
+		return errors.New("--per-album must be at least 1")
+	}
+
+	var clientOpts []spotify.Option
+	if *market != "" {
+		clientOpts = append(clientOpts, spotify.WithMarket(*market))
+	}
+	var sp *spotify.Client
+	var err error
+	if *publish {
+		sp, err = newUserSpotify(*token, clientOpts...)
+	} else if *from == "" {
+		sp, err = newSpotifyFromConfig(clientOpts...)
+	}
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	var discography artistDump
+	if *from != "" {
+		discography, err = readArtistDump(*from, *artistID)
+	} else {
+		discography, err = dumpArtist(ctx, sp, *artistID, artistOptions{AlbumTracks: true, Groups: *groups, TopTracks: true, Market: *market})
+	}
+	if err != nil {
+		return err
+	}
+
+	mp := sampler.Build(discography.Artist, discography.Albums, discography.TopTracks, opts)
+	if len(mp.Tracks) == 0 {
+		return fmt.Errorf("%s has no tracks to sample", discography.Artist.Name)
+	}
+	if !*publish {
+		return json.NewEncoder(os.Stdout).Encode(mp)
+	}
+	user, err := sp.CurrentUser(ctx)
+	if err != nil {
+		return scopeError(err)
+	}
+	op := writeback.Op{Kind: writeback.Create, Name: mp.Name, Public: *public, Tracks: mp.Tracks}
+	return scopeError(applyOp(ctx, sp, user.IntegrationID, op))
+}
+
+// readArtistDump reads the artist with the ID from a file written by
+// spdump artist, the first one when id is empty. The albums must include
+// their tracks.
+func readArtistDump(path string, id string) (artistDump, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return artistDump{}, err
+	}
+	defer f.Close()
+
+	if id != "" {
+		resource, err := spotify.ParseResource(id)
+		if err != nil {
+			return artistDump{}, err
+		}
+		id = resource.ID
+	}
+	dec := json.NewDecoder(f)
+	for {
+		var dump artistDump
+		if err := dec.Decode(&dump); err == io.EOF {
+			return artistDump{}, fmt.Errorf("%s: no artist %s", path, id)
+		} else if err != nil {
+			return artistDump{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if id != "" && dump.Artist.IntegrationID != id {
+			continue
+		}
+		for _, album := range dump.Albums {
+			if len(album.Tracks) == 0 {
+				return artistDump{}, fmt.Errorf("%s: album %q has no tracks, dump the artist with --album-tracks", path, album.Name)
+			}
+		}
+		if len(dump.TopTracks) == 0 {
+			slog.Warn("the dump has no top tracks, sampling the opening tracks of each album", "artist", dump.Artist.Name)
+		}
+		return dump, nil
+	}
+}
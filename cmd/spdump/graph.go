@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/graph"
+	flag "github.com/spf13/pflag"
+)
+
+// runGraph exports the artist collaboration graph of one or more dumps.
+//
+//	spdump graph library.json --format gexf > artists.gexf
+func runGraph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := fs.StringP("format", "f", "dot", "output format: dot or gexf")
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump graph <dump.json>... [--format dot|gexf]")
+	}
+	if *format != "dot" && *format != "gexf" {
+		return fmt.Errorf("unknown graph format %q", *format)
+	}
+
+	// streamed a track at a time, so dumps larger than memory can be graphed
+	builder := graph.NewBuilder()
+	err := dump.StreamFiles(fs.Args(), nil, func(track dump.Track) error {
+		builder.AddTrack(track.MusicTrack)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	g := builder.Graph()
+	if *format == "gexf" {
+		return g.WriteGEXF(os.Stdout)
+	}
+	return g.WriteDOT(os.Stdout)
+}
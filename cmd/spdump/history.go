@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runHistory dumps the user's listening history as ndjson tracks with the
+// time they were played, oldest first. Spotify only keeps the last 50
+// plays, --append run every hour or so builds up a complete history.
+//
+//	spdump history --append history.ndjson
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the user-read-recently-played scope (or set SPOTIFY_TOKEN)")
+	after := fs.String("after", "", "only plays after this RFC3339 time")
+	appendTo := fs.String("append", "", "append the plays newer than the last one in this ndjson file to it")
+	keepQuery := fs.Bool("keep-query", false, "keep query strings (si= share tokens) on external URLs")
+	fields := registerExportFlags(fs)
+	parseFlags(fs, args)
+	if err := fields.loadRedaction(); err != nil {
+		return err
+	}
+
+	var since time.Time
+	if *after != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, *after); err != nil {
+			return fmt.Errorf("--after: %w", err)
+		}
+	}
+	if *appendTo != "" {
+		last, err := lastPlayed(*appendTo)
+		if err != nil {
+			return err
+		}
+		if last.After(since) {
+			since = last
+		}
+	}
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	history, err := sp.PlayHistory(commandContext(), since)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].PlayedAt.Before(history[j].PlayedAt)
+	})
+
+	out := io.Writer(os.Stdout)
+	if *appendTo != "" {
+		f, err := os.OpenFile(*appendTo, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	for _, item := range history {
+		mt := spotify.ConvertToMusicPlay(item)
+		mt.NormalizeURLs(*keepQuery)
+		fields.applyTrack(&mt)
+		if err := enc.Encode(mt); err != nil {
+			return err
+		}
+	}
+	slog.Info("dumped listening history", "plays", len(history), "after", since)
+	return nil
+}
+
+// lastPlayed returns when the newest play in an ndjson history file was
+// played, zero when the file doesn't exist yet.
+func lastPlayed(path string) (time.Time, error) {
+	var last time.Time
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, nil
+	}
+	if err != nil {
+		return last, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		mt := spotify.MusicTrack{}
+		if err := json.Unmarshal(scanner.Bytes(), &mt); err != nil {
+			return last, fmt.Errorf("%s: %w", path, err)
+		}
+		if mt.PlayedAt != nil && mt.PlayedAt.After(last) {
+			last = *mt.PlayedAt
+		}
+	}
+	return last, scanner.Err()
+}
+
+// timeRanges maps the --range names to the API's time ranges.
+var timeRanges = map[string]string{
+	"short":  spotify.TimeRangeShort,
+	"medium": spotify.TimeRangeMedium,
+	"long":   spotify.TimeRangeLong,
+}
+
+// runTop dumps the user's most listened tracks or artists over a time
+// range as ndjson, the most listened first.
+//
+//	spdump top --type artists --range long
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the user-top-read scope (or set SPOTIFY_TOKEN)")
+	kind := fs.String("type", "tracks", "tracks or artists")
+	timeRange := fs.String("range", "medium", "short (about 4 weeks), medium (6 months) or long (a year)")
+	limit := fs.Int("limit", 50, fmt.Sprintf("number of items, at most %d", spotify.MaxTopItems))
+	keepQuery := fs.Bool("keep-query", false, "keep query strings (si= share tokens) on external URLs")
+	fields := registerExportFlags(fs)
+	parseFlags(fs, args)
+	if err := fields.loadRedaction(); err != nil {
+		return err
+	}
+
+	apiRange, ok := timeRanges[*timeRange]
+	if !ok {
+		return fmt.Errorf("unknown --range %q, expected short, medium or long", *timeRange)
+	}
+	if *kind != "tracks" && *kind != "artists" {
+		return fmt.Errorf("unknown --type %q, expected tracks or artists", *kind)
+	}
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	if *kind == "artists" {
+		artists, err := sp.TopArtists(commandContext(), apiRange, *limit)
+		if err != nil {
+			return err
+		}
+		for _, artist := range artists {
+			ma := spotify.ConvertToMusicArtist(artist)
+			ma.ExternalURL = spotify.NormalizeURL(ma.ExternalURL, *keepQuery)
+			if fields.NoArt {
+				ma.ArtistArt = nil
+			}
+			if err := enc.Encode(ma); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tracks, err := sp.TopTracks(commandContext(), apiRange, *limit)
+	if err != nil {
+		return err
+	}
+	for _, track := range tracks {
+		mt := spotify.ConvertToMusicTrack(track)
+		mt.NormalizeURLs(*keepQuery)
+		fields.applyTrack(&mt)
+		if err := enc.Encode(mt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/job"
+)
+
+// jobsDir is the directory of an archive holding the progress of the jobs
+// writing to it, hidden from its collections.
+const jobsDir = ".jobs"
+
+// openJobs opens the job store of the archive.
+func openJobs(arc *archive.Archive) (*job.Store, error) {
+	return job.Open(filepath.Join(arc.Root, jobsDir))
+}
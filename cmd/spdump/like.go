@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/filter"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// likeScopes are the scopes a token needs to change the user's Liked
+// Songs.
+const likeScopes = "user-library-modify"
+
+// runLike saves the tracks of dumps matching filters to the user's Liked
+// Songs, so a dump can drive curating the library.
+//
+//	spdump like --from dump.json --filter 'artist =~ Radiohead' --dry-run
+func runLike(args []string) error {
+	return runLikeTracks("like", args)
+}
+
+// runUnlike removes the tracks of dumps matching filters from the user's
+// Liked Songs.
+//
+//	spdump unlike --from dump.json --filter 'added_before 2015-01-01'
+func runUnlike(args []string) error {
+	return runLikeTracks("unlike", args)
+}
+
+// runLikeTracks likes or unlikes the tracks selected from dumps.
+func runLikeTracks(command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	from := fs.StringArray("from", nil, "dump to take the tracks from (repeatable)")
+	filters := fs.StringArray("filter", nil, "only the tracks matching this expression, as the dump's --filter (repeatable, all must match)")
+	dryRun := fs.Bool("dry-run", false, "list the tracks without changing the library")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+likeScopes+" scope (or set SPOTIFY_TOKEN)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if len(*from) == 0 || fs.NArg() > 0 {
+		return fmt.Errorf("usage: spdump %s --from <dump.json>... [--filter <expr>]... [--dry-run]", command)
+	}
+	trackFilter, err := filter.ParseAll(*filters)
+	if err != nil {
+		return err
+	}
+	playlists, err := dump.ReadFiles(*from...)
+	if err != nil {
+		return err
+	}
+	tracks := likeableTracks(playlists, trackFilter)
+
+	if *dryRun {
+		t := table.New("ID", "ARTISTS", "NAME").Fixed("ID")
+		for _, track := range tracks {
+			t.Add(track.IntegrationID, track.Artists, track.Name)
+		}
+		if err := output.print(tracks, t); err != nil {
+			return err
+		}
+		slog.Info("dry run, pass no --dry-run to "+command+" the tracks", "tracks", len(tracks))
+		return nil
+	}
+	if len(tracks) == 0 {
+		slog.Info("no tracks to " + command)
+		return nil
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	return likeTracks(commandContext(), sp, command, tracks)
+}
+
+// likeableTracks returns the tracks of the playlists matching the filter
+// which can be liked, each once. Episodes and local files can't, and are
+// left out with a warning.
+func likeableTracks(playlists []spotify.MusicPlaylist, trackFilter *filter.Filter) []spotify.MusicTrack {
+	var tracks []spotify.MusicTrack
+	seen := map[string]bool{}
+	episodes, local := 0, 0
+	for _, mp := range playlists {
+		for _, track := range trackFilter.Tracks(mp.Tracks) {
+			switch {
+			case track.Source == spotify.SourceLocal || track.IntegrationID == "":
+				local++
+			case track.Type == spotify.TypeEpisode:
+				episodes++
+			case !seen[track.IntegrationID]:
+				seen[track.IntegrationID] = true
+				tracks = append(tracks, track)
+			}
+		}
+	}
+	if episodes > 0 || local > 0 {
+		slog.Warn("skipping episodes and local files, they aren't Liked Songs", "episodes", episodes, "local_files", local)
+	}
+	return tracks
+}
+
+// likeTracks saves the tracks to the user's Liked Songs, or removes them
+// for unlike, MaxSavedTracksPerRequest to a request.
+func likeTracks(ctx context.Context, sp *spotify.Client, command string, tracks []spotify.MusicTrack) error {
+	ids := make([]string, len(tracks))
+	for i, track := range tracks {
+		ids[i] = track.IntegrationID
+	}
+	var err error
+	if command == "unlike" {
+		err = sp.RemoveSavedTracks(ctx, ids)
+	} else {
+		err = sp.SaveTracks(ctx, ids)
+	}
+	if errors.Is(err, spotify.ErrUnauthorized) || errors.Is(err, spotify.ErrForbidden) {
+		return fmt.Errorf("%w (changing Liked Songs needs a user token with the %s scope)", err, likeScopes)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info(command+"d tracks", "tracks", len(ids), "requests", (len(ids)+spotify.MaxSavedTracksPerRequest-1)/spotify.MaxSavedTracksPerRequest)
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/color"
+	flag "github.com/spf13/pflag"
+)
+
+// logOptions are the logging flags shared by every command. Logs always go
+// to stderr so stdout stays clean for the dump itself.
+type logOptions struct {
+	Verbose bool
+	Quiet   bool
+	Format  string
+	// Color is auto, always or never, Theme the colors used.
+	Color string
+	Theme string
+}
+
+// logging holds the logging flags of the running command.
+var logging logOptions
+
+// stdoutColor and stderrColor color what the running command prints and
+// logs, each colored when it goes to a terminal unless --color says
+// otherwise.
+var stdoutColor, stderrColor color.Painter
+
+// parseFlags adds the flags shared by every command to fs, the logging
+// and color flags, --timeout, --usage, --profile and the transport flags,
+// parses args and sets up the default logger.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.BoolVarP(&logging.Verbose, "verbose", "v", false, "log every API request")
+	fs.BoolVarP(&logging.Quiet, "quiet", "q", false, "only log errors, no progress output")
+	fs.StringVar(&logging.Format, "log-format", "text", "log format: text or json")
+	fs.StringVar(&logging.Color, "color", color.Auto, "color tables, diffs and logs: auto (on a terminal, unless NO_COLOR is set), always or never")
+	fs.StringVar(&logging.Theme, "theme", firstNonEmpty(os.Getenv("SPDUMP_THEME"), "default"), "colors to use: default, light (for light backgrounds) or mono (bold and underline only) (or set SPDUMP_THEME)")
+	fs.DurationVar(&timeout, "timeout", 0, "give up on the whole command after this long, e.g. 10m")
+	fs.BoolVar(&showUsage, "usage", false, "report the wall and CPU time, peak memory, API calls, cache hit rate and bytes downloaded of the run when it ends")
+	registerTransportFlags(fs)
+	fs.StringVar(&profile, "profile", os.Getenv("SPDUMP_PROFILE"), "use the credentials and archive of this [spotify.<name>] profile in config.toml (or set SPDUMP_PROFILE)")
+	fs.Parse(args)
+
+	if err := logging.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := profileArchive(fs); err != nil {
+		fatal(err)
+	}
+}
+
+// setup sets up color and installs the default logger.
+func (o logOptions) setup() error {
+	var err error
+	if stdoutColor, err = color.New(o.Color, o.Theme, os.Stdout); err != nil {
+		return err
+	}
+	if stderrColor, err = color.New(o.Color, o.Theme, os.Stderr); err != nil {
+		return err
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case o.Quiet:
+		level = slog.LevelError
+	case o.Verbose:
+		level = slog.LevelDebug
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch o.Format {
+	case "text":
+		// timestamps are noise on a terminal, json logs keep them
+		handlerOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		}
+		handler = slog.NewTextHandler(levelColorWriter{os.Stderr, stderrColor}, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, handlerOpts)
+	default:
+		return fmt.Errorf("unknown log format %q", o.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// levelLabels are the levels as the text handler writes them, and the
+// roles they are colored as.
+var levelLabels = []struct {
+	label string
+	role  string
+}{
+	{"level=ERROR", color.Error},
+	{"level=WARN", color.Warn},
+	{"level=INFO", color.Info},
+	{"level=DEBUG", color.Debug},
+}
+
+// levelColorWriter colors the level of the log lines the text handler
+// writes, one line a write. Coloring the level attribute itself would
+// have the handler quote the escapes.
+type levelColorWriter struct {
+	w       io.Writer
+	painter color.Painter
+}
+
+func (o levelColorWriter) Write(p []byte) (int, error) {
+	if !o.painter.Enabled() {
+		return o.w.Write(p)
+	}
+	line := p
+	for _, level := range levelLabels {
+		if bytes.HasPrefix(p, []byte(level.label)) {
+			line = append([]byte(o.painter.Paint(level.role, level.label)), p[len(level.label):]...)
+			break
+		}
+	}
+	if _, err := o.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/library"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runMatch compares dumped playlists against a local music directory and
+// reports which tracks are already owned and which are missing.
+//
+//	spdump match library.json --library /music --missing missing.json
+func runMatch(args []string) error {
+	fs := flag.NewFlagSet("match", flag.ExitOnError)
+	libraryDir := fs.String("library", "", "local music directory, mp3 and flac tags are read")
+	archiveDir := fs.String("archive", "", "archive to match the latest snapshots of")
+	threshold := fs.Float64("threshold", library.DefaultThreshold, "similarity from 0 to 1 artist and title need for a fuzzy match")
+	missing := fs.String("missing", "", "write the missing tracks as json to this file, - for stdout")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if *libraryDir == "" || (*archiveDir == "" && fs.NArg() == 0) {
+		return errors.New("usage: spdump match <dump.json>... --library <dir> [--missing missing.json]")
+	}
+
+	var playlists []spotify.MusicPlaylist
+	var err error
+	if fs.NArg() > 0 {
+		if playlists, err = dump.ReadFiles(fs.Args()...); err != nil {
+			return err
+		}
+	}
+	if *archiveDir != "" {
+		archived, err := latestArchived(*archiveDir)
+		if err != nil {
+			return err
+		}
+		playlists = append(playlists, archived...)
+	}
+
+	files, err := library.Scan(*libraryDir)
+	if err != nil {
+		return err
+	}
+	slog.Info("scanned library", "dir", *libraryDir, "files", len(files))
+
+	report := library.NewMatcher(files, *threshold).Match(playlists)
+
+	if *missing != "" {
+		out := os.Stdout
+		if *missing != "-" {
+			f, err := os.Create(*missing)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report.Missing); err != nil {
+			return err
+		}
+		if *missing == "-" {
+			return nil
+		}
+	}
+
+	t := table.New("OWNED", "TRACKS", "MISSING", "PLAYLIST", "ID").Fixed("ID")
+	for _, pr := range report.Playlists {
+		t.Add(percent(pr.Owned, pr.Tracks), pr.Tracks, pr.Missing, pr.Name, pr.ID)
+	}
+	t.Add(percent(report.Owned, report.Tracks), report.Tracks, report.Tracks-report.Owned, "total", "")
+	return output.print(report, t)
+}
+
+// percent formats n out of total as a percentage.
+func percent(n int, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(n)*100/float64(total))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/pyrat/spd/internal/oembed"
+	"github.com/pyrat/spd/internal/table"
+	flag "github.com/spf13/pflag"
+)
+
+// runOEmbed prints the title and thumbnail of spotify links, fetched from
+// the public oEmbed endpoint without any credentials.
+//
+//	spdump oembed https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M
+func runOEmbed(args []string) error {
+	fs := flag.NewFlagSet("oembed", flag.ExitOnError)
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump oembed <link or uri>... [--output json]")
+	}
+
+	client := &oembed.Client{}
+	ctx := commandContext()
+	var embeds []oembed.Embed
+	for _, link := range fs.Args() {
+		embed, err := client.Fetch(ctx, link)
+		if err != nil {
+			return err
+		}
+		embeds = append(embeds, embed)
+	}
+
+	// json is a description per line, including the embed html
+	if output.json() {
+		enc := json.NewEncoder(os.Stdout)
+		for _, embed := range embeds {
+			if err := enc.Encode(embed); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	t := table.New("TITLE", "THUMBNAIL", "URL").Fixed("URL")
+	for _, embed := range embeds {
+		t.Add(embed.Title, embed.ThumbnailURL, embed.URL)
+	}
+	return output.print(embeds, t)
+}
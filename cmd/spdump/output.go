@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pyrat/spd/internal/artwork"
+	"github.com/pyrat/spd/internal/explicit"
+	"github.com/pyrat/spd/internal/filter"
+	"github.com/pyrat/spd/internal/job"
+	"github.com/pyrat/spd/internal/portable"
+	"github.com/pyrat/spd/internal/purchase"
+	"github.com/pyrat/spd/internal/report"
+	"github.com/pyrat/spd/internal/script"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/pipeline"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Output formats understood by the --format flag.
+const (
+	formatJSON         = "json"
+	formatNDJSON       = "ndjson"
+	formatNDJSONTracks = "ndjson-tracks"
+	formatCSV          = "csv"
+	formatMarkdown     = report.Markdown
+	formatHTML         = report.HTML
+	formatPortable     = "portable"
+	formatURIs         = "uris"
+)
+
+// playlistTrackLine is a single line of ndjson-tracks output, a track
+// tagged with the playlist it came from.
+type playlistTrackLine struct {
+	PlaylistID   string
+	PlaylistName string
+	spotify.MusicTrack
+}
+
+// dumpOptions controls how playlists are written out.
+type dumpOptions struct {
+	Format string
+	// Concurrency is the number of playlists fetched in parallel.
+	Concurrency int
+	// KeepQuery leaves query strings such as the si= share token on
+	// external URLs instead of normalizing them away.
+	KeepQuery bool
+	// Art downloads the cover images of everything dumped when set.
+	Art *artwork.Downloader
+	// Fields selects the nested objects written out.
+	Fields exportFields
+	// Location is the time zone timestamps are displayed in by the
+	// human oriented formats. JSON output is always UTC.
+	Location *time.Location
+	// Report configures the markdown and html formats.
+	Report report.Options
+	// Progress tracks the dump on stderr, it may be nil.
+	Progress *progress
+	// Policy is the explicit content policy, Pair finds the versions
+	// swapped in to satisfy it.
+	Policy explicit.Policy
+	Pair   explicit.Pair
+	// Jobs persists the progress of archive jobs when set, for them to
+	// be resumed after a restart and followed in serve mode.
+	Jobs *job.Store
+	// RefetchAll makes a sync fetch every playlist again, not only those
+	// whose snapshot_id changed, to catch catalog edits to their tracks,
+	// which leave the playlists' snapshot_id as it was.
+	RefetchAll bool
+	// Genres looks up the genres of the tracks' artists when set, each
+	// artist once for the whole dump.
+	Genres *spotify.Planner
+	// Language guesses the language of the tracks when set.
+	Language bool
+	// Filter selects the tracks dumped, nil for all of them.
+	Filter *filter.Filter
+	// Previews fills in the previews the API left out when set.
+	Previews *previewFallback
+	// Purchase adds the stores selling the tracks' albums when set.
+	Purchase *purchase.Finder
+	// Pipeline is the steps playlists go through, see newPipeline. The
+	// dump's own stages alone when nil.
+	Pipeline pipeline.Pipeline
+	// Script are the steps of the --where and --compute expressions.
+	Script pipeline.Pipeline
+}
+
+// convertPlaylist converts a fetched playlist into its dumped form and
+// runs it through the pipeline, downloading its artwork when requested.
+func (o dumpOptions) convertPlaylist(ctx context.Context, playlist spotify.SpotifyPlaylist) (spotify.MusicPlaylist, error) {
+	mp := spotify.ConvertToMusicPlaylist(playlist)
+	o.Progress.addPlaylist(len(mp.Tracks))
+	if err := o.steps(false).Apply(ctx, &mp); err != nil {
+		return mp, err
+	}
+	mp.NormalizeURLs(o.KeepQuery)
+	// artwork is fetched before --no-art strips it from the export
+	if o.Art != nil {
+		if err := o.Art.AddPlaylist(ctx, mp); err != nil {
+			return mp, err
+		}
+	}
+	o.Fields.applyPlaylist(&mp)
+	return mp, nil
+}
+
+// convertTrack converts a fetched playlist item into its dumped form,
+// running it through the pipeline on its own, and downloads its artwork
+// when requested. It returns the tracks the pipeline made of it, none for
+// a track the explicit content policy or a filter leaves out.
+func (o dumpOptions) convertTrack(ctx context.Context, playlist spotify.SpotifyPlaylist, item spotify.SpotifyPlaylistTrack) ([]spotify.MusicTrack, error) {
+	mp := spotify.MusicPlaylist{
+		IntegrationID: playlist.IntegrationID,
+		Name:          playlist.Name,
+		Tracks:        []spotify.MusicTrack{spotify.ConvertToMusicPlaylistTrack(item)},
+	}
+	o.Progress.addTracks(1)
+	if err := o.steps(true).Apply(ctx, &mp); err != nil {
+		return nil, err
+	}
+	for i := range mp.Tracks {
+		mt := &mp.Tracks[i]
+		mt.NormalizeURLs(o.KeepQuery)
+		if o.Art != nil {
+			if err := o.Art.AddTrack(ctx, *mt); err != nil {
+				return nil, err
+			}
+		}
+		o.Fields.applyTrack(mt)
+	}
+	return mp.Tracks, nil
+}
+
+// writePlaylists dumps the playlists to w in the requested format. The
+// ndjson formats write each record as soon as it is fetched so memory use
+// stays flat however many playlists or tracks are dumped.
+func writePlaylists(ctx context.Context, w io.Writer, sp *spotify.Client, ids []string, opts dumpOptions) error {
+	enc := json.NewEncoder(w)
+
+	switch opts.Format {
+	case formatJSON:
+		playlists, err := collectPlaylists(ctx, sp, ids, opts)
+		if err != nil {
+			return err
+		}
+		// a single playlist is written as an object so existing
+		// consumers of the dump keep working.
+		if len(playlists) == 1 {
+			return enc.Encode(playlists[0])
+		}
+		return enc.Encode(playlists)
+
+	case formatNDJSON:
+		return fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+			mp, err := opts.convertPlaylist(ctx, playlist)
+			if err != nil {
+				return err
+			}
+			return enc.Encode(mp)
+		})
+
+	case formatNDJSONTracks:
+		if err := opts.checkPerTrack(); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			playlist, err := sp.PlaylistSummaryFromID(ctx, id)
+			if err != nil {
+				return err
+			}
+			err = sp.PlaylistTracks(ctx, id, func(page spotify.SpotifyPlaylistTracks) error {
+				if opts.Genres != nil {
+					// look up the artists of the whole page in one go
+					for _, item := range page.Items {
+						opts.Genres.AddTrackArtists([]spotify.MusicTrack{spotify.ConvertToMusicPlaylistTrack(item)})
+					}
+				}
+				for _, item := range page.Items {
+					tracks, err := opts.convertTrack(ctx, playlist, item)
+					if err != nil {
+						return err
+					}
+					for _, mt := range tracks {
+						line := playlistTrackLine{
+							PlaylistID:   playlist.IntegrationID,
+							PlaylistName: playlist.Name,
+							MusicTrack:   mt,
+						}
+						if err := enc.Encode(line); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			opts.Progress.addPlaylist(0)
+		}
+		return nil
+
+	case formatMarkdown, formatHTML:
+		playlists, err := collectPlaylists(ctx, sp, ids, opts)
+		if err != nil {
+			return err
+		}
+		return report.Render(w, opts.Format, playlists, opts.Report)
+
+	case formatPortable:
+		// other services need every artist on its own
+		opts.Fields.ArtistsStructured = true
+		playlists, err := collectPlaylists(ctx, sp, ids, opts)
+		if err != nil {
+			return err
+		}
+		upcs, err := albumUPCs(ctx, sp.NewPlanner(), playlists)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(portable.Convert(playlists, upcs, time.Now()))
+
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(opts.csvHeader())
+		err := fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+			mp, err := opts.convertPlaylist(ctx, playlist)
+			if err != nil {
+				return err
+			}
+			for _, track := range mp.Tracks {
+				cw.Write(opts.csvRecord(mp, track))
+			}
+			cw.Flush()
+			return cw.Error()
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case formatURIs:
+		// one URI per line, as the desktop app copies and pastes tracks;
+		// local files have none and are left out
+		return fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+			mp, err := opts.convertPlaylist(ctx, playlist)
+			if err != nil {
+				return err
+			}
+			uris, _ := writeback.URIs(mp.Tracks)
+			for _, uri := range uris {
+				if _, err := fmt.Fprintln(w, uri); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return fmt.Errorf("unknown output format %q", opts.Format)
+}
+
+// collectPlaylists fetches and converts all playlists, for the formats
+// written out as a whole.
+func collectPlaylists(ctx context.Context, sp *spotify.Client, ids []string, opts dumpOptions) ([]spotify.MusicPlaylist, error) {
+	var playlists []spotify.MusicPlaylist
+	err := fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(ctx, playlist)
+		if err != nil {
+			return err
+		}
+		playlists = append(playlists, mp)
+		return nil
+	})
+	return playlists, err
+}
+
+// albumUPCs looks up the UPC of every album in the playlists, which the
+// albums embedded in playlist tracks don't include.
+func albumUPCs(ctx context.Context, planner *spotify.Planner, playlists []spotify.MusicPlaylist) (map[string]string, error) {
+	for _, mp := range playlists {
+		planner.AddTrackAlbums(mp.Tracks)
+	}
+	if err := planner.Fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	upcs := map[string]string{}
+	for _, mp := range playlists {
+		for _, track := range mp.Tracks {
+			if album, ok := planner.Album(track.AlbumID); ok && album.ExternalIDs.UPC != "" {
+				upcs[album.IntegrationID] = album.ExternalIDs.UPC
+			}
+		}
+	}
+	return upcs, nil
+}
+
+// csvHeader names the columns of the csv format, one row per track.
+var csvHeader = []string{"playlist_id", "playlist_name", "track_id", "name", "artists", "album", "release_date", "added_at", "url"}
+
+// firstNonEmpty returns the first of values which isn't empty, episodes
+// fill in their show where tracks have an album.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// csvHeader returns the columns of the csv format, followed by the
+// fields computed by the pipeline.
+func (o dumpOptions) csvHeader() []string {
+	return append(append([]string{}, csvHeader...), o.computedFields()...)
+}
+
+// csvRecord returns the csv row for a track of the playlist.
+func (o dumpOptions) csvRecord(mp spotify.MusicPlaylist, track spotify.MusicTrack) []string {
+	record := []string{
+		mp.IntegrationID,
+		mp.Name,
+		track.IntegrationID,
+		track.Name,
+		track.Artists,
+		firstNonEmpty(track.AlbumName, track.ShowName),
+		firstNonEmpty(track.AlbumReleaseDate, track.ReleaseDate),
+		spotify.FormatTime(track.AddedAt, o.Location),
+		track.ExternalURL,
+	}
+	for _, field := range o.computedFields() {
+		record = append(record, script.Format(track.Computed[field]))
+	}
+	return record
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runPermissions reports, for every playlist in the library, whether the
+// user owns it, whether it is public or collaborative, and so which
+// changes the write-back commands may make to it.
+//
+//	spdump permissions --token $SPOTIFY_TOKEN
+func runPermissions(args []string) error {
+	fs := flag.NewFlagSet("permissions", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the playlist-read-private and playlist-read-collaborative scopes (or set SPOTIFY_TOKEN)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	_, listed, err := libraryAccess(commandContext(), sp)
+	if err != nil {
+		return err
+	}
+
+	t := table.New("PLAYLIST", "OWNER", "OWNED", "PUBLIC", "COLLABORATIVE", "CAN", "ID").Fixed("ID")
+	for _, access := range listed {
+		public := "-"
+		if access.Public != nil {
+			public = yesNo(*access.Public)
+		}
+		var can []string
+		for _, kind := range access.Ops {
+			can = append(can, string(kind))
+		}
+		if access.Details {
+			can = append(can, "details")
+		}
+		if len(can) == 0 {
+			can = []string{"nothing"}
+		}
+		t.Add(access.Name, access.OwnerID, yesNo(access.Owned), public, yesNo(access.Collaborative), strings.Join(can, ","), access.PlaylistID)
+	}
+	return output.print(listed, t)
+}
+
+// libraryAccess returns the token's owner and their access to every
+// playlist in their library, in library order.
+func libraryAccess(ctx context.Context, sp *spotify.Client) (spotify.SpotifyUser, []writeback.Access, error) {
+	user, err := sp.CurrentUser(ctx)
+	if err != nil {
+		return user, nil, err
+	}
+	playlists, err := sp.UserPlaylists(ctx, "")
+	if err != nil {
+		return user, nil, err
+	}
+	listed := make([]writeback.Access, len(playlists))
+	for i, playlist := range playlists {
+		listed[i] = writeback.AccessOf(playlist, user.IntegrationID)
+	}
+	return user, listed, nil
+}
+
+// accessByID indexes access by playlist ID, for writeback.Check.
+func accessByID(listed []writeback.Access) map[string]writeback.Access {
+	access := make(map[string]writeback.Access, len(listed))
+	for _, a := range listed {
+		access[a.PlaylistID] = a
+	}
+	return access
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pyrat/spd/internal/explicit"
+	"github.com/pyrat/spd/internal/language"
+	"github.com/pyrat/spd/pkg/pipeline"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// dumpStages are the steps of a dump configured by its own flags, in the
+// order they run unless a pipeline places them: the explicit content
+// policy, genres, language, --filter, the --where and --compute
+// expressions, preview fallback and purchase links.
+var dumpStages = []string{"explicit", "genres", "language", "filter", "script", "previews", "purchase"}
+
+// isDumpStage reports whether spec names one of the dump's stages rather
+// than a registered step. The filter step with an expression of its own
+// is the registered one.
+func isDumpStage(spec pipeline.Spec) bool {
+	for _, stage := range dumpStages {
+		if spec.Name == stage && len(spec.Config) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pipelineSpecs returns the steps given by --step, or else those of
+// [pipeline] steps in config.toml, written as strings or as tables:
+//
+//	[pipeline]
+//	steps = ["explicit", "dedupe:by=isrc", "sort:by=added,reverse"]
+//
+//	[[pipeline.steps]]
+//	name = "limit"
+//	n = 50
+func pipelineSpecs(steps []string) ([]pipeline.Spec, error) {
+	if len(steps) == 0 {
+		config, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		switch configured := config.Get("pipeline.steps").(type) {
+		case []interface{}:
+			for _, step := range configured {
+				steps = append(steps, fmt.Sprint(step))
+			}
+		case []*toml.Tree:
+			var specs []pipeline.Spec
+			for _, tree := range configured {
+				spec := pipeline.Spec{Config: pipeline.Config{}}
+				for key, value := range tree.ToMap() {
+					if key == "name" {
+						spec.Name = fmt.Sprint(value)
+					} else {
+						spec.Config[key] = fmt.Sprint(value)
+					}
+				}
+				if spec.Name == "" {
+					return nil, fmt.Errorf("a step of pipeline.steps in config.toml has no name")
+				}
+				if len(spec.Config) == 0 {
+					spec.Config = nil
+				}
+				specs = append(specs, spec)
+			}
+			return specs, nil
+		}
+	}
+	var specs []pipeline.Spec
+	for _, step := range steps {
+		spec, err := pipeline.ParseSpec(step)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// newPipeline builds the steps the specs name. The dump's stages are left
+// as placeholders without a Step, filled in by dumpOptions.steps; those
+// the specs don't place run first, in their usual order.
+func newPipeline(specs []pipeline.Spec) (pipeline.Pipeline, error) {
+	placed := map[string]bool{}
+	for _, spec := range specs {
+		if isDumpStage(spec) {
+			placed[spec.Name] = true
+		}
+	}
+	var p pipeline.Pipeline
+	for _, stage := range dumpStages {
+		if !placed[stage] {
+			p = append(p, pipeline.Named{Name: stage})
+		}
+	}
+	for _, spec := range specs {
+		if isDumpStage(spec) {
+			p = append(p, pipeline.Named{Name: spec.Name})
+			continue
+		}
+		step, err := pipeline.New(spec.Name, spec.Config)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, pipeline.Named{Name: spec.String(), Step: step})
+	}
+	if len(specs) > 0 {
+		slog.Debug("dump pipeline", "steps", strings.Join(p.Names(), " → "))
+	}
+	return p, nil
+}
+
+// steps returns the dump's pipeline with its stages in place, leaving
+// out those turned off. perTrack builds them for tracks streamed one at a
+// time, which aren't logged each; the steps looking at the whole playlist
+// are then refused by checkPerTrack.
+func (o dumpOptions) steps(perTrack bool) pipeline.Pipeline {
+	p := o.Pipeline
+	if p == nil {
+		p, _ = newPipeline(nil)
+	}
+	steps := make(pipeline.Pipeline, 0, len(p))
+	for _, step := range p {
+		if step.Step == nil {
+			if step.Step = o.stage(step.Name, perTrack); step.Step == nil {
+				continue
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// checkPerTrack returns an error when a step of the pipeline looks at the
+// whole playlist, so can't run on tracks streamed one at a time: sort,
+// limit and dedupe would see a single track and do nothing, exec would
+// run for every track.
+func (o dumpOptions) checkPerTrack() error {
+	if err := o.steps(true).CheckPerTrack(); err != nil {
+		return fmt.Errorf("%w, as --format %s streams them; dump with --format ndjson instead", err, formatNDJSONTracks)
+	}
+	return nil
+}
+
+// computedFields returns the fields the pipeline computes, in order.
+func (o dumpOptions) computedFields() []string {
+	if o.Pipeline == nil {
+		return o.Script.Fields()
+	}
+	var fields []string
+	for _, step := range o.Pipeline {
+		if step.Step == nil && step.Name == "script" {
+			fields = append(fields, o.Script.Fields()...)
+		} else if fielder, ok := step.Step.(pipeline.Fielder); ok {
+			fields = append(fields, fielder.Fields()...)
+		}
+	}
+	return fields
+}
+
+// stage returns the step of one of the dump's stages, nil when it is
+// turned off.
+func (o dumpOptions) stage(name string, perTrack bool) pipeline.Step {
+	switch name {
+	case "explicit":
+		return pipeline.TrackFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+			var err error
+			if perTrack {
+				mp.Tracks, _, err = explicit.Apply(ctx, o.Policy, mp.Tracks, o.Pair)
+			} else {
+				mp.Tracks, err = applyPolicy(ctx, o.Policy, o.Pair, mp.Name, mp.Tracks)
+			}
+			return err
+		})
+	case "genres":
+		if o.Genres == nil {
+			return nil
+		}
+		return pipeline.TrackFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+			if perTrack {
+				return o.Genres.TrackGenres(ctx, mp.Tracks)
+			}
+			return o.Genres.PlaylistGenres(ctx, mp)
+		})
+	case "language":
+		if !o.Language {
+			return nil
+		}
+		return pipeline.TrackFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+			for i := range mp.Tracks {
+				mp.Tracks[i].Language = language.Track(mp.Tracks[i])
+			}
+			return nil
+		})
+	case "filter":
+		if o.Filter == nil {
+			return nil
+		}
+		return pipeline.TrackFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+			mp.Tracks = o.Filter.Tracks(mp.Tracks)
+			return nil
+		})
+	case "script":
+		if len(o.Script) == 0 {
+			return nil
+		}
+		return o.Script
+	case "previews":
+		if o.Previews == nil {
+			return nil
+		}
+		return pipeline.TrackFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+			o.Previews.fill(ctx, mp.Tracks)
+			return nil
+		})
+	case "purchase":
+		if o.Purchase == nil {
+			return nil
+		}
+		return pipeline.TrackFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+			o.Purchase.Fill(ctx, mp.Tracks)
+			return nil
+		})
+	}
+	return nil
+}
+
+// scriptSteps builds the steps of the --where and --compute expressions,
+// or else those of [script] in config.toml:
+//
+//	[script]
+//	where = "year >= 2000 && !explicit"
+//	fields = ['decade = string(year / 10 * 10) + "s"', "minutes = round(duration / 60.0, 1)"]
+//
+// Fields are computed in order, each seeing those before it.
+func scriptSteps(where []string, compute []string) (pipeline.Pipeline, error) {
+	if len(where) == 0 || len(compute) == 0 {
+		config, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if len(where) == 0 {
+			where = configStrings(config, "script.where")
+		}
+		if len(compute) == 0 {
+			compute = configStrings(config, "script.fields")
+		}
+	}
+
+	var p pipeline.Pipeline
+	for _, definition := range compute {
+		field, expr, ok := strings.Cut(definition, "=")
+		if field = strings.TrimSpace(field); !ok || field == "" {
+			return nil, fmt.Errorf("computed field %q is not written name = expression", definition)
+		}
+		step, err := pipeline.Compute(field, strings.TrimSpace(expr))
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, pipeline.Named{Name: "compute:" + field, Step: step})
+	}
+	for _, expr := range where {
+		step, err := pipeline.Where(expr)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, pipeline.Named{Name: "where", Step: step})
+	}
+	return p, nil
+}
+
+// configStrings returns a config value given as a string or a list of
+// them.
+func configStrings(config *toml.Tree, key string) []string {
+	switch value := config.Get(key).(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, len(value))
+		for i, v := range value {
+			values[i] = fmt.Sprint(v)
+		}
+		return values
+	}
+	return nil
+}
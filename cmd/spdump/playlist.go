@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// modifyScopes are the scopes a token needs to change playlists.
+const modifyScopes = "playlist-modify-public and playlist-modify-private"
+
+// runPlaylist changes playlists in the user's account: creating them,
+// adding, removing and moving tracks, or applying the edits made to a
+// dump of them. It needs a user token with the playlist-modify scopes.
+//
+//	spdump playlist create --name "Road trip" <track>...
+//	spdump playlist add <playlist> <track>... [--from-file uris.txt]
+//	spdump playlist remove <playlist> <track>...
+//	spdump playlist reorder <playlist> --from 12 --before 1
+//	spdump playlist apply edited.json --dry-run
+func runPlaylist(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "create":
+			return runPlaylistCreate(args[1:])
+		case "add", "remove":
+			return runPlaylistTracks(writeback.Kind(args[0]), args[1:])
+		case "reorder":
+			return runPlaylistReorder(args[1:])
+		case "apply":
+			return runPlaylistApply(args[1:])
+		}
+	}
+	return errors.New("usage: spdump playlist create|add|remove|reorder|apply")
+}
+
+// runPlaylistCreate creates a playlist holding the tracks given.
+func runPlaylistCreate(args []string) error {
+	fs := flag.NewFlagSet("playlist create", flag.ExitOnError)
+	name := fs.StringP("name", "n", "", "name of the new playlist")
+	public := fs.Bool("public", false, "make the playlist public")
+	fromFile := fs.String("from-file", "", "also add the tracks listed in this file, one ID, URI or link per line as --format uris writes them, - for stdin")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if *name == "" {
+		return errors.New("usage: spdump playlist create --name <name> [<track>...] [--from-file uris.txt]")
+	}
+	tracks, err := trackFileArgs(fs.Args(), *fromFile)
+	if err != nil {
+		return err
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+	user, err := sp.CurrentUser(ctx)
+	if err != nil {
+		return scopeError(err)
+	}
+	op := writeback.Op{Kind: writeback.Create, Name: *name, Public: *public, Tracks: tracks}
+	return scopeError(applyOp(ctx, sp, user.IntegrationID, op))
+}
+
+// runPlaylistTracks adds the tracks given to a playlist, or removes every
+// occurrence of them.
+func runPlaylistTracks(kind writeback.Kind, args []string) error {
+	fs := flag.NewFlagSet("playlist "+string(kind), flag.ExitOnError)
+	fromFile := fs.String("from-file", "", "also take the tracks listed in this file, one ID, URI or link per line as --format uris writes them, - for stdin")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if fs.NArg() < 1 || (fs.NArg() < 2 && *fromFile == "") {
+		return fmt.Errorf("usage: spdump playlist %s <playlist> <track>... [--from-file uris.txt]", kind)
+	}
+	tracks, err := trackFileArgs(fs.Args()[1:], *fromFile)
+	if err != nil {
+		return err
+	}
+	if len(tracks) == 0 {
+		return fmt.Errorf("%s lists no tracks", *fromFile)
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	return modifyPlaylist(commandContext(), sp, fs.Arg(0), func(playlist spotify.SpotifyPlaylist) ([]writeback.Op, error) {
+		return []writeback.Op{{Kind: kind, PlaylistID: playlist.IntegrationID, Name: playlist.Name, Tracks: tracks}}, nil
+	}, false)
+}
+
+// runPlaylistReorder moves tracks within a playlist.
+func runPlaylistReorder(args []string) error {
+	fs := flag.NewFlagSet("playlist reorder", flag.ExitOnError)
+	from := fs.Int("from", 0, "position of the first track moved, from 1")
+	length := fs.Int("length", 1, "number of tracks moved")
+	before := fs.Int("before", 0, "position of the track they are moved in front of, from 1, one past the last track to move them to the end")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 || *from < 1 || *before < 1 || *length < 1 {
+		return errors.New("usage: spdump playlist reorder <playlist> --from <position> --before <position> [--length 1]")
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	// a range moves as one, the ops only ever move a single track
+	moves := make([]writeback.Move, 0, *length)
+	for i := 0; i < *length; i++ {
+		move := writeback.Move{From: *from - 1 + i, Before: *before - 1 + i}
+		if *before > *from {
+			move = writeback.Move{From: *from - 1, Before: *before - 1}
+		}
+		moves = append(moves, move)
+	}
+	return modifyPlaylist(commandContext(), sp, fs.Arg(0), func(playlist spotify.SpotifyPlaylist) ([]writeback.Op, error) {
+		return []writeback.Op{{Kind: writeback.Reorder, PlaylistID: playlist.IntegrationID, Name: playlist.Name, Moves: moves}}, nil
+	}, false)
+}
+
+// runPlaylistApply applies the edits made to dumps of playlists, e.g. by
+// hand or with jq, back to Spotify: tracks removed from the dump are
+// removed from the playlist, tracks added are added and the rest moved
+// into the dump's order.
+func runPlaylistApply(args []string) error {
+	fs := flag.NewFlagSet("playlist apply", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the changes without making them")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump playlist apply <edited.json>... [--dry-run]")
+	}
+	edited, err := dump.ReadFiles(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+
+	ctx := commandContext()
+	for _, mp := range edited {
+		err := modifyPlaylist(ctx, sp, mp.IntegrationID, func(playlist spotify.SpotifyPlaylist) ([]writeback.Op, error) {
+			current := spotify.ConvertToMusicPlaylist(playlist)
+			ops := writeback.Plan(current, mp)
+			if len(ops) == 0 {
+				slog.Info("playlist unchanged", "playlist", mp.IntegrationID, "name", mp.Name)
+			}
+			return ops, nil
+		}, *dryRun)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modifyPlaylist fetches a playlist, plans the ops changing it with plan
+// and applies them, after checking the user may make them at all.
+func modifyPlaylist(ctx context.Context, sp *spotify.Client, id string, plan func(playlist spotify.SpotifyPlaylist) ([]writeback.Op, error), dryRun bool) error {
+	user, err := sp.CurrentUser(ctx)
+	if err != nil {
+		return scopeError(err)
+	}
+	playlist, err := sp.PlaylistFromID(ctx, id)
+	if err != nil {
+		return err
+	}
+	ops, err := plan(playlist)
+	if err != nil {
+		return err
+	}
+
+	access := writeback.AccessOf(playlist, user.IntegrationID)
+	if _, denied := writeback.Check(ops, map[string]writeback.Access{access.PlaylistID: access}); len(denied) > 0 {
+		return fmt.Errorf("%s: %w", denied[0], spotify.ErrForbidden)
+	}
+	for _, op := range ops {
+		if dryRun {
+			fmt.Println(op)
+			continue
+		}
+		if err := applyOp(ctx, sp, user.IntegrationID, op); err != nil {
+			return scopeError(err)
+		}
+	}
+	return nil
+}
+
+// trackArgs parses track IDs, URIs or links given as arguments.
+func trackArgs(args []string) ([]spotify.MusicTrack, error) {
+	tracks := make([]spotify.MusicTrack, 0, len(args))
+	for _, arg := range args {
+		resource, err := spotify.ParseResource(arg)
+		if err != nil {
+			return nil, err
+		}
+		track := spotify.MusicTrack{IntegrationID: resource.ID}
+		switch resource.Type {
+		case "", spotify.TypeTrack:
+		case spotify.TypeEpisode:
+			track.Type = spotify.TypeEpisode
+		default:
+			return nil, fmt.Errorf("%s is a %s, not a track", arg, resource.Type)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+// trackFileArgs parses the tracks given as arguments followed by those
+// listed in a file, when path isn't empty. Local files, which the desktop
+// app copies as spotify:local: URIs, can't be added through the API and
+// are skipped.
+func trackFileArgs(args []string, path string) ([]spotify.MusicTrack, error) {
+	if path != "" {
+		lines, err := readIDFile(path)
+		if err != nil {
+			return nil, err
+		}
+		args = append([]string(nil), args...)
+		local := 0
+		for _, line := range lines {
+			if strings.HasPrefix(line, "spotify:local:") {
+				local++
+				continue
+			}
+			args = append(args, line)
+		}
+		if local > 0 {
+			slog.Warn("skipping local files, they can't be added through the API", "file", path, "local_files", local)
+		}
+	}
+	return trackArgs(args)
+}
+
+// scopeError points out the scopes a token lacks when a change was refused.
+func scopeError(err error) error {
+	if errors.Is(err, spotify.ErrUnauthorized) || errors.Is(err, spotify.ErrForbidden) {
+		return fmt.Errorf("%w (changing playlists needs a user token with the %s scopes)", err, modifyScopes)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/pyrat/spd/internal/explicit"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// policyFlags are the explicit content policy toggles.
+type policyFlags struct {
+	CleanOnly    bool
+	ExplicitOnly bool
+}
+
+// registerPolicyFlags adds --clean-only and --explicit-only to fs.
+func registerPolicyFlags(fs *flag.FlagSet) *policyFlags {
+	flags := &policyFlags{}
+	fs.BoolVar(&flags.CleanOnly, "clean-only", false, "swap explicit tracks for their clean versions, leaving out those without one")
+	fs.BoolVar(&flags.ExplicitOnly, "explicit-only", false, "swap clean edits for their explicit versions, leaving out tracks without one")
+	return flags
+}
+
+// policy returns the policy the flags select.
+func (o policyFlags) policy() (explicit.Policy, error) {
+	switch {
+	case o.CleanOnly && o.ExplicitOnly:
+		return explicit.Any, errors.New("--clean-only and --explicit-only can't be combined")
+	case o.CleanOnly:
+		return explicit.CleanOnly, nil
+	case o.ExplicitOnly:
+		return explicit.ExplicitOnly, nil
+	}
+	return explicit.Any, nil
+}
+
+// versionPairer looks up the other versions of tracks through sp,
+// remembering them so tracks in several playlists are searched once.
+func versionPairer(sp *spotify.Client) explicit.Pair {
+	type pairing struct {
+		track spotify.MusicTrack
+		ok    bool
+	}
+	var mu sync.Mutex
+	seen := map[string]pairing{}
+
+	return func(ctx context.Context, track spotify.MusicTrack, wantExplicit bool) (spotify.MusicTrack, bool, error) {
+		mu.Lock()
+		p, found := seen[track.IntegrationID]
+		mu.Unlock()
+		if found {
+			return p.track, p.ok, nil
+		}
+
+		other, ok, err := sp.OtherVersion(ctx, track, wantExplicit)
+		if err != nil {
+			return other, false, err
+		}
+		mu.Lock()
+		seen[track.IntegrationID] = pairing{track: other, ok: ok}
+		mu.Unlock()
+		return other, ok, nil
+	}
+}
+
+// applyPolicy enforces the policy on a playlist's tracks, logging what it
+// changed.
+func applyPolicy(ctx context.Context, policy explicit.Policy, pair explicit.Pair, name string, tracks []spotify.MusicTrack) ([]spotify.MusicTrack, error) {
+	kept, result, err := explicit.Apply(ctx, policy, tracks, pair)
+	if err != nil {
+		return nil, err
+	}
+	if result.Swapped > 0 || result.Dropped > 0 {
+		slog.Info("applied explicit content policy", "playlist", name, "policy", string(policy), "swapped", result.Swapped, "dropped", result.Dropped)
+	}
+	return kept, nil
+}
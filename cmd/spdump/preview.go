@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxPreviewFailures is how many embed lookups in a row may fail before
+// the fallback gives up for the rest of the dump.
+const maxPreviewFailures = 5
+
+// previewLookups is how many embed pages are fetched at once.
+const previewLookups = 4
+
+// previewFallback fills in the previews the API left out from the embed
+// player, best effort: a failed lookup leaves the track without one
+// rather than failing the dump.
+type previewFallback struct {
+	sp *spotify.Client
+
+	mu       sync.Mutex
+	found    map[string]string
+	failures int
+}
+
+func newPreviewFallback(sp *spotify.Client) *previewFallback {
+	return &previewFallback{sp: sp, found: map[string]string{}}
+}
+
+// fill looks up the previews of the tracks lacking one. Episodes and
+// local files are left as they are.
+func (o *previewFallback) fill(ctx context.Context, tracks []spotify.MusicTrack) {
+	if o == nil {
+		return
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(previewLookups)
+	for i := range tracks {
+		track := &tracks[i]
+		if track.PreviewURL != "" || track.Type == spotify.TypeEpisode || track.Source == spotify.SourceLocal || track.IntegrationID == "" {
+			continue
+		}
+		g.Go(func() error {
+			if preview := o.lookup(ctx, track.IntegrationID); preview != "" {
+				track.PreviewURL = preview
+				track.PreviewSource = spotify.PreviewFromEmbed
+			}
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// lookup returns the embed preview of a track, each looked up once.
+func (o *previewFallback) lookup(ctx context.Context, trackID string) string {
+	o.mu.Lock()
+	preview, ok := o.found[trackID]
+	gaveUp := o.failures >= maxPreviewFailures
+	o.mu.Unlock()
+	if ok || gaveUp {
+		return preview
+	}
+
+	preview, err := o.sp.EmbedPreview(ctx, trackID)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			o.failures++
+			slog.Debug("embed preview lookup failed", "track", trackID, "err", err)
+			if o.failures == maxPreviewFailures {
+				slog.Warn("giving up on embed previews after repeated failures", "err", err)
+			}
+		}
+		return ""
+	}
+	o.failures = 0
+	o.found[trackID] = preview
+	return preview
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pyrat/spd/internal/writeback"
+	flag "github.com/spf13/pflag"
+)
+
+// runPrivacy makes the owned playlists whose names match a pattern public,
+// private or collaborative, e.g. to clean up a public profile. It only
+// prints the changes unless --apply is given.
+//
+//	spdump privacy --match 'archive-*' --set private --apply
+func runPrivacy(args []string) error {
+	fs := flag.NewFlagSet("privacy", flag.ExitOnError)
+	patterns := fs.StringArrayP("match", "m", nil, "shell pattern matching the names of the playlists to change, case insensitive (repeatable)")
+	set := fs.String("set", "", "make the playlists public, private or collaborative (collaborative playlists are private)")
+	apply := fs.Bool("apply", false, "make the changes instead of printing them")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if len(*patterns) == 0 {
+		return errors.New("usage: spdump privacy --match <pattern> --set public|private|collaborative [--apply]")
+	}
+	for _, pattern := range *patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("--match %q: %w", pattern, err)
+		}
+	}
+	var public, collaborative bool
+	switch *set {
+	case "public":
+		public = true
+	case "private":
+	case "collaborative":
+		collaborative = true
+	default:
+		return fmt.Errorf("--set must be public, private or collaborative, not %q", *set)
+	}
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+	user, listed, err := libraryAccess(ctx, sp)
+	if err != nil {
+		return scopeError(err)
+	}
+
+	var ops []writeback.Op
+	notOwned := 0
+	for _, access := range listed {
+		if !matchesAny(*patterns, access.Name) {
+			continue
+		}
+		if !access.Details {
+			notOwned++
+			continue
+		}
+		if access.Public != nil && *access.Public == public && access.Collaborative == collaborative {
+			continue
+		}
+		ops = append(ops, writeback.Op{
+			Kind:          writeback.Visibility,
+			PlaylistID:    access.PlaylistID,
+			Name:          access.Name,
+			Public:        public,
+			Collaborative: collaborative,
+		})
+	}
+	if notOwned > 0 {
+		slog.Warn("skipping matching playlists owned by others", "playlists", notOwned)
+	}
+	if len(ops) == 0 {
+		slog.Info("no playlists to change")
+		return nil
+	}
+
+	for _, op := range ops {
+		if !*apply {
+			fmt.Println(op)
+			continue
+		}
+		if err := applyOp(ctx, sp, user.IntegrationID, op); err != nil {
+			return scopeError(err)
+		}
+	}
+	if !*apply {
+		slog.Info("dry run, pass --apply to make the changes", "playlists", len(ops))
+	}
+	return nil
+}
+
+// matchesAny reports whether name matches any of the shell patterns,
+// ignoring case.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	flag "github.com/spf13/pflag"
+)
+
+// profile is the --profile of the running command, the [spotify.<name>]
+// section of config.toml used instead of the top level settings.
+var profile string
+
+// applyProfile overlays the profile's section onto config: its keys
+// replace those of [spotify], its tables, e.g. [spotify.work.auth],
+// replace the top level ones. Unless the profile has a cache of its own,
+// a top level cache directory gets a subdirectory per profile, so cached
+// responses of /me endpoints never leak between accounts.
+//
+//	[spotify.work]
+//	client_id = "..."
+//	client_secret_cmd = "pass show spotify/work"
+//	archive = "archive-work"
+//
+//	[spotify.work.auth]
+//	provider = "refresh_token"
+func applyProfile(config *toml.Tree, name string) error {
+	if name == "" {
+		return nil
+	}
+	section, ok := config.GetPath([]string{"spotify", name}).(*toml.Tree)
+	if !ok {
+		return fmt.Errorf("no profile %q in config.toml, profiles: %s", name, strings.Join(profileNames(config), ", "))
+	}
+
+	_, ownCache := section.Get("cache").(*toml.Tree)
+	if dir, _ := config.Get("cache.dir").(string); dir != "" && !ownCache {
+		config.Set("cache.dir", filepath.Join(dir, name))
+	}
+	for _, key := range section.Keys() {
+		switch value := section.Get(key).(type) {
+		case *toml.Tree:
+			config.SetPath([]string{key}, value)
+		default:
+			config.SetPath([]string{"spotify", key}, value)
+		}
+	}
+	return nil
+}
+
+// profileNames lists the profiles defined in config.
+func profileNames(config *toml.Tree) []string {
+	var names []string
+	if spotify, ok := config.Get("spotify").(*toml.Tree); ok {
+		for _, key := range spotify.Keys() {
+			if _, ok := spotify.Get(key).(*toml.Tree); ok {
+				names = append(names, key)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileArchive points the command's --archive at the profile's archive
+// directory when it wasn't given: the profile's archive key, or the
+// default directory suffixed with the profile's name. Commands which only
+// use an archive when asked for one are left alone.
+func profileArchive(fs *flag.FlagSet) error {
+	archive := fs.Lookup("archive")
+	if profile == "" || archive == nil || archive.Changed || archive.DefValue == "" {
+		return nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	dir, _ := config.Get("spotify.archive").(string)
+	if dir == "" {
+		dir = archive.DefValue + "-" + profile
+	}
+	return fs.Set("archive", dir)
+}
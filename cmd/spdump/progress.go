@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// progress draws a progress bar of a multi-playlist dump on stderr: the
+// playlists and tracks fetched so far and the API calls and retries made.
+type progress struct {
+	w     io.Writer
+	sp    *spotify.Client
+	total int
+
+	mu        sync.Mutex
+	playlists int
+	tracks    int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newProgress starts a progress bar for a dump of total playlists. It
+// returns nil, which is safe to use, when there is nothing worth drawing:
+// a single playlist, --quiet, json logs or stderr not being a terminal.
+func newProgress(sp *spotify.Client, total int) *progress {
+	if total < 2 || logging.Quiet || logging.Format != "text" || !isTerminal(os.Stderr) {
+		return nil
+	}
+
+	p := &progress{w: os.Stderr, sp: sp, total: total, done: make(chan struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.draw()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// addPlaylist records a fetched playlist and its tracks.
+func (o *progress) addPlaylist(tracks int) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	o.playlists++
+	o.tracks += tracks
+	o.mu.Unlock()
+}
+
+// addTracks records tracks fetched on their own.
+func (o *progress) addTracks(tracks int) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	o.tracks += tracks
+	o.mu.Unlock()
+}
+
+// stop draws the final state and ends the line.
+func (o *progress) stop() {
+	if o == nil {
+		return
+	}
+	close(o.done)
+	o.wg.Wait()
+	o.draw()
+	fmt.Fprintln(o.w)
+}
+
+// draw redraws the bar in place.
+func (o *progress) draw() {
+	const width = 30
+
+	o.mu.Lock()
+	playlists, tracks := o.playlists, o.tracks
+	o.mu.Unlock()
+	stats := o.sp.Stats()
+
+	filled := width * playlists / o.total
+	bar := strings.Repeat("#", filled) + strings.Repeat(" ", width-filled)
+	fmt.Fprintf(o.w, "\r[%s] %d/%d playlists, %d tracks, %d API calls, %d retries",
+		bar, playlists, o.total, tracks, stats.Requests, stats.Retries)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
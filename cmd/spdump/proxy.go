@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runProxy serves the Spotify Web API to other tools through spdump's
+// client, so they share its token, its response cache and its rate:
+//
+//	spdump proxy --listen :9090 --token s3cret
+//	curl -H 'Authorization: Bearer s3cret' localhost:9090/v1/tracks/4uLU6hMCjMI75M1A2tKUQC
+func runProxy(args []string) error {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	listen := fs.String("listen", "localhost:9090", "address to listen on")
+	token := fs.String("token", os.Getenv("SPDUMP_PROXY_TOKEN"), "bearer token callers must present, required unless listening on loopback (or set SPDUMP_PROXY_TOKEN)")
+	allowWrites := fs.Bool("allow-writes", false, "also forward requests changing the account, such as POST and DELETE, not only GET and HEAD")
+	cacheDir := fs.String("cache-dir", "", "cache API responses in this directory, revalidated by ETag (defaults to [cache] dir in config.toml, or spdump/responses in the user cache directory)")
+	rate := fs.Float64("rate", 10, "most requests a second sent to Spotify, 0 for no limit")
+	parseFlags(fs, args)
+	if *token == "" && !isLoopback(*listen) {
+		return fmt.Errorf("proxy: %s is reachable from other hosts, set --token (or SPDUMP_PROXY_TOKEN) or listen on localhost", *listen)
+	}
+
+	opts := []spotify.Option{spotify.WithRateLimit(*rate)}
+	if *cacheDir == "" {
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if dir, _ := config.Get("cache.dir").(string); dir == "" {
+			if dir, err := os.UserCacheDir(); err == nil {
+				*cacheDir = filepath.Join(dir, "spdump", "responses")
+			}
+		}
+	}
+	if *cacheDir != "" {
+		opts = append(opts, spotify.WithCache(*cacheDir))
+	}
+	sp, err := newSpotifyFromConfig(opts...)
+	if err != nil {
+		return err
+	}
+
+	handler := sp.Proxy(spotify.ProxyOptions{AllowWrites: *allowWrites})
+	if *token != "" {
+		handler = requireToken(*token, handler)
+	}
+	ctx := commandContext()
+	httpServer := &http.Server{Addr: *listen, Handler: logRequests(handler)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("proxying the spotify api", "listen", *listen, "cache", *cacheDir, "rate", *rate, "token", *token != "", "allow_writes", *allowWrites)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	stats := sp.Stats()
+	slog.Info("proxy stopped", "requests", stats.Requests, "cache_hits", stats.CacheHits, "retries", stats.Retries, "errors", stats.Errors)
+	return nil
+}
+
+// isLoopback reports whether the listen address only accepts
+// connections from this host. An address without a host listens on every
+// interface.
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireToken lets through only requests bearing token, in place of a
+// Spotify access token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"status":401,"message":"missing or wrong proxy token"}}` + "\n"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequests logs the requests proxied at debug level.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		slog.Debug("proxied", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pyrat/spd/internal/queue"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runQueue keeps tracks to add to a playlist later in a local file, and
+// adds them all in one go. Queuing needs neither a token nor the network.
+//
+//	spdump queue add <track>...
+//	spdump queue list
+//	spdump queue flush --playlist <id>
+//	spdump queue clear
+func runQueue(args []string) error {
+	fs := flag.NewFlagSet("queue", flag.ExitOnError)
+	file := fs.String("file", "", "queue file (defaults to [queue] file in config.toml, or spdump/queue.json in the user config directory)")
+	fromFile := fs.String("from-file", "", "add: also queue the tracks listed in this file, one ID, URI or link per line, - for stdin")
+	playlist := fs.String("playlist", "", "flush: playlist ID, URI or link to add the queued tracks to")
+	duplicates := fs.Bool("duplicates", false, "flush: also add the queued tracks the playlist already holds")
+	dryRun := fs.Bool("dry-run", false, "flush: print the changes without making them or emptying the queue")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "flush: user access token with the "+modifyScopes+" scopes (or set SPOTIFY_TOKEN)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	usage := errors.New("usage: spdump queue add <track>... | list | flush --playlist <id> [--dry-run] | clear")
+	if fs.NArg() == 0 {
+		return usage
+	}
+	if *file == "" {
+		if *file = defaultQueueFile(); *file == "" {
+			return errors.New("no user config directory for the queue, pass --file")
+		}
+	}
+	q, err := queue.Open(*file)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "add":
+		if fs.NArg() < 2 && *fromFile == "" {
+			return errors.New("usage: spdump queue add <track>... [--from-file uris.txt]")
+		}
+		tracks, err := trackFileArgs(fs.Args()[1:], *fromFile)
+		if err != nil {
+			return err
+		}
+		n := q.Add(tracks...)
+		if err := q.Save(); err != nil {
+			return err
+		}
+		slog.Info("queued tracks", "tracks", n, "already_queued", len(tracks)-n, "queue", len(q.Items()))
+		return nil
+
+	case "list":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		items := q.Items()
+		t := table.New("#", "URI", "QUEUED").Fixed("URI")
+		for i, item := range items {
+			t.Add(fmt.Sprint(i+1), item.URI(), item.Added.Local().Format(time.DateTime))
+		}
+		return output.print(items, t)
+
+	case "flush":
+		if fs.NArg() != 1 || *playlist == "" {
+			return errors.New("usage: spdump queue flush --playlist <id> [--dry-run]")
+		}
+		if len(q.Items()) == 0 {
+			slog.Info("the queue is empty", "file", *file)
+			return nil
+		}
+		sp, err := newUserSpotify(*token)
+		if err != nil {
+			return err
+		}
+		return flushQueue(commandContext(), sp, q, *playlist, *duplicates, *dryRun)
+
+	case "clear":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		slog.Info("emptied the queue", "tracks", len(q.Items()))
+		q.Clear()
+		return q.Save()
+	}
+	return usage
+}
+
+// flushQueue adds the queued tracks to the playlist in one batched write,
+// those it already holds left out unless duplicates is set, and empties
+// the queue. A dry run only prints the change.
+func flushQueue(ctx context.Context, sp *spotify.Client, q *queue.Queue, playlistID string, duplicates bool, dryRun bool) error {
+	err := modifyPlaylist(ctx, sp, playlistID, func(playlist spotify.SpotifyPlaylist) ([]writeback.Op, error) {
+		tracks := q.Tracks()
+		if !duplicates {
+			tracks = notOnPlaylist(tracks, spotify.ConvertToMusicPlaylist(playlist))
+		}
+		if skipped := len(q.Items()) - len(tracks); skipped > 0 {
+			slog.Info("skipping queued tracks the playlist already holds", "playlist", playlist.Name, "tracks", skipped)
+		}
+		if len(tracks) == 0 {
+			return nil, nil
+		}
+		return []writeback.Op{{Kind: writeback.Add, PlaylistID: playlist.IntegrationID, Name: playlist.Name, Tracks: tracks}}, nil
+	}, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		slog.Info("dry run, the queue is kept")
+		return nil
+	}
+	q.Clear()
+	return q.Save()
+}
+
+// notOnPlaylist returns the tracks mp doesn't hold, by URI.
+func notOnPlaylist(tracks []spotify.MusicTrack, mp spotify.MusicPlaylist) []spotify.MusicTrack {
+	held := map[string]bool{}
+	for i := range mp.Tracks {
+		held[mp.Tracks[i].URI()] = true
+	}
+	var left []spotify.MusicTrack
+	for i := range tracks {
+		if !held[tracks[i].URI()] {
+			left = append(left, tracks[i])
+		}
+	}
+	return left
+}
+
+// defaultQueueFile returns the queue file, [queue] file in config.toml or
+// queue.json in spdump's user config directory. Unlike the retry queue it
+// is kept out of the cache directory, as it holds the user's picks.
+func defaultQueueFile() string {
+	if config, err := loadConfig(); err == nil {
+		if file, _ := config.Get("queue.file").(string); file != "" {
+			return file
+		}
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "spdump", "queue.json")
+}
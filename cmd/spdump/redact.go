@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/pyrat/spd/internal/redact"
+)
+
+// redactionRules returns the redaction rules of --redact, each field or
+// field:hash, or else those of [redact] in config.toml, nil when there are
+// none:
+//
+//	[redact]
+//	drop = ["external_url", "owner.name"]
+//	hash = ["added_by", "owner.id"]
+//	key_cmd = "pass show spdump/redact-key"
+//
+// The key hashes are made with comes from config.toml either way, as key,
+// key_cmd or key_vault.
+func redactionRules(specs []string) (*redact.Rules, error) {
+	config, err := loadConfig()
+	if errors.Is(err, fs.ErrNotExist) {
+		config = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var rules []redact.Rule
+	for _, spec := range specs {
+		rule, err := redact.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if len(specs) == 0 && config != nil {
+		for _, action := range []string{redact.Drop, redact.Hash} {
+			for _, name := range configStrings(config, "redact."+action) {
+				rules = append(rules, redact.Rule{Field: name, Action: action})
+			}
+		}
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var key string
+	if config != nil {
+		if key, err = configSecret(config, "redact.key"); err != nil {
+			return nil, err
+		}
+	}
+	return redact.New(rules, []byte(key))
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pyrat/spd/internal/color"
+	"github.com/pyrat/spd/internal/diff"
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/pkg/spotify"
+	"github.com/pyrat/spd/pkg/stats"
+	flag "github.com/spf13/pflag"
+)
+
+// replHelp lists the commands of the REPL.
+const replHelp = `search <query> [-t playlist] [-l 10]  search the catalog
+dump <playlist>... [-f format] [-o file]  dump playlists, to stdout without -o
+diff <old> <new>                          tracks added, removed and moved between two playlists
+stats <playlist>... [--top 10]            duplicates, running time, artists and decades
+forget [<playlist>...]                    drop playlists from the session, all without any
+history                                   commands run, !<n> runs one again
+help                                      this list
+exit                                      leave, as does Ctrl-D
+
+Playlists are IDs, links or URIs, or dump files. Ctrl-C stops the running command.
+`
+
+// repl is an interactive session, keeping one client, its token and the
+// playlists it fetched between commands.
+type repl struct {
+	sp      *spotify.Client
+	opts    dumpOptions
+	out     io.Writer
+	history []string
+	// historyFile is appended each command, none when nil.
+	historyFile *os.File
+	// playlists are the playlists fetched this session by ID, diff and
+	// stats fetch each once.
+	playlists map[string]spotify.MusicPlaylist
+}
+
+// runRepl runs an interactive shell over one client, so exploring the
+// catalog doesn't pay for a token and startup with every command. The
+// API's responses are cached on disk across sessions and the commands are
+// kept in a history file.
+//
+//	spdump repl
+//	spdump> search discover weekly -t playlist
+//	spdump> stats 37i9dQZF1DXcBWIGoYBM5M
+func runRepl(args []string) error {
+	commandSignals = []os.Signal{syscall.SIGTERM}
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	cacheDir := fs.String("cache-dir", "", "cache API responses in this directory, revalidated by ETag (defaults to [cache] dir in config.toml, or spdump/responses in the user cache directory)")
+	historyPath := fs.String("history", defaultReplHistory(), "file the commands are kept in, empty to keep none")
+	concurrency := registerConcurrencyFlags(fs)
+	parseFlags(fs, args)
+
+	opts := concurrency.options()
+	if *cacheDir == "" {
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if dir, _ := config.Get("cache.dir").(string); dir == "" {
+			if dir, err := os.UserCacheDir(); err == nil {
+				*cacheDir = filepath.Join(dir, "spdump", "responses")
+			}
+		}
+	}
+	if *cacheDir != "" {
+		opts = append(opts, spotify.WithCache(*cacheDir))
+	}
+	sp, err := newSpotifyFromConfig(opts...)
+	if err != nil {
+		return err
+	}
+	redaction, err := redactionRules(nil)
+	if err != nil {
+		return err
+	}
+
+	r := &repl{
+		sp:        sp,
+		opts:      dumpOptions{Format: formatJSON, Concurrency: concurrency.playlists(), Pair: versionPairer(sp), Fields: exportFields{Redact: redaction}},
+		out:       os.Stdout,
+		playlists: map[string]spotify.MusicPlaylist{},
+	}
+	if *historyPath != "" {
+		if err := r.openHistory(*historyPath); err != nil {
+			return err
+		}
+		defer r.historyFile.Close()
+	}
+	return r.run(os.Stdin)
+}
+
+// defaultReplHistory returns the history file in the user's config
+// directory.
+func defaultReplHistory() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "spdump", "repl_history")
+}
+
+// openHistory loads the commands of earlier sessions and opens the file
+// to append this session's.
+func (o *repl) openHistory(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				o.history = append(o.history, line)
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	o.historyFile = f
+	return nil
+}
+
+// run reads and runs commands until exit or the end of in. Ctrl-C cancels
+// the command running rather than ending the session.
+func (o *repl) run(in *os.File) error {
+	interactive := isTerminal(in)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for {
+		if interactive {
+			fmt.Fprint(o.out, "spdump> ")
+		}
+		if !scanner.Scan() {
+			if interactive {
+				fmt.Fprintln(o.out)
+			}
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			n, err := strconv.Atoi(line[1:])
+			if err != nil || n < 1 || n > len(o.history) {
+				fmt.Fprintf(os.Stderr, "no command %s in the history\n", line)
+				continue
+			}
+			line = o.history[n-1]
+			fmt.Fprintln(o.out, line)
+		}
+		o.remember(line)
+
+		args, err := splitLine(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+
+		// an interrupt from before the command doesn't cancel it
+		select {
+		case <-interrupts:
+		default:
+		}
+		// --timeout and SIGTERM end the session, Ctrl-C only the command
+		ctx, cancel := context.WithCancel(commandContext())
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-interrupts:
+				cancel()
+			case <-done:
+			}
+		}()
+		err = o.exec(ctx, args)
+		close(done)
+		cancel()
+		if err := commandContext().Err(); err != nil {
+			return err
+		}
+		if errors.Is(err, context.Canceled) {
+			err = errors.New("interrupted")
+		}
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// remember adds a command to the history.
+func (o *repl) remember(line string) {
+	if len(o.history) > 0 && o.history[len(o.history)-1] == line {
+		return
+	}
+	o.history = append(o.history, line)
+	if o.historyFile != nil {
+		fmt.Fprintln(o.historyFile, line)
+	}
+}
+
+// exec runs a command.
+func (o *repl) exec(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "search":
+		return o.search(ctx, args[1:])
+	case "dump":
+		return o.dump(ctx, args[1:])
+	case "diff":
+		return o.diff(ctx, args[1:])
+	case "stats":
+		return o.stats(ctx, args[1:])
+	case "forget":
+		return o.forget(args[1:])
+	case "history":
+		for i, line := range o.history {
+			fmt.Fprintf(o.out, "%5d  %s\n", i+1, line)
+		}
+		return nil
+	case "help", "?":
+		fmt.Fprint(o.out, replHelp)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, try help", args[0])
+}
+
+// flags returns the flag set of a command, reporting errors rather than
+// exiting.
+func (o *repl) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(o.out)
+	return fs
+}
+
+func (o *repl) search(ctx context.Context, args []string) error {
+	fs := o.flags("search")
+	types := fs.StringSliceP("type", "t", spotify.SearchTypes, "types to search for: album, artist, playlist, track")
+	limit := fs.IntP("limit", "l", 10, "results per type, at most 50")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: search <query> [-t playlist] [-l 10]")
+	}
+	result, err := o.sp.Search(ctx, strings.Join(fs.Args(), " "), spotify.SearchOptions{Types: *types, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return writeTable(o.out, searchTable(searchHits(result)))
+}
+
+func (o *repl) dump(ctx context.Context, args []string) error {
+	fs := o.flags("dump")
+	format := fs.StringP("format", "f", formatJSON, "output format: json, ndjson, ndjson-tracks, csv, markdown, html, portable or uris")
+	output := fs.StringP("output", "o", "", "write the dump to this file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: dump <playlist>... [-f format] [-o file]")
+	}
+	if _, ok := formatExtensions[*format]; !ok {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	ids := make([]string, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := spotify.ParseID(arg, spotify.TypePlaylist)
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	opts := o.opts
+	opts.Format = *format
+	if *output == "" {
+		return writePlaylists(ctx, o.out, o.sp, ids, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writePlaylists(ctx, f, o.sp, ids, opts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.out, "wrote %d playlists to %s\n", len(ids), *output)
+	return nil
+}
+
+// load returns the playlists of a dump file, or the playlist given by ID,
+// link or URI, fetched once a session.
+func (o *repl) load(ctx context.Context, arg string) ([]spotify.MusicPlaylist, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return dump.ReadFile(arg)
+	}
+	id, err := spotify.ParseID(arg, spotify.TypePlaylist)
+	if err != nil {
+		return nil, err
+	}
+	if mp, ok := o.playlists[id]; ok {
+		return []spotify.MusicPlaylist{mp}, nil
+	}
+	playlists, err := collectPlaylists(ctx, o.sp, []string{id}, o.opts)
+	if err != nil {
+		return nil, err
+	}
+	o.playlists[id] = playlists[0]
+	return playlists, nil
+}
+
+// loadOne returns the single playlist arg refers to.
+func (o *repl) loadOne(ctx context.Context, arg string) (spotify.MusicPlaylist, error) {
+	playlists, err := o.load(ctx, arg)
+	if err != nil {
+		return spotify.MusicPlaylist{}, err
+	}
+	if len(playlists) != 1 {
+		return spotify.MusicPlaylist{}, fmt.Errorf("%s holds %d playlists, diff compares one with another", arg, len(playlists))
+	}
+	return playlists[0], nil
+}
+
+func (o *repl) diff(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: diff <old> <new>")
+	}
+	old, err := o.loadOne(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	new, err := o.loadOne(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	// compared as versions of the same playlist, even when they aren't
+	new.IntegrationID = old.IntegrationID
+	changes := diff.Compare([]spotify.MusicPlaylist{old}, []spotify.MusicPlaylist{new})
+	if changes.Empty() {
+		fmt.Fprintln(o.out, "no changes")
+		return nil
+	}
+
+	paint := stdoutColor.Paint
+	for _, change := range changes.Playlists {
+		for _, field := range change.Metadata {
+			fmt.Fprintln(o.out, paint(color.Changed, fmt.Sprintf("~ %s: %s -> %s", field.Field, field.Old, field.New)))
+		}
+		for _, track := range change.Added {
+			fmt.Fprintln(o.out, paint(color.Added, fmt.Sprintf("+ %s - %s", track.Artists, track.Name)))
+		}
+		for _, track := range change.Removed {
+			fmt.Fprintln(o.out, paint(color.Removed, fmt.Sprintf("- %s - %s", track.Artists, track.Name)))
+		}
+		for _, move := range change.Moved {
+			fmt.Fprintln(o.out, paint(color.Moved, fmt.Sprintf("= %s - %s (%d -> %d)", move.Track.Artists, move.Track.Name, move.From+1, move.To+1)))
+		}
+		for _, edit := range change.Edited {
+			for _, field := range edit.Fields {
+				fmt.Fprintln(o.out, paint(color.Changed, fmt.Sprintf("~ %s - %s: %s %s -> %s", edit.Track.Artists, edit.Track.Name, field.Field, field.Old, field.New)))
+			}
+		}
+	}
+	added, removed, moved := changes.Totals()
+	_, err = fmt.Fprintf(o.out, "\n%d added, %d removed, %d moved, %d edited\n", added, removed, moved, changes.Edited())
+	return err
+}
+
+func (o *repl) stats(ctx context.Context, args []string) error {
+	fs := o.flags("stats")
+	top := fs.Int("top", 10, "artists to list, 0 for all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: stats <playlist>... [--top 10]")
+	}
+	var playlists []spotify.MusicPlaylist
+	for _, arg := range fs.Args() {
+		loaded, err := o.load(ctx, arg)
+		if err != nil {
+			return err
+		}
+		playlists = append(playlists, loaded...)
+	}
+	return printAnalysis(o.out, stats.Analyze(playlists, stats.Options{}), *top, false)
+}
+
+// forget drops playlists fetched this session, for them to be fetched
+// again, or all of them.
+func (o *repl) forget(args []string) error {
+	if len(args) == 0 {
+		o.playlists = map[string]spotify.MusicPlaylist{}
+		return nil
+	}
+	for _, arg := range args {
+		id, err := spotify.ParseID(arg, spotify.TypePlaylist)
+		if err != nil {
+			return err
+		}
+		delete(o.playlists, id)
+	}
+	return nil
+}
+
+// splitLine splits a command line into words as a shell does, keeping
+// quoted words together and honouring backslash escapes.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return words, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/blocklist"
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runRestore re-creates the playlists of a dump in the authenticated
+// user's account. It needs a user access token with the playlist-modify
+// scopes, from --token or a user token provider under [auth]; client
+// credentials are not allowed to write to an account.
+//
+//	spdump restore dump.json --name "Restored"
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	name := fs.StringP("name", "n", "", "name of the new playlist, for a dump of one playlist (defaults to the dumped name)")
+	public := fs.Bool("public", false, "make the new playlists public")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with playlist-modify scopes, instead of a user token provider under [auth] (or set SPOTIFY_TOKEN)")
+	blocklistFile := fs.String("blocklist", blocklist.DefaultFile, "leave out the artists, tracks and labels blocked in this file")
+	policyFlags := registerPolicyFlags(fs)
+	parseFlags(fs, args)
+
+	policy, err := policyFlags.policy()
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: spdump restore <dump.json> [--name name]")
+	}
+
+	playlists, err := dump.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *name != "" && len(playlists) != 1 {
+		return fmt.Errorf("--name only goes with a dump of one playlist, %s holds %d", fs.Arg(0), len(playlists))
+	}
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	list, err := blocklist.Load(*blocklistFile)
+	if err != nil {
+		return err
+	}
+	user, err := sp.CurrentUser(ctx)
+	if err != nil {
+		return scopeError(err)
+	}
+
+	for _, mp := range playlists {
+		playlistName := *name
+		if playlistName == "" {
+			playlistName = mp.Name
+		}
+		if mp.Tracks, err = filterBlocked(ctx, sp.NewPlanner(), list, mp.Name, mp.Tracks); err != nil {
+			return err
+		}
+		if mp.Tracks, err = applyPolicy(ctx, policy, versionPairer(sp), mp.Name, mp.Tracks); err != nil {
+			return err
+		}
+		if err := restorePlaylist(ctx, sp, user.IntegrationID, playlistName, *public, playlistURIs(mp)); err != nil {
+			return scopeError(err)
+		}
+	}
+	return nil
+}
+
+// restorePlaylist creates a playlist of the tracks in the user's account.
+// Playlists are capped at 10,000 tracks, anything beyond that goes into
+// continuation playlists. A playlist left part filled by a failure is
+// logged, so it can be finished or removed by hand.
+func restorePlaylist(ctx context.Context, sp *spotify.Client, userID string, name string, public bool, uris []string) error {
+	for n, part := range spotify.SplitPlaylists(uris) {
+		partName := spotify.ContinuationName(name, n)
+
+		playlist, err := sp.CreatePlaylist(ctx, userID, partName, public)
+		if err != nil {
+			return err
+		}
+
+		if err := sp.AddTracksToPlaylist(ctx, playlist.IntegrationID, part); err != nil {
+			var batchErr *spotify.BatchError
+			if errors.As(err, &batchErr) {
+				slog.Error("restored playlist partly", "name", partName, "id", batchErr.PlaylistID, "tracks", batchErr.Applied, "of", len(part))
+			} else {
+				slog.Error("created playlist without its tracks", "name", partName, "id", playlist.IntegrationID)
+			}
+			return err
+		}
+
+		slog.Info("restored playlist", "name", partName, "id", playlist.IntegrationID, "tracks", len(part))
+	}
+	return nil
+}
+
+// playlistURIs returns the URIs of the playlist's tracks which can be
+// added back through the API, warning about the local files left out.
+func playlistURIs(mp spotify.MusicPlaylist) []string {
+	uris, local := writeback.URIs(mp.Tracks)
+	if local > 0 {
+		slog.Warn("skipping local files, they can't be added through the API", "playlist", mp.Name, "tracks", local)
+	}
+	return uris
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/pyrat/spd/internal/retries"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runRetries lists the entities enrichment passes couldn't look up and
+// queued to retry, or retries them all now.
+//
+//	spdump retries list
+//	spdump retries flush
+//	spdump retries flush --discard
+func runRetries(args []string) error {
+	fs := flag.NewFlagSet("retries", flag.ExitOnError)
+	file := fs.String("file", "", "retry queue file (defaults to [retries] file in config.toml, or spdump/retries.json in the user cache directory)")
+	discard := fs.Bool("discard", false, "flush: empty the queue without retrying")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	usage := errors.New("usage: spdump retries list | spdump retries flush [--discard]")
+	if fs.NArg() != 1 {
+		return usage
+	}
+	if *file == "" {
+		if *file = defaultRetryQueue(); *file == "" {
+			return errors.New("no user cache directory for the retry queue, pass --file")
+		}
+	}
+	queue, err := retries.Open(*file)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		items := queue.Items()
+		t := table.New("KIND", "ID", "ATTEMPTS", "LAST FAILED", "ERROR").Fixed("ID")
+		for _, item := range items {
+			attempts := fmt.Sprint(item.Attempts)
+			if item.Exhausted() {
+				attempts += " (given up)"
+			}
+			t.Add(item.Kind, item.ID, attempts, item.LastFailed.Local().Format(time.DateTime), item.Error)
+		}
+		return output.print(items, t)
+
+	case "flush":
+		if *discard {
+			slog.Info("discarded the retry queue", "entities", len(queue.Items()))
+			queue.Clear()
+			return queue.Save()
+		}
+		sp, err := newSpotifyFromConfig()
+		if err != nil {
+			return err
+		}
+		return flushRetries(commandContext(), sp, queue)
+	}
+	return usage
+}
+
+// flushRetries retries every queued entity, those passes gave up on too,
+// taking those found off the queue.
+func flushRetries(ctx context.Context, sp *spotify.Client, queue *retries.Queue) error {
+	var albums, artists, tracks []string
+	for _, item := range queue.Items() {
+		switch item.Kind {
+		case spotify.TypeAlbum:
+			albums = append(albums, item.ID)
+		case spotify.TypeArtist:
+			artists = append(artists, item.ID)
+		case retries.AudioFeatures:
+			tracks = append(tracks, item.ID)
+		}
+	}
+
+	pass := &retryPass{queue: queue}
+	planner := sp.NewPlanner()
+	planner.Tolerate(pass.fail)
+	planner.AddAlbums(albums...)
+	planner.AddArtists(artists...)
+	err := planner.Fetch(ctx)
+	if err == nil {
+		err = pass.audioFeatures(ctx, sp, tracks, nil)
+	}
+	pass.settle(planner)
+	if err != nil {
+		return err
+	}
+	left := len(queue.Items())
+	slog.Info("retried the queued entities", "entities", len(albums)+len(artists)+len(tracks), "found", len(albums)+len(artists)+len(tracks)-left, "left", left)
+	return nil
+}
+
+// defaultRetryQueue returns the retry queue file, [retries] file in
+// config.toml or retries.json in spdump's user cache directory.
+func defaultRetryQueue() string {
+	if config, err := loadConfig(); err == nil {
+		if file, _ := config.Get("retries.file").(string); file != "" {
+			return file
+		}
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "spdump", "retries.json")
+}
+
+// retryPass is an enrichment pass which carries on past the entities it
+// can't look up, queuing them to retry, and retries those queued by the
+// passes before it.
+type retryPass struct {
+	queue  *retries.Queue
+	failed atomic.Int64
+}
+
+// newRetryPass opens the retry queue in file, by default that of
+// defaultRetryQueue. Without one, or one which can't be read, the pass
+// still carries on past failures but queues nothing.
+func newRetryPass(file string) *retryPass {
+	pass := &retryPass{}
+	if file == "" {
+		if file = defaultRetryQueue(); file == "" {
+			return pass
+		}
+	}
+	queue, err := retries.Open(file)
+	if err != nil {
+		slog.Warn("not queuing failed lookups to retry", "file", file, "err", err)
+		return pass
+	}
+	pass.queue = queue
+	return pass
+}
+
+// fail queues an entity which couldn't be looked up.
+func (o *retryPass) fail(kind string, id string, err error) {
+	o.failed.Add(1)
+	slog.Debug("lookup failed, queued to retry", "kind", kind, "id", id, "err", err)
+	o.queue.Failed(kind, id, err)
+}
+
+// plan makes the planner carry on past entities it can't fetch and plans
+// to fetch those of the kind queued earlier.
+func (o *retryPass) plan(planner *spotify.Planner, kind string) {
+	planner.Tolerate(o.fail)
+	switch kind {
+	case spotify.TypeAlbum:
+		planner.AddAlbums(o.queue.Pending(kind)...)
+	case spotify.TypeArtist:
+		planner.AddArtists(o.queue.Pending(kind)...)
+	}
+}
+
+// audioFeatures looks up the audio features of the tracks and of those
+// queued earlier, passing each found to found, which may be nil.
+func (o *retryPass) audioFeatures(ctx context.Context, sp *spotify.Client, ids []string, found func(spotify.SpotifyAudioFeatures)) error {
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range o.queue.Pending(retries.AudioFeatures) {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	return spotify.FetchBatches(ctx, ids, spotify.MaxAudioFeaturesPerRequest, func(ctx context.Context, batch []string) ([]string, error) {
+		list, err := sp.AudioFeatures(ctx, batch)
+		got := make([]string, len(list))
+		for i, f := range list {
+			got[i] = f.IntegrationID
+			o.queue.Done(retries.AudioFeatures, f.IntegrationID)
+			if found != nil {
+				found(f)
+			}
+		}
+		return got, err
+	}, func(id string, err error) {
+		o.fail(retries.AudioFeatures, id, err)
+	})
+}
+
+// settle takes the queued albums and artists the planner fetched off the
+// queue and saves it.
+func (o *retryPass) settle(planner *spotify.Planner) {
+	if planner != nil {
+		for _, item := range o.queue.Items() {
+			var ok bool
+			switch item.Kind {
+			case spotify.TypeAlbum:
+				_, ok = planner.Album(item.ID)
+			case spotify.TypeArtist:
+				_, ok = planner.Artist(item.ID)
+			}
+			if ok {
+				o.queue.Done(item.Kind, item.ID)
+			}
+		}
+	}
+	if err := o.queue.Save(); err != nil {
+		slog.Warn("saving the retry queue", "file", o.queue.Path, "err", err)
+	}
+	if failed := o.failed.Load(); failed > 0 && o.queue != nil {
+		slog.Warn("some lookups failed and are queued to retry", "entities", failed, "file", o.queue.Path)
+	} else if failed > 0 {
+		slog.Warn("some lookups failed", "entities", failed)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// searchHit is a single search result as printed by the search command.
+type searchHit struct {
+	Type string
+	ID   string
+	URI  string
+	Name string
+	By   string `json:",omitempty"`
+}
+
+// runSearch resolves names to IDs which can be fed into the other commands.
+//
+//	spdump search "discover weekly" --type playlist
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	types := fs.StringSliceP("type", "t", spotify.SearchTypes, "types to search for: album, artist, playlist, track")
+	market := fs.String("market", "", "only return results available in this market (country code)")
+	limit := fs.IntP("limit", "l", 10, "results per type, at most 50")
+	offset := fs.Int("offset", 0, "skip this many results per type")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump search <query> [--type playlist] [--market SE] [--limit 10]")
+	}
+
+	sp, err := newSpotifyFromConfig()
+	if err != nil {
+		return err
+	}
+
+	result, err := sp.Search(commandContext(), strings.Join(fs.Args(), " "), spotify.SearchOptions{
+		Types:  *types,
+		Market: *market,
+		Limit:  *limit,
+		Offset: *offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	hits := searchHits(result)
+	return output.print(hits, searchTable(hits))
+}
+
+// searchHits flattens the search result. Spotify occasionally returns null
+// entries in the result lists, those are skipped.
+func searchHits(result spotify.SpotifySearchResult) []searchHit {
+	var hits []searchHit
+	for _, item := range result.Playlists.Items {
+		if item.IntegrationID != "" {
+			hits = append(hits, searchHit{Type: "playlist", ID: item.IntegrationID, URI: item.URI, Name: item.Name})
+		}
+	}
+	for _, item := range result.Albums.Items {
+		if item.IntegrationID != "" {
+			hits = append(hits, searchHit{Type: "album", ID: item.IntegrationID, URI: item.URI, Name: item.Name, By: joinArtists(item.Artists)})
+		}
+	}
+	for _, item := range result.Artists.Items {
+		if item.IntegrationID != "" {
+			hits = append(hits, searchHit{Type: "artist", ID: item.IntegrationID, URI: item.URI, Name: item.Name})
+		}
+	}
+	for _, item := range result.Tracks.Items {
+		if item.IntegrationID != "" {
+			hits = append(hits, searchHit{Type: "track", ID: item.IntegrationID, URI: item.TrackURI, Name: item.Name, By: item.CombineArtists()})
+		}
+	}
+	return hits
+}
+
+// searchTable lists the hits in a table.
+func searchTable(hits []searchHit) *table.Table {
+	t := table.New("TYPE", "ID", "URI", "NAME").Fixed("ID", "URI")
+	for _, hit := range hits {
+		name := hit.Name
+		if hit.By != "" {
+			name += " - " + hit.By
+		}
+		t.Add(hit.Type, hit.ID, hit.URI, name)
+	}
+	return t
+}
+
+// joinArtists joins the artist names with commas.
+func joinArtists(artists []spotify.SpotifyArtist) string {
+	names := make([]string, 0, len(artists))
+	for _, artist := range artists {
+		names = append(names, artist.Name)
+	}
+	return strings.Join(names, ", ")
+}
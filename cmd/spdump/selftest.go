@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pyrat/spd/internal/fixtures"
+	"github.com/pyrat/spd/internal/mockapi"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// selftest holds what the steps of spdump selftest share.
+type selftest struct {
+	ctx     context.Context
+	mock    *mockapi.Server
+	options []spotify.Option
+	library []spotify.MusicPlaylist
+	ids     []string
+	dir     string
+
+	sp     *spotify.Client
+	dumped []spotify.MusicPlaylist
+}
+
+// selftestStep is a step of spdump selftest, failing with an error.
+type selftestStep struct {
+	Name string
+	Run  func(t *selftest) error
+}
+
+// selftestSteps run in order, a step failing skips those after it as
+// they build on it.
+var selftestSteps = []selftestStep{
+	{"token", (*selftest).token},
+	{"list", (*selftest).list},
+	{"dump", (*selftest).dump},
+	{"enrich", (*selftest).enrich},
+	{"export", (*selftest).export},
+	{"bundle", (*selftest).bundle},
+	{"restore", (*selftest).restore},
+}
+
+// runSelftest runs a dump, enrich, export and restore cycle against a mock
+// of the Spotify API serving a generated library, see gen-fixtures, and
+// reports which steps passed. It needs neither an account, config.toml
+// nor network access, for checking an install or a package works.
+//
+//	spdump selftest
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	keep := fs.Bool("keep", false, "keep the files written by the export and bundle steps, in a temporary directory")
+	parseFlags(fs, args)
+
+	library := fixtures.Generate(fixtures.Options{Playlists: 4, Tracks: 150, Seed: 1})
+	mock := mockapi.New(library)
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	dir, err := os.MkdirTemp("", "spdump-selftest-")
+	if err != nil {
+		return err
+	}
+	if *keep {
+		slog.Info("keeping selftest files", "dir", dir)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+
+	t := &selftest{
+		ctx:     commandContext(),
+		mock:    mock,
+		options: []spotify.Option{spotify.WithBaseURL(srv.URL + "/v1"), spotify.WithAuthURL(srv.URL + "/api/token")},
+		library: library,
+		dir:     dir,
+	}
+	for _, mp := range library {
+		t.ids = append(t.ids, mp.IntegrationID)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tRESULT\tTIME\tDETAIL")
+	failed := 0
+	for _, step := range selftestSteps {
+		if failed > 0 {
+			fmt.Fprintf(tw, "%s\tskip\t\t\n", step.Name)
+			continue
+		}
+		start := time.Now()
+		if err := step.Run(t); err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tFAIL\t%s\t%s\n", step.Name, time.Since(start).Round(time.Millisecond), err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tok\t%s\t\n", step.Name, time.Since(start).Round(time.Millisecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.New("selftest failed")
+	}
+	slog.Info("selftest passed", "steps", len(selftestSteps), "requests", mock.Requests())
+	return nil
+}
+
+// token gets an app token through the client credentials flow.
+func (o *selftest) token() error {
+	var err error
+	o.sp, err = spotify.NewClient(o.ctx, "selftest", "selftest", o.options...)
+	if err != nil {
+		return err
+	}
+	trackUsage(o.sp)
+	return nil
+}
+
+// list lists the owner's public playlists, as --user does.
+func (o *selftest) list() error {
+	owner := o.library[0].Owner.IntegrationID
+	listed, err := o.sp.UserPublicPlaylists(o.ctx, owner, false)
+	if err != nil {
+		return err
+	}
+	want := 0
+	for _, mp := range o.library {
+		if mp.Public != nil && *mp.Public && !mp.Collaborative {
+			want++
+		}
+	}
+	if len(listed) != want {
+		return fmt.Errorf("listed %d public playlists, expected %d", len(listed), want)
+	}
+	return nil
+}
+
+// dump dumps every playlist and compares it with the library.
+func (o *selftest) dump() error {
+	opts := dumpOptions{Format: formatJSON, Concurrency: 2, Location: time.UTC}
+	err := fetchPlaylists(o.ctx, o.sp, o.ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(o.ctx, playlist)
+		o.dumped = append(o.dumped, mp)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for i, mp := range o.dumped {
+		if err := comparePlaylists(o.library[i], mp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// comparePlaylists checks a dumped playlist came through as generated.
+func comparePlaylists(want spotify.MusicPlaylist, got spotify.MusicPlaylist) error {
+	if got.Name != want.Name || got.Description != want.Description {
+		return fmt.Errorf("playlist %s: dumped as %q, expected %q", want.IntegrationID, got.Name, want.Name)
+	}
+	if len(got.Tracks) != len(want.Tracks) {
+		return fmt.Errorf("playlist %s: %d tracks dumped, expected %d", want.IntegrationID, len(got.Tracks), len(want.Tracks))
+	}
+	for i, track := range got.Tracks {
+		expected := want.Tracks[i]
+		if track.URI() != expected.URI() || track.Name != expected.Name || track.Unavailable != expected.Unavailable || track.Source != expected.Source || track.LinkedFrom != expected.LinkedFrom {
+			return fmt.Errorf("playlist %s: track %d dumped as %s %q (%s), expected %s %q (%s)", want.IntegrationID, i+1, track.URI(), track.Name, track.Unavailable, expected.URI(), expected.Name, expected.Unavailable)
+		}
+	}
+	return nil
+}
+
+// enrich dumps the playlists again looking up the genres of their
+// tracks, as --genres does.
+func (o *selftest) enrich() error {
+	opts := dumpOptions{Format: formatJSON, Concurrency: 2, Location: time.UTC, Genres: o.sp.NewPlanner()}
+	i := 0
+	return fetchPlaylists(o.ctx, o.sp, o.ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(o.ctx, playlist)
+		if err != nil {
+			return err
+		}
+		want := o.library[i]
+		i++
+		for j, track := range mp.Tracks {
+			if len(want.Tracks[j].ArtistList) > 0 && want.Tracks[j].Source != spotify.SourceLocal && len(track.Genres) == 0 {
+				return fmt.Errorf("playlist %s: no genres found for track %d %q", mp.IntegrationID, j+1, track.Name)
+			}
+		}
+		if len(mp.Tracks) > 0 && len(mp.Genres) == 0 {
+			return fmt.Errorf("playlist %s: no genres counted", mp.IntegrationID)
+		}
+		return nil
+	})
+}
+
+// export writes the dump in every format, checking the json one against
+// the dump's schema.
+func (o *selftest) export() error {
+	formats := []string{formatJSON, formatNDJSON, formatNDJSONTracks, formatCSV, formatMarkdown, formatHTML, formatPortable, formatURIs}
+	for _, format := range formats {
+		var buf bytes.Buffer
+		opts := dumpOptions{Format: format, Concurrency: 2, Location: time.UTC}
+		if err := writePlaylists(o.ctx, &buf, o.sp, o.ids, opts); err != nil {
+			return fmt.Errorf("%s: %w", format, err)
+		}
+		if buf.Len() == 0 {
+			return fmt.Errorf("%s: nothing written", format)
+		}
+		if err := os.WriteFile(filepath.Join(o.dir, format+formatExtensions[format]), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+		if format != formatJSON {
+			continue
+		}
+		var playlists []interface{}
+		if err := json.Unmarshal(buf.Bytes(), &playlists); err != nil {
+			return fmt.Errorf("%s: %w", format, err)
+		}
+		for _, playlist := range playlists {
+			if err := fixtures.Validate(playlist); err != nil {
+				return fmt.Errorf("%s: %w", format, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bundle writes a bundle and verifies it against its manifest.
+func (o *selftest) bundle() error {
+	path := filepath.Join(o.dir, "selftest.tar.zst")
+	opts := dumpOptions{Format: formatJSON, Concurrency: 2, Location: time.UTC}
+	if err := writeBundle(o.ctx, path, "", "selftest", o.sp, o.ids, opts); err != nil {
+		return err
+	}
+	_, problems, err := verifyBundle(path)
+	if err != nil {
+		return err
+	}
+	return errors.Join(problems...)
+}
+
+// restore re-creates the first dumped playlist with tracks in the mock
+// user's account, as spdump restore does, and reads it back.
+func (o *selftest) restore() error {
+	sp := trackUsage(spotify.NewClientWithToken(mockapi.Token, o.options...))
+	var mp spotify.MusicPlaylist
+	for _, dumped := range o.dumped {
+		if len(dumped.Tracks) > 0 {
+			mp = dumped
+			break
+		}
+	}
+	uris := playlistURIs(mp)
+
+	user, err := sp.CurrentUser(o.ctx)
+	if err != nil {
+		return err
+	}
+	playlist, err := sp.CreatePlaylist(o.ctx, user.IntegrationID, mp.Name+" (restored)", false)
+	if err != nil {
+		return err
+	}
+	if err := sp.AddTracksToPlaylist(o.ctx, playlist.IntegrationID, uris); err != nil {
+		return err
+	}
+
+	restored, ok := o.mock.Playlist(playlist.IntegrationID)
+	if !ok || len(restored.Tracks) != len(uris) {
+		return fmt.Errorf("restored %d of %d tracks", len(restored.Tracks), len(uris))
+	}
+	for i, track := range restored.Tracks {
+		if track.URI() != uris[i] {
+			return fmt.Errorf("restored track %d is %s, expected %s", i+1, track.URI(), uris[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/job"
+	"github.com/pyrat/spd/internal/server"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runServe runs spdump as a long lived server, a read-only mirror of the
+// archive over a REST API. Given a secret, webhooks, e.g. from IFTTT or a
+// home automation, trigger a dump of a playlist into the archive:
+//
+//	spdump serve --addr :8080 --webhook-secret s3cret
+//	curl localhost:8080/search?q=daft+punk
+//	curl -X POST -H 'X-Webhook-Secret: s3cret' localhost:8080/hooks/refresh/focus
+//
+// The progress of syncs and refreshes writing to the archive is shown at
+// /progress.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	archiveDir := fs.String("archive", "archive", "archive directory to serve and write refreshed playlists to")
+	secret := fs.String("webhook-secret", os.Getenv("SPDUMP_WEBHOOK_SECRET"), "shared secret webhooks must present, webhooks are off without one (or set SPDUMP_WEBHOOK_SECRET)")
+	minInterval := fs.Duration("webhook-interval", time.Minute, "least time between two webhook refreshes of a playlist")
+	maxRefreshes := fs.Int("webhook-max", 10, "most webhook refreshes of all playlists together within --webhook-interval, 0 for no limit")
+	parseFlags(fs, args)
+
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+	jobs, err := openJobs(arc)
+	if err != nil {
+		return err
+	}
+	opts := server.Options{
+		Archive:      arc,
+		Secret:       *secret,
+		MinInterval:  *minInterval,
+		MaxRefreshes: *maxRefreshes,
+		Jobs:         jobs,
+	}
+
+	// webhooks need API access and the playlist names, the
+	// read-only API works without a config file.
+	if *secret != "" {
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		opts.Playlists = map[string]string{}
+		if aliases, ok := config.Get("playlists").(*toml.Tree); ok {
+			for name, id := range aliases.ToMap() {
+				if id, ok := id.(string); ok {
+					opts.Playlists[name] = id
+				}
+			}
+		}
+
+		sp, err := newSpotifyFromConfig()
+		if err != nil {
+			return err
+		}
+		redaction, err := redactionRules(nil)
+		if err != nil {
+			return err
+		}
+		opts.Refresh = func(ctx context.Context, playlistID string) error {
+			run, err := jobs.Create("refresh", "playlist-"+playlistID, []job.Entity{{ID: playlistID}})
+			if err != nil {
+				return err
+			}
+			err = archivePlaylist(ctx, sp, arc, playlistID, dumpOptions{Fields: exportFields{Redact: redaction}})
+			if err == nil {
+				err = run.Complete(playlistID, 0, nil)
+			} else {
+				run.Fail(playlistID, err)
+			}
+			if finishErr := run.Finish(err); err == nil {
+				err = finishErr
+			}
+			return err
+		}
+	}
+
+	srv := server.New(opts)
+	defer srv.Close()
+
+	ctx := commandContext()
+
+	httpServer := &http.Server{Addr: *addr, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving", "addr", *addr, "archive", *archiveDir, "webhooks", *secret != "")
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// archivePlaylist writes a snapshot of a single playlist into its own
+// collection of the archive.
+func archivePlaylist(ctx context.Context, sp *spotify.Client, arc *archive.Archive, playlistID string, opts dumpOptions) error {
+	playlist, err := sp.PlaylistFromID(ctx, playlistID)
+	if err != nil {
+		return err
+	}
+	mp, err := opts.convertPlaylist(ctx, playlist)
+	if err != nil {
+		return err
+	}
+
+	w, err := arc.NewSnapshot("playlist-"+mp.IntegrationID, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := w.WritePlaylist(mp); err != nil {
+		return err
+	}
+	snapshot, err := w.Close()
+	if err != nil {
+		return err
+	}
+	slog.Info("archived playlist", "playlist", mp.IntegrationID, "name", mp.Name, "tracks", len(mp.Tracks), "dir", snapshot.Dir)
+	return nil
+}
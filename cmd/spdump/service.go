@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/pyrat/spd/internal/service"
+	flag "github.com/spf13/pflag"
+)
+
+// daemons are the commands that run as a service.
+var daemons = map[string]func(args []string) error{
+	"sync":  runSync,
+	"serve": runServe,
+}
+
+// defaultDaemon is the command installed as a service when none is given.
+var defaultDaemon = []string{"sync", "--interval", "6h"}
+
+// runService registers a daemon, sync --interval 6h unless another
+// command follows --, with the platform's service manager: a launchd agent
+// on macOS, a Windows service, or a systemd user unit elsewhere. It also
+// reports on and removes the service. run is what the Windows service
+// manager starts.
+//
+//	spdump service install -- sync --interval 1h --user spotifyuser
+//	spdump service install --name mirror -- serve --addr :8080
+//	spdump service status
+//	spdump service uninstall
+func runService(args []string) error {
+	fs := flag.NewFlagSet("service", flag.ExitOnError)
+	name := fs.String("name", "spdump", "name of the service, to install several")
+	dir := fs.String("dir", "", "working directory of the daemon, where config.toml and the archive are (defaults to the current directory)")
+	logDir := fs.String("log-dir", "", `directory the daemon logs to (defaults to ~/Library/Logs/spdump on macOS and %ProgramData%\spdump\logs on Windows, systemd keeps logs in the journal)`)
+	printOnly := fs.Bool("print", false, "with install, only print the systemd unit, launchd agent or sc.exe command")
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump service install|uninstall|status [--name spdump] [-- sync --interval 6h]")
+	}
+	action, daemon := fs.Arg(0), fs.Args()[1:]
+	switch action {
+	case "install":
+		c, err := serviceConfig(*name, *dir, *logDir, daemon)
+		if err != nil {
+			return err
+		}
+		if *printOnly {
+			definition, err := service.Definition(c)
+			fmt.Print(definition)
+			return err
+		}
+		where, err := service.Install(c)
+		if err != nil {
+			return err
+		}
+		log := c.LogFile()
+		if log == "" {
+			log = "journalctl --user -u " + c.Name
+		}
+		slog.Info("installed service", "name", c.Name, "definition", where, "log", log)
+	case "uninstall":
+		if err := service.Uninstall(*name); err != nil {
+			return fmt.Errorf("%s: %w", *name, err)
+		}
+		slog.Info("uninstalled service", "name", *name)
+	case "status":
+		status, err := service.Status(*name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *name, err)
+		}
+		fmt.Print(status)
+	case "run":
+		return runServiceDaemon(*name, *dir, *logDir, daemon)
+	default:
+		return fmt.Errorf("unknown service action %q, expected install, uninstall or status", action)
+	}
+	return nil
+}
+
+// serviceConfig describes the service running daemon, defaultDaemon when
+// empty. The daemon gets the --profile of the service command, and logs
+// with timestamps where it logs to a file.
+func serviceConfig(name string, dir string, logDir string, daemon []string) (service.Config, error) {
+	if len(daemon) == 0 {
+		daemon = defaultDaemon
+	}
+	if _, ok := daemons[daemon[0]]; !ok {
+		return service.Config{}, fmt.Errorf("%q can't run as a service, only sync and serve can", daemon[0])
+	}
+	if profile != "" && !hasFlag(daemon, "--profile") {
+		daemon = append(daemon, "--profile", profile)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return service.Config{}, err
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return service.Config{}, err
+	}
+	if logDir != "" {
+		if logDir, err = filepath.Abs(logDir); err != nil {
+			return service.Config{}, err
+		}
+	}
+
+	c := service.Config{Name: name, Executable: exe, Args: daemon, Dir: dir, LogDir: logDir}
+	// text logs leave out timestamps, which a log file needs
+	if c.LogFile() != "" && !hasFlag(daemon, "--log-format") {
+		c.Args = append(c.Args, "--log-format", "json")
+	}
+	return c, nil
+}
+
+// hasFlag reports whether args set the flag, as --flag value or
+// --flag=value.
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag || len(arg) > len(flag) && arg[:len(flag)+1] == flag+"=" {
+			return true
+		}
+	}
+	return false
+}
+
+// runServiceDaemon runs the daemon as the Windows service manager starts
+// it, in the service's directory and logging to its log file, stopping it
+// when the service is stopped.
+func runServiceDaemon(name string, dir string, logDir string, daemon []string) error {
+	if len(daemon) == 0 {
+		return errors.New("usage: spdump service run --name spdump -- sync|serve [flags]")
+	}
+	if err := service.CheckName(name); err != nil {
+		return err
+	}
+	cmd, ok := daemons[daemon[0]]
+	if !ok {
+		return fmt.Errorf("%q can't run as a service, only sync and serve can", daemon[0])
+	}
+	if dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+	}
+
+	c := service.Config{Name: name, LogDir: logDir}
+	if log := c.LogFile(); log != "" {
+		if err := os.MkdirAll(filepath.Dir(log), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// the daemon sets up its logger to the new stderr
+		os.Stdout, os.Stderr = f, f
+	}
+
+	// the context exists before the service manager may stop it
+	commandContext()
+	return service.Run(name, func() error { return cmd(daemon[1:]) }, interruptCommand)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/bundle"
+	"github.com/pyrat/spd/internal/manifest"
+	"github.com/pyrat/spd/internal/storage"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// shardOf returns the shard of n a track goes to: the FNV-1a hash of its
+// ID modulo n, so downstream jobs can find a track's shard themselves.
+// Tracks without an ID, such as some local files, hash their URL.
+func shardOf(track spotify.MusicTrack, n int) int {
+	key := track.IntegrationID
+	if key == "" {
+		key = track.ExternalURL + "\x00" + track.Artists + "\x00" + track.Name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// shardName names shard i of n, e.g. tracks-00003-of-00016.ndjson.
+func shardName(i int, n int, c bundle.Compression) string {
+	return fmt.Sprintf("tracks-%05d-of-%05d.ndjson%s", i, n, c.Extension())
+}
+
+// shard is an open shard file.
+type shard struct {
+	name   string
+	file   *os.File
+	buf    *bytes.Buffer
+	bw     *bufio.Writer
+	hash   hash.Hash
+	cw     io.WriteCloser
+	enc    *json.Encoder
+	tracks int
+}
+
+// writeShards writes the tracks of the playlists as ndjson-tracks lines
+// partitioned into n shards by track ID, into a directory or bucket
+// location, along with a manifest of their checksums. Local shards are
+// streamed to their files, remote ones are uploaded once complete.
+func writeShards(ctx context.Context, location string, n int, c bundle.Compression, sp *spotify.Client, ids []string, opts dumpOptions) error {
+	if location == "" {
+		location = "."
+	}
+	// the location is always a directory or prefix
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+	backend, err := storage.Open(location)
+	if err != nil {
+		return err
+	}
+	local, isLocal := backend.(storage.Local)
+	if isLocal {
+		if err := os.MkdirAll(local.Root, 0o755); err != nil {
+			return err
+		}
+	}
+
+	shards := make([]*shard, n)
+	for i := range shards {
+		s := &shard{name: shardName(i, n, c)}
+		var w io.Writer
+		if isLocal {
+			if s.file, err = os.Create(filepath.Join(local.Root, s.name)); err != nil {
+				return err
+			}
+			defer s.file.Close()
+			s.bw = bufio.NewWriter(s.file)
+			s.hash = sha256.New()
+			w = io.MultiWriter(s.bw, s.hash)
+		} else {
+			s.buf = &bytes.Buffer{}
+			w = s.buf
+		}
+		if s.cw, err = bundle.NewWriter(w, c); err != nil {
+			return err
+		}
+		s.enc = json.NewEncoder(s.cw)
+		shards[i] = s
+	}
+
+	err = fetchPlaylists(ctx, sp, ids, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(ctx, playlist)
+		if err != nil {
+			return err
+		}
+		for _, track := range mp.Tracks {
+			s := shards[shardOf(track, n)]
+			line := playlistTrackLine{PlaylistID: mp.IntegrationID, PlaylistName: mp.Name, MusicTrack: track}
+			if err := s.enc.Encode(line); err != nil {
+				return err
+			}
+			s.tracks++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m := manifest.New(time.Now())
+	for _, s := range shards {
+		if err := s.cw.Close(); err != nil {
+			return err
+		}
+		if isLocal {
+			if err := s.bw.Flush(); err != nil {
+				return err
+			}
+			info, err := s.file.Stat()
+			if err != nil {
+				return err
+			}
+			if err := s.file.Close(); err != nil {
+				return err
+			}
+			m.AddHash(s.name, hex.EncodeToString(s.hash.Sum(nil)), info.Size(), s.tracks)
+		} else {
+			if _, err := backend.Put(ctx, s.name, s.buf.Bytes()); err != nil {
+				return err
+			}
+			m.Add(s.name, s.buf.Bytes(), s.tracks)
+		}
+		slog.Debug("wrote shard", "shard", backend.Location(s.name), "tracks", s.tracks)
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	if _, err := backend.Put(ctx, manifest.Name, data); err != nil {
+		return err
+	}
+	slog.Info("wrote shards", "output", location, "shards", n)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/locale"
+	"github.com/pyrat/spd/internal/report"
+	"github.com/pyrat/spd/internal/site"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runSite renders the latest snapshot of every archived collection, or
+// the given dumps, as a static website.
+//
+//	spdump site --archive archive/ --out public/
+//	spdump site library.json --out public/ --base-url https://me.github.io/playlists
+func runSite(args []string) error {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	archiveDir := fs.String("archive", "", "archive to publish the latest snapshots of")
+	out := fs.String("out", "public", "directory to write the site to")
+	baseURL := fs.String("base-url", "", "url the site is published at, to write a sitemap")
+	title := fs.String("title", "Playlists", "title of the site")
+	tz := fs.String("tz", "UTC", "time zone for dates on the site")
+	lang := fs.String("locale", "", "locale for numbers, dates and headings, defaults to $LANG")
+	parseFlags(fs, args)
+
+	if *archiveDir == "" && fs.NArg() == 0 {
+		return errors.New("usage: spdump site --archive <dir> | spdump site <dump.json>... [--out public/]")
+	}
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	l, err := locale.Lookup(*lang)
+	if err != nil {
+		return err
+	}
+
+	var playlists []spotify.MusicPlaylist
+	if fs.NArg() > 0 {
+		if playlists, err = dump.ReadFiles(fs.Args()...); err != nil {
+			return err
+		}
+	}
+	if *archiveDir != "" {
+		archived, err := latestArchived(*archiveDir)
+		if err != nil {
+			return err
+		}
+		playlists = append(playlists, archived...)
+	}
+
+	return site.Generate(*out, playlists, site.Options{
+		BaseURL: *baseURL,
+		Title:   *title,
+		Report:  report.Options{Locale: l, Location: location},
+	})
+}
+
+// latestArchived reads the playlists of the latest snapshot of every
+// collection in the archive, skipping playlists already read from an
+// earlier collection.
+func latestArchived(dir string) ([]spotify.MusicPlaylist, error) {
+	a, err := archive.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := a.LatestSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var playlists []spotify.MusicPlaylist
+	for _, snapshot := range snapshots {
+		read, err := snapshot.ReadPlaylists()
+		if err != nil {
+			return nil, err
+		}
+		for _, mp := range read {
+			if !seen[mp.IntegrationID] {
+				seen[mp.IntegrationID] = true
+				playlists = append(playlists, mp)
+			}
+		}
+	}
+	return playlists, nil
+}
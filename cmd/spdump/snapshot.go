@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/blocklist"
+	"github.com/pyrat/spd/internal/hook"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runSnapshot manages named restore points of a user's library, kept in
+// the same archive collection spdump sync writes to.
+//
+//	spdump snapshot create --name pre-cleanup
+//	spdump snapshot list
+//	spdump snapshot restore pre-cleanup
+func runSnapshot(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "create":
+			return runSnapshotCreate(args[1:])
+		case "list":
+			return runSnapshotList(args[1:])
+		case "restore":
+			return runSnapshotRestore(args[1:])
+		}
+	}
+	return errors.New("usage: spdump snapshot create --name <name> | spdump snapshot list | spdump snapshot restore <name>")
+}
+
+// runSnapshotCreate archives the whole library as a named snapshot.
+func runSnapshotCreate(args []string) error {
+	fs := flag.NewFlagSet("snapshot create", flag.ExitOnError)
+	name := fs.StringP("name", "n", "", "name of the restore point, e.g. pre-cleanup")
+	user := fs.String("user", "", "user whose playlists are archived (defaults to the token's owner)")
+	archiveDir := fs.String("archive", "archive", "archive directory snapshots are written to")
+	concurrency := registerConcurrencyFlags(fs)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with playlist-read-private scope (or set SPOTIFY_TOKEN)")
+	parseFlags(fs, args)
+
+	if *name == "" {
+		return errors.New("usage: spdump snapshot create --name <name>")
+	}
+
+	sp, err := newUserSpotify(*token, concurrency.options()...)
+	if err != nil {
+		return err
+	}
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+
+	redaction, err := redactionRules(nil)
+	if err != nil {
+		return err
+	}
+	opts := dumpOptions{Concurrency: concurrency.playlists(), Fields: exportFields{Redact: redaction}}
+	if err := syncPlaylists(commandContext(), sp, arc, *user, *name, hook.Hooks{}, opts); err != nil {
+		return err
+	}
+	concurrency.report()
+	reportUsage(sp)
+	slog.Info("created snapshot", "name", *name, "collection", syncCollection(*user))
+	return nil
+}
+
+// runSnapshotList lists the snapshots of a user's library, named or not.
+func runSnapshotList(args []string) error {
+	fs := flag.NewFlagSet("snapshot list", flag.ExitOnError)
+	user := fs.String("user", "", "user whose snapshots are listed (defaults to the token's owner)")
+	archiveDir := fs.String("archive", "archive", "archive directory snapshots are read from")
+	named := fs.Bool("named", false, "only list named snapshots")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+	snapshots, err := arc.Snapshots(syncCollection(*user))
+	if err != nil {
+		return err
+	}
+
+	type listedSnapshot struct {
+		Name      string `json:",omitempty"`
+		Dir       string
+		CreatedAt time.Time
+		Playlists int
+		Tracks    int
+	}
+	var listed []listedSnapshot
+	t := table.New("NAME", "CREATED", "PLAYLISTS", "TRACKS")
+	for _, snapshot := range snapshots {
+		if *named && snapshot.Name == "" {
+			continue
+		}
+		tracks := 0
+		for _, entry := range snapshot.Playlists {
+			tracks += entry.Tracks
+		}
+		listed = append(listed, listedSnapshot{snapshot.Name, snapshot.Dir, snapshot.CreatedAt, len(snapshot.Playlists), tracks})
+		t.Add(firstNonEmpty(snapshot.Name, "-"), snapshot.CreatedAt.Format("2006-01-02 15:04:05"), len(snapshot.Playlists), tracks)
+	}
+	return output.print(listed, t)
+}
+
+// runSnapshotRestore puts the library's playlists back as they were in a
+// snapshot. Playlists still in the library get their tracks replaced,
+// those removed since are created again. Playlists added since the
+// snapshot are left alone.
+func runSnapshotRestore(args []string) error {
+	fs := flag.NewFlagSet("snapshot restore", flag.ExitOnError)
+	user := fs.String("user", "", "user whose snapshot is restored (defaults to the token's owner)")
+	archiveDir := fs.String("archive", "archive", "archive directory snapshots are read from")
+	public := fs.Bool("public", false, "make re-created playlists public")
+	dryRun := fs.Bool("dry-run", false, "print what would be restored without changing anything")
+	blocklistFile := fs.String("blocklist", blocklist.DefaultFile, "leave out the artists, tracks and labels blocked in this file")
+	simulate := fs.Bool("simulate", false, "apply the restore to the latest archived snapshot instead of the account and print the resulting dump")
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with playlist-modify scopes (or set SPOTIFY_TOKEN)")
+	policyFlags := registerPolicyFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: spdump snapshot restore <name> [--dry-run] [--simulate]")
+	}
+	policy, err := policyFlags.policy()
+	if err != nil {
+		return err
+	}
+
+	collection := syncCollection(*user)
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+	snapshot, err := arc.Find(collection, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	playlists, err := snapshot.ReadPlaylists()
+	if err != nil {
+		return err
+	}
+	list, err := blocklist.Load(*blocklistFile)
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	if *simulate {
+		// the latest snapshot stands in for the library as it is now
+		latest, _, err := arc.Latest(collection)
+		if err != nil {
+			return err
+		}
+		current, err := latest.ReadPlaylists()
+		if err != nil {
+			return err
+		}
+		// labels are looked up through the API, which a simulation
+		// doesn't touch
+		offline := *list
+		if len(offline.Labels) > 0 {
+			slog.Warn("blocked labels aren't checked when simulating", "labels", len(offline.Labels))
+			offline.Labels = nil
+		}
+		for i := range playlists {
+			if playlists[i].Tracks, err = filterBlocked(ctx, nil, &offline, playlists[i].Name, playlists[i].Tracks); err != nil {
+				return err
+			}
+			// nor are other versions searched for, tracks breaking
+			// the policy are left out
+			if playlists[i].Tracks, err = applyPolicy(ctx, policy, nil, playlists[i].Name, playlists[i].Tracks); err != nil {
+				return err
+			}
+		}
+		return printSimulation(current, restoreOps(playlists, current, *public))
+	}
+
+	sp, err := newUserSpotify(*token)
+	if err != nil {
+		return err
+	}
+	pair := versionPairer(sp)
+	// the albums of every playlist are fetched together for the labels
+	planner := sp.NewPlanner()
+	if len(list.Labels) > 0 {
+		for _, mp := range playlists {
+			planner.AddTrackAlbums(mp.Tracks)
+		}
+	}
+	for i := range playlists {
+		if playlists[i].Tracks, err = filterBlocked(ctx, planner, list, playlists[i].Name, playlists[i].Tracks); err != nil {
+			return err
+		}
+		if playlists[i].Tracks, err = applyPolicy(ctx, policy, pair, playlists[i].Name, playlists[i].Tracks); err != nil {
+			return err
+		}
+	}
+	owner, listed, err := libraryAccess(ctx, sp)
+	if err != nil {
+		return err
+	}
+	current := make([]spotify.MusicPlaylist, 0, len(listed))
+	for _, access := range listed {
+		current = append(current, spotify.MusicPlaylist{IntegrationID: access.PlaylistID, Name: access.Name})
+	}
+
+	// followed playlists of other users can't be written to, they are
+	// skipped up front rather than failing halfway through
+	ops, denied := writeback.Check(restoreOps(playlists, current, *public), accessByID(listed))
+	for _, d := range denied {
+		slog.Warn("skipping playlist that can't be changed", "playlist", d.Op.PlaylistID, "name", d.Op.Name, "reason", d.Reason)
+	}
+	for _, op := range ops {
+		if *dryRun {
+			fmt.Println(op)
+			continue
+		}
+		if err := applyOp(ctx, sp, owner.IntegrationID, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreOps plans putting the snapshot's playlists back into the current
+// library. Playlists over the size limit continue in new playlists.
+func restoreOps(snapshot []spotify.MusicPlaylist, current []spotify.MusicPlaylist, public bool) []writeback.Op {
+	existing := map[string]bool{}
+	for _, mp := range current {
+		existing[mp.IntegrationID] = true
+	}
+
+	var ops []writeback.Op
+	for _, mp := range snapshot {
+		for n, part := range splitTracks(mp.Tracks) {
+			op := writeback.Op{Kind: writeback.Create, Name: spotify.ContinuationName(mp.Name, n), Public: public, Tracks: part}
+			if n == 0 && existing[mp.IntegrationID] {
+				op.Kind = writeback.Replace
+				op.PlaylistID = mp.IntegrationID
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// splitTracks splits tracks into as many playlists as are needed to stay
+// under spotify.MaxPlaylistTracks, like spotify.SplitPlaylists.
+func splitTracks(tracks []spotify.MusicTrack) [][]spotify.MusicTrack {
+	parts := [][]spotify.MusicTrack{tracks[:min(len(tracks), spotify.MaxPlaylistTracks)]}
+	for start := spotify.MaxPlaylistTracks; start < len(tracks); start += spotify.MaxPlaylistTracks {
+		parts = append(parts, tracks[start:min(len(tracks), start+spotify.MaxPlaylistTracks)])
+	}
+	return parts
+}
@@ -5,58 +5,308 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"log"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
-	"github.com/pelletier/go-toml"
-	"github.com/pyrat/spd/internal/spotify"
+	"github.com/pyrat/spd/internal/artwork"
+	"github.com/pyrat/spd/internal/bundle"
+	"github.com/pyrat/spd/internal/clipboard"
+	"github.com/pyrat/spd/internal/filter"
+	"github.com/pyrat/spd/internal/locale"
+	"github.com/pyrat/spd/internal/purchase"
+	"github.com/pyrat/spd/internal/report"
+	"github.com/pyrat/spd/pkg/pipeline"
+	"github.com/pyrat/spd/pkg/spotify"
 	flag "github.com/spf13/pflag"
 )
 
+// commands maps subcommand names to their implementations. Running spdump
+// without a known subcommand dumps a playlist.
+var commands = map[string]func(args []string) error{
+	"restore":      runRestore,
+	"artist":       runArtist,
+	"collage":      runCollage,
+	"search":       runSearch,
+	"graph":        runGraph,
+	"staleness":    runStaleness,
+	"browse":       runBrowse,
+	"site":         runSite,
+	"serve":        runServe,
+	"sync":         runSync,
+	"snapshot":     runSnapshot,
+	"match":        runMatch,
+	"block":        runBlock,
+	"analyze":      runAnalyze,
+	"check":        runCheck,
+	"history":      runHistory,
+	"top":          runTop,
+	"permissions":  runPermissions,
+	"playlist":     runPlaylist,
+	"privacy":      runPrivacy,
+	"cleanup":      runCleanup,
+	"oembed":       runOEmbed,
+	"gen-fixtures": runGenFixtures,
+	"following":    runFollowing,
+	"verify":       runVerify,
+	"selftest":     runSelftest,
+	"service":      runService,
+	"repl":         runRepl,
+	"retries":      runRetries,
+	"queue":        runQueue,
+	"like":         runLike,
+	"unlike":       runUnlike,
+	"albums":       runAlbums,
+	"generate":     runGenerate,
+	"duplicates":   runDuplicates,
+	"digest":       runDigest,
+	"proxy":        runProxy,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fatal(err)
+			}
+			reportRunUsage()
+			return
+		}
+	}
+
 	// implement the cli here
 	// Define flags
 	// playlistPtr := flag.String("playlist", "", "Playlist to dump")
-	var playlistPtr *string = flag.StringP("playlist", "p", "3rpdjX0UZGjjmk3A86FrU3", "playlist_id to dump")
+	var playlistPtr *[]string = flag.StringSliceP("playlist", "p", []string{"3rpdjX0UZGjjmk3A86FrU3"}, "playlist ID, URI or link to dump, repeat for several playlists")
+	var userPtr *string = flag.StringP("user", "u", "", "dump the public playlists of this Spotify user ID instead")
+	var ownedPtr *bool = flag.Bool("owned", false, "with --user, only the playlists the user owns rather than also those they follow")
+	var formatPtr *string = flag.StringP("format", "f", formatJSON, "output format: json, ndjson (one playlist per line), ndjson-tracks (one track per line), csv, markdown, html, portable (with ISRC/UPC codes) or uris (one spotify:track: URI per line)")
+	var outputPtr *string = flag.String("output", "", "store the dump in a directory or s3://bucket/prefix/ instead of writing it to stdout, a trailing slash names it by time")
+	var compressPtr *string = flag.String("compress", "", "compress the output with gzip or zstd")
+	var fromClipboardPtr *bool = flag.Bool("from-clipboard", false, "dump the playlist or user links copied to the clipboard")
+	var toClipboardPtr *bool = flag.Bool("to-clipboard", false, "copy the dump to the clipboard instead of writing it to stdout")
+	var bundlePtr *string = flag.String("bundle", "", "pack every playlist and an index.json into this tar file, compressed by its extension (.tar.gz, .tar.zst), - for stdout")
+	var dryRunPtr *bool = flag.Bool("dry-run", false, "only print the playlists, their track counts and the API requests a dump would make")
+	var shardsPtr *int = flag.Int("shards", 0, "partition the tracks by ID hash into this many ndjson files, written to --output or the working directory")
+	var keepQueryPtr *bool = flag.Bool("keep-query", false, "keep query strings (si= share tokens) on external URLs")
+	concurrency := registerConcurrencyFlags(flag.CommandLine)
+	var artDirPtr *string = flag.String("download-art", "", "download cover images into this directory")
+	var cacheDirPtr *string = flag.String("cache-dir", "", "cache API responses in this directory, revalidated by ETag (overrides [cache] dir in config.toml)")
+	var artMaxSizePtr *int = flag.Int("art-max-size", 0, "largest cover image width to download in pixels, 0 for the biggest available")
+	fields := registerExportFlags(flag.CommandLine)
+	policyFlags := registerPolicyFlags(flag.CommandLine)
+	var tzPtr *string = flag.String("tz", "UTC", "time zone for timestamps in csv, markdown and html output, e.g. Europe/London or Local")
+	var templatePtr *string = flag.String("template", "", "template file replacing the built in markdown/html one")
+	var localePtr *string = flag.String("locale", "", "locale for numbers, dates and headings in markdown/html output, defaults to $LANG")
+	var filterPtr *[]string = flag.StringArray("filter", nil, "only dump the tracks matching this expression, e.g. 'artist =~ Radiohead' or 'added_after 2023-01-01 and duration > 10m' (repeatable, all must match)")
+	var wherePtr *[]string = flag.StringArray("where", nil, "only dump the tracks this expression is true for, e.g. 'year >= 2000 && !explicit' (repeatable, all must be true; replaces [script] where in config.toml)")
+	var computePtr *[]string = flag.StringArray("compute", nil, "add a computed field to the tracks, a csv column, e.g. 'decade = string(year / 10 * 10) + \"s\"' (repeatable, in order; replaces [script] fields in config.toml)")
+	var stepsPtr *[]string = flag.StringArray("step", nil, "run the tracks through this pipeline step, e.g. 'dedupe:by=isrc' or 'sort:by=added,reverse' (repeatable, in order; replaces [pipeline] steps in config.toml), one of "+strings.Join(pipeline.Names(), ", ")+" or a stage of the dump: "+strings.Join(dumpStages, ", "))
+	var sortPtr *string = flag.String("sort", "", "order the tracks of each playlist: chronological (album release date, then disc and track number), name, artist, album, added, released or duration, after the other steps")
+	var purchaseLinksPtr *bool = flag.Bool("purchase-links", false, "add links to buy each track's album: searches of the iTunes Store, Bandcamp and Qobuz")
+	var purchasePricesPtr *bool = flag.Bool("purchase-prices", false, "with --purchase-links, look albums up in the iTunes Store for a direct link and their price in --market, about 20 albums a minute")
+	var previewFallbackPtr *bool = flag.Bool("preview-fallback", false, "look up the previews the API leaves out in the public embed player, best effort, marking them PreviewSource embed")
+	var genresPtr *bool = flag.Bool("genres", false, "add the genres of their artists to the tracks and a count of tracks per genre to the playlists")
+	var languagePtr *bool = flag.Bool("language", false, "guess the language of each track from its title, album and artists, a Language field to --filter on")
+	var retryQueuePtr *string = flag.String("retry-queue", "", "file queuing the artists whose genres couldn't be looked up, to retry on the next run (defaults to that of spdump retries)")
+	var marketPtr *string = flag.String("market", "", "market (country code, or from_token) for region correct availability, relinked tracks and previews")
 
 	// Parse command line arguments
-	flag.Parse()
+	parseFlags(flag.CommandLine, os.Args[1:])
+	defer reportRunUsage()
+	if err := fields.loadRedaction(); err != nil {
+		fatal(err)
+	}
 
-	// Read the TOML file
-	tomlData, err := ioutil.ReadFile("config.toml")
+	location, err := time.LoadLocation(*tzPtr)
 	if err != nil {
-		panic(err)
+		fatal(err)
 	}
 
-	// Parse the TOML data
-	config, err := toml.Load(string(tomlData))
-	if err != nil {
-		panic(err)
+	// only the reports are localized, other formats don't mind $LANG but
+	// still reject a --locale there is none for
+	var lang *locale.Locale
+	if *formatPtr == formatMarkdown || *formatPtr == formatHTML || *localePtr != "" {
+		if lang, err = locale.Lookup(*localePtr); err != nil {
+			fatal(err)
+		}
 	}
 
-	// Get the Spotify client ID and secret
-	clientID := config.Get("spotify.client_id").(string)
-	clientSecret := config.Get("spotify.client_secret").(string)
+	clientOpts := concurrency.options()
+	if *cacheDirPtr != "" {
+		clientOpts = append(clientOpts, spotify.WithCache(*cacheDirPtr))
+	}
+	if *marketPtr != "" {
+		clientOpts = append(clientOpts, spotify.WithMarket(*marketPtr))
+	}
 
-	log.Println("clientID: ", clientID)
+	policy, err := policyFlags.policy()
+	if err != nil {
+		fatal(err)
+	}
+	trackFilter, err := filter.ParseAll(*filterPtr)
+	if err != nil {
+		fatal(err)
+	}
 
-	sp, err := spotify.NewSpotify(clientID, clientSecret)
+	scripted, err := scriptSteps(*wherePtr, *computePtr)
+	if err != nil {
+		fatal(err)
+	}
+	specs, err := pipelineSpecs(*stepsPtr)
+	if err != nil {
+		fatal(err)
+	}
+	if *sortPtr != "" {
+		specs = append(specs, pipeline.Spec{Name: "sort", Config: pipeline.Config{"by": *sortPtr}})
+	}
+	steps, err := newPipeline(specs)
 	if err != nil {
-		panic(err)
+		fatal(err)
 	}
 
-	// Get the user's playlists
-	playlist, err := sp.PlaylistFromID(*playlistPtr)
+	compression, err := bundle.ParseCompression(*compressPtr)
 	if err != nil {
-		panic(err)
+		fatal(err)
+	}
+	if *bundlePtr != "" && *outputPtr != "" {
+		fatal(errors.New("--bundle writes its own file and can't be combined with --output"))
+	}
+	if *shardsPtr < 0 || (*shardsPtr > 0 && *bundlePtr != "") {
+		fatal(errors.New("--shards takes a positive number of shards and can't be combined with --bundle"))
+	}
+	if *toClipboardPtr && (*outputPtr != "" || *bundlePtr != "" || *shardsPtr > 0 || compression != bundle.None) {
+		fatal(errors.New("--to-clipboard copies text and can't be combined with --output, --bundle, --shards or --compress"))
 	}
 
-	mp := spotify.ConvertToMusicPlaylist(playlist)
+	sp, err := newSpotifyFromConfig(clientOpts...)
+	if err != nil {
+		fatal(err)
+	}
+
+	playlists := *playlistPtr
+	if *fromClipboardPtr {
+		copied, user, err := clipboardPlaylists()
+		if err != nil {
+			fatal(err)
+		}
+		if !flag.CommandLine.Changed("playlist") {
+			playlists = nil
+		}
+		playlists = append(playlists, copied...)
+		if *userPtr == "" {
+			*userPtr = user
+		}
+		slog.Info("read the clipboard", "playlists", len(copied), "user", user)
+	}
+	if *userPtr != "" {
+		if !flag.CommandLine.Changed("playlist") && !*fromClipboardPtr {
+			playlists = nil
+		}
+		listed, err := sp.UserPublicPlaylists(commandContext(), *userPtr, *ownedPtr)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("listed public playlists", "user", *userPtr, "playlists", len(listed))
+		for _, playlist := range listed {
+			playlists = append(playlists, playlist.IntegrationID)
+		}
+	}
+
+	// Print the playlists
+	opts := dumpOptions{
+		Format:      *formatPtr,
+		KeepQuery:   *keepQueryPtr,
+		Concurrency: concurrency.playlists(),
+		Fields:      *fields,
+		Location:    location,
+		Policy:      policy,
+		Filter:      trackFilter,
+		Language:    *languagePtr,
+		Pipeline:    steps,
+		Script:      scripted,
+		Pair:        versionPairer(sp),
+		Report: report.Options{
+			Template: *templatePtr,
+			Locale:   lang,
+			Location: location,
+		},
+	}
+	var retryPass *retryPass
+	if *genresPtr {
+		opts.Genres = sp.NewPlanner()
+		retryPass = newRetryPass(*retryQueuePtr)
+		retryPass.plan(opts.Genres, spotify.TypeArtist)
+	}
+	if *purchaseLinksPtr || *purchasePricesPtr {
+		opts.Purchase = &purchase.Finder{Country: *marketPtr, Lookup: *purchasePricesPtr}
+	}
+	if *previewFallbackPtr && !fields.NoPreview {
+		opts.Previews = newPreviewFallback(sp)
+	}
+	if *artDirPtr != "" {
+		opts.Art, err = artwork.NewDownloader(*artDirPtr, *artMaxSizePtr)
+		if err != nil {
+			fatal(err)
+		}
+	}
 
-	// Print the playlist
-	bytes, _ := json.Marshal(mp)
-	fmt.Println(string(bytes))
+	if *dryRunPtr {
+		if err := planDump(commandContext(), os.Stdout, sp, playlists, opts); err != nil {
+			fatal(err)
+		}
+		reportUsage(sp)
+		return
+	}
+
+	// stored dumps are buffered to be hashed and uploaded in one go
+	out := io.Writer(os.Stdout)
+	var buf bytes.Buffer
+	if (*outputPtr != "" && *shardsPtr == 0) || *toClipboardPtr {
+		out = &buf
+	}
+	opts.Progress = newProgress(sp, len(playlists))
+	switch {
+	case *shardsPtr > 0:
+		err = writeShards(commandContext(), *outputPtr, *shardsPtr, compression, sp, playlists, opts)
+	case *bundlePtr != "":
+		collection := "playlists"
+		if *userPtr != "" {
+			collection = "user:" + *userPtr
+		}
+		err = writeBundle(commandContext(), *bundlePtr, compression, collection, sp, playlists, opts)
+	default:
+		err = writeCompressed(commandContext(), out, compression, sp, playlists, opts)
+	}
+	opts.Progress.stop()
+	if err != nil {
+		fatal(err)
+	}
+	if retryPass != nil {
+		retryPass.settle(opts.Genres)
+	}
+	concurrency.report()
+	reportUsage(sp)
+	if *outputPtr != "" && *shardsPtr == 0 {
+		if err := storeOutput(commandContext(), *outputPtr, formatExtensions[*formatPtr]+compression.Extension(), &buf); err != nil {
+			fatal(err)
+		}
+	}
+	if *toClipboardPtr {
+		if err := clipboard.Write(buf.Bytes()); err != nil {
+			fatal(err)
+		}
+		slog.Info("copied the dump to the clipboard", "bytes", buf.Len())
+	}
 
+	if opts.Art != nil {
+		if err := opts.Art.Close(); err != nil {
+			fatal(err)
+		}
+	}
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/staleness"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runStaleness scores dumped playlists by how neglected they are: time
+// since a track was last added, fraction of unavailable tracks and, given
+// a user token, how often their tracks were played recently.
+//
+//	spdump staleness library.json
+func runStaleness(args []string) error {
+	fs := flag.NewFlagSet("staleness", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("SPOTIFY_TOKEN"), "user access token with user-read-recently-played, to weigh in recent plays (or set SPOTIFY_TOKEN)")
+	output := registerOutputFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: spdump staleness <dump.json>... [--token token]")
+	}
+
+	var plays map[string]int
+	if *token != "" {
+		sp, err := newUserSpotify(*token)
+		if err != nil {
+			return err
+		}
+		history, err := sp.RecentlyPlayed(commandContext(), 50)
+		if err != nil {
+			return err
+		}
+		plays = map[string]int{}
+		for _, item := range history {
+			plays[item.Track.IntegrationID]++
+		}
+	}
+
+	scorer := staleness.NewScorer(plays, time.Now())
+	err := dump.StreamFiles(fs.Args(), func(mp spotify.MusicPlaylist) error {
+		scorer.AddPlaylist(mp)
+		return nil
+	}, func(track dump.Track) error {
+		scorer.AddTrack(track.PlaylistID, track.MusicTrack)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reports := scorer.Reports()
+
+	t := table.New("SCORE", "PLAYLIST", "TRACKS", "LAST ADDED", "UNAVAILABLE", "PLAYS", "ID").Fixed("ID")
+	for _, report := range reports {
+		lastAdded := "never"
+		if report.LastModified != nil {
+			lastAdded = report.LastModified.Format("2006-01-02")
+		}
+		recentPlays := "-"
+		if report.Plays != nil {
+			recentPlays = fmt.Sprint(*report.Plays)
+		}
+		t.Add(fmt.Sprintf("%.0f", report.Score), report.Name, report.Tracks, lastAdded,
+			fmt.Sprintf("%.0f%%", report.Unavailable*100), recentPlays, report.PlaylistID)
+	}
+	return output.print(reports, t)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pyrat/spd/internal/storage"
+)
+
+// formatExtensions are the file extensions of the output formats.
+var formatExtensions = map[string]string{
+	formatJSON:         ".json",
+	formatNDJSON:       ".ndjson",
+	formatNDJSONTracks: ".ndjson",
+	formatCSV:          ".csv",
+	formatMarkdown:     ".md",
+	formatHTML:         ".html",
+	formatPortable:     ".json",
+	formatURIs:         ".txt",
+}
+
+// storeOutput uploads a dump to a directory or bucket location. A location
+// ending in a slash is a prefix, the dump is named after the time it was
+// taken with the extension, e.g. s3://bucket/dumps/spdump-20240102T150405Z.json.
+func storeOutput(ctx context.Context, location string, ext string, dump *bytes.Buffer) error {
+	dir, key := storage.Split(location)
+	if key == "" {
+		key = "spdump-" + time.Now().UTC().Format("20060102T150405Z") + ext
+	}
+	backend, err := storage.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	written, err := backend.Put(ctx, key, dump.Bytes())
+	if err != nil {
+		return err
+	}
+	if !written {
+		slog.Info("dump unchanged, not uploaded", "output", backend.Location(key))
+		return nil
+	}
+	slog.Info("stored dump", "output", backend.Location(key), "bytes", dump.Len(), "sha256", storage.Hash(dump.Bytes()))
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/diff"
+	"github.com/pyrat/spd/internal/hook"
+	"github.com/pyrat/spd/internal/job"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// runSync keeps archiving a user's playlists. Playlists whose snapshot_id
+// hasn't changed since the last snapshot are carried over without being
+// fetched again. It runs until SIGINT or SIGTERM, finishing the archive
+// it is writing first. Progress is saved in the archive as it goes, so a
+// sync stopped halfway resumes with the playlists it had left. Running at
+// an interval, the hooks are alerted when too many requests fail. With
+// recipients for the digest, the changes are emailed to them weekly.
+//
+//	spdump sync --user spotifyuser --interval 6h
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	user := fs.String("user", "", "user whose playlists are synced (defaults to sync.user in config.toml, or the token's owner)")
+	interval := fs.Duration("interval", 0, "sync again at this interval, e.g. 6h, until stopped; once when zero")
+	archiveDir := fs.String("archive", "archive", "archive directory snapshots are written to")
+	concurrency := registerConcurrencyFlags(fs)
+	errorBudget := registerBudgetFlags(fs)
+	hookURL := fs.String("hook-url", "", "POST a JSON summary of the changes to this URL (defaults to hooks.url in config.toml)")
+	hookCmd := fs.String("hook-cmd", "", "run this command with the changes as JSON on stdin (defaults to hooks.command in config.toml)")
+	notifyDesktop := fs.Bool("notify-desktop", false, "show a desktop notification of the changes (or set hooks.desktop in config.toml)")
+	checkCatalog := fs.Bool("check-catalog", false, "fetch every playlist again, not only changed ones, to catch edits to track titles, album art and release dates (or set sync.check_catalog in config.toml)")
+	digestOpts := registerDigestFlags(fs, true)
+	parseFlags(fs, args)
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *user == "" {
+		*user, _ = config.Get("sync.user").(string)
+	}
+	if err := digestOpts.load(config); err != nil {
+		return err
+	}
+	hooks := hook.Hooks{URL: *hookURL, Command: *hookCmd, Desktop: *notifyDesktop}
+	if hooks.URL == "" {
+		hooks.URL, _ = config.Get("hooks.url").(string)
+	}
+	if hooks.Command == "" {
+		hooks.Command, _ = config.Get("hooks.command").(string)
+	}
+	if !hooks.Desktop {
+		hooks.Desktop, _ = config.Get("hooks.desktop").(bool)
+	}
+	if !*checkCatalog {
+		*checkCatalog, _ = config.Get("sync.check_catalog").(bool)
+	}
+
+	sp, err := newSpotifyFromConfig(concurrency.options()...)
+	if err != nil {
+		return err
+	}
+	arc, err := archive.Open(*archiveDir)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := openJobs(arc)
+	if err != nil {
+		return err
+	}
+
+	redaction, err := redactionRules(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := commandContext()
+	opts := dumpOptions{Concurrency: concurrency.playlists(), Jobs: jobs, RefetchAll: *checkCatalog, Fields: exportFields{Redact: redaction}}
+	if *interval > 0 {
+		go errorBudget.watch(ctx, sp, hooks)
+	}
+	for {
+		err := syncPlaylists(ctx, sp, arc, *user, "", hooks, opts)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			if *interval <= 0 {
+				return err
+			}
+			// a long running sync outlives a failed cycle
+			slog.Error("sync failed", "err", err)
+		}
+		if digestOpts.enabled() {
+			// a digest which can't be sent is tried again next cycle
+			if err := maybeSendDigest(ctx, arc, syncCollection(*user), digestOpts); err != nil {
+				slog.Error("sync: emailing digest", "err", err)
+			}
+		}
+		if *interval <= 0 {
+			return nil
+		}
+
+		select {
+		case <-time.After(*interval):
+		case <-ctx.Done():
+			slog.Info("sync stopped")
+			return nil
+		}
+	}
+}
+
+// syncCollection returns the archive collection a user's playlists are
+// synced into.
+func syncCollection(user string) string {
+	if user == "" {
+		return "me"
+	}
+	return "user-" + user
+}
+
+// syncPlaylists writes a new snapshot of the user's playlists when any
+// changed since the latest one, logs what changed and fires the hooks. A
+// snapshot given a name is a restore point and always written.
+func syncPlaylists(ctx context.Context, sp *spotify.Client, arc *archive.Archive, user string, name string, hooks hook.Hooks, opts dumpOptions) error {
+	listed, err := sp.UserPlaylists(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	collection := syncCollection(user)
+	var previous []spotify.MusicPlaylist
+	if snapshot, ok, err := arc.Latest(collection); err != nil {
+		return err
+	} else if ok {
+		if previous, err = snapshot.ReadPlaylists(); err != nil {
+			return err
+		}
+	}
+	known := map[string]spotify.MusicPlaylist{}
+	for _, mp := range previous {
+		known[mp.IntegrationID] = mp
+	}
+
+	// only playlists with a new snapshot_id are fetched, unless checking
+	// the catalog
+	var fetch []string
+	for _, playlist := range listed {
+		if mp, ok := known[playlist.IntegrationID]; opts.RefetchAll || !ok || mp.SnapshotID == "" || mp.SnapshotID != playlist.SnapshotID {
+			fetch = append(fetch, playlist.IntegrationID)
+		}
+	}
+	if len(fetch) == 0 && len(listed) == len(previous) && name == "" {
+		slog.Info("sync: no changes", "collection", collection, "playlists", len(listed))
+		return nil
+	}
+
+	// playlists fetched by an interrupted run are taken over when they
+	// haven't changed since
+	run, fetched, fetch, err := resumeSync(opts.Jobs, collection, listed, fetch)
+	if err != nil {
+		return err
+	}
+	err = fetchPlaylists(ctx, sp, fetch, opts.Concurrency, func(playlist spotify.SpotifyPlaylist) error {
+		mp, err := opts.convertPlaylist(ctx, playlist)
+		if err != nil {
+			return err
+		}
+		fetched[mp.IntegrationID] = mp
+		return run.Complete(mp.IntegrationID, len(mp.Tracks), mp)
+	})
+	if err != nil {
+		if finishErr := run.Finish(err); finishErr != nil {
+			slog.Error("sync: saving job", "job", run.ID, "err", finishErr)
+		}
+		return err
+	}
+
+	current := make([]spotify.MusicPlaylist, 0, len(listed))
+	for _, playlist := range listed {
+		mp, ok := fetched[playlist.IntegrationID]
+		if !ok {
+			mp = known[playlist.IntegrationID]
+		}
+		current = append(current, mp)
+	}
+	changes := diff.Compare(previous, current)
+
+	// fetching everything, a snapshot is only worth writing when it
+	// differs from the last
+	if opts.RefetchAll && changes.Empty() && name == "" && len(previous) > 0 {
+		if err := run.Finish(nil); err != nil {
+			return err
+		}
+		slog.Info("sync: no changes", "collection", collection, "playlists", len(listed))
+		return nil
+	}
+
+	w, err := arc.NewSnapshot(collection, time.Now())
+	if err != nil {
+		return err
+	}
+	w.SetName(name)
+	for _, mp := range current {
+		if err := w.WritePlaylist(mp); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteJSON(archive.ChangesName, changes); err != nil {
+		return err
+	}
+	snapshot, err := w.Close()
+	if err != nil {
+		return err
+	}
+	if err := run.Finish(nil); err != nil {
+		return err
+	}
+
+	for _, change := range changes.Playlists {
+		slog.Info("sync: playlist "+change.Status, "playlist", change.ID, "name", change.Name, "added", len(change.Added), "removed", len(change.Removed), "moved", len(change.Moved), "edited", len(change.Edited))
+	}
+	added, removed, moved := changes.Totals()
+	slog.Info("sync: archived", "collection", collection, "playlists", len(current), "fetched", len(fetch), "added", added, "removed", removed, "moved", moved, "edited", changes.Edited(), "dir", snapshot.Dir)
+
+	// a failing hook shouldn't fail the sync, the snapshot is written
+	summary := hook.Summarize(collection, snapshot.CreatedAt, changes)
+	if err := hooks.Fire(ctx, summary, changes); err != nil {
+		slog.Error("sync: firing hooks", "err", err)
+	}
+	return nil
+}
+
+// resumeSync starts the job of fetching the playlists of a sync, or
+// resumes the one a previous run left unfinished. It returns the
+// playlists the previous run fetched which are still current, and those
+// left to fetch.
+func resumeSync(jobs *job.Store, collection string, listed []spotify.SpotifyPlaylist, fetch []string) (*job.Job, map[string]spotify.MusicPlaylist, []string, error) {
+	fetched := map[string]spotify.MusicPlaylist{}
+	names := map[string]string{}
+	snapshotIDs := map[string]string{}
+	for _, playlist := range listed {
+		names[playlist.IntegrationID] = playlist.Name
+		snapshotIDs[playlist.IntegrationID] = playlist.SnapshotID
+	}
+	entities := make([]job.Entity, len(fetch))
+	for i, id := range fetch {
+		entities[i] = job.Entity{ID: id, Name: names[id]}
+	}
+
+	run, resumed, err := jobs.Resume("sync", collection, entities)
+	if err != nil || !resumed {
+		return run, fetched, fetch, err
+	}
+	var remaining []string
+	for _, id := range fetch {
+		mp := spotify.MusicPlaylist{}
+		ok, err := run.Result(id, &mp)
+		if err != nil {
+			return run, nil, nil, err
+		}
+		if ok && mp.SnapshotID != "" && mp.SnapshotID == snapshotIDs[id] {
+			fetched[id] = mp
+		} else {
+			remaining = append(remaining, id)
+		}
+	}
+	slog.Info("sync: resuming", "job", run.ID, "done", len(fetched), "left", len(remaining))
+	return run, fetched, remaining, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/pyrat/spd/internal/color"
+	"github.com/pyrat/spd/internal/table"
+	"github.com/pyrat/spd/internal/tui"
+	flag "github.com/spf13/pflag"
+)
+
+// outputFormat is the value of --output, checked as it is parsed.
+type outputFormat string
+
+func (o *outputFormat) String() string { return string(*o) }
+func (o *outputFormat) Type() string   { return "format" }
+
+func (o *outputFormat) Set(value string) error {
+	format, err := table.ParseFormat(value)
+	if err != nil {
+		return err
+	}
+	*o = outputFormat(format)
+	return nil
+}
+
+// outputFlags are the flags of list commands choosing how they print.
+type outputFlags struct {
+	Format outputFormat
+	// JSON is the older --json, the same as --output json.
+	JSON bool
+	// Page pipes table output through a pager.
+	Page bool
+}
+
+// registerOutputFlags adds --output and --pager to a list command's flag
+// set, and --json, hidden, which scripts may still pass.
+func registerOutputFlags(fs *flag.FlagSet) *outputFlags {
+	flags := &outputFlags{Format: table.Text}
+	fs.Var(&flags.Format, "output", "print the output as a table, json or csv")
+	fs.BoolVar(&flags.JSON, "json", false, "print the output as json, the same as --output json")
+	fs.MarkHidden("json")
+	fs.BoolVar(&flags.Page, "pager", false, "page a table longer than the terminal through $PAGER, less by default")
+	return flags
+}
+
+// json reports whether the output is printed as json.
+func (o *outputFlags) json() bool {
+	return o.JSON || o.Format == table.JSON
+}
+
+// print prints v as json, or the tables as csv or aligned text: cut to
+// fit the terminal and paged when asked to. Several tables are printed
+// one after another, separated by a blank line.
+func (o *outputFlags) print(v interface{}, tables ...*table.Table) error {
+	if o.json() {
+		return json.NewEncoder(os.Stdout).Encode(v)
+	}
+
+	var buf bytes.Buffer
+	for i, t := range tables {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		var err error
+		if o.Format == table.CSV {
+			err = t.WriteCSV(&buf)
+		} else {
+			err = writeTable(&buf, t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if o.Page && o.Format == table.Text && isTerminal(os.Stdout) {
+		return page(&buf)
+	}
+	_, err := buf.WriteTo(os.Stdout)
+	return err
+}
+
+// writeTable writes a table to stdout, or on to it through w, as text:
+// cut to the terminal's width, with its headings colored.
+func writeTable(w io.Writer, t *table.Table) error {
+	t.HeaderStyle = stdoutColor.Func(color.Header)
+	return t.WriteText(w, terminalWidth())
+}
+
+// terminalWidth returns the columns of the terminal stdout is, from
+// $COLUMNS or the terminal itself, or 0 when it isn't one and tables are
+// printed in full.
+func terminalWidth() int {
+	if !isTerminal(os.Stdout) {
+		return 0
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	if _, cols, err := tui.Size(os.Stdout); err == nil {
+		return cols
+	}
+	return 0
+}
+
+// page pipes r through $PAGER, by default less quitting when r fits a
+// screen, or more on Windows. It prints r itself when the pager can't be
+// started.
+func page(r io.Reader) error {
+	pager := os.Getenv("PAGER")
+	var cmd *exec.Cmd
+	switch {
+	case pager != "" && runtime.GOOS == "windows":
+		cmd = exec.Command("cmd", "/c", pager)
+	case pager != "":
+		cmd = exec.Command("sh", "-c", pager)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("more")
+	default:
+		cmd = exec.Command("less", "-FRX")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		slog.Debug("starting pager", "err", err)
+		_, err := io.Copy(os.Stdout, r)
+		return err
+	}
+	// quitting the pager early closes the pipe, which isn't an error
+	io.Copy(stdin, r)
+	stdin.Close()
+	return cmd.Wait()
+}
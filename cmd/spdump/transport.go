@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pyrat/spd/pkg/spotify"
+	flag "github.com/spf13/pflag"
+)
+
+// transportOptions are how the running command reaches the API, from its
+// flags, overriding [http] in config.toml.
+type transportOptions struct {
+	RequestTimeout time.Duration
+	Proxy          string
+	CAFile         string
+}
+
+// transport holds the transport flags of the running command.
+var transport transportOptions
+
+// registerTransportFlags adds the transport flags to fs.
+func registerTransportFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&transport.RequestTimeout, "request-timeout", 0, "give up on a single API request after this long (default 15s)")
+	fs.StringVar(&transport.Proxy, "proxy", "", "send API requests through this HTTP proxy instead of $HTTPS_PROXY")
+	fs.StringVar(&transport.CAFile, "ca-file", "", "also trust the PEM certificates in this file, e.g. an intercepting proxy's CA")
+}
+
+// spotifyOptions returns the client options for the transport flags and
+// [http] in config, which may be nil when there is no config.toml.
+func (o transportOptions) spotifyOptions(config *toml.Tree) ([]spotify.Option, error) {
+	if config != nil {
+		if o.RequestTimeout == 0 {
+			if value, _ := config.Get("http.timeout").(string); value != "" {
+				d, err := time.ParseDuration(value)
+				if err != nil {
+					return nil, fmt.Errorf("http.timeout: %w", err)
+				}
+				o.RequestTimeout = d
+			}
+		}
+		if o.Proxy == "" {
+			o.Proxy, _ = config.Get("http.proxy").(string)
+		}
+		if o.CAFile == "" {
+			o.CAFile, _ = config.Get("http.ca_file").(string)
+		}
+	}
+
+	var opts []spotify.Option
+	if o.RequestTimeout > 0 {
+		opts = append(opts, spotify.WithTimeout(o.RequestTimeout))
+	}
+	if o.Proxy != "" {
+		proxyURL, err := url.Parse(o.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", o.Proxy)
+		}
+		opts = append(opts, spotify.WithProxy(proxyURL))
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", o.CAFile)
+		}
+		opts = append(opts, spotify.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	}
+	return opts, nil
+}
+
+// userTransportOptions returns the client options for a user token, which
+// doesn't need config.toml, so [http] is only read when it exists.
+func userTransportOptions() ([]spotify.Option, error) {
+	config, err := loadConfig()
+	if errors.Is(err, os.ErrNotExist) {
+		config, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return transport.spotifyOptions(config)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// showUsage is the --usage of the running command: report the resources
+// the run took once it ends.
+var showUsage bool
+
+// runStart is when the process started, near enough.
+var runStart = time.Now()
+
+// usageClients are the clients the running command made, whose API usage
+// is reported.
+var usageClients struct {
+	mu   sync.Mutex
+	list []*spotify.Client
+}
+
+// usageOnce makes sure the run is reported once.
+var usageOnce sync.Once
+
+// trackUsage adds the client's API calls to those --usage reports.
+func trackUsage(sp *spotify.Client) *spotify.Client {
+	usageClients.mu.Lock()
+	defer usageClients.mu.Unlock()
+	usageClients.list = append(usageClients.list, sp)
+	return sp
+}
+
+// reportRunUsage reports, with --usage, the wall and CPU time the run
+// took, its peak memory and the API calls of its clients, so the effect of
+// --concurrency or the cache can be compared between runs. It reports
+// once, however the run ended. The report goes to stderr, as a log record
+// with --log-format json.
+func reportRunUsage() {
+	if !showUsage {
+		return
+	}
+	usageOnce.Do(func() {
+		wall := time.Since(runStart)
+		cpu, peak, ok := processUsage()
+		if !ok {
+			// without the OS's figures, the memory the Go runtime holds
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			peak = int64(mem.Sys)
+		}
+		var stats spotify.Stats
+		usageClients.mu.Lock()
+		for _, sp := range usageClients.list {
+			s := sp.Stats()
+			stats.Requests += s.Requests
+			stats.Retries += s.Retries
+			stats.Errors += s.Errors
+			stats.CacheHits += s.CacheHits
+			stats.CacheFresh += s.CacheFresh
+			stats.BytesSent += s.BytesSent
+			stats.BytesReceived += s.BytesReceived
+		}
+		usageClients.mu.Unlock()
+
+		if logging.Format == "json" {
+			slog.Info("run usage",
+				"wall_seconds", wall.Seconds(),
+				"cpu_seconds", cpu.Seconds(),
+				"peak_memory_bytes", peak,
+				"api_calls", stats.Calls(),
+				"requests", stats.Requests,
+				"retries", stats.Retries,
+				"errors", stats.Errors,
+				"cache_hits", stats.CacheHits,
+				"cache_hit_rate", stats.CacheHitRate(),
+				"bytes_received", stats.BytesReceived)
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "wall time\t%s\n", wall.Round(time.Millisecond))
+		switch {
+		case ok && wall >= time.Second:
+			fmt.Fprintf(tw, "cpu time\t%s (%.0f%% of the wall time)\n", cpu.Round(time.Millisecond), cpu.Seconds()*100/wall.Seconds())
+		case ok:
+			// the runtime starting up outweighs a run this short
+			fmt.Fprintf(tw, "cpu time\t%s\n", cpu.Round(time.Millisecond))
+		default:
+			fmt.Fprintf(tw, "cpu time\t-\n")
+		}
+		if ok {
+			fmt.Fprintf(tw, "peak memory\t%s\n", formatBytes(peak))
+		} else {
+			fmt.Fprintf(tw, "memory\t%s held by the Go runtime\n", formatBytes(peak))
+		}
+		fmt.Fprintf(tw, "api calls\t%d (%d requests, %d retries, %d errors)\n", stats.Calls(), stats.Requests, stats.Retries, stats.Errors)
+		fmt.Fprintf(tw, "cache hits\t%d (%.1f%%)\n", stats.CacheHits, stats.CacheHitRate()*100)
+		fmt.Fprintf(tw, "downloaded\t%s\n", formatBytes(stats.BytesReceived))
+		tw.Flush()
+	})
+}
+
+// formatBytes formats a size in bytes with binary units, e.g. 12.3 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"time"
+)
+
+// processUsage doesn't know the process's CPU time and peak memory on
+// this platform.
+func processUsage() (time.Duration, int64, bool) {
+	return 0, 0, false
+}
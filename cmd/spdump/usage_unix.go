@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processUsage returns the CPU time the process has used, user and system,
+// and its peak resident memory in bytes.
+func processUsage() (time.Duration, int64, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, false
+	}
+	cpu := time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+	peak := int64(ru.Maxrss)
+	// macOS counts bytes, the other systems kilobytes
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		peak *= 1024
+	}
+	return cpu, peak, true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/bundle"
+	"github.com/pyrat/spd/internal/fixtures"
+	"github.com/pyrat/spd/internal/manifest"
+	flag "github.com/spf13/pflag"
+)
+
+// runVerify checks dumps written with a manifest, archive snapshots,
+// shard directories and bundles, against the checksums and item counts
+// of their manifest, and their playlists and tracks against the dump's
+// JSON Schema. A directory without a manifest, such as the root of an
+// archive, has every dump below it checked.
+//
+//	spdump verify archive
+//	spdump verify library.tar.zst
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: spdump verify <dir|bundle>")
+	}
+	root := fs.Arg(0)
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+
+	var dumps, failed int
+	report := func(dump string, m manifest.Manifest, problems []error) {
+		dumps++
+		if len(problems) > 0 {
+			failed++
+		}
+		for _, problem := range problems {
+			slog.Error("verification failed", "dump", dump, "error", problem)
+		}
+		slog.Info("verified dump", "dump", dump, "files", len(m.Files), "problems", len(problems), "version", m.Version)
+	}
+
+	if !info.IsDir() {
+		m, problems, err := verifyBundle(root)
+		if err != nil {
+			return fmt.Errorf("%s: %w", root, err)
+		}
+		report(root, m, problems)
+	} else {
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() || d.Name() != manifest.Name {
+				return err
+			}
+			dir := filepath.Dir(path)
+			m, problems, err := verifyDump(func(name string) ([]byte, error) {
+				return os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", dir, err)
+			}
+			report(dir, m, problems)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if dumps == 0 {
+		return fmt.Errorf("no %s in %s, dumps written before manifests can't be verified", manifest.Name, root)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d dumps failed verification", failed, dumps)
+	}
+	return nil
+}
+
+// verifyBundle checks the bundle at path, compressed as its extension
+// says.
+func verifyBundle(path string) (manifest.Manifest, []error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return manifest.Manifest{}, nil, err
+	}
+	defer f.Close()
+	files, err := bundle.ReadFiles(f, bundle.CompressionFor(path))
+	if err != nil {
+		return manifest.Manifest{}, nil, err
+	}
+	return verifyDump(func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, errors.New("missing from the bundle")
+		}
+		return data, nil
+	})
+}
+
+// verifyDump checks the dump whose files open reads against its
+// manifest. The playlists are those the dump's index lists, if it has
+// one.
+func verifyDump(open func(name string) ([]byte, error)) (manifest.Manifest, []error, error) {
+	data, err := open(manifest.Name)
+	if err != nil {
+		return manifest.Manifest{}, nil, err
+	}
+	m, err := manifest.Parse(data)
+	if err != nil {
+		return m, nil, err
+	}
+
+	playlists := map[string]bool{}
+	if data, err := open(archive.IndexName); err == nil {
+		index := archive.Snapshot{}
+		if json.Unmarshal(data, &index) == nil {
+			for _, entry := range index.Playlists {
+				playlists[entry.File] = true
+			}
+		}
+	}
+
+	safeOpen := func(name string) ([]byte, error) {
+		if !filepath.IsLocal(name) {
+			return nil, errors.New("outside the dump")
+		}
+		return open(name)
+	}
+	return m, m.Verify(safeOpen, func(name string, data []byte) (int, error) {
+		return checkDumpFile(name, data, playlists[name])
+	}), nil
+}
+
+// checkDumpFile validates a file of a dump, returning how many items the
+// manifest should count in it: the tracks of a playlist or the lines of a
+// shard.
+func checkDumpFile(name string, data []byte, playlist bool) (int, error) {
+	switch {
+	case playlist:
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return 0, err
+		}
+		if err := fixtures.Validate(v); err != nil {
+			return 0, err
+		}
+		tracks, _ := v.(map[string]interface{})["Tracks"].([]interface{})
+		return len(tracks), nil
+	case strings.Contains(name, ".ndjson"):
+		r, err := bundle.NewReader(bytes.NewReader(data), bundle.CompressionFor(name))
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+		dec := json.NewDecoder(r)
+		lines := 0
+		for {
+			var v interface{}
+			if err := dec.Decode(&v); err == io.EOF {
+				return lines, nil
+			} else if err != nil {
+				return lines, fmt.Errorf("line %d: %w", lines+1, err)
+			}
+			if err := fixtures.ValidateTrack(v); err != nil {
+				return lines, fmt.Errorf("line %d: %w", lines+1, err)
+			}
+			lines++
+		}
+	case name == archive.IndexName:
+		return 0, json.Unmarshal(data, &archive.Snapshot{})
+	case strings.HasSuffix(name, ".json") && !json.Valid(data):
+		return 0, errors.New("invalid JSON")
+	}
+	return 0, nil
+}
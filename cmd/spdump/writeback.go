@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/pyrat/spd/internal/writeback"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// applyOp makes the change op describes in the account of userID.
+func applyOp(ctx context.Context, sp *spotify.Client, userID string, op writeback.Op) error {
+	uris, local := op.URIs()
+	if local > 0 {
+		slog.Warn("skipping local files, they can't be added through the API", "playlist", op.Name, "tracks", local)
+	}
+
+	switch op.Kind {
+	case writeback.Create:
+		playlist, err := sp.CreatePlaylist(ctx, userID, op.Name, op.Public)
+		if err != nil {
+			return err
+		}
+		if err := sp.AddTracksToPlaylist(ctx, playlist.IntegrationID, uris); err != nil {
+			return err
+		}
+		slog.Info("created playlist", "name", op.Name, "id", playlist.IntegrationID, "tracks", len(uris))
+		return nil
+	case writeback.Replace:
+		if err := sp.ReplacePlaylistTracks(ctx, op.PlaylistID, uris); err != nil {
+			return err
+		}
+	case writeback.Add:
+		if err := sp.AddTracksToPlaylist(ctx, op.PlaylistID, uris); err != nil {
+			return err
+		}
+	case writeback.Remove:
+		if err := sp.RemoveTracksFromPlaylist(ctx, op.PlaylistID, uris); err != nil {
+			return err
+		}
+	case writeback.Reorder:
+		for _, move := range op.Moves {
+			if _, err := sp.ReorderPlaylist(ctx, op.PlaylistID, move.From, move.Before, 1, ""); err != nil {
+				return err
+			}
+		}
+		slog.Info("reordered tracks", "name", op.Name, "id", op.PlaylistID, "moves", len(op.Moves))
+		return nil
+	case writeback.Unfollow:
+		if err := sp.UnfollowPlaylist(ctx, op.PlaylistID); err != nil {
+			return err
+		}
+		slog.Info("unfollowed playlist", "name", op.Name, "id", op.PlaylistID)
+		return nil
+	case writeback.Visibility:
+		if err := sp.SetPlaylistVisibility(ctx, op.PlaylistID, op.Public, op.Collaborative); err != nil {
+			return err
+		}
+		slog.Info("changed visibility", "name", op.Name, "id", op.PlaylistID, "visibility", writeback.VisibilityName(op.Public, op.Collaborative))
+		return nil
+	}
+	slog.Info(string(op.Kind)+" tracks", "name", op.Name, "id", op.PlaylistID, "tracks", len(uris))
+	return nil
+}
+
+// printSimulation writes the playlists the ops would leave behind as a
+// json dump.
+func printSimulation(playlists []spotify.MusicPlaylist, ops []writeback.Op) error {
+	return json.NewEncoder(os.Stdout).Encode(writeback.Simulate(playlists, ops))
+}
@@ -1,9 +1,11 @@
 module github.com/pyrat/spd
 
-go 1.19
+go 1.22
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/opentracing/opentracing-go v1.2.0
 	github.com/pelletier/go-toml v1.9.5
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/sync v0.10.0
 )
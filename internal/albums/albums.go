@@ -0,0 +1,102 @@
+// Package albums checks how complete the albums a playlist draws on are,
+// for listeners who prefer whole albums to single tracks.
+package albums
+
+import (
+	"strings"
+
+	"github.com/pyrat/spd/internal/library"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Report is the completeness of the albums of a single playlist.
+type Report struct {
+	PlaylistID string
+	Name       string
+	Albums     []Album
+}
+
+// Album is an album some tracks of a playlist are on.
+type Album struct {
+	AlbumID string
+	Name    string
+	Artists string
+	// Present is how many of the album's tracks the playlist holds, Total
+	// how many the album has.
+	Present int
+	Total   int
+	// Missing are the tracks the playlist lacks, in album order.
+	Missing []spotify.MusicTrack `json:",omitempty"`
+}
+
+// Complete reports whether the playlist holds every track of the album.
+func (o Album) Complete() bool {
+	return len(o.Missing) == 0
+}
+
+// Missing returns the missing tracks of all the albums, album by album.
+func (o Report) Missing() []spotify.MusicTrack {
+	var missing []spotify.MusicTrack
+	for _, album := range o.Albums {
+		missing = append(missing, album.Missing...)
+	}
+	return missing
+}
+
+// Check reports on the albums of the playlist's tracks, in the order
+// they first appear, given the albums with all their tracks. Albums
+// missing from albums are left out, as are those with fewer than
+// minPresent tracks in the playlist.
+//
+// A track counts as present by its ID, the ID it was relinked from or,
+// for versions Spotify relinked differently, its title.
+func Check(mp spotify.MusicPlaylist, albums map[string]spotify.SpotifyAlbum, minPresent int) Report {
+	report := Report{PlaylistID: mp.IntegrationID, Name: mp.Name}
+
+	var order []string
+	ids := map[string]bool{}
+	titles := map[string]map[string]bool{}
+	for _, track := range mp.Tracks {
+		if track.Source == spotify.SourceLocal || track.Type == spotify.TypeEpisode || track.AlbumID == "" {
+			continue
+		}
+		if titles[track.AlbumID] == nil {
+			titles[track.AlbumID] = map[string]bool{}
+			order = append(order, track.AlbumID)
+		}
+		ids[track.IntegrationID] = true
+		if track.LinkedFrom != "" {
+			ids[track.LinkedFrom] = true
+		}
+		titles[track.AlbumID][library.NormalizeTitle(track.Name)] = true
+	}
+
+	for _, id := range order {
+		sa, ok := albums[id]
+		if !ok {
+			continue
+		}
+		album := Album{AlbumID: id, Name: sa.Name, Artists: albumArtists(sa), Total: len(sa.TracksCollection.Items)}
+		for _, st := range sa.TracksCollection.Items {
+			if ids[st.IntegrationID] || titles[id][library.NormalizeTitle(st.Name)] {
+				album.Present++
+				continue
+			}
+			// album tracks come without their album
+			st.Album = sa
+			album.Missing = append(album.Missing, spotify.ConvertToMusicTrack(st))
+		}
+		if album.Present >= minPresent {
+			report.Albums = append(report.Albums, album)
+		}
+	}
+	return report
+}
+
+func albumArtists(album spotify.SpotifyAlbum) string {
+	names := make([]string, len(album.Artists))
+	for i, artist := range album.Artists {
+		names[i] = artist.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,309 @@
+// Package archive stores playlist dumps on disk as timestamped snapshots,
+// building up a history of how playlists change over time.
+//
+// An archive is laid out as
+//
+//	<root>/<collection>/<timestamp>/index.json
+//	<root>/<collection>/<timestamp>/index.html
+//	<root>/<collection>/<timestamp>/manifest.json
+//	<root>/<collection>/<timestamp>/<playlist id>.json
+//
+// where a collection groups the snapshots of one set of playlists, e.g. a
+// user's library or a browse category, and timestamp is the snapshot time
+// in UTC. The manifest lists the checksums of the other files, see package
+// manifest.
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+	"github.com/pyrat/spd/internal/manifest"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// IndexName is the name of the index file of a snapshot.
+const IndexName = "index.json"
+
+// ChangesName is the name of the file describing what changed since the
+// previous snapshot, written by incremental backups.
+const ChangesName = "changes.json"
+
+// timestampLayout names snapshot directories, sortable and filesystem safe.
+const timestampLayout = "20060102T150405Z"
+
+// Archive is a directory of snapshots.
+type Archive struct {
+	Root string
+}
+
+// Snapshot describes a snapshot, as stored in its index file.
+type Snapshot struct {
+	Collection string
+	CreatedAt  time.Time
+	// Name labels snapshots taken as restore points.
+	Name      string `json:",omitempty"`
+	Playlists []Entry
+	// Dir is the directory holding the snapshot.
+	Dir string `json:"-"`
+}
+
+// Entry describes a playlist in a snapshot, see NewEntry.
+type Entry struct {
+	ID     string
+	Name   string
+	Tracks int
+	// Episodes counts the episodes among Tracks.
+	Episodes int `json:",omitempty"`
+	// DurationMS is the length of the playlist, Duration that formatted
+	// as m:ss, or h:mm:ss past an hour.
+	DurationMS int    `json:",omitempty"`
+	Duration   string `json:",omitempty"`
+	// SnapshotID is the version of the playlist dumped.
+	SnapshotID string `json:",omitempty"`
+	// File is the path of the playlist's file relative to the index.
+	File string
+}
+
+// Open opens the archive at root, creating the directory if needed.
+func Open(root string) (*Archive, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Archive{Root: root}, nil
+}
+
+// unsafeChars are replaced in collection names and file names.
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CollectionName turns a label such as "category:focus" into a directory
+// safe collection name.
+func CollectionName(label string) string {
+	return strings.Trim(unsafeChars.ReplaceAllString(label, "-"), "-")
+}
+
+// Writer writes a new snapshot. Close must be called to write its index
+// and manifest, snapshots without an index are ignored when reading.
+type Writer struct {
+	snapshot Snapshot
+	manifest *manifest.Manifest
+}
+
+// NewSnapshot starts a snapshot of collection taken at t.
+func (o *Archive) NewSnapshot(collection string, t time.Time) (*Writer, error) {
+	t = t.UTC().Truncate(time.Second)
+	dir := filepath.Join(o.Root, CollectionName(collection), t.Format(timestampLayout))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		snapshot: Snapshot{
+			Collection: CollectionName(collection),
+			CreatedAt:  t,
+			Dir:        dir,
+		},
+		manifest: manifest.New(t),
+	}, nil
+}
+
+// WritePlaylist adds a playlist to the snapshot.
+func (o *Writer) WritePlaylist(mp spotify.MusicPlaylist) error {
+	file := CollectionName(mp.IntegrationID) + ".json"
+	if err := o.write(file, mp, len(mp.Tracks)); err != nil {
+		return err
+	}
+	o.snapshot.Playlists = append(o.snapshot.Playlists, NewEntry(mp, file))
+	return nil
+}
+
+// WriteJSON writes v to a file of the snapshot other than a playlist,
+// such as ChangesName.
+func (o *Writer) WriteJSON(name string, v interface{}) error {
+	return o.write(name, v, 0)
+}
+
+// SetName labels the snapshot, so it can be found by Find.
+func (o *Writer) SetName(name string) {
+	o.snapshot.Name = name
+}
+
+// Close writes the indexes and the manifest, completing the snapshot.
+func (o *Writer) Close() (Snapshot, error) {
+	page, err := o.snapshot.HTML()
+	if err != nil {
+		return o.snapshot, err
+	}
+	if err := o.writeData(IndexHTMLName, page, 0); err != nil {
+		return o.snapshot, err
+	}
+	if err := o.write(IndexName, o.snapshot, 0); err != nil {
+		return o.snapshot, err
+	}
+	data, err := o.manifest.Marshal()
+	if err != nil {
+		return o.snapshot, err
+	}
+	return o.snapshot, writeFile(filepath.Join(o.snapshot.Dir, manifest.Name), data)
+}
+
+// write writes v to the file name of the snapshot and lists it in the
+// manifest with its items.
+func (o *Writer) write(name string, v interface{}, items int) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return o.writeData(name, data, items)
+}
+
+// writeData writes data to the file name of the snapshot and lists it in
+// the manifest with its items.
+func (o *Writer) writeData(name string, data []byte, items int) error {
+	if err := writeFile(filepath.Join(o.snapshot.Dir, name), data); err != nil {
+		return err
+	}
+	o.manifest.Add(name, data, items)
+	return nil
+}
+
+// Collections lists the collections in the archive.
+func (o *Archive) Collections() ([]string, error) {
+	entries, err := os.ReadDir(o.Root)
+	if err != nil {
+		return nil, err
+	}
+	var collections []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			collections = append(collections, entry.Name())
+		}
+	}
+	return collections, nil
+}
+
+// Snapshots lists the complete snapshots of a collection, oldest first.
+func (o *Archive) Snapshots(collection string) ([]Snapshot, error) {
+	dir := filepath.Join(o.Root, CollectionName(collection))
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := readIndex(filepath.Join(dir, entry.Name()))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Latest returns the newest snapshot of a collection, false when there
+// is none.
+func (o *Archive) Latest(collection string) (Snapshot, bool, error) {
+	snapshots, err := o.Snapshots(collection)
+	if err != nil || len(snapshots) == 0 {
+		return Snapshot{}, false, err
+	}
+	return snapshots[len(snapshots)-1], true, nil
+}
+
+// Find returns the snapshot of a collection with the given name, the
+// newest one when several share it. A snapshot can also be referred to by
+// its timestamp directory, e.g. 20240102T150405Z.
+func (o *Archive) Find(collection string, ref string) (Snapshot, error) {
+	snapshots, err := o.Snapshots(collection)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Name == ref || filepath.Base(snapshots[i].Dir) == ref {
+			return snapshots[i], nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("no snapshot %q in %s", ref, CollectionName(collection))
+}
+
+// LatestSnapshots returns the newest snapshot of every collection.
+func (o *Archive) LatestSnapshots() ([]Snapshot, error) {
+	collections, err := o.Collections()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, collection := range collections {
+		snapshot, ok, err := o.Latest(collection)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+// ReadPlaylists reads all playlists of a snapshot.
+func (o Snapshot) ReadPlaylists() ([]spotify.MusicPlaylist, error) {
+	playlists := make([]spotify.MusicPlaylist, 0, len(o.Playlists))
+	for _, entry := range o.Playlists {
+		mp, err := o.ReadPlaylist(entry)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, mp)
+	}
+	return playlists, nil
+}
+
+// ReadPlaylist reads a single playlist of the snapshot.
+func (o Snapshot) ReadPlaylist(entry Entry) (spotify.MusicPlaylist, error) {
+	mp := spotify.MusicPlaylist{}
+	data, err := os.ReadFile(filepath.Join(o.Dir, entry.File))
+	if err != nil {
+		return mp, err
+	}
+	if err := json.Unmarshal(data, &mp); err != nil {
+		return mp, fmt.Errorf("%s: %w", entry.File, err)
+	}
+	return mp, nil
+}
+
+// readIndex reads the index of the snapshot in dir.
+func readIndex(dir string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	data, err := os.ReadFile(filepath.Join(dir, IndexName))
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("%s: %w", dir, err)
+	}
+	snapshot.Dir = dir
+	return snapshot, nil
+}
+
+// writeFile writes data to path atomically.
+func writeFile(path string, data []byte) error {
+	return atomicfile.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// IndexHTMLName is the name of the index of a snapshot for people, a
+// page linking to each playlist's file.
+const IndexHTMLName = "index.html"
+
+// NewEntry returns the index entry of a playlist written to file, a path
+// relative to the index.
+func NewEntry(mp spotify.MusicPlaylist, file string) Entry {
+	entry := Entry{
+		ID:         mp.IntegrationID,
+		Name:       mp.Name,
+		Tracks:     len(mp.Tracks),
+		SnapshotID: mp.SnapshotID,
+		File:       file,
+	}
+	var length time.Duration
+	for _, track := range mp.Tracks {
+		if track.Type == spotify.TypeEpisode {
+			entry.Episodes++
+		}
+		length += track.Length()
+	}
+	entry.DurationMS = int(length / time.Millisecond)
+	entry.Duration = spotify.FormatDuration(length)
+	return entry
+}
+
+// Length returns the length of the playlist.
+func (o Entry) Length() time.Duration {
+	return time.Duration(o.DurationMS) * time.Millisecond
+}
+
+// indexPage lists the playlists of a snapshot, with their totals.
+type indexPage struct {
+	Snapshot
+	Tracks   int
+	Episodes int
+	Duration string
+}
+
+var indexHTML = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Collection}} {{.CreatedAt.Format "2006-01-02 15:04:05"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+td.n { text-align: right; }
+tfoot td { border-top: 1px solid #999; font-weight: bold; }
+code { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Collection}}{{if .Name}} ({{.Name}}){{end}}</h1>
+<p>{{len .Playlists}} playlists dumped {{.CreatedAt.Format "2006-01-02 15:04:05"}} UTC</p>
+<table>
+<thead><tr><th>Playlist</th><th>Tracks</th><th>Episodes</th><th>Duration</th><th>Snapshot ID</th><th>File</th></tr></thead>
+<tbody>
+{{range .Playlists}}<tr><td><a href="{{.File}}">{{.Name}}</a></td><td class="n">{{.Tracks}}</td><td class="n">{{.Episodes}}</td><td class="n">{{.Duration}}</td><td><code>{{.SnapshotID}}</code></td><td><code>{{.File}}</code></td></tr>
+{{end}}</tbody>
+<tfoot><tr><td>Total</td><td class="n">{{.Tracks}}</td><td class="n">{{.Episodes}}</td><td class="n">{{.Duration}}</td><td></td><td></td></tr></tfoot>
+</table>
+</body>
+</html>
+`))
+
+// HTML renders the index of the snapshot as a page, written next to
+// index.json as IndexHTMLName.
+func (o Snapshot) HTML() ([]byte, error) {
+	page := indexPage{Snapshot: o}
+	var length time.Duration
+	for _, entry := range o.Playlists {
+		page.Tracks += entry.Tracks
+		page.Episodes += entry.Episodes
+		length += entry.Length()
+	}
+	page.Duration = spotify.FormatDuration(length)
+	var buf bytes.Buffer
+	if err := indexHTML.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
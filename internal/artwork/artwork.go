@@ -0,0 +1,203 @@
+// Package artwork downloads the cover images referenced by playlist dumps
+// so archives can be browsed offline.
+package artwork
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// ManifestName is the name of the manifest written into the art directory.
+const ManifestName = "manifest.json"
+
+// Manifest maps playlists and tracks to the local paths of their cover
+// images, relative to the art directory.
+type Manifest struct {
+	Playlists map[string]string `json:"playlists"`
+	Tracks    map[string]string `json:"tracks"`
+}
+
+// Downloader downloads cover images into Dir. Images are stored under a
+// hash of their URL so every image is only downloaded once however many
+// tracks share it.
+type Downloader struct {
+	Dir string
+	// MaxSize is the largest width in pixels to download, the biggest
+	// image no wider than this is picked. Zero picks the biggest image.
+	MaxSize int
+	Client  *http.Client
+
+	manifest   Manifest
+	downloaded map[string]string
+}
+
+// NewDownloader initialises a Downloader writing into dir, creating it
+// when missing.
+func NewDownloader(dir string, maxSize int) (*Downloader, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Downloader{
+		Dir:     dir,
+		MaxSize: maxSize,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		manifest: Manifest{
+			Playlists: map[string]string{},
+			Tracks:    map[string]string{},
+		},
+		downloaded: map[string]string{},
+	}, nil
+}
+
+// AddPlaylist downloads the cover of the playlist and the album art of
+// all its tracks.
+func (o *Downloader) AddPlaylist(ctx context.Context, mp spotify.MusicPlaylist) error {
+	var images []spotify.SpotifyAlbumImage
+	for _, image := range mp.PlaylistArt {
+		images = append(images, spotify.SpotifyAlbumImage(image))
+	}
+
+	path, err := o.download(ctx, images)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		o.manifest.Playlists[mp.IntegrationID] = path
+	}
+
+	for _, track := range mp.Tracks {
+		if err := o.AddTrack(ctx, track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTrack downloads the album art of the track.
+func (o *Downloader) AddTrack(ctx context.Context, mt spotify.MusicTrack) error {
+	path, err := o.download(ctx, mt.AlbumArt)
+	if err != nil {
+		return err
+	}
+	if path != "" && mt.IntegrationID != "" {
+		o.manifest.Tracks[mt.IntegrationID] = path
+	}
+	return nil
+}
+
+// Close writes the manifest.
+func (o *Downloader) Close() error {
+	data, err := json.MarshalIndent(o.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(o.Dir, ManifestName), data, 0o644)
+}
+
+// Pick returns the URL of the largest image no wider than maxSize, or of
+// the smallest image when all are wider. Zero maxSize picks the largest.
+func Pick(images []spotify.SpotifyAlbumImage, maxSize int) string {
+	var best, smallest *spotify.SpotifyAlbumImage
+	for i := range images {
+		image := &images[i]
+		if smallest == nil || image.Width < smallest.Width {
+			smallest = image
+		}
+		if maxSize > 0 && image.Width > maxSize {
+			continue
+		}
+		if best == nil || image.Width > best.Width {
+			best = image
+		}
+	}
+	if best == nil {
+		best = smallest
+	}
+	if best == nil {
+		return ""
+	}
+	return best.URL
+}
+
+// download fetches the picked image unless it is already on disk and
+// returns its path relative to Dir.
+func (o *Downloader) download(ctx context.Context, images []spotify.SpotifyAlbumImage) (string, error) {
+	imageURL := Pick(images, o.MaxSize)
+	if imageURL == "" {
+		return "", nil
+	}
+	if path, ok := o.downloaded[imageURL]; ok {
+		return path, nil
+	}
+
+	sum := sha256.Sum256([]byte(imageURL))
+	name := hex.EncodeToString(sum[:16])
+
+	// a previous run may already have fetched it, only files renamed into
+	// place count, not the temporary files of a run killed midway
+	for _, ext := range extensions {
+		if _, err := os.Stat(filepath.Join(o.Dir, name+ext)); err == nil {
+			o.downloaded[imageURL] = name + ext
+			return name + ext, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// a missing image shouldn't abort the whole dump, it is
+	// just left out of the manifest.
+	if resp.StatusCode != 200 {
+		slog.Warn("skipping artwork", "url", imageURL, "status", resp.Status)
+		return "", nil
+	}
+
+	path := name + extension(resp.Header.Get("Content-Type"))
+	err = atomicfile.Write(filepath.Join(o.Dir, path), 0o600, func(w io.Writer) error {
+		_, err := io.Copy(w, resp.Body)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	o.downloaded[imageURL] = path
+	return path, nil
+}
+
+// extensions are those extension returns.
+var extensions = []string{".jpg", ".png", ".webp"}
+
+// extension returns the file extension for an image content type.
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".jpg"
+}
@@ -0,0 +1,54 @@
+package atomicfile_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "queue.json")
+
+	var v []string
+	if err := atomicfile.ReadJSON(path, &v); err != nil || v != nil {
+		t.Fatalf("reading a missing file gave %v, %v", v, err)
+	}
+	if err := atomicfile.WriteJSON(path, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := atomicfile.ReadJSON(path, &v); err != nil || len(v) != 2 {
+		t.Fatalf("read back %v, %v", v, err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("state file has mode %v, want 0600", info.Mode())
+	}
+
+	// a failed write leaves the old file and no temporary one
+	failed := errors.New("failed")
+	if err := atomicfile.Write(path, 0o600, func(w io.Writer) error {
+		w.Write([]byte("half"))
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Errorf("got %v, want the write's error", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files left next to the target", len(entries))
+	}
+	if err := atomicfile.ReadJSON(path, &v); err != nil || len(v) != 2 {
+		t.Errorf("old file read back as %v, %v", v, err)
+	}
+
+	if err := atomicfile.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := atomicfile.Remove(path); err != nil {
+		t.Errorf("removing a missing file: %v", err)
+	}
+}
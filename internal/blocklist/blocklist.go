@@ -0,0 +1,171 @@
+// Package blocklist keeps a persistent list of artists, tracks and labels
+// which must never end up in playlists spdump writes.
+package blocklist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// DefaultFile is where the blocklist is kept unless told otherwise.
+const DefaultFile = "blocklist.json"
+
+// Kinds of entries.
+const (
+	KindArtist = "artist"
+	KindTrack  = "track"
+	KindLabel  = "label"
+)
+
+// List is a blocklist. Artists are artist IDs or names, tracks are track
+// IDs and labels are record label names, names compared ignoring case.
+type List struct {
+	Artists []string `json:",omitempty"`
+	Tracks  []string `json:",omitempty"`
+	Labels  []string `json:",omitempty"`
+}
+
+// Load reads the blocklist at path, an empty one when the file doesn't
+// exist yet.
+func Load(path string) (*List, error) {
+	list := &List{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return list, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// Save writes the blocklist to path.
+func (o *List) Save(path string) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Empty reports whether nothing is blocked.
+func (o *List) Empty() bool {
+	return len(o.Artists) == 0 && len(o.Tracks) == 0 && len(o.Labels) == 0
+}
+
+// entries returns the entries of a kind.
+func (o *List) entries(kind string) (*[]string, error) {
+	switch kind {
+	case KindArtist:
+		return &o.Artists, nil
+	case KindTrack:
+		return &o.Tracks, nil
+	case KindLabel:
+		return &o.Labels, nil
+	}
+	return nil, fmt.Errorf("unknown blocklist entry %q, expected artist, track or label", kind)
+}
+
+// Normalize turns the value of an entry into the form it is stored in:
+// the ID of an artist or track given as a URI or link. Artists can also
+// be given by name.
+func Normalize(kind string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case KindArtist:
+		if strings.HasPrefix(value, "spotify:") || strings.Contains(value, "/") {
+			return spotify.ParseID(value, spotify.TypeArtist)
+		}
+	case KindTrack:
+		return spotify.ParseID(value, spotify.TypeTrack)
+	}
+	return value, nil
+}
+
+// Add adds an entry, false when it was already there.
+func (o *List) Add(kind string, value string) (bool, error) {
+	entries, err := o.entries(kind)
+	if err != nil {
+		return false, err
+	}
+	if value, err = Normalize(kind, value); err != nil {
+		return false, err
+	}
+	if contains(*entries, value) {
+		return false, nil
+	}
+	*entries = append(*entries, value)
+	return true, nil
+}
+
+// Remove removes an entry, false when it wasn't there.
+func (o *List) Remove(kind string, value string) (bool, error) {
+	entries, err := o.entries(kind)
+	if err != nil {
+		return false, err
+	}
+	if value, err = Normalize(kind, value); err != nil {
+		return false, err
+	}
+	for i, entry := range *entries {
+		if strings.EqualFold(entry, value) {
+			*entries = append((*entries)[:i], (*entries)[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Blocked reports whether the track is blocked. label is the record label
+// of its album, empty when unknown.
+func (o *List) Blocked(track spotify.MusicTrack, label string) bool {
+	if contains(o.Tracks, track.IntegrationID) {
+		return true
+	}
+	if label != "" && contains(o.Labels, label) {
+		return true
+	}
+	for _, artist := range track.ArtistList {
+		if contains(o.Artists, artist.IntegrationID) || contains(o.Artists, artist.Name) {
+			return true
+		}
+	}
+	if len(track.ArtistList) == 0 {
+		for _, name := range strings.Split(track.Artists, ", ") {
+			if contains(o.Artists, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filter returns the tracks which aren't blocked and the number dropped.
+// labels maps album IDs to their record label and may be nil.
+func (o *List) Filter(tracks []spotify.MusicTrack, labels map[string]string) ([]spotify.MusicTrack, int) {
+	kept := make([]spotify.MusicTrack, 0, len(tracks))
+	for _, track := range tracks {
+		if !o.Blocked(track, labels[track.AlbumID]) {
+			kept = append(kept, track)
+		}
+	}
+	return kept, len(tracks) - len(kept)
+}
+
+func contains(entries []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Package budget watches the error rate of a long running spdump over a
+// sliding window, so that an archiver silently failing, e.g. on a revoked
+// token or a deprecated endpoint, is noticed within hours rather than
+// months.
+package budget
+
+import (
+	"fmt"
+	"time"
+)
+
+// Budget is the share of requests allowed to fail over a window. Feed it
+// the client's counts with Observe, it tells when the budget is exceeded
+// and when the errors are back within it.
+type Budget struct {
+	// MaxRate is the fraction of requests allowed to fail, e.g. 0.1.
+	MaxRate float64
+	// Window is how far back requests count.
+	Window time.Duration
+	// MinRequests is how many requests the window needs before its rate
+	// is taken seriously, a single failure out of two isn't an outage.
+	MinRequests int64
+
+	samples  []sample
+	exceeded bool
+}
+
+// allFailed is how many calls failing with none succeeding exceed the
+// budget however few MinRequests asks for: a broken token fails the one
+// call of every run.
+const allFailed = 3
+
+// sample is the client's counts at a time.
+type sample struct {
+	at               time.Time
+	requests, errors int64
+}
+
+// Status is the state of the budget over the window.
+type Status struct {
+	Requests int64
+	Errors   int64
+	// Rate is the fraction of the requests which failed.
+	Rate     float64
+	Window   time.Duration
+	Exceeded bool
+}
+
+// String describes the status in a line, for notifications.
+func (o Status) String() string {
+	state := "within"
+	if o.Exceeded {
+		state = "exceeded"
+	}
+	return fmt.Sprintf("error budget %s: %d of %d spotify requests failed (%.0f%%) in the last %s",
+		state, o.Errors, o.Requests, o.Rate*100, o.Window)
+}
+
+// Observe records the client's total requests and errors at t, returning
+// the status over the window and whether it changed: the budget was just
+// exceeded, or the errors are back within it.
+func (o *Budget) Observe(t time.Time, requests int64, errors int64) (Status, bool) {
+	o.samples = append(o.samples, sample{at: t, requests: requests, errors: errors})
+
+	// the latest sample from before the window is the baseline the
+	// window's counts are taken from
+	cutoff := t.Add(-o.Window)
+	for len(o.samples) > 1 && !o.samples[1].at.After(cutoff) {
+		o.samples = o.samples[1:]
+	}
+	base := o.samples[0]
+
+	status := Status{
+		Requests: requests - base.requests,
+		Errors:   errors - base.errors,
+		Window:   o.Window,
+	}
+	// calls failing for want of a token never became requests
+	status.Requests = max(status.Requests, status.Errors)
+	if status.Requests > 0 {
+		status.Rate = float64(status.Errors) / float64(status.Requests)
+	}
+	switch {
+	case status.Errors >= allFailed && status.Errors == status.Requests:
+		status.Exceeded = true
+	case status.Errors == status.Requests:
+		// nothing succeeded, yet too little was tried to tell
+		status.Exceeded = o.exceeded
+	default:
+		status.Exceeded = status.Requests >= o.MinRequests && status.Rate > o.MaxRate
+	}
+
+	changed := status.Exceeded != o.exceeded
+	o.exceeded = status.Exceeded
+	return status, changed
+}
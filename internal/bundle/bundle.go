@@ -0,0 +1,223 @@
+// Package bundle writes compressed dumps: a single output stream through
+// gzip or zstd, or a bundle packing every playlist of a dump into one
+// tar archive with an index, ready to be seeded as a torrent.
+//
+// A bundle is laid out like an archive snapshot, with the index and the
+// manifest listing the checksums of the other files last:
+//
+//	<playlist id>.json
+//	index.html
+//	index.json
+//	manifest.json
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/manifest"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Compression is a compression format.
+type Compression string
+
+// The compression formats. None leaves the output as it is.
+const (
+	None Compression = ""
+	Gzip Compression = "gzip"
+	Zstd Compression = "zstd"
+)
+
+// ParseCompression checks a compression name.
+func ParseCompression(name string) (Compression, error) {
+	switch c := Compression(name); c {
+	case None, Gzip, Zstd:
+		return c, nil
+	}
+	return None, fmt.Errorf("unknown compression %q, expected gzip or zstd", name)
+}
+
+// CompressionFor returns the compression a file name's extension calls
+// for, e.g. zstd for out.tar.zst.
+func CompressionFor(name string) Compression {
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return Gzip
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".tzst"):
+		return Zstd
+	}
+	return None
+}
+
+// Extension returns the file extension of the compression, e.g. ".gz".
+func (o Compression) Extension() string {
+	switch o {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// NewWriter returns a writer compressing to w. Closing it flushes the
+// compressed stream, w itself isn't closed.
+func NewWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	}
+	return nopCloser{w}, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Writer writes a bundle. Close must be called to add the index and
+// finish the archive.
+type Writer struct {
+	compressed io.WriteCloser
+	tar        *tar.Writer
+	index      archive.Snapshot
+	manifest   *manifest.Manifest
+}
+
+// NewBundle starts a bundle of the collection's playlists written to w
+// with the compression.
+func NewBundle(w io.Writer, c Compression, collection string, t time.Time) (*Writer, error) {
+	compressed, err := NewWriter(w, c)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		compressed: compressed,
+		tar:        tar.NewWriter(compressed),
+		index: archive.Snapshot{
+			Collection: archive.CollectionName(collection),
+			CreatedAt:  t.UTC().Truncate(time.Second),
+		},
+		manifest: manifest.New(t),
+	}, nil
+}
+
+// WritePlaylist adds a playlist to the bundle.
+func (o *Writer) WritePlaylist(mp spotify.MusicPlaylist) error {
+	file := archive.CollectionName(mp.IntegrationID) + ".json"
+	if err := o.writeJSON(file, mp, len(mp.Tracks)); err != nil {
+		return err
+	}
+	o.index.Playlists = append(o.index.Playlists, archive.NewEntry(mp, file))
+	return nil
+}
+
+// Close adds the indexes and the manifest and finishes the archive and
+// its compression.
+func (o *Writer) Close() (archive.Snapshot, error) {
+	page, err := o.index.HTML()
+	if err != nil {
+		return o.index, err
+	}
+	if err := o.writeFile(archive.IndexHTMLName, page); err != nil {
+		return o.index, err
+	}
+	o.manifest.Add(archive.IndexHTMLName, page, 0)
+	if err := o.writeJSON(archive.IndexName, o.index, 0); err != nil {
+		return o.index, err
+	}
+	data, err := o.manifest.Marshal()
+	if err != nil {
+		return o.index, err
+	}
+	if err := o.writeFile(manifest.Name, data); err != nil {
+		return o.index, err
+	}
+	if err := o.tar.Close(); err != nil {
+		return o.index, err
+	}
+	return o.index, o.compressed.Close()
+}
+
+// writeJSON adds v to the archive as the file name and lists it in the
+// manifest with its items.
+func (o *Writer) writeJSON(name string, v interface{}, items int) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := o.writeFile(name, data); err != nil {
+		return err
+	}
+	o.manifest.Add(name, data, items)
+	return nil
+}
+
+// writeFile adds data to the archive as the file name.
+func (o *Writer) writeFile(name string, data []byte) error {
+	err := o.tar.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: o.index.CreatedAt,
+		Format:  tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = o.tar.Write(data)
+	return err
+}
+
+// NewReader returns a reader decompressing r with c.
+func NewReader(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// ReadFiles reads back the files of a bundle from r, compressed with c,
+// by name.
+func ReadFiles(r io.Reader, c Compression) (map[string][]byte, error) {
+	dr, err := NewReader(r, c)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(dr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if files[header.Name], err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+}
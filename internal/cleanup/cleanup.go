@@ -0,0 +1,128 @@
+// Package cleanup finds the followed playlists which are dead weight in a
+// library: deleted by their owner, emptied, or left unchanged for years,
+// judged by the archive's history of them.
+package cleanup
+
+import (
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Reason is why a playlist is up for unfollowing.
+type Reason string
+
+// The reasons, in the order they are checked.
+const (
+	Deleted   Reason = "deleted"
+	Empty     Reason = "empty"
+	Unchanged Reason = "unchanged"
+)
+
+// Candidate is a followed playlist up for unfollowing.
+type Candidate struct {
+	PlaylistID string
+	Name       string
+	OwnerID    string
+	Reason     Reason
+	// UnchangedSince is how long the playlist is known to have been
+	// as it is, when the archive tells.
+	UnchangedSince *time.Time `json:",omitempty"`
+}
+
+// History returns since when the archive's snapshots, oldest first, have
+// held the current version of each playlist, current mapping playlist IDs
+// to their snapshot IDs. Playlists the archive doesn't hold in their
+// current version are left out.
+//
+// A playlist unchanged across every snapshot holding it may be older
+// still: it is taken to be unchanged since the last track was added to
+// it, when that is earlier.
+func History(snapshots []archive.Snapshot, current map[string]string) (map[string]time.Time, error) {
+	since := map[string]time.Time{}
+	// walked newest first, a playlist is settled once an older
+	// snapshot holds another version of it or doesn't hold it at all
+	settled := map[string]bool{}
+	var oldest map[string]spotify.MusicPlaylist
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		held := map[string]bool{}
+		for _, entry := range snapshot.Playlists {
+			snapshotID, ok := current[entry.ID]
+			if !ok || settled[entry.ID] {
+				continue
+			}
+			held[entry.ID] = true
+			mp, err := snapshot.ReadPlaylist(entry)
+			if err != nil {
+				return nil, err
+			}
+			if mp.SnapshotID == "" || mp.SnapshotID != snapshotID {
+				settled[entry.ID] = true
+				continue
+			}
+			since[entry.ID] = snapshot.CreatedAt
+			if oldest == nil {
+				oldest = map[string]spotify.MusicPlaylist{}
+			}
+			oldest[entry.ID] = mp
+		}
+		for id := range since {
+			if !held[id] {
+				settled[id] = true
+			}
+		}
+	}
+
+	for id, mp := range oldest {
+		if settled[id] {
+			continue
+		}
+		if added := lastAdded(mp); added != nil && added.Before(since[id]) {
+			since[id] = *added
+		}
+	}
+	return since, nil
+}
+
+// lastAdded returns when the last track was added to the playlist, nil
+// when that isn't known.
+func lastAdded(mp spotify.MusicPlaylist) *time.Time {
+	var last *time.Time
+	for _, track := range mp.Tracks {
+		if track.AddedAt != nil && (last == nil || track.AddedAt.After(*last)) {
+			last = track.AddedAt
+		}
+	}
+	return last
+}
+
+// Find returns the followed playlists up for unfollowing: those deleted,
+// empty, or unchanged since before cutoff according to since, as returned
+// by History. A zero cutoff leaves unchanged playlists be.
+func Find(followed []spotify.SpotifyPlaylist, deleted map[string]bool, since map[string]time.Time, cutoff time.Time) []Candidate {
+	var candidates []Candidate
+	for _, playlist := range followed {
+		candidate := Candidate{
+			PlaylistID: playlist.IntegrationID,
+			Name:       playlist.Name,
+			OwnerID:    playlist.Owner.IntegrationID,
+		}
+		if t, ok := since[playlist.IntegrationID]; ok {
+			candidate.UnchangedSince = &t
+		}
+		switch {
+		case deleted[playlist.IntegrationID]:
+			candidate.Reason = Deleted
+		case playlist.TracksCollection.Total == 0:
+			candidate.Reason = Empty
+		case !cutoff.IsZero() && candidate.UnchangedSince != nil && candidate.UnchangedSince.Before(cutoff):
+			candidate.Reason = Unchanged
+		default:
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
@@ -0,0 +1,99 @@
+// Package clipboard reads and writes the system clipboard through the
+// platform's own tools: pbcopy and pbpaste on macOS, PowerShell on Windows,
+// and wl-clipboard, xclip or xsel on Linux and the BSDs.
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no clipboard tool is installed.
+var ErrUnavailable = errors.New("no clipboard tool found, install wl-clipboard, xclip or xsel")
+
+// tool is a pair of commands copying stdin to the clipboard and pasting
+// it to stdout.
+type tool struct {
+	copy  []string
+	paste []string
+}
+
+// Windows PowerShell reads and writes the console in the OEM code page
+// unless told otherwise.
+const (
+	windowsCopy  = "[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"
+	windowsPaste = "[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard -Raw"
+)
+
+// find returns the clipboard tool of the platform.
+func find() (tool, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return tool{copy: []string{"pbcopy"}, paste: []string{"pbpaste"}}, nil
+	case "windows":
+		return tool{
+			copy:  []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", windowsCopy},
+			paste: []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", windowsPaste},
+		}, nil
+	}
+
+	candidates := []tool{
+		{copy: []string{"xclip", "-selection", "clipboard", "-in"}, paste: []string{"xclip", "-selection", "clipboard", "-out"}},
+		{copy: []string{"xsel", "--clipboard", "--input"}, paste: []string{"xsel", "--clipboard", "--output"}},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		wayland := tool{copy: []string{"wl-copy"}, paste: []string{"wl-paste", "--no-newline"}}
+		candidates = append([]tool{wayland}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate.copy[0]); err == nil {
+			return candidate, nil
+		}
+	}
+	return tool{}, ErrUnavailable
+}
+
+// Read returns the text on the clipboard.
+func Read() (string, error) {
+	t, err := find()
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(t.paste[0], t.paste[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", commandError(cmd, err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// Write replaces the clipboard's contents with data.
+func Write(data []byte) error {
+	t, err := find()
+	if err != nil {
+		return err
+	}
+	// xclip and wl-copy stay in the background serving the clipboard,
+	// holding on to a stderr pipe Run would wait to close
+	cmd := exec.Command(t.copy[0], t.copy[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	if err := cmd.Run(); err != nil {
+		return commandError(cmd, err, "")
+	}
+	return nil
+}
+
+// commandError describes a clipboard command which failed, with what it
+// printed.
+func commandError(cmd *exec.Cmd, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("clipboard: %s: %w: %s", cmd.Args[0], err, msg)
+	}
+	return fmt.Errorf("clipboard: %s: %w", cmd.Args[0], err)
+}
@@ -0,0 +1,208 @@
+// Package collage composes a grid of album covers into a single image,
+// such as a playlist cover.
+package collage
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"sort"
+
+	// cover art is served as jpeg, sometimes png
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Grid is the number of columns and rows of a collage.
+type Grid struct {
+	Cols int
+	Rows int
+}
+
+// ParseGrid parses a grid such as "3x3".
+func ParseGrid(s string) (Grid, error) {
+	grid := Grid{}
+	if _, err := fmt.Sscanf(s, "%dx%d", &grid.Cols, &grid.Rows); err != nil || grid.Cols < 1 || grid.Rows < 1 {
+		return grid, fmt.Errorf("invalid grid %q, expected e.g. 3x3", s)
+	}
+	return grid, nil
+}
+
+// Tiles is the number of images in the grid.
+func (o Grid) Tiles() int {
+	return o.Cols * o.Rows
+}
+
+// TopAlbumCovers returns the cover URLs of the n albums with the most
+// tracks in the playlist, ties broken by first appearance. Albums are told
+// apart by their cover, the smallest one at least tileSize wide is picked.
+// When the playlist has fewer albums than n the covers are repeated.
+func TopAlbumCovers(mp spotify.MusicPlaylist, n int, tileSize int) []string {
+	type albumCount struct {
+		cover string
+		count int
+		first int
+	}
+
+	counts := map[string]*albumCount{}
+	for i, track := range mp.Tracks {
+		cover := pick(track.AlbumArt, tileSize)
+		if cover == "" {
+			continue
+		}
+		if ac, ok := counts[cover]; ok {
+			ac.count++
+			continue
+		}
+		counts[cover] = &albumCount{cover: cover, count: 1, first: i}
+	}
+
+	ranked := make([]*albumCount, 0, len(counts))
+	for _, ac := range counts {
+		ranked = append(ranked, ac)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].count != ranked[j].count {
+			return ranked[i].count > ranked[j].count
+		}
+		return ranked[i].first < ranked[j].first
+	})
+
+	var covers []string
+	for i := 0; len(ranked) > 0 && i < n; i++ {
+		covers = append(covers, ranked[i%len(ranked)].cover)
+	}
+	return covers
+}
+
+// pick returns the smallest image at least minWidth wide, or the biggest
+// image when none is wide enough.
+func pick(images []spotify.SpotifyAlbumImage, minWidth int) string {
+	var best *spotify.SpotifyAlbumImage
+	for i := range images {
+		image := &images[i]
+		switch {
+		case best == nil:
+			best = image
+		case best.Width < minWidth:
+			if image.Width > best.Width {
+				best = image
+			}
+		case image.Width >= minWidth && image.Width < best.Width:
+			best = image
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.URL
+}
+
+// Fetch downloads and decodes the images at urls, downloading each
+// distinct URL once.
+func Fetch(ctx context.Context, client *http.Client, urls []string) ([]image.Image, error) {
+	fetched := map[string]image.Image{}
+	images := make([]image.Image, 0, len(urls))
+	for _, u := range urls {
+		if img, ok := fetched[u]; ok {
+			images = append(images, img)
+			continue
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error downloading cover %s: %s", u, resp.Status)
+		}
+		img, _, err := image.Decode(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding cover %s: %w", u, err)
+		}
+
+		fetched[u] = img
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// Compose lays the images out row by row in the grid, each scaled to
+// a tile of tileSize pixels square. Missing tiles are left black.
+func Compose(images []image.Image, grid Grid, tileSize int) image.Image {
+	out := image.NewRGBA(image.Rect(0, 0, grid.Cols*tileSize, grid.Rows*tileSize))
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	for i, img := range images {
+		if i >= grid.Tiles() {
+			break
+		}
+		x := (i % grid.Cols) * tileSize
+		y := (i / grid.Cols) * tileSize
+		tile := scale(img, cropSquare(img), tileSize)
+		draw.Draw(out, image.Rect(x, y, x+tileSize, y+tileSize), tile, image.Point{}, draw.Src)
+	}
+	return out
+}
+
+// cropSquare returns the centred square of img.
+func cropSquare(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// scale resamples the src rectangle of img to size x size pixels by
+// averaging the source pixels covering each destination pixel, which is
+// plenty for cover art and avoids pulling in an imaging library.
+func scale(img image.Image, src image.Rectangle, size int) image.Image {
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	side := src.Dx()
+
+	for dy := 0; dy < size; dy++ {
+		y0 := src.Min.Y + dy*side/size
+		y1 := src.Min.Y + (dy+1)*side/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < size; dx++ {
+			x0 := src.Min.X + dx*side/size
+			x1 := src.Min.X + (dx+1)*side/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := img.At(x, y).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			out.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return out
+}
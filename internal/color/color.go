@@ -0,0 +1,145 @@
+// Package color colors terminal output with ANSI escapes: table headings,
+// the lines of diffs and log levels, in one of a few themes. Output is
+// only colored on a terminal unless asked to, and never when NO_COLOR is
+// set (https://no-color.org) unless asked to explicitly.
+package color
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Modes of --color.
+const (
+	Auto   = "auto"
+	Always = "always"
+	Never  = "never"
+)
+
+// Roles are what is colored, the keys of a theme.
+const (
+	Header  = "header"
+	Added   = "added"
+	Removed = "removed"
+	Moved   = "moved"
+	Changed = "changed"
+	Error   = "error"
+	Warn    = "warn"
+	Info    = "info"
+	Debug   = "debug"
+)
+
+// Theme maps roles to the SGR parameters they are drawn with, e.g. "1;31"
+// for bold red. Roles missing are left plain.
+type Theme map[string]string
+
+// Themes are the themes to choose from.
+var Themes = map[string]Theme{
+	// default suits the dark backgrounds most terminals have
+	"default": {
+		Header:  "1;36",
+		Added:   "32",
+		Removed: "31",
+		Moved:   "33",
+		Changed: "35",
+		Error:   "1;31",
+		Warn:    "33",
+		Info:    "32",
+		Debug:   "2",
+	},
+	// light avoids yellow and cyan, which are hard to read on white
+	"light": {
+		Header:  "1;34",
+		Added:   "32",
+		Removed: "31",
+		Moved:   "35",
+		Changed: "34",
+		Error:   "1;31",
+		Warn:    "1;35",
+		Info:    "34",
+		Debug:   "2",
+	},
+	// mono sets text apart by weight and style alone, for terminals
+	// with a palette of their own or readers who can't tell red from
+	// green
+	"mono": {
+		Header:  "1;4",
+		Added:   "1",
+		Removed: "9",
+		Moved:   "3",
+		Changed: "4",
+		Error:   "1;7",
+		Warn:    "1",
+		Debug:   "2",
+	},
+}
+
+// ThemeNames returns the names of the themes, sorted.
+func ThemeNames() []string {
+	var names []string
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Painter colors text for one output stream, or leaves it as it is when
+// color is off. The zero Painter leaves text plain.
+type Painter struct {
+	theme Theme
+}
+
+// New returns the painter of output written to f in the theme, colored
+// as mode says: always, never, or auto when f is a terminal and neither
+// NO_COLOR is set nor TERM is dumb.
+func New(mode string, theme string, f *os.File) (Painter, error) {
+	t, ok := Themes[theme]
+	if !ok {
+		return Painter{}, fmt.Errorf("unknown theme %q, expected %s", theme, strings.Join(ThemeNames(), ", "))
+	}
+	switch mode {
+	case Always:
+		return Painter{theme: t}, nil
+	case Never:
+		return Painter{}, nil
+	case Auto:
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(f) {
+			return Painter{}, nil
+		}
+		return Painter{theme: t}, nil
+	}
+	return Painter{}, fmt.Errorf("unknown color mode %q, expected auto, always or never", mode)
+}
+
+// Enabled reports whether the painter colors anything.
+func (o Painter) Enabled() bool {
+	return o.theme != nil
+}
+
+// Paint colors s as the role is in the theme.
+func (o Painter) Paint(role string, s string) string {
+	sgr := o.theme[role]
+	if sgr == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + sgr + "m" + s + "\x1b[0m"
+}
+
+// Func returns Paint for the role, e.g. to style the headings of a table.
+func (o Painter) Func(role string) func(string) string {
+	return func(s string) string {
+		return o.Paint(role, s)
+	}
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,304 @@
+// Package diff compares two versions of a set of playlists, e.g. two
+// snapshots of an archive, track by track, catching the edits Spotify
+// makes to the catalog details of tracks kept too.
+package diff
+
+import (
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Playlist statuses.
+const (
+	StatusAdded   = "added"
+	StatusRemoved = "removed"
+	StatusChanged = "changed"
+)
+
+// PlaylistChange describes how a playlist changed.
+type PlaylistChange struct {
+	ID       string
+	Name     string
+	Status   string
+	Added    []spotify.MusicTrack `json:",omitempty"`
+	Removed  []spotify.MusicTrack `json:",omitempty"`
+	Moved    []TrackMove          `json:",omitempty"`
+	Metadata []FieldChange        `json:",omitempty"`
+	// Edited are the tracks in both versions whose details changed in
+	// the catalog, such as a retitled track or replaced album art.
+	Edited []TrackEdit `json:",omitempty"`
+}
+
+// TrackMove is a track which changed position relative to the other
+// tracks. From and To are its zero based positions in the old and new
+// playlist.
+type TrackMove struct {
+	Track spotify.MusicTrack
+	From  int
+	To    int
+}
+
+// TrackEdit is a track whose details changed, as it is now.
+type TrackEdit struct {
+	Track  spotify.MusicTrack
+	Fields []FieldChange
+}
+
+// FieldChange is a changed playlist or track detail.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Changes lists the playlists which changed, in the order of the newer
+// version with removed playlists last.
+type Changes struct {
+	Playlists []PlaylistChange
+}
+
+// Empty reports whether nothing changed.
+func (o Changes) Empty() bool {
+	return len(o.Playlists) == 0
+}
+
+// Totals returns the number of tracks added, removed and moved across
+// playlists.
+func (o Changes) Totals() (added int, removed int, moved int) {
+	for _, change := range o.Playlists {
+		added += len(change.Added)
+		removed += len(change.Removed)
+		moved += len(change.Moved)
+	}
+	return added, removed, moved
+}
+
+// Edited returns the number of tracks edited, each counted once however
+// many playlists hold it.
+func (o Changes) Edited() int {
+	seen := map[string]bool{}
+	for _, change := range o.Playlists {
+		for _, edit := range change.Edited {
+			seen[TrackKey(edit.Track)] = true
+		}
+	}
+	return len(seen)
+}
+
+// Compare returns the changes from the old to the new playlists.
+func Compare(old []spotify.MusicPlaylist, new []spotify.MusicPlaylist) Changes {
+	before := map[string]spotify.MusicPlaylist{}
+	for _, mp := range old {
+		before[mp.IntegrationID] = mp
+	}
+
+	changes := Changes{}
+	seen := map[string]bool{}
+	for _, mp := range new {
+		seen[mp.IntegrationID] = true
+		prev, ok := before[mp.IntegrationID]
+		if !ok {
+			changes.Playlists = append(changes.Playlists, PlaylistChange{
+				ID:     mp.IntegrationID,
+				Name:   mp.Name,
+				Status: StatusAdded,
+				Added:  mp.Tracks,
+			})
+			continue
+		}
+
+		change := PlaylistChange{ID: mp.IntegrationID, Name: mp.Name, Status: StatusChanged}
+		change.Added = subtract(mp.Tracks, prev.Tracks)
+		change.Removed = subtract(prev.Tracks, mp.Tracks)
+		change.Moved = moves(prev.Tracks, mp.Tracks)
+		change.Metadata = metadata(prev, mp)
+		change.Edited = edits(prev.Tracks, mp.Tracks)
+		if len(change.Added) > 0 || len(change.Removed) > 0 || len(change.Moved) > 0 || len(change.Metadata) > 0 || len(change.Edited) > 0 {
+			changes.Playlists = append(changes.Playlists, change)
+		}
+	}
+
+	for _, mp := range old {
+		if !seen[mp.IntegrationID] {
+			changes.Playlists = append(changes.Playlists, PlaylistChange{
+				ID:      mp.IntegrationID,
+				Name:    mp.Name,
+				Status:  StatusRemoved,
+				Removed: mp.Tracks,
+			})
+		}
+	}
+	return changes
+}
+
+// TrackKey identifies a track across dumps, by ID or for local files,
+// which have none, by name and artists.
+func TrackKey(track spotify.MusicTrack) string {
+	if track.IntegrationID != "" {
+		return track.IntegrationID
+	}
+	return track.Name + "\x00" + track.Artists
+}
+
+// subtract returns the tracks of a missing from b, counting duplicates, so
+// a track added a second time shows up as added.
+func subtract(a []spotify.MusicTrack, b []spotify.MusicTrack) []spotify.MusicTrack {
+	counts := map[string]int{}
+	for _, track := range b {
+		counts[TrackKey(track)]++
+	}
+	var missing []spotify.MusicTrack
+	for _, track := range a {
+		key := TrackKey(track)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		missing = append(missing, track)
+	}
+	return missing
+}
+
+// moves returns the tracks in both playlists which changed their order.
+// The tracks kept in place are the longest run still in the old order,
+// the rest are reported as moved. The n-th occurrence of a duplicated
+// track is matched with its n-th occurrence in the other playlist.
+func moves(old []spotify.MusicTrack, new []spotify.MusicTrack) []TrackMove {
+	// positions in the new playlist of each occurrence of a track
+	positions := map[string][]int{}
+	for i, track := range new {
+		key := TrackKey(track)
+		positions[key] = append(positions[key], i)
+	}
+
+	// the tracks in both, in old order, with their new positions
+	type pair struct{ from, to int }
+	var common []pair
+	for i, track := range old {
+		key := TrackKey(track)
+		if p := positions[key]; len(p) > 0 {
+			common = append(common, pair{i, p[0]})
+			positions[key] = p[1:]
+		}
+	}
+
+	// longest increasing subsequence of the new positions, the tracks
+	// on it stayed in order relative to each other
+	tails := []int{} // index into common of the smallest tail per length
+	prev := make([]int, len(common))
+	for i, p := range common {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if common[tails[mid]].to < p.to {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
+	}
+	kept := make([]bool, len(common))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			kept[i] = true
+		}
+	}
+
+	var moved []TrackMove
+	for i, p := range common {
+		if !kept[i] {
+			moved = append(moved, TrackMove{Track: new[p.to], From: p.from, To: p.to})
+		}
+	}
+	return moved
+}
+
+// edits returns the tracks in both playlists whose details changed, the
+// n-th occurrence of a duplicated track compared with its n-th occurrence
+// in the other playlist, and each track reported once.
+func edits(old []spotify.MusicTrack, new []spotify.MusicTrack) []TrackEdit {
+	occurrences := map[string][]spotify.MusicTrack{}
+	for _, track := range old {
+		key := TrackKey(track)
+		occurrences[key] = append(occurrences[key], track)
+	}
+
+	var edited []TrackEdit
+	reported := map[string]bool{}
+	for _, track := range new {
+		key := TrackKey(track)
+		prev := occurrences[key]
+		if len(prev) == 0 {
+			continue
+		}
+		occurrences[key] = prev[1:]
+		if reported[key] {
+			continue
+		}
+		if fields := trackFields(prev[0], track); len(fields) > 0 {
+			reported[key] = true
+			edited = append(edited, TrackEdit{Track: track, Fields: fields})
+		}
+	}
+	return edited
+}
+
+// trackFields returns the changed catalog details of a track: its title
+// and artists, and its album's title, art and release date, or the
+// release date of an episode. Details which depend on the listener or
+// the playlist, like playability or when it was added, are left out.
+func trackFields(old spotify.MusicTrack, new spotify.MusicTrack) []FieldChange {
+	var changes []FieldChange
+	compare := func(field string, old string, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	compare("Name", old.Name, new.Name)
+	compare("Artists", old.Artists, new.Artists)
+	compare("AlbumName", old.AlbumName, new.AlbumName)
+	// art missing from either was most likely left out of the dump
+	if oldArt, newArt := albumArt(old), albumArt(new); oldArt != "" && newArt != "" {
+		compare("AlbumArt", oldArt, newArt)
+	}
+	compare("AlbumReleaseDate", old.AlbumReleaseDate, new.AlbumReleaseDate)
+	compare("ReleaseDate", old.ReleaseDate, new.ReleaseDate)
+	return changes
+}
+
+// albumArt returns the URL of the track's biggest album image.
+func albumArt(track spotify.MusicTrack) string {
+	if len(track.AlbumArt) == 0 {
+		return ""
+	}
+	return track.AlbumArt[0].URL
+}
+
+// metadata returns the changed details of a playlist. The snapshot ID is
+// left out, it changes with every edit.
+func metadata(old spotify.MusicPlaylist, new spotify.MusicPlaylist) []FieldChange {
+	var changes []FieldChange
+	if old.Name != new.Name {
+		changes = append(changes, FieldChange{Field: "Name", Old: old.Name, New: new.Name})
+	}
+	if oldArt, newArt := cover(old), cover(new); oldArt != newArt {
+		changes = append(changes, FieldChange{Field: "PlaylistArt", Old: oldArt, New: newArt})
+	}
+	return changes
+}
+
+// cover returns the URL of the playlist's biggest cover image.
+func cover(mp spotify.MusicPlaylist) string {
+	if len(mp.PlaylistArt) == 0 {
+		return ""
+	}
+	return mp.PlaylistArt[0].URL
+}
@@ -0,0 +1,234 @@
+// Package digest sums up what changed in a collection of an archive over
+// a period, such as a week of syncs, as an email: the summary of the
+// changes, a table of the new tracks with their album art, and the
+// collection as it is now attached as CSV, for a passive record in an
+// inbox.
+//
+// When a digest was last sent is kept in the archive as
+//
+//	<root>/.digests/<collection>.json
+package digest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/diff"
+	"github.com/pyrat/spd/internal/hook"
+	"github.com/pyrat/spd/internal/mail"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// stateDir is the directory of an archive holding when digests were sent,
+// hidden from its collections.
+const stateDir = ".digests"
+
+// maxListed is how many new tracks the body lists, the attachment holds
+// them all.
+const maxListed = 100
+
+// Digest is what changed in a collection over a period.
+type Digest struct {
+	Collection string
+	Since      time.Time
+	Until      time.Time
+	Summary    hook.Summary
+	NewTracks  []NewTrack
+	// Playlists are the collection's playlists as they are now.
+	Playlists []spotify.MusicPlaylist
+}
+
+// NewTrack is a track added to a playlist over the period.
+type NewTrack struct {
+	Playlist string
+	spotify.MusicTrack
+}
+
+// Art returns the URL of the track's smallest album image, a thumbnail
+// for the table.
+func (o NewTrack) Art() string {
+	if len(o.AlbumArt) == 0 {
+		return ""
+	}
+	return o.AlbumArt[len(o.AlbumArt)-1].URL
+}
+
+// Build compares the collection's latest snapshot with the one it had at
+// since, or its oldest when it has none as old.
+func Build(arc *archive.Archive, collection string, since time.Time) (Digest, error) {
+	snapshots, err := arc.Snapshots(collection)
+	if err != nil {
+		return Digest{}, err
+	}
+	if len(snapshots) == 0 {
+		return Digest{}, fmt.Errorf("no snapshots of %s", collection)
+	}
+	base := snapshots[0]
+	for _, snapshot := range snapshots {
+		if snapshot.CreatedAt.After(since) {
+			break
+		}
+		base = snapshot
+	}
+	latest := snapshots[len(snapshots)-1]
+
+	old, err := base.ReadPlaylists()
+	if err != nil {
+		return Digest{}, err
+	}
+	current, err := latest.ReadPlaylists()
+	if err != nil {
+		return Digest{}, err
+	}
+	changes := diff.Compare(old, current)
+
+	d := Digest{
+		Collection: collection,
+		Since:      base.CreatedAt,
+		Until:      latest.CreatedAt,
+		Summary:    hook.Summarize(collection, latest.CreatedAt, changes),
+		Playlists:  current,
+	}
+	for _, change := range changes.Playlists {
+		for _, track := range change.Added {
+			d.NewTracks = append(d.NewTracks, NewTrack{Playlist: change.Name, MusicTrack: track})
+		}
+	}
+	return d, nil
+}
+
+// Message renders the digest as an email with the csv attached.
+func (o Digest) Message(from string, to []string, csv []byte) (mail.Message, error) {
+	data := struct {
+		Digest
+		Listed []NewTrack
+		More   int
+	}{Digest: o, Listed: o.NewTracks}
+	if len(data.Listed) > maxListed {
+		data.Listed, data.More = data.Listed[:maxListed], len(data.Listed)-maxListed
+	}
+
+	var text, html bytes.Buffer
+	if err := textBody.Execute(&text, data); err != nil {
+		return mail.Message{}, err
+	}
+	if err := htmlBody.Execute(&html, data); err != nil {
+		return mail.Message{}, err
+	}
+	msg := mail.Message{
+		From:    from,
+		To:      to,
+		Subject: fmt.Sprintf("spdump digest for %s: %d new tracks", o.Collection, len(o.NewTracks)),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}
+	if csv != nil {
+		msg.Attachments = append(msg.Attachments, mail.Attachment{
+			Name:        archive.CollectionName(o.Collection) + "-" + o.Until.UTC().Format("2006-01-02") + ".csv",
+			ContentType: "text/csv",
+			Data:        csv,
+		})
+	}
+	return msg, nil
+}
+
+var funcs = map[string]interface{}{
+	"date": func(t time.Time) string { return t.Local().Format("Jan 2, 2006") },
+}
+
+var textBody = texttemplate.Must(texttemplate.New("text").Funcs(funcs).Parse(`Changes to {{ .Collection }} from {{ date .Since }} to {{ date .Until }}
+
+{{ .Summary.Message }}
+{{ range .Summary.Playlists }}
+- {{ .Name }}: {{ .Status }}, {{ .Added }} added, {{ .Removed }} removed, {{ .Moved }} moved
+{{- end }}
+{{ if .Listed }}
+New tracks:
+{{ range .Listed }}
+- {{ .Artists }} - {{ .Name }} ({{ .Playlist }})
+{{- end }}
+{{- if .More }}
+- and {{ .More }} more
+{{- end }}
+{{ end }}
+The collection as it is now is attached as CSV.
+`))
+
+var htmlBody = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222;">
+<h2>Changes to {{ .Collection }}</h2>
+<p>{{ date .Since }} to {{ date .Until }}: {{ .Summary.Added }} tracks added, {{ .Summary.Removed }} removed, {{ .Summary.Moved }} moved{{ with .Summary.Edited }}, {{ . }} edited{{ end }}.</p>
+{{- if .Summary.Playlists }}
+<ul>
+{{- range .Summary.Playlists }}
+<li><b>{{ .Name }}</b>: {{ .Status }}, {{ .Added }} added, {{ .Removed }} removed, {{ .Moved }} moved</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- if .Listed }}
+<h3>New tracks</h3>
+<table style="border-collapse: collapse;">
+{{- range .Listed }}
+<tr>
+<td style="padding: 4px;">{{ with .Art }}<img src="{{ . }}" width="48" height="48" alt="">{{ end }}</td>
+<td style="padding: 4px;">{{ if .ExternalURL }}<a href="{{ .ExternalURL }}">{{ .Name }}</a>{{ else }}{{ .Name }}{{ end }}<br><small>{{ .Artists }}{{ with .AlbumName }} · {{ . }}{{ end }}</small></td>
+<td style="padding: 4px; color: #888;">{{ .Playlist }}</td>
+</tr>
+{{- end }}
+</table>
+{{- if .More }}
+<p>and {{ .More }} more.</p>
+{{- end }}
+{{- end }}
+<p style="color: #888;">The collection as it is now is attached as CSV.</p>
+</body>
+</html>
+`))
+
+// state is when a collection's digest was last sent.
+type state struct {
+	SentAt time.Time
+}
+
+func statePath(arc *archive.Archive, collection string) string {
+	return filepath.Join(arc.Root, stateDir, archive.CollectionName(collection)+".json")
+}
+
+// LastSent returns when the collection's digest was last sent, the zero
+// time when never.
+func LastSent(arc *archive.Archive, collection string) (time.Time, error) {
+	data, err := os.ReadFile(statePath(arc, collection))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return time.Time{}, err
+	}
+	return s.SentAt, nil
+}
+
+// MarkSent records that the collection's digest was sent at t.
+func MarkSent(arc *archive.Archive, collection string, t time.Time) error {
+	path := statePath(arc, collection)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state{SentAt: t.UTC()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,150 @@
+// Package dump reads playlist dumps written by spdump back in, those
+// written as json, ndjson or ndjson-tracks. The other output formats, such
+// as csv and markdown, are for people and other tools and can't be read
+// back.
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// errNotJSON is returned for dumps in an output format which can't be
+// read back.
+var errNotJSON = errors.New("not a json, ndjson or ndjson-tracks dump, other output formats such as csv can't be read back")
+
+// checkStart checks the first non whitespace byte of a dump starts a JSON
+// object or array.
+func checkStart(first byte) error {
+	if first == 0 {
+		return errors.New("empty dump")
+	}
+	if first != '{' && first != '[' {
+		return errNotJSON
+	}
+	return nil
+}
+
+// trackLine is a line of ndjson-tracks output.
+type trackLine struct {
+	PlaylistID   string
+	PlaylistName string
+	spotify.MusicTrack
+}
+
+// ReadFile reads the playlists of the dump at path, "-" reads stdin.
+func ReadFile(path string) ([]spotify.MusicPlaylist, error) {
+	if path == "-" {
+		return Read(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	playlists, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return playlists, nil
+}
+
+// Read reads the playlists of a dump. It accepts a single playlist object,
+// an array of playlists, ndjson with one playlist per line and ndjson with
+// one track per line, which is regrouped into playlists.
+func Read(r io.Reader) ([]spotify.MusicPlaylist, error) {
+	br := bufio.NewReader(r)
+
+	first, err := peekNonSpace(br)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStart(first); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(br)
+
+	if first == '[' {
+		var playlists []spotify.MusicPlaylist
+		if err := dec.Decode(&playlists); err != nil {
+			return nil, err
+		}
+		return playlists, nil
+	}
+
+	var playlists []spotify.MusicPlaylist
+	index := map[string]int{}
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// ndjson-tracks lines carry the playlist they belong to
+		if bytes.Contains(raw, []byte(`"PlaylistID"`)) {
+			line := trackLine{}
+			if err := json.Unmarshal(raw, &line); err != nil {
+				return nil, err
+			}
+			i, ok := index[line.PlaylistID]
+			if !ok {
+				i = len(playlists)
+				index[line.PlaylistID] = i
+				playlists = append(playlists, spotify.MusicPlaylist{
+					Name:          line.PlaylistName,
+					IntegrationID: line.PlaylistID,
+				})
+			}
+			playlists[i].Tracks = append(playlists[i].Tracks, line.MusicTrack)
+			continue
+		}
+
+		mp := spotify.MusicPlaylist{}
+		if err := json.Unmarshal(raw, &mp); err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, mp)
+	}
+	return playlists, nil
+}
+
+// peekNonSpace returns the first non whitespace byte without consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return 0, fmt.Errorf("empty dump")
+			}
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// ReadFiles reads and concatenates the playlists of several dumps.
+func ReadFiles(paths ...string) ([]spotify.MusicPlaylist, error) {
+	var playlists []spotify.MusicPlaylist
+	for _, path := range paths {
+		read, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, read...)
+	}
+	return playlists, nil
+}
@@ -0,0 +1,149 @@
+package dump_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pyrat/spd/internal/dump"
+	"github.com/pyrat/spd/internal/fixtures"
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// encoding is a dump and the playlists reading it back gives.
+type encoding struct {
+	data []byte
+	want []model.MusicPlaylist
+}
+
+// encodings writes the library in each dump format.
+func encodings(t *testing.T, library []model.MusicPlaylist) map[string]encoding {
+	t.Helper()
+	encode := func(v interface{}) []byte {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	var ndjson, ndjsonTracks bytes.Buffer
+	var regrouped []model.MusicPlaylist
+	for _, mp := range library {
+		ndjson.Write(append(encode(mp), '\n'))
+		if len(mp.Tracks) == 0 {
+			// an ndjson-tracks dump has no line for an empty playlist
+			continue
+		}
+		regrouped = append(regrouped, model.MusicPlaylist{Name: mp.Name, IntegrationID: mp.IntegrationID, Tracks: mp.Tracks})
+		for _, track := range mp.Tracks {
+			ndjsonTracks.Write(append(encode(struct {
+				PlaylistID   string
+				PlaylistName string
+				model.MusicTrack
+			}{mp.IntegrationID, mp.Name, track}), '\n'))
+		}
+	}
+	return map[string]encoding{
+		"json":          {encode(library), library},
+		"json object":   {encode(library[0]), library[:1]},
+		"ndjson":        {ndjson.Bytes(), library},
+		"ndjson-tracks": {ndjsonTracks.Bytes(), regrouped},
+	}
+}
+
+// sameJSON fails the test unless got and want encode the same.
+func sameJSON(t *testing.T, name string, got []model.MusicPlaylist, want []model.MusicPlaylist) {
+	t.Helper()
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("%s: read back %d playlists differing from the %d written", name, len(got), len(want))
+	}
+}
+
+func TestRead(t *testing.T) {
+	library := fixtures.Generate(fixtures.Options{Playlists: 4, Tracks: 60, Seed: 1})
+	for name, e := range encodings(t, library) {
+		got, err := dump.Read(bytes.NewReader(e.data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		sameJSON(t, name, got, e.want)
+	}
+}
+
+// streamed collects the playlists a stream hands over.
+type streamed struct {
+	playlists []model.MusicPlaylist
+}
+
+func (o *streamed) playlist(mp model.MusicPlaylist) error {
+	mp.Tracks = nil
+	o.playlists = append(o.playlists, mp)
+	return nil
+}
+
+func (o *streamed) track(track dump.Track) error {
+	mp := &o.playlists[len(o.playlists)-1]
+	if mp.IntegrationID != track.PlaylistID || track.Position != len(mp.Tracks)+1 {
+		return os.ErrInvalid
+	}
+	mp.Tracks = append(mp.Tracks, track.MusicTrack)
+	return nil
+}
+
+func TestStream(t *testing.T) {
+	library := fixtures.Generate(fixtures.Options{Playlists: 4, Tracks: 60, Seed: 2})
+	dir := t.TempDir()
+	for name, e := range encodings(t, library) {
+		s := &streamed{}
+		if err := dump.Stream(bytes.NewReader(e.data), s.playlist, s.track); err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else {
+			sameJSON(t, name, s.playlists, e.want)
+		}
+
+		// files are memory mapped where the platform allows
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, e.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		s = &streamed{}
+		if err := dump.StreamFile(path, s.playlist, s.track); err != nil {
+			t.Errorf("%s file: %v", name, err)
+		} else {
+			sameJSON(t, name+" file", s.playlists, e.want)
+		}
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	for _, data := range []string{"", "  \n", `"playlist"`, `[{"Name":"a","Tracks":[{"Name":`, `{"Name":"a"} [`} {
+		if err := dump.Stream(bytes.NewReader([]byte(data)), nil, nil); err == nil {
+			t.Errorf("streamed %q without an error", data)
+		}
+	}
+}
+
+func TestNotJSON(t *testing.T) {
+	csv := []byte("Name,Artists,Album\nSong,Artist,Album\n")
+	path := filepath.Join(t.TempDir(), "library.csv")
+	if err := os.WriteFile(path, csv, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, readErr := dump.Read(bytes.NewReader(csv))
+	for name, err := range map[string]error{
+		"Read":       readErr,
+		"Stream":     dump.Stream(bytes.NewReader(csv), nil, nil),
+		"StreamFile": dump.StreamFile(path, nil, nil),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "can't be read back") {
+			t.Errorf("%s: got %v, want an error saying csv dumps can't be read back", name, err)
+		}
+	}
+}
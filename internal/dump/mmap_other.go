@@ -0,0 +1,12 @@
+//go:build !unix
+
+package dump
+
+import (
+	"os"
+)
+
+// mapFile doesn't map files on this platform.
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	return nil, nil, errNoMmap
+}
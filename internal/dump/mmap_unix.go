@@ -0,0 +1,26 @@
+//go:build unix
+
+package dump
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file into memory read only, returning its contents
+// and the function unmapping them.
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	if !fi.Mode().IsRegular() || size == 0 || int64(int(size)) != size {
+		return nil, nil, errNoMmap
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, errNoMmap
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
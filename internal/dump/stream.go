@@ -0,0 +1,327 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// errNoMmap is returned by mapFile for files which can't be mapped, which
+// are read as a stream instead.
+var errNoMmap = errors.New("file can't be memory mapped")
+
+// Track is a track of a dumped playlist, as streamed by Stream.
+type Track struct {
+	PlaylistID   string
+	PlaylistName string
+	// Position is the position of the track in its playlist, from 1.
+	Position int
+	spotify.MusicTrack
+}
+
+// PlaylistFunc is called with the details of every playlist streamed,
+// without its tracks, before any of them.
+type PlaylistFunc func(mp spotify.MusicPlaylist) error
+
+// TrackFunc is called with every track streamed.
+type TrackFunc func(track Track) error
+
+// StreamFile streams the dump at path like Stream, "-" streams stdin.
+// Files are memory mapped where the platform allows, so not even a whole
+// playlist is read onto the heap: only the playlist details and a single
+// track at a time are decoded, however large the dump.
+func StreamFile(path string, playlist PlaylistFunc, track TrackFunc) error {
+	if path == "-" {
+		return Stream(os.Stdin, playlist, track)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, unmap, err := mapFile(f)
+	if errors.Is(err, errNoMmap) {
+		err = Stream(f, playlist, track)
+	} else if err == nil {
+		err = streamDump(json.NewDecoder(bytes.NewReader(data)), data, playlist, track)
+		if unmapErr := unmap(); err == nil {
+			err = unmapErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// StreamFiles streams several dumps one after another.
+func StreamFiles(paths []string, playlist PlaylistFunc, track TrackFunc) error {
+	for _, path := range paths {
+		if err := StreamFile(path, playlist, track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stream reads a dump in any of the formats Read accepts, handing over
+// each playlist's details and then its tracks one at a time instead of
+// collecting them, so a dump larger than memory can be analysed. Either
+// function may be nil. A single playlist is held in memory at a time,
+// StreamFile avoids even that.
+func Stream(r io.Reader, playlist PlaylistFunc, track TrackFunc) error {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err != nil {
+		return err
+	}
+	if err := checkStart(first); err != nil {
+		return err
+	}
+	return streamDump(json.NewDecoder(br), nil, playlist, track)
+}
+
+// streamDump streams the playlists, or ndjson track lines, read by dec.
+// When data holds the whole dump its objects are sliced out of it rather
+// than copied.
+func streamDump(dec *json.Decoder, data []byte, playlist PlaylistFunc, track TrackFunc) error {
+	s := streamer{playlist: playlist, track: track, positions: map[string]int{}}
+
+	// a json dump of several playlists is an array of them
+	array := false
+	if data != nil {
+		if err := checkStart(firstByte(data)); err != nil {
+			return err
+		}
+		array = firstByte(data) == '['
+	}
+	if data == nil || array {
+		if tok, err := dec.Token(); err == io.EOF {
+			return errors.New("empty dump")
+		} else if err != nil {
+			return err
+		} else if delim, ok := tok.(json.Delim); ok && delim == '[' {
+			array = true
+		} else if ok && delim == '{' && data == nil {
+			// a single object, which is read whole
+			raw, err := readObjectRest(dec)
+			if err != nil {
+				return err
+			}
+			if err := s.object(raw); err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf("unexpected %v at the start of the dump", tok)
+		}
+	}
+
+	for dec.More() {
+		var raw []byte
+		if data != nil {
+			start := dec.InputOffset()
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			raw = bytes.TrimLeft(data[start:dec.InputOffset()], " \t\r\n,")
+		} else {
+			var msg json.RawMessage
+			if err := dec.Decode(&msg); err != nil {
+				return err
+			}
+			raw = msg
+		}
+		if err := s.object(raw); err != nil {
+			return err
+		}
+	}
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamer hands the objects of a dump over.
+type streamer struct {
+	playlist PlaylistFunc
+	track    TrackFunc
+	// positions counts the tracks of the playlists in ndjson-tracks
+	// dumps, whose lines don't say where they are.
+	positions map[string]int
+}
+
+// object streams a playlist object or an ndjson-tracks line. Playlists are
+// read twice: once for their details, skipping the tracks, and once for
+// the tracks, which may come before the details.
+func (o *streamer) object(raw []byte) error {
+	details, isTrackLine, err := objectDetails(raw)
+	if err != nil {
+		return err
+	}
+
+	if isTrackLine {
+		line := trackLine{}
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return err
+		}
+		n, seen := o.positions[line.PlaylistID]
+		if !seen && o.playlist != nil {
+			if err := o.playlist(spotify.MusicPlaylist{Name: line.PlaylistName, IntegrationID: line.PlaylistID}); err != nil {
+				return err
+			}
+		}
+		o.positions[line.PlaylistID] = n + 1
+		if o.track == nil {
+			return nil
+		}
+		return o.track(Track{PlaylistID: line.PlaylistID, PlaylistName: line.PlaylistName, Position: n + 1, MusicTrack: line.MusicTrack})
+	}
+
+	mp := spotify.MusicPlaylist{}
+	if err := json.Unmarshal(details, &mp); err != nil {
+		return err
+	}
+	if o.playlist != nil {
+		if err := o.playlist(mp); err != nil {
+			return err
+		}
+	}
+	if o.track == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if !findKey(dec, "Tracks") {
+		return nil
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		// null or missing tracks
+		return err
+	}
+	for position := 1; dec.More(); position++ {
+		t := Track{PlaylistID: mp.IntegrationID, PlaylistName: mp.Name, Position: position}
+		if err := dec.Decode(&t.MusicTrack); err != nil {
+			return err
+		}
+		if err := o.track(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectDetails returns an object with its Tracks left out, and whether
+// it is an ndjson-tracks line rather than a playlist.
+func objectDetails(raw []byte) ([]byte, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil {
+		return nil, false, err
+	} else if tok != json.Delim('{') {
+		return nil, false, fmt.Errorf("expected a playlist object, found %v", tok)
+	}
+
+	details := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		key, _ := tok.(string)
+		if key == "Tracks" {
+			if err := skipValue(dec); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false, err
+		}
+		details[key] = value
+	}
+	_, isTrackLine := details["PlaylistID"]
+	encoded, err := json.Marshal(details)
+	return encoded, isTrackLine, err
+}
+
+// findKey advances dec to the value of key in the top level object,
+// returning false when it has no such key.
+func findKey(dec *json.Decoder, key string) bool {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if tok == key {
+			return true
+		}
+		if err := skipValue(dec); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// skipValue reads past the next value without decoding it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// readObjectRest reads the rest of an object whose opening brace dec has
+// already returned as a token, re-encoding it.
+func readObjectRest(dec *json.Decoder) ([]byte, error) {
+	object := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		object[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(object)
+}
+
+// firstByte returns the first non whitespace byte of data.
+func firstByte(data []byte) byte {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
@@ -0,0 +1,83 @@
+// Package explicit enforces explicit content policies on track lists, for
+// playlists shared with a family or played in public.
+package explicit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Policy decides which tracks may stay in a playlist.
+type Policy string
+
+// The policies. Any keeps every track.
+const (
+	Any          Policy = ""
+	CleanOnly    Policy = "clean"
+	ExplicitOnly Policy = "explicit"
+)
+
+// ParsePolicy checks a policy name.
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(name); policy {
+	case Any, CleanOnly, ExplicitOnly:
+		return policy, nil
+	}
+	return Any, fmt.Errorf("unknown explicit content policy %q, expected clean or explicit", name)
+}
+
+// Allows reports whether the track satisfies the policy. Local files carry
+// no explicit flag and count as clean.
+func (o Policy) Allows(track spotify.MusicTrack) bool {
+	switch o {
+	case CleanOnly:
+		return !track.Explicit
+	case ExplicitOnly:
+		return track.Explicit
+	}
+	return true
+}
+
+// Pair finds the version of a track with the given explicit flag, false
+// when there is none. See spotify.Client.OtherVersion.
+type Pair func(ctx context.Context, track spotify.MusicTrack, explicit bool) (spotify.MusicTrack, bool, error)
+
+// Result counts what Apply changed.
+type Result struct {
+	Swapped int
+	Dropped int
+}
+
+// Apply enforces the policy on tracks. A track which doesn't satisfy it is
+// swapped for its other version when pair finds one, and dropped
+// otherwise. pair may be nil to only drop.
+func Apply(ctx context.Context, policy Policy, tracks []spotify.MusicTrack, pair Pair) ([]spotify.MusicTrack, Result, error) {
+	result := Result{}
+	if policy == Any {
+		return tracks, result, nil
+	}
+
+	kept := make([]spotify.MusicTrack, 0, len(tracks))
+	for _, track := range tracks {
+		if policy.Allows(track) {
+			kept = append(kept, track)
+			continue
+		}
+		if pair != nil {
+			other, ok, err := pair(ctx, track, policy == ExplicitOnly)
+			if err != nil {
+				return nil, result, err
+			}
+			if ok && policy.Allows(other) {
+				other.AddedAt = track.AddedAt
+				kept = append(kept, other)
+				result.Swapped++
+				continue
+			}
+		}
+		result.Dropped++
+	}
+	return kept, result, nil
+}
@@ -0,0 +1,541 @@
+// Package filter selects tracks with simple expressions, so a subset of a
+// large playlist can be dumped without post-processing the output.
+//
+// An expression compares a field of the track with a value, and can be
+// combined with and, or, not (or &&, || and !) and parentheses:
+//
+//	artist =~ "Radiohead"
+//	added_after 2023-01-01 and duration > 10m
+//	explicit = false or not (genre =~ "metal")
+//
+// String fields (name, artist, album, show, id, isrc, type, source,
+// unavailable, genre, language) take = (or ==) and != comparing case
+// insensitively, and =~ and !~ matching a regular expression case
+// insensitively; genre matches when any of the track's genres does. Date
+// fields (added, released) take a date, 2023-01-01, or an RFC3339 time,
+// duration takes a length such as 10m, 3m30s or 3:30, and both are
+// compared with =, !=, <, <=, > and >=. Boolean fields (explicit,
+// playable, local) take = and !=. added_after, added_before,
+// released_after and released_before are shorthands for comparing the
+// dates.
+//
+// An expression which isn't in this syntax is taken as one of package
+// script, as --where takes them, so either syntax works wherever a filter
+// does:
+//
+//	year >= 2000 && !explicit
+//	"rock" in genres || matches(artist, "^The ")
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Filter is a parsed expression. A nil Filter matches every track.
+type Filter struct {
+	source string
+	root   node
+}
+
+// Parse parses an expression, in the syntax of filters or else of
+// package script.
+func Parse(expr string) (*Filter, error) {
+	root, err := parse(expr)
+	if err != nil {
+		var errScript error
+		if root, errScript = compileScript(expr); errScript != nil {
+			return nil, fmt.Errorf("filter %q: %w (nor is it a --where expression: %v)", expr, err, unwrap(errScript))
+		}
+	}
+	return &Filter{source: expr, root: root}, nil
+}
+
+// parse parses an expression in the syntax of filters.
+func parse(expr string) (node, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return root, err
+}
+
+// ParseAll parses several expressions into a filter matching the tracks
+// every one of them matches, nil when there are none.
+func ParseAll(exprs []string) (*Filter, error) {
+	var all *Filter
+	for _, expr := range exprs {
+		f, err := Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		if all == nil {
+			all = f
+			continue
+		}
+		all = &Filter{source: all.source + " and " + f.source, root: and{all.root, f.root}}
+	}
+	return all, nil
+}
+
+func (o *Filter) String() string {
+	if o == nil {
+		return ""
+	}
+	return o.source
+}
+
+// Match reports whether the track matches the filter.
+func (o *Filter) Match(track spotify.MusicTrack) bool {
+	if o == nil {
+		return true
+	}
+	return o.root.match(track)
+}
+
+// Tracks returns the tracks matching the filter, in order.
+func (o *Filter) Tracks(tracks []spotify.MusicTrack) []spotify.MusicTrack {
+	if o == nil {
+		return tracks
+	}
+	var kept []spotify.MusicTrack
+	for _, track := range tracks {
+		if o.Match(track) {
+			kept = append(kept, track)
+		}
+	}
+	return kept
+}
+
+type node interface {
+	match(track spotify.MusicTrack) bool
+}
+
+type and [2]node
+
+func (o and) match(track spotify.MusicTrack) bool { return o[0].match(track) && o[1].match(track) }
+
+type or [2]node
+
+func (o or) match(track spotify.MusicTrack) bool { return o[0].match(track) || o[1].match(track) }
+
+type not struct{ node }
+
+func (o not) match(track spotify.MusicTrack) bool { return !o.node.match(track) }
+
+// Kinds of fields, deciding the operators and values they take.
+const (
+	kindString = "string"
+	kindTime   = "date"
+	kindLength = "duration"
+	kindBool   = "boolean"
+)
+
+// field describes a field of a track expressions can refer to. Exactly
+// one of the getters is set, going by kind.
+type field struct {
+	kind    string
+	strings func(track spotify.MusicTrack) []string
+	time    func(track spotify.MusicTrack) *time.Time
+	length  func(track spotify.MusicTrack) time.Duration
+	bool    func(track spotify.MusicTrack) bool
+}
+
+func one(get func(track spotify.MusicTrack) string) field {
+	return field{kind: kindString, strings: func(track spotify.MusicTrack) []string {
+		return []string{get(track)}
+	}}
+}
+
+var fields = map[string]field{
+	"name":        one(func(track spotify.MusicTrack) string { return track.Name }),
+	"artist":      one(func(track spotify.MusicTrack) string { return track.Artists }),
+	"album":       one(func(track spotify.MusicTrack) string { return track.AlbumName }),
+	"show":        one(func(track spotify.MusicTrack) string { return track.ShowName }),
+	"id":          one(func(track spotify.MusicTrack) string { return track.IntegrationID }),
+	"isrc":        one(func(track spotify.MusicTrack) string { return track.ISRC }),
+	"type":        one(trackType),
+	"source":      one(func(track spotify.MusicTrack) string { return track.Source }),
+	"unavailable": one(func(track spotify.MusicTrack) string { return track.Unavailable }),
+	"language":    one(func(track spotify.MusicTrack) string { return track.Language }),
+	"genre": {kind: kindString, strings: func(track spotify.MusicTrack) []string {
+		return track.Genres
+	}},
+	"added": {kind: kindTime, time: func(track spotify.MusicTrack) *time.Time {
+		return track.AddedAt
+	}},
+	"released": {kind: kindTime, time: func(track spotify.MusicTrack) *time.Time {
+		if track.Released == nil {
+			return nil
+		}
+		t := track.Released.Time()
+		return &t
+	}},
+	"duration": {kind: kindLength, length: spotify.MusicTrack.Length},
+	"explicit": {kind: kindBool, bool: func(track spotify.MusicTrack) bool {
+		return track.Explicit
+	}},
+	"playable": {kind: kindBool, bool: func(track spotify.MusicTrack) bool {
+		return !track.Dead() && (track.IsPlayable == nil || *track.IsPlayable)
+	}},
+	"local": {kind: kindBool, bool: func(track spotify.MusicTrack) bool {
+		return track.Source == spotify.SourceLocal
+	}},
+}
+
+// shorthands are operators standing for a date comparison.
+var shorthands = map[string][2]string{
+	"added_after":     {"added", ">"},
+	"added_before":    {"added", "<"},
+	"released_after":  {"released", ">"},
+	"released_before": {"released", "<"},
+}
+
+func trackType(track spotify.MusicTrack) string {
+	if track.Type == "" {
+		return spotify.TypeTrack
+	}
+	return track.Type
+}
+
+// condition compares a field with a value.
+type condition struct {
+	field  field
+	op     string
+	text   string
+	re     *regexp.Regexp
+	time   time.Time
+	length time.Duration
+	bool   bool
+}
+
+func newCondition(name string, op string, value string) (node, error) {
+	f, ok := fields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", name)
+	}
+	c := condition{field: f, op: op}
+	var err error
+	switch f.kind {
+	case kindString:
+		switch op {
+		case "=", "!=":
+			c.text = value
+		case "=~", "!~":
+			c.re, err = regexp.Compile("(?i)" + value)
+		default:
+			return nil, fmt.Errorf("%s is a %s, compared with =, !=, =~ or !~, not %s", name, f.kind, op)
+		}
+	case kindTime, kindLength:
+		if op == "=~" || op == "!~" {
+			return nil, fmt.Errorf("%s is a %s, compared with =, !=, <, <=, > or >=, not %s", name, f.kind, op)
+		}
+		if f.kind == kindTime {
+			c.time, err = parseTime(value)
+		} else {
+			c.length, err = parseLength(value)
+		}
+	case kindBool:
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("%s is a %s, compared with = or !=, not %s", name, f.kind, op)
+		}
+		c.bool, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+func (o condition) match(track spotify.MusicTrack) bool {
+	switch o.field.kind {
+	case kindString:
+		values := o.field.strings(track)
+		negated := o.op == "!=" || o.op == "!~"
+		for _, value := range values {
+			var matched bool
+			if o.re != nil {
+				matched = o.re.MatchString(value)
+			} else {
+				matched = strings.EqualFold(value, o.text)
+			}
+			if matched {
+				return !negated
+			}
+		}
+		return negated
+	case kindTime:
+		t := o.field.time(track)
+		if t == nil {
+			return false
+		}
+		return compare(o.op, t.Compare(o.time))
+	case kindLength:
+		d := o.field.length(track)
+		switch {
+		case d < o.length:
+			return compare(o.op, -1)
+		case d > o.length:
+			return compare(o.op, 1)
+		}
+		return compare(o.op, 0)
+	case kindBool:
+		return (o.field.bool(track) == o.bool) == (o.op == "=")
+	}
+	return false
+}
+
+// compare applies an ordering operator to the result of a comparison.
+func compare(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// parseTime parses a date, taken as midnight UTC, or an RFC3339 time.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q, want e.g. 2023-01-01", value)
+	}
+	return t, nil
+}
+
+// parseLength parses a duration as Go writes them, 10m or 3m30s, or as a
+// clock, 3:30 or 1:02:05.
+func parseLength(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration %q, want e.g. 10m, 3m30s or 3:30", value)
+	}
+	var d time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q, want e.g. 10m, 3m30s or 3:30", value)
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second, nil
+}
+
+// token is a lexed word, quoted string, operator or parenthesis.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// operators are the comparison operators, longest first.
+var operators = []string{"==", "!=", "=~", "!~", "<=", ">=", "=", "<", ">"}
+
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	rest := expr
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			return tokens, nil
+		}
+		switch c := rest[0]; {
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: rest[:1]})
+			rest = rest[1:]
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(rest[1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", rest)
+			}
+			tokens = append(tokens, token{text: rest[1 : end+1], quoted: true})
+			rest = rest[end+2:]
+			continue
+		}
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				rest = rest[len(op):]
+				if op == "==" {
+					op = "="
+				}
+				tokens = append(tokens, token{text: op})
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if rest[0] == '!' {
+			tokens = append(tokens, token{text: "!"})
+			rest = rest[1:]
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return unicode.IsSpace(r) || strings.ContainsRune("()\"'=!<>~", r)
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("unexpected %q", rest[:1])
+		}
+		tokens = append(tokens, token{text: rest[:end]})
+		rest = rest[end:]
+	}
+}
+
+// parser is a recursive descent parser over the tokens, and binding
+// tighter than or.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (o *parser) peek() (token, bool) {
+	if o.pos >= len(o.tokens) {
+		return token{}, false
+	}
+	return o.tokens[o.pos], true
+}
+
+func (o *parser) next() (token, error) {
+	t, ok := o.peek()
+	if !ok {
+		return t, fmt.Errorf("unexpected end")
+	}
+	o.pos++
+	return t, nil
+}
+
+// keyword reports whether the next token is the unquoted word, taking it
+// if so.
+func (o *parser) keyword(words ...string) bool {
+	t, ok := o.peek()
+	if !ok || t.quoted {
+		return false
+	}
+	for _, word := range words {
+		if strings.EqualFold(t.text, word) {
+			o.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (o *parser) or() (node, error) {
+	left, err := o.and()
+	if err != nil {
+		return nil, err
+	}
+	for o.keyword("or", "||") {
+		right, err := o.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (o *parser) and() (node, error) {
+	left, err := o.unary()
+	if err != nil {
+		return nil, err
+	}
+	for o.keyword("and", "&&") {
+		right, err := o.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (o *parser) unary() (node, error) {
+	if o.keyword("not", "!") {
+		n, err := o.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{n}, nil
+	}
+	if o.keyword("(") {
+		n, err := o.or()
+		if err != nil {
+			return nil, err
+		}
+		if !o.keyword(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return n, nil
+	}
+	return o.condition()
+}
+
+func (o *parser) condition() (node, error) {
+	name, err := o.next()
+	if err != nil {
+		return nil, err
+	}
+	if name.quoted {
+		return nil, fmt.Errorf("expected a field, not %q", name.text)
+	}
+	field, op := strings.ToLower(name.text), ""
+	if shorthand, ok := shorthands[field]; ok {
+		field, op = shorthand[0], shorthand[1]
+	} else {
+		t, err := o.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.quoted || !isOperator(t.text) {
+			return nil, fmt.Errorf("expected an operator after %s, not %q", name.text, t.text)
+		}
+		op = t.text
+	}
+	value, err := o.next()
+	if err != nil {
+		return nil, err
+	}
+	return newCondition(field, op, value.text)
+}
+
+func isOperator(text string) bool {
+	for _, op := range operators {
+		if text == op {
+			return true
+		}
+	}
+	return false
+}
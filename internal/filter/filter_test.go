@@ -0,0 +1,142 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+func TestMatch(t *testing.T) {
+	added := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	unplayable := false
+	tracks := map[string]spotify.MusicTrack{
+		"song": {
+			Name: "Paranoid Android", Artists: "Radiohead", AlbumName: "OK Computer",
+			IntegrationID: "id1", ISRC: "GBAYE9700001", Source: "spotify", Language: "en",
+			Genres: []string{"alternative rock", "art rock"}, AddedAt: &added,
+			Released: spotify.ParseReleaseDate("1997-05-21", "day"), DurationMS: 386000, Explicit: true,
+		},
+		"episode": {
+			Name: "Episode 1", ShowName: "The Show", Type: spotify.TypeEpisode,
+			DurationMS: 3600000, IsPlayable: &unplayable,
+		},
+		"local": {Name: "Demo", Source: spotify.SourceLocal, Unavailable: "local", DurationMS: 90000},
+	}
+
+	for _, c := range []struct {
+		expr string
+		want []string
+	}{
+		// strings, compared ignoring case or matched by a regular expression
+		{`name = "paranoid android"`, []string{"song"}},
+		{`name == 'PARANOID ANDROID'`, []string{"song"}},
+		{`name != "Demo"`, []string{"episode", "song"}},
+		{`artist =~ "^radio"`, []string{"song"}},
+		{`artist !~ "head$"`, []string{"episode", "local"}},
+		{`album = "ok computer"`, []string{"song"}},
+		{`show =~ show`, []string{"episode"}},
+		{`id = id1`, []string{"song"}},
+		{`isrc = gbaye9700001`, []string{"song"}},
+		{`type = track`, []string{"local", "song"}},
+		{`type = episode`, []string{"episode"}},
+		{`source = local`, []string{"local"}},
+		{`unavailable != ""`, []string{"local"}},
+		{`language = EN`, []string{"song"}},
+		{`genre = "art rock"`, []string{"song"}},
+		{`genre =~ "^alt"`, []string{"song"}},
+		{`genre != "jazz"`, []string{"episode", "local", "song"}},
+		{`genre !~ "rock"`, []string{"episode", "local"}},
+
+		// dates, a track without one matching no comparison
+		{`added > 2023-01-01`, []string{"song"}},
+		{`added >= 2023-06-01T12:00:00Z`, []string{"song"}},
+		{`added < 2023-06-01T12:00:00Z`, nil},
+		{`added <= 2023-06-01T12:00:00Z`, []string{"song"}},
+		{`added = 2023-06-01T12:00:00Z`, []string{"song"}},
+		{`added != 2023-06-01T12:00:00Z`, nil},
+		{`released < 2000-01-01`, []string{"song"}},
+		{`released = 1997-05-21`, []string{"song"}},
+
+		// durations
+		{`duration > 10m`, []string{"episode"}},
+		{`duration >= 6m26s`, []string{"episode", "song"}},
+		{`duration < 3:30`, []string{"local"}},
+		{`duration <= 1:30`, []string{"local"}},
+		{`duration = 1:00:00`, []string{"episode"}},
+		{`duration != 1:00:00`, []string{"local", "song"}},
+
+		// booleans
+		{`explicit = true`, []string{"song"}},
+		{`explicit != true`, []string{"episode", "local"}},
+		{`playable = false`, []string{"episode", "local"}},
+		{`local = 1`, []string{"local"}},
+
+		// shorthands
+		{`added_after 2023-01-01`, []string{"song"}},
+		{`added_before 2023-01-01`, nil},
+		{`released_after 1990-01-01`, []string{"song"}},
+		{`released_before 1990-01-01`, nil},
+		{`ADDED_AFTER 2023-01-01`, []string{"song"}},
+
+		// combinations
+		{`explicit = false and duration > 1m`, []string{"episode", "local"}},
+		{`explicit = false && duration > 1m && type = track`, []string{"local"}},
+		{`local = true or type = episode`, []string{"episode", "local"}},
+		{`local = true || explicit = true and type = episode`, []string{"local"}},
+		{`(local = true || explicit = true) and type = track`, []string{"local", "song"}},
+		{`not (genre =~ "rock")`, []string{"episode", "local"}},
+		{`!local = true`, []string{"episode", "song"}},
+		{`not not local = true`, []string{"local"}},
+	} {
+		f, err := Parse(c.expr)
+		if err != nil {
+			t.Errorf("%s: %v", c.expr, err)
+			continue
+		}
+		var got []string
+		for _, name := range []string{"episode", "local", "song"} {
+			if f.Match(tracks[name]) {
+				got = append(got, name)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%s matched %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		`name = "open`:                `unterminated string "open`,
+		`name ~ x`:                    `unexpected "~"`,
+		`name =`:                      "unexpected end",
+		`"name" = x`:                  `expected a field, not "name"`,
+		`name x`:                      `expected an operator after name, not "x"`,
+		`name = a b`:                  `unexpected "b"`,
+		`(name = a`:                   "missing )",
+		`name = a and`:                "unexpected end",
+		`colour = red`:                `unknown field "colour"`,
+		`name > a`:                    "name is a string, compared with =, !=, =~ or !~, not >",
+		`name =~ "("`:                 "name: error parsing regexp",
+		`added =~ 2023`:               "added is a date, compared with =, !=, <, <=, > or >=, not =~",
+		`added > yesterday`:           `added: invalid date "yesterday"`,
+		`added_after 2023-13-01`:      `added: invalid date "2023-13-01"`,
+		`duration !~ 3m`:              "duration is a duration, compared with",
+		`duration > long`:             `duration: invalid duration "long"`,
+		`duration > 1:2:3:4`:          `duration: invalid duration "1:2:3:4"`,
+		`duration > 3:-1`:             `duration: invalid duration "3:-1"`,
+		`explicit < true`:             "explicit is a boolean, compared with = or !=, not <",
+		`explicit = maybe`:            `explicit: strconv.ParseBool: parsing "maybe"`,
+		`released_before "last year"`: `released: invalid date "last year"`,
+	} {
+		if _, err := parse(expr); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error with %q", expr, err, want)
+		}
+		// nor do they compile as --where expressions
+		if _, err := Parse(expr); err == nil || !strings.Contains(err.Error(), "nor is it a --where expression") {
+			t.Errorf("%s: Parse gave %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"errors"
+
+	"github.com/pyrat/spd/internal/script"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// scripted is an expression of package script, the syntax of --where,
+// true for the tracks it matches.
+type scripted struct {
+	program *script.Program
+}
+
+// compileScript compiles an expression in the syntax of package script,
+// checking it only uses the variables a lone track has: a filter doesn't
+// know the playlist, so neither position, playlist nor playlist_id.
+func compileScript(expr string) (node, error) {
+	program, err := script.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	n := scripted{program}
+	if _, err := program.Eval(trackVars(spotify.MusicTrack{})); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (o scripted) match(track spotify.MusicTrack) bool {
+	ok, err := o.program.Bool(trackVars(track))
+	return err == nil && ok
+}
+
+// trackVars returns the variables of a track outside of a playlist.
+func trackVars(track spotify.MusicTrack) script.Vars {
+	vars := script.TrackVars(spotify.MusicPlaylist{}, 0, track)
+	delete(vars, "position")
+	delete(vars, "playlist")
+	delete(vars, "playlist_id")
+	return vars
+}
+
+// unwrap returns the error of an expression without the expression,
+// which the filter's error already quotes.
+func unwrap(err error) error {
+	if inner := errors.Unwrap(err); inner != nil {
+		return inner
+	}
+	return err
+}
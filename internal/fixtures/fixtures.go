@@ -0,0 +1,290 @@
+// Package fixtures generates a synthetic library of playlists shaped like
+// real dumps, for testing parsers of the dump format against realistic
+// data without a Spotify account. The same options always generate the
+// same library, byte for byte once encoded. The tests of internal/dump
+// read it back in every dump format, and selftest serves it through a
+// mock of the API.
+//
+// Playlists of 20 tracks or more cover the edge cases seen in real dumps:
+// local files, podcast episodes, unavailable, removed and relinked
+// tracks, tracks without previews or with embed ones, explicit tracks,
+// duplicates, release dates known to the year or month, tracks longer than
+// an hour, names in scripts other than latin along with emoji, and an
+// empty and a collaborative playlist.
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// Options size the generated library.
+type Options struct {
+	// Playlists is how many playlists to generate, at least one.
+	Playlists int
+	// Tracks is how many tracks each playlist has, the empty playlist
+	// aside.
+	Tracks int
+	// Seed picks the library, different seeds give different names,
+	// IDs and dates.
+	Seed int64
+}
+
+// epoch is when the generated tracks start being added, fixed so the
+// library doesn't depend on the day it is generated.
+var epoch = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// words make up the generated names, the later ones in other scripts.
+var words = []string{
+	"midnight", "river", "echo", "golden", "static", "summer", "neon",
+	"paper", "ghost", "velvet", "satellite", "harbor", "winter", "signal",
+	"Ünïcödé", "café", "naïve", "東京", "夜明け", "сердце", "ветер",
+	"قلب", "שלום", "나비", "🎸", "🌙✨", "Ångström", "Zoë",
+}
+
+// artists are the generated artists, shared between tracks so that
+// artist counts, genres and graphs have something to group.
+var artists = []string{
+	"The Static Harbors", "Velvet Signal", "Ghost Paper", "Midnight River",
+	"Sigur Rós", "坂本 龍一", "Beyoncé", "Мумий Тролль", "Fairuz", "AC/DC",
+	"Björk", "!!!", "Sunn O)))", "MØ",
+}
+
+// genres are the genres of the generated artists.
+var genres = []string{"indie rock", "shoegaze", "j-pop", "ambient", "post-punk", "synthwave", "k-indie"}
+
+// shows are the podcasts episodes come from.
+var shows = []string{"The Listening Room", "Ночной эфир", "Podcast 🎙"}
+
+// Generate returns a library of opts.Playlists playlists. The second
+// playlist is empty and the third collaborative, when there are that many.
+func Generate(opts Options) []model.MusicPlaylist {
+	r := rand.New(rand.NewSource(opts.Seed))
+	g := &generator{rand: r, owner: model.MusicUser{Name: "Fixture Owner", IntegrationID: "fixtureowner"}}
+
+	playlists := make([]model.MusicPlaylist, max(opts.Playlists, 1))
+	for i := range playlists {
+		tracks := opts.Tracks
+		if i == 1 {
+			tracks = 0
+		}
+		playlists[i] = g.playlist(i, tracks)
+	}
+	return playlists
+}
+
+type generator struct {
+	rand  *rand.Rand
+	owner model.MusicUser
+	// made are the tracks generated so far, duplicates are picked from.
+	made []model.MusicTrack
+}
+
+// id returns a random 22 character base62 ID, as Spotify's are.
+func (o *generator) id() string {
+	const alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	b := make([]byte, 22)
+	for i := range b {
+		b[i] = alphabet[o.rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+// name returns a title of one to four words.
+func (o *generator) name() string {
+	n := 1 + o.rand.Intn(4)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = words[o.rand.Intn(len(words))]
+	}
+	first := []rune(parts[0])
+	parts[0] = string(unicode.ToUpper(first[0])) + string(first[1:])
+	return strings.Join(parts, " ")
+}
+
+func (o *generator) playlist(index int, tracks int) model.MusicPlaylist {
+	public := index%4 != 3
+	mp := model.MusicPlaylist{
+		Name:          fmt.Sprintf("%s #%d", o.name(), index+1),
+		Description:   "Fixture playlist & friends, <generated> by spdump gen-fixtures\nsecond line",
+		Owner:         &model.MusicUser{Name: o.owner.Name, IntegrationID: o.owner.IntegrationID},
+		Public:        &public,
+		Collaborative: index == 2,
+		Followers:     o.rand.Intn(10000),
+		PlaylistArt: []model.Image{
+			{Height: 640, Width: 640, URL: "https://i.scdn.co/image/" + o.id()},
+		},
+		IntegrationID: o.id(),
+		SnapshotID:    o.id() + o.id(),
+	}
+	if index == 2 {
+		mp.Public = nil
+	}
+
+	added := epoch.Add(time.Duration(index) * 24 * time.Hour)
+	for i := 0; i < tracks; i++ {
+		added = added.Add(time.Duration(1+o.rand.Intn(72*60)) * time.Minute)
+		track := o.track(i)
+		at := added
+		track.AddedAt = &at
+		if mp.Collaborative {
+			track.AddedBy = []string{"fixtureowner", "fixturefriend", "fixtureguest"}[i%3]
+		}
+		mp.Tracks = append(mp.Tracks, track)
+		for _, genre := range track.Genres {
+			if mp.Genres == nil {
+				mp.Genres = map[string]int{}
+			}
+			mp.Genres[genre]++
+		}
+	}
+	return mp
+}
+
+// track returns the i-th track of a playlist, every tenth one of them or
+// so an edge case.
+func (o *generator) track(i int) model.MusicTrack {
+	switch {
+	case i%17 == 16 && len(o.made) > 0:
+		// the same track twice, as playlists often have
+		return o.made[o.rand.Intn(len(o.made))]
+	case i%13 == 5:
+		return o.episode()
+	case i%11 == 7:
+		return o.local()
+	}
+
+	track := o.spotifyTrack()
+	switch {
+	case i%19 == 9:
+		track.Unavailable = model.UnavailableRemoved
+		track.IntegrationID, track.ExternalURL, track.PreviewURL, track.ISRC = "", "", "", ""
+	case i%7 == 3:
+		track.Unavailable = []string{"market", model.UnavailableNotPlayable, model.UnavailableNoMarkets}[o.rand.Intn(3)]
+		playable := false
+		track.IsPlayable = &playable
+	case i%9 == 4:
+		track.LinkedFrom = o.id()
+	}
+	switch {
+	case i%23 == 12:
+		track.DurationMS = 3600000 + o.rand.Intn(3600000)
+		track.Duration = model.FormatDuration(track.Length())
+	case i%5 == 2:
+		track.PreviewURL = ""
+	case i%8 == 6:
+		track.PreviewSource = model.PreviewFromEmbed
+	}
+	o.made = append(o.made, track)
+	return track
+}
+
+// artist returns one of the artists, its ID derived from its name so it
+// is the same across tracks.
+func (o *generator) artist() model.MusicArtist {
+	i := o.rand.Intn(len(artists))
+	return model.MusicArtist{
+		Name:          artists[i],
+		IntegrationID: fmt.Sprintf("fixtureartist%09d", i),
+	}
+}
+
+// releaseDate returns a release date at a random precision, mostly days.
+func (o *generator) releaseDate() string {
+	year := 1960 + o.rand.Intn(65)
+	switch o.rand.Intn(8) {
+	case 0:
+		return fmt.Sprintf("%04d", year)
+	case 1:
+		return fmt.Sprintf("%04d-%02d", year, 1+o.rand.Intn(12))
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", year, 1+o.rand.Intn(12), 1+o.rand.Intn(28))
+}
+
+func (o *generator) spotifyTrack() model.MusicTrack {
+	id := o.id()
+	list := []model.MusicArtist{o.artist()}
+	if o.rand.Intn(4) == 0 {
+		list = append(list, o.artist())
+	}
+	names := make([]string, len(list))
+	for i, artist := range list {
+		names[i] = artist.Name
+	}
+	released := o.releaseDate()
+	duration := 90000 + o.rand.Intn(300000)
+	playable := true
+	return model.MusicTrack{
+		Name:             o.name(),
+		PreviewURL:       "https://p.scdn.co/mp3-preview/" + o.id(),
+		AlbumName:        o.name(),
+		AlbumID:          o.id(),
+		AlbumArt:         o.art(),
+		AlbumReleaseDate: released,
+		DurationMS:       duration,
+		Duration:         model.FormatDuration(time.Duration(duration) * time.Millisecond),
+		Released:         model.ParseReleaseDate(released, ""),
+		ISRC:             fmt.Sprintf("QZ%s%02d%05d", []string{"ES", "FX", "GB"}[o.rand.Intn(3)], o.rand.Intn(100), o.rand.Intn(100000)),
+		IntegrationID:    id,
+		Source:           model.SourceSpotify,
+		ExternalURL:      "https://open.spotify.com/track/" + id,
+		Artists:          strings.Join(names, ", "),
+		ArtistList:       list,
+		IsPlayable:       &playable,
+		Explicit:         o.rand.Intn(6) == 0,
+		Genres:           []string{genres[o.rand.Intn(len(genres))]},
+	}
+}
+
+func (o *generator) art() []model.Image {
+	var images []model.Image
+	for _, size := range []int{640, 300, 64} {
+		images = append(images, model.Image{Height: size, Width: size, URL: "https://i.scdn.co/image/" + o.id()})
+	}
+	return images
+}
+
+// episode returns a podcast episode, released to the day.
+func (o *generator) episode() model.MusicTrack {
+	id := o.id()
+	released := fmt.Sprintf("%04d-%02d-%02d", 2015+o.rand.Intn(10), 1+o.rand.Intn(12), 1+o.rand.Intn(28))
+	duration := 600000 + o.rand.Intn(7200000)
+	playable := true
+	show := shows[o.rand.Intn(len(shows))]
+	return model.MusicTrack{
+		Type:          model.TypeEpisode,
+		Name:          "Episode " + fmt.Sprint(1+o.rand.Intn(300)) + ": " + o.name(),
+		PreviewURL:    "https://podz-content.spotifycdn.com/audio/clips/" + o.id() + "/clip.mp3",
+		ShowName:      show,
+		AlbumArt:      o.art(),
+		ReleaseDate:   released,
+		DurationMS:    duration,
+		Duration:      model.FormatDuration(time.Duration(duration) * time.Millisecond),
+		Released:      model.ParseReleaseDate(released, model.PrecisionDay),
+		IntegrationID: id,
+		IsPlayable:    &playable,
+		Source:        model.SourceSpotify,
+		ExternalURL:   "https://open.spotify.com/episode/" + id,
+		Artists:       show + " Network",
+	}
+}
+
+// local returns a local file, which only has the names from its tags.
+func (o *generator) local() model.MusicTrack {
+	artist := artists[o.rand.Intn(len(artists))]
+	duration := 120000 + o.rand.Intn(240000)
+	return model.MusicTrack{
+		Name:       o.name() + " (demo)",
+		AlbumName:  o.name(),
+		DurationMS: duration,
+		Duration:   model.FormatDuration(time.Duration(duration) * time.Millisecond),
+		Source:     model.SourceLocal,
+		Artists:    artist,
+		ArtistList: []model.MusicArtist{{Name: artist}},
+	}
+}
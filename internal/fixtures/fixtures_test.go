@@ -0,0 +1,55 @@
+package fixtures_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pyrat/spd/internal/fixtures"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	encode := func(opts fixtures.Options) []byte {
+		data, err := json.Marshal(fixtures.Generate(opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	opts := fixtures.Options{Playlists: 3, Tracks: 40, Seed: 7}
+	if !bytes.Equal(encode(opts), encode(opts)) {
+		t.Error("the same options generated different libraries")
+	}
+	other := opts
+	other.Seed++
+	if bytes.Equal(encode(opts), encode(other)) {
+		t.Error("another seed generated the same library")
+	}
+}
+
+func TestGenerateValidates(t *testing.T) {
+	library := fixtures.Generate(fixtures.Options{Playlists: 4, Tracks: 80, Seed: 1})
+	if len(library[1].Tracks) != 0 || !library[2].Collaborative {
+		t.Error("the second playlist isn't empty or the third isn't collaborative")
+	}
+	for _, mp := range library {
+		data, err := json.Marshal(mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := fixtures.Validate(v); err != nil {
+			t.Errorf("%s: %v", mp.Name, err)
+		}
+	}
+
+	if err := fixtures.Validate(map[string]interface{}{"Name": 1}); err == nil {
+		t.Error("validated a playlist whose name is a number")
+	}
+	if err := fixtures.ValidateTrack("track"); err == nil {
+		t.Error("validated a string as a track")
+	}
+}
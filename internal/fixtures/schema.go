@@ -0,0 +1,183 @@
+package fixtures
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// SchemaURL is the JSON Schema dialect of Schema.
+const SchemaURL = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema of a playlist in a json dump, derived from
+// model.MusicPlaylist so it can't drift from what spdump writes: fields
+// without omitempty are required, the others may be left out.
+func Schema() map[string]interface{} {
+	defs := map[string]interface{}{}
+	schema := objectSchema(reflect.TypeOf(model.MusicPlaylist{}), defs)
+	schema["$schema"] = SchemaURL
+	schema["title"] = "spdump playlist"
+	schema["$defs"] = defs
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the schema of a Go type. Structs are added to defs by
+// name and referred to.
+func schemaOf(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), defs)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		// encoding/json writes a nil slice or map as null
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": schemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": schemaOf(t.Elem(), defs)}
+	case reflect.Interface:
+		// any value, such as a computed field
+		return map[string]interface{}{}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if _, ok := defs[t.Name()]; !ok {
+		defs[t.Name()] = nil // placeholder for recursive types
+		defs[t.Name()] = objectSchema(t, defs)
+	}
+	return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+}
+
+// objectSchema returns the schema of a struct, its fields named as
+// encoding/json names them.
+func objectSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, defs)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// schema is Schema, built once for validation.
+var schema = Schema()
+
+// Validate checks a playlist of a json dump, as decoded by encoding/json
+// into an interface{}, against Schema, returning the first violation.
+func Validate(v interface{}) error {
+	return validate(schema, v, "playlist")
+}
+
+// ValidateTrack checks a track, such as a line of an ndjson-tracks dump,
+// against the track definition of Schema.
+func ValidateTrack(v interface{}) error {
+	return validate(def("MusicTrack"), v, "track")
+}
+
+// def returns the definition name of schema.
+func def(name string) map[string]interface{} {
+	d, _ := schema["$defs"].(map[string]interface{})[name].(map[string]interface{})
+	return d
+}
+
+// validate checks v against the subset of JSON Schema Schema uses.
+func validate(s map[string]interface{}, v interface{}, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		return validate(def(strings.TrimPrefix(ref, "#/$defs/")), v, path)
+	}
+	kind := s["type"]
+	if types, ok := kind.([]string); ok {
+		if v == nil && slices.Contains(types, "null") {
+			return nil
+		}
+		kind = types[0]
+	}
+	switch kind {
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		required, _ := s["required"].([]string)
+		for _, name := range required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: missing %s", path, name)
+			}
+		}
+		properties, _ := s["properties"].(map[string]interface{})
+		additional, _ := s["additionalProperties"].(map[string]interface{})
+		for name, value := range object {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				property = additional
+			}
+			if property == nil {
+				continue
+			}
+			if err := validate(property, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		for i, item := range array {
+			if err := validate(s["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+		if s["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: expected a date-time: %w", path, err)
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected an integer", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected a number", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+	}
+	return nil
+}
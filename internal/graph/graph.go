@@ -0,0 +1,249 @@
+// Package graph builds the network of artists credited together on tracks
+// and exports it for tools such as GraphViz and Gephi.
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Node is an artist in the graph.
+type Node struct {
+	ID   string
+	Name string
+	// Tracks is the number of distinct tracks the artist is credited on.
+	Tracks int
+}
+
+// Edge connects two artists credited on the same tracks.
+type Edge struct {
+	Source string
+	Target string
+	// Weight is the number of distinct tracks they share.
+	Weight int
+}
+
+// Graph is an undirected artist collaboration graph.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Build builds the collaboration graph of the tracks in the playlists.
+// Tracks appearing in several playlists are only counted once. Artists are
+// identified by ID when the dump has structured artists, otherwise by name.
+func Build(playlists []spotify.MusicPlaylist) Graph {
+	b := NewBuilder()
+	for _, mp := range playlists {
+		for _, track := range mp.Tracks {
+			b.AddTrack(track)
+		}
+	}
+	return b.Graph()
+}
+
+// Builder builds the graph a track at a time, so a dump can be streamed
+// through it. It holds the artists, their pairs and a key per distinct
+// track, not the tracks themselves.
+type Builder struct {
+	nodes map[string]*Node
+	edges map[[2]string]*Edge
+	seen  map[string]bool
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{
+		nodes: map[string]*Node{},
+		edges: map[[2]string]*Edge{},
+		seen:  map[string]bool{},
+	}
+}
+
+// AddTrack adds the artists of a track, unless it was added before.
+func (o *Builder) AddTrack(track spotify.MusicTrack) {
+	key := track.IntegrationID
+	if key == "" {
+		key = track.Name + "\x00" + track.Artists
+	}
+	if o.seen[key] {
+		return
+	}
+	o.seen[key] = true
+
+	artists := trackArtists(track)
+	for _, artist := range artists {
+		node, ok := o.nodes[artist.IntegrationID]
+		if !ok {
+			node = &Node{ID: artist.IntegrationID, Name: artist.Name}
+			o.nodes[artist.IntegrationID] = node
+		}
+		node.Tracks++
+	}
+
+	for i := 0; i < len(artists); i++ {
+		for j := i + 1; j < len(artists); j++ {
+			a, b := artists[i].IntegrationID, artists[j].IntegrationID
+			if a == b {
+				continue
+			}
+			if a > b {
+				a, b = b, a
+			}
+			edge, ok := o.edges[[2]string{a, b}]
+			if !ok {
+				edge = &Edge{Source: a, Target: b}
+				o.edges[[2]string{a, b}] = edge
+			}
+			edge.Weight++
+		}
+	}
+}
+
+// Graph returns the graph of the tracks added so far.
+func (o *Builder) Graph() Graph {
+	g := Graph{}
+	for _, node := range o.nodes {
+		g.Nodes = append(g.Nodes, *node)
+	}
+	for _, edge := range o.edges {
+		g.Edges = append(g.Edges, *edge)
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Source != g.Edges[j].Source {
+			return g.Edges[i].Source < g.Edges[j].Source
+		}
+		return g.Edges[i].Target < g.Edges[j].Target
+	})
+	return g
+}
+
+// trackArtists returns the artists of a track, falling back to splitting
+// the combined Artists string when the dump has no structured artists.
+// Artists without an ID, as on local files, are keyed by name.
+func trackArtists(track spotify.MusicTrack) []spotify.MusicArtist {
+	if len(track.ArtistList) > 0 {
+		artists := make([]spotify.MusicArtist, 0, len(track.ArtistList))
+		for _, artist := range track.ArtistList {
+			if artist.IntegrationID == "" {
+				artist.IntegrationID = artist.Name
+			}
+			artists = append(artists, artist)
+		}
+		return artists
+	}
+
+	var artists []spotify.MusicArtist
+	for _, name := range strings.Split(track.Artists, ", ") {
+		if name = strings.TrimSpace(name); name != "" {
+			artists = append(artists, spotify.MusicArtist{Name: name, IntegrationID: name})
+		}
+	}
+	return artists
+}
+
+// WriteDOT writes the graph in GraphViz DOT format.
+func (g Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("graph artists {\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%s, tracks=%d];\n", quote(node.ID), quote(node.Name), node.Tracks)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %s -- %s [weight=%d];\n", quote(edge.Source), quote(edge.Target), edge.Weight)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote quotes s as a DOT string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+type gexfDoc struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string         `xml:"defaultedgetype,attr"`
+	Attributes      gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode     `xml:"nodes>node"`
+	Edges           []gexfEdge     `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class     string          `xml:"class,attr"`
+	Attribute []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	ID     int    `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Weight int    `xml:"weight,attr"`
+}
+
+// WriteGEXF writes the graph in Gephi's GEXF format.
+func (g Graph) WriteGEXF(w io.Writer) error {
+	doc := gexfDoc{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: gexfGraph{
+			DefaultEdgeType: "undirected",
+			Attributes: gexfAttributes{
+				Class:     "node",
+				Attribute: []gexfAttribute{{ID: "tracks", Title: "tracks", Type: "integer"}},
+			},
+		},
+	}
+	for _, node := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:        node.ID,
+			Label:     node.Name,
+			AttValues: []gexfAttValue{{For: "tracks", Value: fmt.Sprint(node.Tracks)}},
+		})
+	}
+	for i, edge := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{ID: i, Source: edge.Source, Target: edge.Target, Weight: edge.Weight})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
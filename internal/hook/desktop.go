@@ -0,0 +1,150 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pyrat/spd/internal/diff"
+)
+
+// desktopMessage returns the title and body of the desktop notification of
+// a sync's changes, the body a line per playlist.
+func desktopMessage(summary Summary) (string, string) {
+	title := fmt.Sprintf("%d playlists changed", len(summary.Playlists))
+	if summary.Added > 0 {
+		title = fmt.Sprintf("%d new tracks in %d playlists", summary.Added, len(summary.Playlists))
+	}
+
+	var lines []string
+	for _, playlist := range summary.Playlists {
+		var line string
+		switch playlist.Status {
+		case diff.StatusAdded:
+			line = fmt.Sprintf("%s: new playlist, %d tracks", playlist.Name, playlist.Added)
+		case diff.StatusRemoved:
+			line = playlist.Name + ": removed"
+		default:
+			var counts []string
+			if playlist.Added > 0 {
+				counts = append(counts, fmt.Sprintf("%d new tracks", playlist.Added))
+			}
+			if playlist.Removed > 0 {
+				counts = append(counts, fmt.Sprintf("%d removed", playlist.Removed))
+			}
+			if playlist.Moved > 0 {
+				counts = append(counts, fmt.Sprintf("%d moved", playlist.Moved))
+			}
+			if len(playlist.Metadata) > 0 {
+				counts = append(counts, "details changed")
+			}
+			line = playlist.Name + ": " + strings.Join(counts, ", ")
+		}
+		lines = append(lines, line)
+	}
+	// notifications show a few lines at most
+	const shown = 5
+	if len(lines) > shown {
+		lines = append(lines[:shown], fmt.Sprintf("and %d more", len(lines)-shown))
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+// notifyDesktop shows a native desktop notification: through the
+// freedesktop notification service on D-Bus on Linux and the BSDs, the
+// notification center on macOS and a toast on Windows. It needs the
+// daemon to run in the user's session, as a systemd user unit or launchd
+// agent do but a Windows service doesn't.
+func notifyDesktop(ctx context.Context, title string, body string) error {
+	title = "spdump: " + title
+	slog.Debug("showing desktop notification", "title", title)
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		err = notifyMacOS(ctx, title, body)
+	case "windows":
+		err = notifyWindows(ctx, title, body)
+	default:
+		err = notifyDBus(ctx, title, body)
+	}
+	if err != nil {
+		return fmt.Errorf("desktop notification: %w", err)
+	}
+	return nil
+}
+
+// notifyDBus calls org.freedesktop.Notifications.Notify with gdbus,
+// which ships with GLib, or with notify-send where gdbus is missing.
+func notifyDBus(ctx context.Context, title string, body string) error {
+	if _, err := exec.LookPath("gdbus"); err == nil {
+		return runNotifier(exec.CommandContext(ctx, "gdbus", "call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			// app name, replaces id, icon, summary, body, actions, hints
+			// and timeout in milliseconds
+			"spdump", "0", "''", gvariantString(title), gvariantString(body), "[]", "{}", "10000"))
+	}
+	if _, err := exec.LookPath("notify-send"); err == nil {
+		return runNotifier(exec.CommandContext(ctx, "notify-send", "--app-name=spdump", "--", title, body))
+	}
+	return errors.New("neither gdbus nor notify-send found")
+}
+
+// gvariantString quotes s as a GVariant string, the text format gdbus
+// parses its arguments in.
+func gvariantString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// notifyMacOS posts to the notification center with osascript.
+func notifyMacOS(ctx context.Context, title string, body string) error {
+	script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+	return runNotifier(exec.CommandContext(ctx, "osascript", "-e", script))
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// windowsToast shows a toast with the title and body in
+// $SPDUMP_TITLE and $SPDUMP_SUMMARY. Toasts need a registered app ID,
+// it borrows that of PowerShell.
+const windowsToast = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:SPDUMP_TITLE)) | Out-Null
+$text.Item(1).AppendChild($template.CreateTextNode($env:SPDUMP_SUMMARY)) | Out-Null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe').Show($toast)
+`
+
+// notifyWindows shows a toast with PowerShell, passing the text in the
+// environment rather than quoting it into the script.
+func notifyWindows(ctx context.Context, title string, body string) error {
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", windowsToast)
+	cmd.Env = append(os.Environ(), "SPDUMP_TITLE="+title, "SPDUMP_SUMMARY="+body)
+	return runNotifier(cmd)
+}
+
+// runNotifier runs a notification command, returning its output with
+// the error when it fails.
+func runNotifier(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", cmd.Args[0], err, msg)
+		}
+		return fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return nil
+}
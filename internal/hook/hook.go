@@ -0,0 +1,200 @@
+// Package hook notifies other systems of playlist changes, by POSTing a
+// JSON summary to a webhook, piping the changes into a shell command or
+// showing a desktop notification, and of alerts about spdump itself the
+// same way.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/diff"
+)
+
+// Hooks are the hooks fired on changes. Any may be empty.
+type Hooks struct {
+	// URL receives a POST of the Summary as JSON.
+	URL string
+	// Command is run with sh, with the full changes as JSON on stdin and
+	// the summary message in $SPDUMP_SUMMARY.
+	Command string
+	// Desktop shows a native desktop notification.
+	Desktop bool
+	// Client makes the webhook requests, one with a 15 second timeout
+	// when nil.
+	Client *http.Client
+}
+
+// PlaylistSummary counts the changes to a playlist.
+type PlaylistSummary struct {
+	ID      string
+	Name    string
+	Status  string
+	Added   int
+	Removed int
+	Moved   int
+	// Edited counts the tracks whose catalog details changed.
+	Edited   int                `json:",omitempty"`
+	Metadata []diff.FieldChange `json:",omitempty"`
+}
+
+// Summary describes the changes found by a sync, without the tracks.
+type Summary struct {
+	Collection string
+	CreatedAt  time.Time
+	Added      int
+	Removed    int
+	Moved      int
+	Edited     int `json:",omitempty"`
+	Playlists  []PlaylistSummary
+	// Message is a one line description for notifications.
+	Message string
+}
+
+// Summarize returns the summary of changes to a collection.
+func Summarize(collection string, createdAt time.Time, changes diff.Changes) Summary {
+	summary := Summary{Collection: collection, CreatedAt: createdAt}
+	summary.Added, summary.Removed, summary.Moved = changes.Totals()
+	summary.Edited = changes.Edited()
+
+	var names []string
+	for _, change := range changes.Playlists {
+		summary.Playlists = append(summary.Playlists, PlaylistSummary{
+			ID:       change.ID,
+			Name:     change.Name,
+			Status:   change.Status,
+			Added:    len(change.Added),
+			Removed:  len(change.Removed),
+			Moved:    len(change.Moved),
+			Edited:   len(change.Edited),
+			Metadata: change.Metadata,
+		})
+		names = append(names, change.Name)
+	}
+
+	summary.Message = fmt.Sprintf("%s: %d playlists changed, %d tracks added, %d removed, %d moved",
+		collection, len(changes.Playlists), summary.Added, summary.Removed, summary.Moved)
+	if summary.Edited > 0 {
+		summary.Message += fmt.Sprintf(", %d edited", summary.Edited)
+	}
+	if len(names) > 0 {
+		const shown = 5
+		if len(names) > shown {
+			names = append(names[:shown], fmt.Sprintf("and %d more", len(names)-shown))
+		}
+		summary.Message += " (" + strings.Join(names, ", ") + ")"
+	}
+	return summary
+}
+
+// Fire runs the hooks for the changes. Nothing is fired when nothing
+// changed. Both hooks are tried, the errors of both are returned.
+func (o Hooks) Fire(ctx context.Context, summary Summary, changes diff.Changes) error {
+	if changes.Empty() {
+		return nil
+	}
+
+	title, body := desktopMessage(summary)
+	return o.fire(ctx, summary, summary.Message, changes, title, body)
+}
+
+// Alert is a problem with spdump itself rather than news of the
+// playlists, such as its requests failing.
+type Alert struct {
+	// Kind tells alerts apart, e.g. "error_budget_exceeded".
+	Kind string
+	At   time.Time
+	// Message is a one line description for notifications.
+	Message string
+	// Details is anything more the kind of alert carries.
+	Details interface{} `json:",omitempty"`
+}
+
+// Notify fires the hooks for an alert: the webhook receives it as JSON,
+// the command gets it on stdin with the message in $SPDUMP_SUMMARY.
+func (o Hooks) Notify(ctx context.Context, alert Alert) error {
+	return o.fire(ctx, alert, alert.Message, alert, strings.ReplaceAll(alert.Kind, "_", " "), alert.Message)
+}
+
+// fire posts body to the webhook, pipes stdin into the command and shows
+// the desktop notification. Every hook is tried, the errors of all are
+// returned.
+func (o Hooks) fire(ctx context.Context, body interface{}, message string, stdin interface{}, title string, desktopBody string) error {
+	var errs []string
+	if o.URL != "" {
+		if err := o.post(ctx, body); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if o.Command != "" {
+		if err := o.run(ctx, message, stdin); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if o.Desktop {
+		if err := notifyDesktop(ctx, title, desktopBody); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("hooks: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// post sends v to the webhook as JSON.
+func (o Hooks) post(ctx context.Context, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", o.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	slog.Debug("firing webhook", "url", o.URL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", o.URL, resp.Status)
+	}
+	return nil
+}
+
+// run pipes v as JSON into the command, with the message in
+// $SPDUMP_SUMMARY.
+func (o Hooks) run(ctx context.Context, message string, v interface{}) error {
+	stdin, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	slog.Debug("running hook", "command", o.Command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", o.Command)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "SPDUMP_SUMMARY="+message)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook %q: %w", o.Command, err)
+	}
+	return nil
+}
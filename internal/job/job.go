@@ -0,0 +1,396 @@
+// Package job persists the progress of long running jobs, such as a sync
+// of a large library or a webhook refresh, so that a restarted daemon
+// carries on where it stopped instead of starting over, and serve mode
+// can show how far along they are.
+//
+// Jobs are stored in a directory, usually the archive's .jobs, as
+//
+//	<dir>/<job id>.json           the job and the state of each entity
+//	<dir>/<job id>/<entity>.json  the result of each entity done so far
+//
+// The results are removed once the job is done, the state is kept as a
+// record of the run.
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+)
+
+// State is the state of a job or of one of its entities.
+type State string
+
+// The states. Entities start out pending. A job left running by a crash,
+// interrupted by a shutdown or failed is resumed by the next run.
+const (
+	Pending     State = "pending"
+	Running     State = "running"
+	Done        State = "done"
+	Failed      State = "failed"
+	Interrupted State = "interrupted"
+)
+
+// idLayout starts job IDs, sortable by start time.
+const idLayout = "20060102T150405Z"
+
+// Entity is a unit of work of a job, e.g. a playlist to fetch.
+type Entity struct {
+	ID    string
+	Name  string `json:",omitempty"`
+	State State
+	// Tracks is how many tracks the entity came to once done.
+	Tracks int    `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// Job is a job and the progress of its entities. Its methods save every
+// change straight away, and are safe to call on a nil Job, doing nothing,
+// so that persistence can be left off.
+type Job struct {
+	ID         string
+	Kind       string
+	Collection string `json:",omitempty"`
+	State      State
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time `json:",omitempty"`
+	Error      string     `json:",omitempty"`
+	Entities   []Entity
+
+	mu    sync.Mutex
+	store *Store
+}
+
+// Store is a directory of jobs.
+type Store struct {
+	Dir string
+}
+
+// Open opens the job store in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// Create starts a job of the kind over the entities, all pending. A nil
+// Store creates a nil Job.
+func (o *Store) Create(kind string, collection string, entities []Entity) (*Job, error) {
+	if o == nil {
+		return nil, nil
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	j := &Job{
+		ID:         now.Format(idLayout) + "-" + safeName(kind) + "-" + hex.EncodeToString(suffix),
+		Kind:       kind,
+		Collection: collection,
+		State:      Running,
+		StartedAt:  now,
+		UpdatedAt:  now,
+		store:      o,
+	}
+	for _, entity := range entities {
+		entity.State = Pending
+		j.Entities = append(j.Entities, entity)
+	}
+	if err := o.prune(); err != nil {
+		return nil, err
+	}
+	return j, j.save()
+}
+
+// keepDone is how many done jobs are kept as a record, older ones are
+// removed as new jobs start.
+const keepDone = 50
+
+// prune removes the done jobs beyond the latest keepDone.
+func (o *Store) prune() error {
+	jobs, err := o.List()
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, j := range jobs {
+		if j.State != Done {
+			continue
+		}
+		if kept++; kept <= keepDone {
+			continue
+		}
+		if err := os.Remove(filepath.Join(o.Dir, j.ID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resume returns the latest unfinished job of the kind and collection,
+// running again over the entities: those it already did keep their state
+// and results, the others are pending. Without such a job it creates one.
+func (o *Store) Resume(kind string, collection string, entities []Entity) (*Job, bool, error) {
+	if o == nil {
+		return nil, false, nil
+	}
+	jobs, err := o.List()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, j := range jobs {
+		if j.Kind != kind || j.Collection != collection || j.State == Done {
+			continue
+		}
+		done := map[string]Entity{}
+		for _, entity := range j.Entities {
+			if entity.State == Done {
+				done[entity.ID] = entity
+			}
+		}
+		j.Entities = nil
+		for _, entity := range entities {
+			if previous, ok := done[entity.ID]; ok {
+				entity = previous
+			} else {
+				entity.State = Pending
+			}
+			j.Entities = append(j.Entities, entity)
+		}
+		j.State = Running
+		j.Error = ""
+		j.FinishedAt = nil
+		return j, true, j.save()
+	}
+	j, err := o.Create(kind, collection, entities)
+	return j, false, err
+}
+
+// Get reads the job with the ID.
+func (o *Store) Get(id string) (*Job, error) {
+	if id != safeName(id) {
+		return nil, fmt.Errorf("invalid job ID %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(o.Dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	j := &Job{store: o}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("job %s: %w", id, err)
+	}
+	return j, nil
+}
+
+// List returns the jobs, newest first.
+func (o *Store) List() ([]*Job, error) {
+	entries, err := os.ReadDir(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		j, err := o.Get(strings.TrimSuffix(name, ".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			// finished and pruned meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].ID > jobs[k].ID
+	})
+	return jobs, nil
+}
+
+// Complete marks the entity done, saving its result to be taken over
+// should the job be resumed. result may be nil.
+func (o *Job) Complete(entityID string, tracks int, result interface{}) error {
+	if o == nil {
+		return nil
+	}
+	if result != nil {
+		if err := os.MkdirAll(o.resultsDir(), 0o755); err != nil {
+			return err
+		}
+		if err := writeJSON(o.resultPath(entityID), result); err != nil {
+			return err
+		}
+	}
+	return o.update(entityID, func(entity *Entity) {
+		entity.State = Done
+		entity.Tracks = tracks
+		entity.Error = ""
+	})
+}
+
+// Fail marks the entity failed.
+func (o *Job) Fail(entityID string, err error) error {
+	if o == nil {
+		return nil
+	}
+	return o.update(entityID, func(entity *Entity) {
+		entity.State = Failed
+		entity.Error = err.Error()
+	})
+}
+
+// Result reads the saved result of a done entity into v, reporting
+// whether there was one.
+func (o *Job) Result(entityID string, v interface{}) (bool, error) {
+	if o == nil {
+		return false, nil
+	}
+	o.mu.Lock()
+	done := false
+	for _, entity := range o.Entities {
+		if entity.ID == entityID {
+			done = entity.State == Done
+		}
+	}
+	o.mu.Unlock()
+	if !done {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(o.resultPath(entityID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// Finish ends the job, failed when err isn't nil or interrupted when its
+// context was canceled. The results of a job done are removed, those of
+// any other are kept for it to be resumed.
+func (o *Job) Finish(err error) error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	now := time.Now().UTC().Truncate(time.Second)
+	switch {
+	case err == nil:
+		o.State = Done
+		o.FinishedAt = &now
+	case errors.Is(err, context.Canceled):
+		o.State = Interrupted
+	default:
+		o.State = Failed
+		o.Error = err.Error()
+		o.FinishedAt = &now
+	}
+	done := o.State == Done
+	o.mu.Unlock()
+
+	if saveErr := o.save(); saveErr != nil {
+		return saveErr
+	}
+	if !done {
+		return nil
+	}
+	return os.RemoveAll(o.resultsDir())
+}
+
+// Progress returns how many entities are done and failed, and their
+// total.
+func (o *Job) Progress() (done int, failed int, total int) {
+	if o == nil {
+		return 0, 0, 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, entity := range o.Entities {
+		switch entity.State {
+		case Done:
+			done++
+		case Failed:
+			failed++
+		}
+	}
+	return done, failed, len(o.Entities)
+}
+
+// Tracks returns the tracks of the entities done so far.
+func (o *Job) Tracks() int {
+	if o == nil {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	tracks := 0
+	for _, entity := range o.Entities {
+		tracks += entity.Tracks
+	}
+	return tracks
+}
+
+// update changes the entity with the ID and saves the job.
+func (o *Job) update(entityID string, fn func(entity *Entity)) error {
+	o.mu.Lock()
+	for i := range o.Entities {
+		if o.Entities[i].ID == entityID {
+			fn(&o.Entities[i])
+		}
+	}
+	o.mu.Unlock()
+	return o.save()
+}
+
+// save writes the job's state.
+func (o *Job) save() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	return writeJSON(filepath.Join(o.store.Dir, o.ID+".json"), o)
+}
+
+func (o *Job) resultsDir() string {
+	return filepath.Join(o.store.Dir, o.ID)
+}
+
+func (o *Job) resultPath(entityID string) string {
+	return filepath.Join(o.resultsDir(), safeName(entityID)+".json")
+}
+
+// unsafeChars are replaced in job IDs and result file names.
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func safeName(s string) string {
+	return strings.Trim(unsafeChars.ReplaceAllString(s, "-"), "-.")
+}
+
+// writeJSON writes v to path atomically, a job being read by serve mode
+// while a sync updates it.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0o600)
+}
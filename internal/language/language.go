@@ -0,0 +1,210 @@
+// Package language guesses the language of tracks from their titles,
+// album names and artists, for filtering multilingual libraries. Titles
+// are short, so the guess is rough: scripts other than Latin decide on
+// their own, and Latin text is scored by common words and letters
+// particular to a language. No guess is better than a wrong one, so text
+// too short or too ambiguous to tell gets none.
+package language
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Track guesses the ISO 639-1 code of the language of a track, or of an
+// episode, empty when it can't tell. The title counts most, the album or
+// show name less, and the artists only by their script, as names rarely
+// tell a Latin language.
+func Track(track spotify.MusicTrack) string {
+	scores := map[string]float64{}
+	scoreText(scores, stripVersion(track.Name), 2)
+	scoreText(scores, stripVersion(firstNonEmpty(track.AlbumName, track.ShowName)), 1)
+	scoreScripts(scores, track.Artists, 1)
+	return best(scores)
+}
+
+// scripts are the scripts which tell the language on their own, by the
+// share of the letters written in them.
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Bengali, "bn"},
+	{unicode.Tamil, "ta"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// words are common words of the Latin languages, frequent in titles. A
+// word of several languages counts for each of them in part.
+var words = map[string][]string{
+	"en": {"the", "and", "you", "your", "my", "me", "i", "i'm", "don't", "love", "of", "in", "on", "with", "for", "is", "it", "be", "we", "all", "this", "to", "night", "heart", "baby", "never", "what", "when", "can't", "it's"},
+	"es": {"el", "de", "los", "las", "y", "que", "mi", "tu", "con", "para", "por", "una", "amor", "corazón", "quiero", "eres", "sin", "noche", "vida", "cuando", "como", "mí", "tú", "soy", "está", "del"},
+	"pt": {"o", "de", "os", "as", "e", "não", "que", "meu", "minha", "você", "com", "uma", "amor", "coração", "sem", "noite", "vida", "quando", "como", "eu", "sou", "do", "da", "dos", "das", "em", "ao"},
+	"fr": {"le", "de", "les", "et", "je", "tu", "mon", "ma", "mes", "ne", "pas", "avec", "pour", "une", "amour", "cœur", "sans", "nuit", "vie", "quand", "comme", "suis", "est", "du", "des", "au", "c'est", "j'ai", "moi", "toi"},
+	"de": {"der", "die", "das", "und", "ich", "du", "mein", "meine", "nicht", "mit", "für", "ein", "eine", "liebe", "herz", "ohne", "nacht", "leben", "wenn", "wie", "bin", "ist", "dich", "mich", "auf", "im"},
+	"it": {"il", "lo", "gli", "e", "che", "mio", "mia", "non", "con", "per", "una", "amore", "cuore", "senza", "notte", "vita", "quando", "come", "sono", "è", "del", "della", "ti", "io"},
+	"nl": {"de", "het", "en", "ik", "jij", "je", "mijn", "niet", "met", "voor", "een", "liefde", "hart", "zonder", "nacht", "leven", "als", "ben", "is", "van", "op"},
+	"sv": {"och", "jag", "du", "min", "mitt", "inte", "med", "för", "en", "ett", "kärlek", "hjärta", "utan", "natt", "livet", "när", "som", "är", "av", "på", "till"},
+}
+
+// letters are letters particular to a Latin language, or to a few.
+var letters = map[rune][]string{
+	'ñ': {"es"}, '¿': {"es"}, '¡': {"es"},
+	'ã': {"pt"}, 'õ': {"pt"}, 'ç': {"pt", "fr"},
+	'ß': {"de"}, 'ü': {"de"}, 'ä': {"de", "sv"}, 'ö': {"de", "sv"},
+	'å': {"sv"},
+	'œ': {"fr"}, 'ê': {"fr", "pt"}, 'è': {"fr", "it"}, 'à': {"fr", "it", "pt"}, 'ù': {"fr", "it"}, 'î': {"fr"}, 'û': {"fr"},
+	'ì': {"it"}, 'ò': {"it"},
+	'ĳ': {"nl"},
+}
+
+// scoreText adds the scores of the text's scripts, words and letters,
+// weighed.
+func scoreText(scores map[string]float64, text string, weight float64) {
+	if scoreScripts(scores, text, weight) {
+		return
+	}
+	lower := strings.ToLower(text)
+	for _, r := range lower {
+		for _, code := range letters[r] {
+			scores[code] += weight / float64(len(letters[r]))
+		}
+	}
+	for _, word := range strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		word = strings.ReplaceAll(word, "’", "'")
+		var codes []string
+		for code, list := range words {
+			for _, w := range list {
+				if w == word {
+					codes = append(codes, code)
+					break
+				}
+			}
+		}
+		for _, code := range codes {
+			scores[code] += weight / float64(len(codes))
+		}
+	}
+}
+
+// scoreScripts scores text written mostly in a script telling the
+// language, reporting whether it was. Chinese characters are Japanese
+// along with kana and Chinese without.
+func scoreScripts(scores map[string]float64, text string, weight float64) bool {
+	counts := map[string]int{}
+	han, total := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		if unicode.Is(unicode.Han, r) {
+			han++
+			continue
+		}
+		if unicode.Is(unicode.Cyrillic, r) {
+			counts[cyrillic(text)]++
+			continue
+		}
+		for _, script := range scripts {
+			if unicode.Is(script.table, r) {
+				counts[script.code]++
+				break
+			}
+		}
+	}
+	if han > 0 {
+		if counts["ja"] > 0 {
+			counts["ja"] += han
+		} else {
+			counts["zh"] += han
+		}
+	}
+	scored := false
+	for code, n := range counts {
+		if n*2 >= total {
+			scores[code] += 3 * weight
+			scored = true
+		}
+	}
+	return scored
+}
+
+// cyrillic tells Ukrainian, Bulgarian and Serbian from Russian by their
+// own letters.
+func cyrillic(text string) string {
+	switch lower := strings.ToLower(text); {
+	case strings.ContainsAny(lower, "іїєґ"):
+		return "uk"
+	case strings.ContainsAny(lower, "ђћџљњ"):
+		return "sr"
+	case strings.ContainsAny(lower, "ъ") && !strings.ContainsAny(lower, "ыэё"):
+		return "bg"
+	}
+	return "ru"
+}
+
+// best returns the language scoring highest, if it scored enough and
+// clearly more than the next.
+func best(scores map[string]float64) string {
+	code, top, second := "", 0.0, 0.0
+	for c, score := range scores {
+		switch {
+		case score > top || (score == top && c < code):
+			code, top, second = c, score, top
+		case score > second:
+			second = score
+		}
+	}
+	if top < 2 || top < second*1.5 {
+		return ""
+	}
+	return code
+}
+
+// stripVersion drops what describes the release rather than the song,
+// as "(Remastered 2011)" or " - Radio Edit", usually in English.
+func stripVersion(title string) string {
+	if i := strings.Index(title, " - "); i > 0 {
+		title = title[:i]
+	}
+	var b strings.Builder
+	depth := 0
+	for _, r := range title {
+		switch r {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+// Package library matches dumped playlists against a local music
+// collection, to see which tracks are already owned and which are
+// missing.
+package library
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// File is an audio file of the local library.
+type File struct {
+	Path string
+	Tags
+}
+
+// audioExtensions are the file types scanned. Only mp3 and flac tags are
+// read, the others are matched by their file name.
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".m4a":  true,
+	".ogg":  true,
+	".opus": true,
+	".wav":  true,
+}
+
+// Scan walks root for audio files and reads their tags. Files without
+// readable tags fall back to an "Artist - Title" file name.
+func Scan(root string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		tags, err := ReadTags(path)
+		if err != nil {
+			tags = tagsFromName(path)
+		}
+		files = append(files, File{Path: path, Tags: tags})
+		return nil
+	})
+	return files, err
+}
+
+// tagsFromName guesses the artist and title from a file name such as
+// "01 - Artist - Title.mp3", the album from its directory.
+func tagsFromName(path string) Tags {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	parts := strings.Split(name, " - ")
+	// drop a leading track number
+	if len(parts) > 2 && strings.Trim(parts[0], "0123456789. ") == "" {
+		parts = parts[1:]
+	}
+
+	tags := Tags{Title: strings.TrimSpace(parts[len(parts)-1])}
+	if len(parts) > 1 {
+		tags.Artist = strings.TrimSpace(parts[len(parts)-2])
+	}
+	tags.Album = filepath.Base(filepath.Dir(path))
+	return tags
+}
@@ -0,0 +1,259 @@
+package library
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// DefaultThreshold is the similarity, from 0 to 1, both the artist and the
+// title need to reach for a fuzzy match.
+const DefaultThreshold = 0.85
+
+// Report is the outcome of matching playlists against a library.
+type Report struct {
+	Tracks    int
+	Owned     int
+	Playlists []PlaylistReport
+	Missing   []MissingTrack
+}
+
+// PlaylistReport counts the owned tracks of a playlist.
+type PlaylistReport struct {
+	ID      string
+	Name    string
+	Tracks  int
+	Owned   int
+	Missing int
+}
+
+// MissingTrack is a playlist track not found in the library.
+type MissingTrack struct {
+	PlaylistID    string
+	PlaylistName  string
+	Name          string
+	Artists       string
+	AlbumName     string `json:",omitempty"`
+	ISRC          string `json:",omitempty"`
+	IntegrationID string
+	ExternalURL   string `json:",omitempty"`
+}
+
+// Matcher finds playlist tracks among the files of a library, by ISRC
+// when both sides have one and otherwise by a fuzzy artist and title match.
+type Matcher struct {
+	Threshold float64
+	files     []File
+	titles    []string
+	isrc      map[string]int
+	artists   map[string][]int
+}
+
+// NewMatcher indexes the files for matching.
+func NewMatcher(files []File, threshold float64) *Matcher {
+	o := &Matcher{
+		Threshold: threshold,
+		files:     files,
+		titles:    make([]string, len(files)),
+		isrc:      map[string]int{},
+		artists:   map[string][]int{},
+	}
+	for i, file := range files {
+		o.titles[i] = NormalizeTitle(file.Title)
+		if file.ISRC != "" {
+			o.isrc[strings.ToUpper(file.ISRC)] = i
+		}
+		for _, artist := range splitArtists(file.Artist) {
+			if key := NormalizeArtist(artist); key != "" {
+				o.artists[key] = append(o.artists[key], i)
+			}
+		}
+	}
+	return o
+}
+
+// Find returns the file best matching the track and its similarity.
+func (o *Matcher) Find(track spotify.MusicTrack) (File, float64, bool) {
+	if i, ok := o.isrc[strings.ToUpper(track.ISRC)]; ok && track.ISRC != "" {
+		return o.files[i], 1, true
+	}
+
+	title := NormalizeTitle(track.Name)
+	best, bestScore := -1, 0.0
+	for _, artist := range TrackArtists(track) {
+		artist = NormalizeArtist(artist)
+		for key, indexes := range o.artists {
+			artistScore := similarity(artist, key)
+			if artistScore < o.Threshold {
+				continue
+			}
+			for _, i := range indexes {
+				score := similarity(title, o.titles[i])
+				if artistScore < score {
+					score = artistScore
+				}
+				if score >= o.Threshold && score > bestScore {
+					best, bestScore = i, score
+				}
+			}
+		}
+	}
+	if best < 0 {
+		return File{}, 0, false
+	}
+	return o.files[best], bestScore, true
+}
+
+// Match reports which of the playlists' tracks are in the library.
+func (o *Matcher) Match(playlists []spotify.MusicPlaylist) Report {
+	report := Report{}
+	for _, mp := range playlists {
+		pr := PlaylistReport{ID: mp.IntegrationID, Name: mp.Name, Tracks: len(mp.Tracks)}
+		for _, track := range mp.Tracks {
+			if _, _, ok := o.Find(track); ok {
+				pr.Owned++
+				continue
+			}
+			pr.Missing++
+			report.Missing = append(report.Missing, MissingTrack{
+				PlaylistID:    mp.IntegrationID,
+				PlaylistName:  mp.Name,
+				Name:          track.Name,
+				Artists:       track.Artists,
+				AlbumName:     track.AlbumName,
+				ISRC:          track.ISRC,
+				IntegrationID: track.IntegrationID,
+				ExternalURL:   track.ExternalURL,
+			})
+		}
+		report.Tracks += pr.Tracks
+		report.Owned += pr.Owned
+		report.Playlists = append(report.Playlists, pr)
+	}
+	return report
+}
+
+// TrackArtists returns the names of the track's artists.
+func TrackArtists(track spotify.MusicTrack) []string {
+	if len(track.ArtistList) > 0 {
+		names := make([]string, 0, len(track.ArtistList))
+		for _, artist := range track.ArtistList {
+			names = append(names, artist.Name)
+		}
+		return names
+	}
+	return splitArtists(track.Artists)
+}
+
+// artistSeparators split the artists of a tag such as "A feat. B & C".
+var artistSeparators = strings.NewReplacer(
+	" feat. ", ",", " feat ", ",", " ft. ", ",", " featuring ", ",",
+	" & ", ",", " x ", ",", ";", ",", "/", ",",
+)
+
+func splitArtists(artists string) []string {
+	var names []string
+	for _, name := range strings.Split(artistSeparators.Replace(artists), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// NormalizeArtist folds case, accents and punctuation and drops a
+// leading "The".
+func NormalizeArtist(artist string) string {
+	return strings.TrimPrefix(normalize(artist), "the ")
+}
+
+// NormalizeTitle drops what differs between releases of the same
+// recording: bracketed parts like "(Remastered 2011)" or "[feat. X]", a
+// " - Radio Edit" suffix and featured artists.
+func NormalizeTitle(title string) string {
+	title = strings.ToLower(title)
+	if i := strings.Index(title, " - "); i > 0 {
+		title = title[:i]
+	}
+	for _, feat := range []string{" feat. ", " feat ", " ft. ", " featuring "} {
+		if i := strings.Index(title, feat); i > 0 {
+			title = title[:i]
+		}
+	}
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range title {
+		switch r {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				b.WriteRune(r)
+			}
+		}
+	}
+	if normalized := normalize(b.String()); normalized != "" {
+		return normalized
+	}
+	// the title is all brackets
+	return normalize(title)
+}
+
+// accents folds the accented latin letters to their base letter.
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y",
+	"ß", "ss", "æ", "ae", "œ", "oe", "&", "and",
+)
+
+// normalize lower cases s, folds accents and reduces punctuation and
+// runs of spaces to single spaces.
+func normalize(s string) string {
+	s = accents.Replace(strings.ToLower(s))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// similarity is 1 minus the edit distance of a and b relative to the
+// longer one, 1 for equal strings.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,339 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tags are the track details read from an audio file.
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string `json:",omitempty"`
+	ISRC   string `json:",omitempty"`
+}
+
+// errNoTags is returned for files without tags spdump can read.
+var errNoTags = errors.New("no tags")
+
+// maxTagSize bounds the tag data read from a single file.
+const maxTagSize = 16 << 20
+
+// ReadTags reads the ID3 tags of an mp3 or the Vorbis comments of a flac
+// file.
+func ReadTags(path string) (Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".flac":
+		return readFLAC(f)
+	case ".mp3":
+		tags, err := readID3v2(f)
+		if err == errNoTags {
+			return readID3v1(f)
+		}
+		return tags, err
+	}
+	return Tags{}, errNoTags
+}
+
+// readID3v2 reads an ID3v2.2, 2.3 or 2.4 tag at the start of the file.
+func readID3v2(r io.ReadSeeker) (Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:3]) != "ID3" {
+		return Tags{}, errNoTags
+	}
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if size > maxTagSize {
+		return Tags{}, errors.New("id3 tag too big")
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Tags{}, err
+	}
+	if flags&0x80 != 0 && version < 4 {
+		data = unsynchronise(data)
+	}
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		// skip the extended header
+		extended := int(binary.BigEndian.Uint32(data))
+		if version == 4 {
+			extended = syncsafe(data[:4])
+		} else {
+			extended += 4
+		}
+		if extended > len(data) {
+			return Tags{}, errNoTags
+		}
+		data = data[extended:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	tags := Tags{}
+	for len(data) >= headerSize && data[0] != 0 {
+		id := string(data[:idSize])
+		var frameSize int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			frameSize = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[4:8]))
+			frameFlags = binary.BigEndian.Uint16(data[8:10])
+		default:
+			frameSize = syncsafe(data[4:8])
+			frameFlags = binary.BigEndian.Uint16(data[8:10])
+		}
+		if frameSize > len(data)-headerSize {
+			break
+		}
+		frame := data[headerSize : headerSize+frameSize]
+		data = data[headerSize+frameSize:]
+
+		if version == 4 {
+			if frameFlags&0x0008 != 0 || frameFlags&0x0004 != 0 {
+				// compressed or encrypted
+				continue
+			}
+			if frameFlags&0x0001 != 0 && len(frame) >= 4 {
+				frame = frame[4:]
+			}
+			if frameFlags&0x0002 != 0 {
+				frame = unsynchronise(frame)
+			}
+		} else if version == 3 && frameFlags&0x00c0 != 0 {
+			continue
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = id3Text(frame)
+		case "TPE1", "TP1":
+			tags.Artist = id3Text(frame)
+		case "TALB", "TAL":
+			tags.Album = id3Text(frame)
+		case "TSRC", "TRC":
+			tags.ISRC = id3Text(frame)
+		}
+	}
+	if tags.Title == "" {
+		return tags, errNoTags
+	}
+	return tags, nil
+}
+
+// readID3v1 reads the ID3v1 tag in the last 128 bytes of the file.
+func readID3v1(r io.ReadSeeker) (Tags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return Tags{}, errNoTags
+	}
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(r, tag); err != nil || string(tag[:3]) != "TAG" {
+		return Tags{}, errNoTags
+	}
+	tags := Tags{
+		Title:  latin1(trimNull(tag[3:33])),
+		Artist: latin1(trimNull(tag[33:63])),
+		Album:  latin1(trimNull(tag[63:93])),
+	}
+	if tags.Title == "" {
+		return tags, errNoTags
+	}
+	return tags, nil
+}
+
+// readFLAC reads the Vorbis comment block of a flac file.
+func readFLAC(r io.ReadSeeker) (Tags, error) {
+	// some taggers put an ID3v2 tag in front of the stream
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Tags{}, errNoTags
+	}
+	offset := int64(0)
+	if string(header[:3]) == "ID3" {
+		offset = 10 + int64(syncsafe(header[6:10]))
+		if header[5]&0x10 != 0 {
+			offset += 10 // footer
+		}
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(r, marker); err != nil || string(marker) != "fLaC" {
+		return Tags{}, errNoTags
+	}
+
+	block := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return Tags{}, errNoTags
+		}
+		last := block[0]&0x80 != 0
+		blockType := block[0] & 0x7f
+		size := int64(block[1])<<16 | int64(block[2])<<8 | int64(block[3])
+
+		if blockType == 4 {
+			if size > maxTagSize {
+				return Tags{}, errors.New("flac comments too big")
+			}
+			comments := make([]byte, size)
+			if _, err := io.ReadFull(r, comments); err != nil {
+				return Tags{}, err
+			}
+			return vorbisComments(comments)
+		}
+		if last {
+			return Tags{}, errNoTags
+		}
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return Tags{}, err
+		}
+	}
+}
+
+// vorbisComments parses a Vorbis comment block, repeated artists are
+// joined like spotify joins them.
+func vorbisComments(block []byte) (Tags, error) {
+	next := func() ([]byte, bool) {
+		if len(block) < 4 {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(block)
+		if uint64(n) > uint64(len(block)-4) {
+			return nil, false
+		}
+		value := block[4 : 4+n]
+		block = block[4+n:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok { // vendor
+		return Tags{}, errNoTags
+	}
+	if len(block) < 4 {
+		return Tags{}, errNoTags
+	}
+	count := binary.LittleEndian.Uint32(block)
+	block = block[4:]
+
+	tags := Tags{}
+	var artists []string
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+		key, value, ok := strings.Cut(string(comment), "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			artists = append(artists, value)
+		case "ALBUM":
+			tags.Album = value
+		case "ISRC":
+			tags.ISRC = value
+		}
+	}
+	tags.Artist = strings.Join(artists, ", ")
+	if tags.Title == "" {
+		return tags, errNoTags
+	}
+	return tags, nil
+}
+
+// id3Text decodes a text frame, several values are joined with commas.
+func id3Text(frame []byte) string {
+	if len(frame) < 1 {
+		return ""
+	}
+	encoding, data := frame[0], frame[1:]
+
+	var text string
+	switch encoding {
+	case 1, 2:
+		text = decodeUTF16(data, encoding == 2)
+	case 3:
+		text = string(data)
+	default:
+		text = latin1(data)
+	}
+
+	var values []string
+	for _, value := range strings.Split(text, "\x00") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// decodeUTF16 decodes UTF-16 text, honouring a byte order mark.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	var units []uint16
+	for i := 0; i+1 < len(data); i += 2 {
+		switch {
+		case data[i] == 0xff && data[i+1] == 0xfe:
+			bigEndian = false
+			continue
+		case data[i] == 0xfe && data[i+1] == 0xff:
+			bigEndian = true
+			continue
+		}
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 decodes ISO-8859-1 text.
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// syncsafe decodes a 28 bit ID3 syncsafe integer.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// unsynchronise undoes ID3 unsynchronisation, which inserts a zero byte
+// after every 0xff.
+func unsynchronise(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+func trimNull(b []byte) []byte {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return bytes.TrimRight(b, " ")
+}
@@ -0,0 +1,162 @@
+// Package locale formats numbers, dates and durations and translates the
+// headings used in human readable reports.
+package locale
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale describes how to format report values for one language.
+type Locale struct {
+	Tag        string
+	Decimal    string
+	Group      string
+	DateLayout string
+	Hours      string
+	Minutes    string
+	headings   map[string]string
+}
+
+// Default is the locale used when none is requested.
+var Default = locales["en"]
+
+var locales = map[string]*Locale{
+	"en": {Tag: "en", Decimal: ".", Group: ",", DateLayout: "Jan 2, 2006", Hours: "h", Minutes: "min"},
+	"de": {Tag: "de", Decimal: ",", Group: ".", DateLayout: "02.01.2006", Hours: "Std.", Minutes: "Min.", headings: map[string]string{
+		"Playlist": "Playlist", "Tracks": "Titel", "Title": "Titel", "Artists": "Künstler", "Album": "Album",
+		"Duration": "Dauer", "Added": "Hinzugefügt", "Link": "Link", "Total duration": "Gesamtdauer", "Released": "Veröffentlicht",
+		"Shopping list": "Einkaufsliste", "Buy": "Kaufen",
+	}},
+	"fr": {Tag: "fr", Decimal: ",", Group: " ", DateLayout: "02/01/2006", Hours: "h", Minutes: "min", headings: map[string]string{
+		"Playlist": "Playlist", "Tracks": "Titres", "Title": "Titre", "Artists": "Artistes", "Album": "Album",
+		"Duration": "Durée", "Added": "Ajouté", "Link": "Lien", "Total duration": "Durée totale", "Released": "Sortie",
+		"Shopping list": "Liste d'achats", "Buy": "Acheter",
+	}},
+	"es": {Tag: "es", Decimal: ",", Group: ".", DateLayout: "02/01/2006", Hours: "h", Minutes: "min", headings: map[string]string{
+		"Playlist": "Lista", "Tracks": "Canciones", "Title": "Título", "Artists": "Artistas", "Album": "Álbum",
+		"Duration": "Duración", "Added": "Añadida", "Link": "Enlace", "Total duration": "Duración total", "Released": "Publicado",
+		"Shopping list": "Lista de compras", "Buy": "Comprar",
+	}},
+	"nl": {Tag: "nl", Decimal: ",", Group: ".", DateLayout: "02-01-2006", Hours: "u", Minutes: "min", headings: map[string]string{
+		"Playlist": "Afspeellijst", "Tracks": "Nummers", "Title": "Titel", "Artists": "Artiesten", "Album": "Album",
+		"Duration": "Duur", "Added": "Toegevoegd", "Link": "Link", "Total duration": "Totale duur", "Released": "Uitgebracht",
+		"Shopping list": "Aankooplijst", "Buy": "Kopen",
+	}},
+	"sv": {Tag: "sv", Decimal: ",", Group: " ", DateLayout: "2006-01-02", Hours: "tim", Minutes: "min", headings: map[string]string{
+		"Playlist": "Spellista", "Tracks": "Låtar", "Title": "Titel", "Artists": "Artister", "Album": "Album",
+		"Duration": "Längd", "Added": "Tillagd", "Link": "Länk", "Total duration": "Total längd", "Released": "Utgiven",
+		"Shopping list": "Inköpslista", "Buy": "Köp",
+	}},
+	"pt": {Tag: "pt", Decimal: ",", Group: ".", DateLayout: "02/01/2006", Hours: "h", Minutes: "min", headings: map[string]string{
+		"Playlist": "Playlist", "Tracks": "Faixas", "Title": "Título", "Artists": "Artistas", "Album": "Álbum",
+		"Duration": "Duração", "Added": "Adicionada", "Link": "Link", "Total duration": "Duração total", "Released": "Lançamento",
+		"Shopping list": "Lista de compras", "Buy": "Comprar",
+	}},
+}
+
+// Lookup returns the locale for a tag such as "de", "de-AT" or
+// "de_DE.UTF-8". Only the language part is used. An empty tag picks
+// the locale from the LC_ALL and LANG environment variables, falling back
+// to Default when they name a locale there is none for, such as C.UTF-8
+// or it_IT.UTF-8: only a tag asked for explicitly is an error.
+func Lookup(tag string) (*Locale, error) {
+	if tag != "" {
+		l, ok := lookup(tag)
+		if !ok {
+			return nil, fmt.Errorf("unsupported locale %q", tag)
+		}
+		return l, nil
+	}
+	tag = os.Getenv("LC_ALL")
+	if tag == "" {
+		tag = os.Getenv("LANG")
+	}
+	if l, ok := lookup(tag); ok {
+		return l, nil
+	}
+	return Default, nil
+}
+
+// lookup returns the locale for the language of tag.
+func lookup(tag string) (*Locale, bool) {
+	lang := strings.ToLower(tag)
+	if i := strings.IndexAny(lang, "-_.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	l, ok := locales[lang]
+	return l, ok
+}
+
+// T translates a report heading, falling back to the English text.
+func (l *Locale) T(heading string) string {
+	if translated, ok := l.headings[heading]; ok {
+		return translated
+	}
+	return heading
+}
+
+// Number formats n with the locale's digit grouping.
+func (l *Locale) Number(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// Float formats f with prec decimals and the locale's separators.
+func (l *Locale) Float(f float64, prec int) string {
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	n, _ := strconv.Atoi(whole)
+	out := l.Number(n)
+	if n == 0 && strings.HasPrefix(whole, "-") {
+		out = "-" + out
+	}
+	if frac != "" {
+		out += l.Decimal + frac
+	}
+	return out
+}
+
+// Date formats t in the locale's short date style.
+func (l *Locale) Date(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// Duration formats a track length as m:ss, or h:mm:ss past an hour.
+func (l *Locale) Duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d%time.Hour) / int(time.Minute)
+	s := int(d%time.Minute) / int(time.Second)
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// LongDuration formats a total such as a playlist length in words,
+// e.g. "3 h 25 min".
+func (l *Locale) LongDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d / time.Hour)
+	m := int(d%time.Hour) / int(time.Minute)
+	if h > 0 {
+		return fmt.Sprintf("%s %s %d %s", l.Number(h), l.Hours, m, l.Minutes)
+	}
+	return fmt.Sprintf("%d %s", m, l.Minutes)
+}
@@ -0,0 +1,215 @@
+// Package mail sends email through an SMTP server: a message with a plain
+// text and an html body, and attachments, such as the weekly digest.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an email.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	// Text and HTML are the plain text and html versions of the body,
+	// either may be empty.
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Bytes renders the message as MIME, ready to send.
+func (o Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(o.From)
+	if err != nil {
+		return nil, fmt.Errorf("from address %q: %w", o.From, err)
+	}
+	if len(o.To) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	for _, to := range o.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("to address %q: %w", to, err)
+		}
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", o.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(o.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", o.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	mixed := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed.Boundary())
+
+	// the bodies are alternatives of each other, the attachments follow
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, text string }{
+		{"text/plain", o.Text},
+		{"text/html", o.HTML},
+	} {
+		if part.text == "" {
+			continue
+		}
+		w, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.text))
+		qp.Close()
+	}
+	alternative.Close()
+	w, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + strconv.Quote(alternative.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Write(body.Bytes())
+
+	for _, attachment := range o.Attachments {
+		w, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(attachment.ContentType, map[string]string{"name": attachment.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(w, attachment.Data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters, as
+// MIME wants them.
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// Server is the SMTP server messages are sent through.
+type Server struct {
+	Host string
+	// Port is 587 when zero. Port 465 is spoken to over TLS from the
+	// start, others are upgraded with STARTTLS when the server offers it.
+	Port     int
+	Username string
+	Password string
+}
+
+// timeout bounds a whole delivery.
+const timeout = time.Minute
+
+// Send delivers the message. Credentials are only sent over TLS, or to a
+// server on localhost.
+func (o Server) Send(ctx context.Context, msg Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	port := o.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(o.Host, strconv.Itoa(port))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: o.Host}
+	if port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, o.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp %s: starttls: %w", addr, err)
+		}
+	}
+	if o.Username != "" {
+		// PlainAuth refuses to send the password in the clear itself
+		if err := client.Auth(smtp.PlainAuth("", o.Username, o.Password, o.Host)); err != nil {
+			return fmt.Errorf("smtp %s: auth: %w", addr, err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(msg.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	for _, to := range msg.To {
+		rcpt, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("smtp %s: recipient %s: %w", addr, rcpt.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,145 @@
+// Package manifest lists the files of a multi-file dump, such as an
+// archive snapshot, a bundle or a set of shards, with their SHA-256
+// hashes, sizes and item counts, so that a dump copied around or seeded
+// for years can be checked to have come through whole.
+//
+// The manifest is written last, as manifest.json next to the files it
+// lists, e.g.
+//
+//	{
+//	  "Tool": "spdump",
+//	  "Version": "v1.4.0",
+//	  "CreatedAt": "2024-01-02T15:04:05Z",
+//	  "Files": [
+//	    {"Name": "37i9dQZF1DXcBWIGoYBM5M.json", "SHA256": "9f86d0...", "Bytes": 48213, "Items": 50},
+//	    {"Name": "index.json", "SHA256": "2c26b4...", "Bytes": 311}
+//	  ]
+//	}
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Name is the file name of a manifest.
+const Name = "manifest.json"
+
+// Tool names the program writing manifests.
+const Tool = "spdump"
+
+// Manifest describes the files of a dump.
+type Manifest struct {
+	Tool string
+	// Version is the version of the tool which wrote the dump.
+	Version   string
+	CreatedAt time.Time
+	Files     []File
+}
+
+// File describes a file of a dump.
+type File struct {
+	// Name is the path of the file relative to the manifest.
+	Name string
+	// SHA256 is the hex encoded SHA-256 hash of the file's contents.
+	SHA256 string
+	Bytes  int64
+	// Items counts the tracks of a playlist, or the lines of a shard.
+	Items int `json:",omitempty"`
+}
+
+// New starts the manifest of a dump created at t.
+func New(t time.Time) *Manifest {
+	return &Manifest{
+		Tool:      Tool,
+		Version:   Version(),
+		CreatedAt: t.UTC().Truncate(time.Second),
+	}
+}
+
+// Version returns the version spdump was built at: its module version
+// when installed with go install, the VCS revision for builds from a
+// checkout, or "devel".
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return "devel"
+}
+
+// Add lists a file of the dump with its contents.
+func (o *Manifest) Add(name string, data []byte, items int) {
+	sum := sha256.Sum256(data)
+	o.AddHash(name, hex.EncodeToString(sum[:]), int64(len(data)), items)
+}
+
+// AddHash lists a file whose contents were hashed as they were streamed
+// out.
+func (o *Manifest) AddHash(name string, sum string, size int64, items int) {
+	o.Files = append(o.Files, File{Name: name, SHA256: sum, Bytes: size, Items: items})
+}
+
+// Marshal encodes the manifest as written to Name.
+func (o *Manifest) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Parse decodes a manifest.
+func Parse(data []byte) (Manifest, error) {
+	m := Manifest{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", Name, err)
+	}
+	if m.Tool != Tool {
+		return m, fmt.Errorf("%s: not an %s manifest", Name, Tool)
+	}
+	return m, nil
+}
+
+// Verify checks every file of the manifest, read by open, against its
+// hash and size. When check is given it also validates the contents of
+// the files whose hash matches, returning how many items it found in
+// them to compare with the manifest's count. Verify returns a problem
+// per bad file, none when the dump is whole.
+func (o Manifest) Verify(open func(name string) ([]byte, error), check func(name string, data []byte) (int, error)) []error {
+	var problems []error
+	for _, f := range o.Files {
+		data, err := open(f.Name)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", f.Name, err))
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != f.Bytes || hex.EncodeToString(sum[:]) != f.SHA256 {
+			problems = append(problems, fmt.Errorf("%s: checksum mismatch, %d bytes hashing to %x, expected %d bytes hashing to %s", f.Name, len(data), sum, f.Bytes, f.SHA256))
+			continue
+		}
+		if check == nil {
+			continue
+		}
+		items, err := check(f.Name, data)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", f.Name, err))
+		} else if items != f.Items {
+			problems = append(problems, fmt.Errorf("%s: %d items, expected %d", f.Name, items, f.Items))
+		}
+	}
+	return problems
+}
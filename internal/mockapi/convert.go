@@ -0,0 +1,138 @@
+package mockapi
+
+import (
+	"html"
+	"time"
+
+	"github.com/pyrat/spd/pkg/model"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// apiPlaylist returns the playlist object the API sends for mp, without
+// its tracks.
+func apiPlaylist(mp model.MusicPlaylist) spotify.SpotifyPlaylist {
+	playlist := spotify.SpotifyPlaylist{
+		Name:          mp.Name,
+		Images:        mp.PlaylistArt,
+		URI:           "spotify:playlist:" + mp.IntegrationID,
+		ExternalURL:   spotify.SpotifyExternalURL{Spotify: "https://open.spotify.com/playlist/" + mp.IntegrationID},
+		IntegrationID: mp.IntegrationID,
+		SnapshotID:    mp.SnapshotID,
+		// the API escapes descriptions as HTML
+		Description:   html.EscapeString(mp.Description),
+		Public:        mp.Public,
+		Collaborative: mp.Collaborative,
+		Followers:     spotify.SpotifyFollowers{Total: mp.Followers},
+	}
+	if mp.Owner != nil {
+		playlist.Owner = spotify.SpotifyUser{DisplayName: mp.Owner.Name, IntegrationID: mp.Owner.IntegrationID}
+	}
+	playlist.TracksCollection.Total = len(mp.Tracks)
+	return playlist
+}
+
+// apiItem returns the playlist item the API sends for a dumped track,
+// undoing spotify.ConvertToMusicPlaylistTrack.
+func apiItem(track model.MusicTrack) spotify.SpotifyPlaylistTrack {
+	item := spotify.SpotifyPlaylistTrack{AddedBy: spotify.SpotifyUser{IntegrationID: track.AddedBy}}
+	if track.AddedAt != nil {
+		item.AddedAt = *track.AddedAt
+	}
+	if track.Type == model.TypeEpisode {
+		episode := apiEpisode(track)
+		item.Episode = &episode
+		return item
+	}
+	item.Track = apiTrack(track)
+	return item
+}
+
+// precision returns the precision of a parsed release date.
+func precision(released *model.ReleaseDate) string {
+	if released == nil {
+		return ""
+	}
+	return released.Precision
+}
+
+func apiEpisode(track model.MusicTrack) spotify.SpotifyEpisode {
+	return spotify.SpotifyEpisode{
+		Name:                 track.Name,
+		AudioPreviewURL:      track.PreviewURL,
+		URI:                  track.URI(),
+		IntegrationID:        track.IntegrationID,
+		DurationMS:           track.DurationMS,
+		ReleaseDate:          track.ReleaseDate,
+		ReleaseDatePrecision: precision(track.Released),
+		Images:               track.AlbumArt,
+		ExternalURL:          spotify.SpotifyExternalURL{Spotify: track.ExternalURL},
+		IsPlayable:           track.IsPlayable,
+		Explicit:             track.Explicit,
+		Show:                 spotify.SpotifyShow{Name: track.ShowName, Publisher: track.Artists},
+	}
+}
+
+func apiTrack(track model.MusicTrack) spotify.SpotifyTrack {
+	st := spotify.SpotifyTrack{
+		Album: spotify.SpotifyAlbum{
+			Name:                 track.AlbumName,
+			Images:               track.AlbumArt,
+			IntegrationID:        track.AlbumID,
+			ReleaseDate:          track.AlbumReleaseDate,
+			ReleaseDatePrecision: precision(track.Released),
+		},
+		Name:          track.Name,
+		PreviewURL:    track.PreviewURL,
+		IntegrationID: track.IntegrationID,
+		DurationMS:    track.DurationMS,
+		ExternalURL:   spotify.SpotifyExternalURL{Spotify: track.ExternalURL},
+		IsPlayable:    track.IsPlayable,
+		IsLocal:       track.Source == model.SourceLocal,
+		Explicit:      track.Explicit,
+		DiscNumber:    track.DiscNumber,
+		TrackNumber:   track.TrackNumber,
+		ExternalIDs:   spotify.SpotifyExternalIDs{ISRC: track.ISRC},
+		Type:          model.TypeTrack,
+	}
+	if track.IntegrationID != "" {
+		st.TrackURI = track.URI()
+	}
+	if track.AlbumID != "" {
+		st.Album.URI = "spotify:album:" + track.AlbumID
+	}
+	for _, artist := range track.ArtistList {
+		st.Artists = append(st.Artists, spotify.SpotifyArtist{Name: artist.Name, IntegrationID: artist.IntegrationID})
+	}
+	if track.LinkedFrom != "" {
+		st.LinkedFrom = &spotify.SpotifyLinkedTrack{IntegrationID: track.LinkedFrom, URI: "spotify:track:" + track.LinkedFrom}
+	}
+
+	switch track.Unavailable {
+	case "", model.UnavailableRemoved:
+	case model.UnavailableNotPlayable:
+		playable := false
+		st.IsPlayable = &playable
+	case model.UnavailableNoMarkets:
+		st.IsPlayable = nil
+		st.AvailableMarkets = []string{}
+	default:
+		st.Restrictions = &spotify.SpotifyRestrictions{Reason: track.Unavailable}
+	}
+	return st
+}
+
+// addedTrack returns the track for a URI added through the API: the
+// library's track with that URI, or a bare one.
+func (o *Server) addedTrack(uri string, now time.Time) model.MusicTrack {
+	track, ok := o.tracks[uri]
+	if !ok {
+		resource, _ := spotify.ParseResource(uri)
+		track = model.MusicTrack{IntegrationID: resource.ID, Source: model.SourceSpotify}
+		if resource.Type == spotify.TypeEpisode {
+			track.Type = model.TypeEpisode
+		}
+	}
+	track.AddedAt = &now
+	track.AddedBy = o.user.IntegrationID
+	return track
+}
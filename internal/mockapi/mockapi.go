@@ -0,0 +1,410 @@
+// Package mockapi serves a library of playlists, such as one generated by
+// package fixtures, through a mock of the parts of the Spotify Web API
+// spdump uses, so whole runs can be checked without an account or network
+// access:
+//
+//	srv := httptest.NewServer(mockapi.New(library))
+//	sp, err := spotify.NewClient(ctx, "id", "secret",
+//		spotify.WithBaseURL(srv.URL+"/v1"),
+//		spotify.WithAuthURL(srv.URL+"/api/token"))
+//
+// Tokens are handed to any client, playlists created through the API are
+// owned by the owner of the library's first playlist.
+package mockapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/pkg/model"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Token is the access token the mock hands out and accepts.
+const Token = "mockapi-token"
+
+// Server is the mock API. It must be created with New.
+type Server struct {
+	mux  *http.ServeMux
+	user model.MusicUser
+
+	mu        sync.Mutex
+	playlists map[string]*model.MusicPlaylist
+	order     []string
+	// tracks are the library's tracks by URI, for tracks added through
+	// the API.
+	tracks  map[string]model.MusicTrack
+	albums  map[string]spotify.SpotifyAlbum
+	artists map[string]spotify.SpotifyArtist
+	created int
+	// requests counts the API requests served, token requests aside.
+	requests int
+}
+
+// New returns a Server for the library.
+func New(library []model.MusicPlaylist) *Server {
+	s := &Server{
+		mux:       http.NewServeMux(),
+		user:      model.MusicUser{Name: "Mock User", IntegrationID: "mockuser"},
+		playlists: map[string]*model.MusicPlaylist{},
+		tracks:    map[string]model.MusicTrack{},
+		albums:    map[string]spotify.SpotifyAlbum{},
+		artists:   map[string]spotify.SpotifyArtist{},
+	}
+	if len(library) > 0 && library[0].Owner != nil {
+		s.user = *library[0].Owner
+	}
+	for _, mp := range library {
+		mp := mp
+		s.playlists[mp.IntegrationID] = &mp
+		s.order = append(s.order, mp.IntegrationID)
+		for _, track := range mp.Tracks {
+			if track.IntegrationID != "" && track.Source != model.SourceLocal {
+				s.tracks[track.URI()] = track
+			}
+			s.addAlbum(track)
+			s.addArtists(track)
+		}
+	}
+
+	s.mux.HandleFunc("/api/token", s.handleToken)
+	s.mux.HandleFunc("/v1/me", s.api(s.handleMe))
+	s.mux.HandleFunc("/v1/me/playlists", s.api(s.handleUserPlaylists))
+	s.mux.HandleFunc("/v1/users/", s.api(s.handleUserPlaylists))
+	s.mux.HandleFunc("/v1/playlists/", s.api(s.handlePlaylist))
+	s.mux.HandleFunc("/v1/albums", s.api(s.handleAlbums))
+	s.mux.HandleFunc("/v1/artists", s.api(s.handleArtists))
+	return s
+}
+
+// addAlbum records the album of a track, with the track among its tracks
+// and a UPC made up from its ID.
+func (o *Server) addAlbum(track model.MusicTrack) {
+	if track.AlbumID == "" || track.Source == model.SourceLocal {
+		return
+	}
+	album, ok := o.albums[track.AlbumID]
+	if !ok {
+		st := apiTrack(track)
+		album = st.Album
+		album.ExternalURL = spotify.SpotifyExternalURL{Spotify: "https://open.spotify.com/album/" + track.AlbumID}
+		album.AlbumType = "album"
+		album.Artists = st.Artists
+		album.ExternalIDs = spotify.SpotifyExternalIDs{UPC: fmt.Sprintf("%012d", crc32.ChecksumIEEE([]byte(track.AlbumID)))}
+		album.Label = "Fixture Records"
+	}
+	st := apiTrack(track)
+	st.Album = spotify.SpotifyAlbum{}
+	album.TracksCollection.Items = append(album.TracksCollection.Items, st)
+	o.albums[track.AlbumID] = album
+}
+
+// addArtists records the artists of a track, with the track's genres as
+// theirs since Spotify keeps genres on artists.
+func (o *Server) addArtists(track model.MusicTrack) {
+	for _, artist := range track.ArtistList {
+		if artist.IntegrationID == "" {
+			continue
+		}
+		known, ok := o.artists[artist.IntegrationID]
+		if !ok {
+			known = spotify.SpotifyArtist{
+				Name:          artist.Name,
+				IntegrationID: artist.IntegrationID,
+				URI:           "spotify:artist:" + artist.IntegrationID,
+				ExternalURL:   spotify.SpotifyExternalURL{Spotify: "https://open.spotify.com/artist/" + artist.IntegrationID},
+				Genres:        []string{},
+			}
+		}
+		for _, genre := range track.Genres {
+			if !contains(known.Genres, genre) {
+				known.Genres = append(known.Genres, genre)
+			}
+		}
+		o.artists[artist.IntegrationID] = known
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP implements http.Handler.
+func (o *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mux.ServeHTTP(w, r)
+}
+
+// Playlist returns a playlist as the mock holds it, including those
+// created and changed through the API.
+func (o *Server) Playlist(id string) (model.MusicPlaylist, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	mp, ok := o.playlists[id]
+	if !ok {
+		return model.MusicPlaylist{}, false
+	}
+	return *mp, true
+}
+
+// Requests returns how many API requests were served.
+func (o *Server) Requests() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests
+}
+
+// api wraps an API handler, checking the token and holding the lock.
+func (o *Server) api(fn func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+Token {
+			writeError(w, http.StatusUnauthorized, "Invalid access token")
+			return
+		}
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.requests++
+		fn(w, r)
+	}
+}
+
+// writeJSON writes v as the response body with the status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response shaped like the Web API's.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"status": status, "message": msg},
+	})
+}
+
+func (o *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, _, ok := r.BasicAuth(); !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": Token,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (o *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, spotify.SpotifyUser{DisplayName: o.user.Name, IntegrationID: o.user.IntegrationID})
+}
+
+// handleUserPlaylists lists the playlists of the library, or creates a
+// playlist on POST /users/{id}/playlists.
+func (o *Server) handleUserPlaylists(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		o.createPlaylist(w, r)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/playlists") || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "Service not found")
+		return
+	}
+
+	offset, limit := paging(r, 50)
+	page := struct {
+		Items []spotify.SpotifyPlaylist `json:"items"`
+		Next  string                    `json:"next"`
+		Total int                       `json:"total"`
+	}{Items: []spotify.SpotifyPlaylist{}, Total: len(o.order)}
+	for _, id := range o.order[min(offset, len(o.order)):min(offset+limit, len(o.order))] {
+		page.Items = append(page.Items, apiPlaylist(*o.playlists[id]))
+	}
+	if offset+limit < len(o.order) {
+		page.Next = nextURL(r, r.URL.Path, offset+limit, limit)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (o *Server) createPlaylist(w http.ResponseWriter, r *http.Request) {
+	userID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/users/"), "/playlists")
+	if userID != o.user.IntegrationID {
+		writeError(w, http.StatusForbidden, "You cannot create a playlist for another user")
+		return
+	}
+	var payload struct {
+		Name   string `json:"name"`
+		Public *bool  `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Name == "" {
+		writeError(w, http.StatusBadRequest, "Missing required field: name")
+		return
+	}
+
+	o.created++
+	owner := o.user
+	mp := &model.MusicPlaylist{
+		Name:          payload.Name,
+		Owner:         &owner,
+		Public:        payload.Public,
+		IntegrationID: fmt.Sprintf("mockplaylist%010d", o.created),
+		SnapshotID:    strconv.Itoa(o.created),
+	}
+	o.playlists[mp.IntegrationID] = mp
+	o.order = append(o.order, mp.IntegrationID)
+	writeJSON(w, http.StatusCreated, apiPlaylist(*mp))
+}
+
+// handlePlaylist serves /playlists/{id} and /playlists/{id}/tracks.
+func (o *Server) handlePlaylist(w http.ResponseWriter, r *http.Request) {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/playlists/"), "/")
+	mp, ok := o.playlists[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		playlist := apiPlaylist(*mp)
+		playlist.TracksCollection = o.tracksPage(r, mp, 0, spotify.MaxPageSize)
+		writeJSON(w, http.StatusOK, playlist)
+	case rest == "" && r.Method == http.MethodPut:
+		var payload struct {
+			Public        *bool `json:"public"`
+			Collaborative *bool `json:"collaborative"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, http.StatusBadRequest, "Error parsing JSON")
+			return
+		}
+		if payload.Public != nil {
+			mp.Public = payload.Public
+		}
+		if payload.Collaborative != nil {
+			mp.Collaborative = *payload.Collaborative
+		}
+		w.WriteHeader(http.StatusOK)
+	case rest == "tracks" && r.Method == http.MethodGet:
+		offset, limit := paging(r, spotify.MaxPageSize)
+		writeJSON(w, http.StatusOK, o.tracksPage(r, mp, offset, limit))
+	case rest == "tracks" && r.Method == http.MethodPost:
+		o.addTracks(w, r, mp)
+	default:
+		writeError(w, http.StatusNotFound, "Service not found")
+	}
+}
+
+// tracksPage returns a page of the playlist's items, linking to the next
+// one.
+func (o *Server) tracksPage(r *http.Request, mp *model.MusicPlaylist, offset int, limit int) spotify.SpotifyPlaylistTracks {
+	page := spotify.SpotifyPlaylistTracks{Items: []spotify.SpotifyPlaylistTrack{}, Total: len(mp.Tracks)}
+	for _, track := range mp.Tracks[min(offset, len(mp.Tracks)):min(offset+limit, len(mp.Tracks))] {
+		page.Items = append(page.Items, apiItem(track))
+	}
+	if offset+limit < len(mp.Tracks) {
+		page.Next = nextURL(r, "/v1/playlists/"+mp.IntegrationID+"/tracks", offset+limit, limit)
+	}
+	return page
+}
+
+func (o *Server) addTracks(w http.ResponseWriter, r *http.Request, mp *model.MusicPlaylist) {
+	if mp.Owner == nil || mp.Owner.IntegrationID != o.user.IntegrationID {
+		writeError(w, http.StatusForbidden, "You cannot add tracks to a playlist you don't own.")
+		return
+	}
+	var payload struct {
+		URIs []string `json:"uris"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "Error parsing JSON")
+		return
+	}
+	if len(payload.URIs) == 0 || len(payload.URIs) > spotify.MaxTracksPerRequest {
+		writeError(w, http.StatusBadRequest, "Invalid number of uris")
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, uri := range payload.URIs {
+		if _, err := spotify.ParseResource(uri); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid track uri: "+uri)
+			return
+		}
+		mp.Tracks = append(mp.Tracks, o.addedTrack(uri, now))
+	}
+	o.created++
+	mp.SnapshotID = strconv.Itoa(o.created)
+	writeJSON(w, http.StatusCreated, map[string]string{"snapshot_id": mp.SnapshotID})
+}
+
+func (o *Server) handleAlbums(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("ids"), ",")
+	if len(ids) > spotify.MaxAlbumsPerRequest {
+		writeError(w, http.StatusBadRequest, "Too many ids requested")
+		return
+	}
+	result := struct {
+		Albums []*spotify.SpotifyAlbum `json:"albums"`
+	}{}
+	for _, id := range ids {
+		if album, ok := o.albums[id]; ok {
+			result.Albums = append(result.Albums, &album)
+		} else {
+			result.Albums = append(result.Albums, nil)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (o *Server) handleArtists(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("ids"), ",")
+	if len(ids) > spotify.MaxArtistsPerRequest {
+		writeError(w, http.StatusBadRequest, "Too many ids requested")
+		return
+	}
+	result := struct {
+		Artists []*spotify.SpotifyArtist `json:"artists"`
+	}{}
+	for _, id := range ids {
+		if artist, ok := o.artists[id]; ok {
+			result.Artists = append(result.Artists, &artist)
+		} else {
+			result.Artists = append(result.Artists, nil)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// paging returns the offset and limit of a paged request.
+func paging(r *http.Request, maxLimit int) (int, int) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > maxLimit {
+		limit = maxLimit
+	}
+	return max(offset, 0), limit
+}
+
+// nextURL links to the page of path at offset, keeping the query of the
+// request, as an absolute URL like the API's.
+func nextURL(r *http.Request, path string, offset int, limit int) string {
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(limit))
+	next := url.URL{Scheme: "http", Host: r.Host, Path: path, RawQuery: query.Encode()}
+	return next.String()
+}
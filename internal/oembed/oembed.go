@@ -0,0 +1,87 @@
+// Package oembed fetches the oEmbed description of spotify links: the
+// title and thumbnail of a track, album, playlist, show or episode, without
+// any authentication, for quick previews and notification messages.
+package oembed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// DefaultEndpoint is spotify's oEmbed endpoint.
+const DefaultEndpoint = "https://open.spotify.com/oembed"
+
+// ErrNotFound is returned for links spotify has nothing to embed for.
+var ErrNotFound = errors.New("oembed: not found")
+
+// Embed is the oEmbed description of a link.
+type Embed struct {
+	// URL is the link described, in its canonical form.
+	URL             string `json:"url"`
+	Type            string `json:"type"`
+	Title           string `json:"title"`
+	ProviderName    string `json:"provider_name"`
+	ThumbnailURL    string `json:"thumbnail_url"`
+	ThumbnailWidth  int    `json:"thumbnail_width"`
+	ThumbnailHeight int    `json:"thumbnail_height"`
+	HTML            string `json:"html"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+}
+
+// Client fetches oEmbed descriptions.
+type Client struct {
+	// Endpoint is the oEmbed endpoint, DefaultEndpoint when empty.
+	Endpoint string
+	// HTTP makes the requests, one with a 15 second timeout when nil.
+	HTTP *http.Client
+}
+
+// Fetch returns the description of a spotify link, URI or typed ID.
+func (o *Client) Fetch(ctx context.Context, link string) (Embed, error) {
+	resource, err := spotify.ParseResource(link)
+	if err != nil {
+		return Embed{}, err
+	}
+	if resource.Type == "" {
+		return Embed{}, fmt.Errorf("%q is a bare ID, give a link or URI saying what it is", link)
+	}
+
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	client := o.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	embed := Embed{URL: resource.URL()}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?url="+url.QueryEscape(embed.URL), nil)
+	if err != nil {
+		return embed, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return embed, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest:
+		return embed, fmt.Errorf("%s: %w", embed.URL, ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return embed, fmt.Errorf("oembed %s: %s", embed.URL, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embed); err != nil {
+		return embed, fmt.Errorf("oembed %s: %w", embed.URL, err)
+	}
+	return embed, nil
+}
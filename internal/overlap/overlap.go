@@ -0,0 +1,169 @@
+// Package overlap finds near-duplicate playlists in a library: accidental
+// copies, and forks which went stale, sharing most of their tracks.
+package overlap
+
+import (
+	"sort"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Playlist is one side of a Pair.
+type Playlist struct {
+	PlaylistID string
+	Name       string
+	OwnerID    string `json:",omitempty"`
+	Tracks     int
+}
+
+// Pair is two playlists sharing most of their tracks, with the merge
+// suggested for them: the tracks of Merge added to Keep, and Merge
+// dropped.
+type Pair struct {
+	Keep  Playlist
+	Merge Playlist
+	// Shared is the number of tracks on both.
+	Shared int
+	// Overlap is Shared over the tracks of the smaller playlist, so a
+	// copy which kept growing still overlaps fully. Similarity is
+	// Shared over the tracks on either.
+	Overlap    float64
+	Similarity float64
+	// Missing are the tracks of Merge which Keep lacks, in order.
+	Missing []spotify.MusicTrack `json:",omitempty"`
+}
+
+// Options tune what counts as a duplicate.
+type Options struct {
+	// Threshold is the least Overlap of a pair, from 0 to 1.
+	Threshold float64
+	// MinTracks leaves out playlists with fewer tracks, which overlap
+	// by chance.
+	MinTracks int
+	// UserID is the user whose library it is, whose own playlists are
+	// kept over those they follow. Otherwise the larger playlist is
+	// kept.
+	UserID string
+}
+
+// Find returns the pairs of playlists overlapping by at least
+// opts.Threshold, most overlapping first. Tracks count as the same by
+// ISRC or ID, local files by their names.
+func Find(playlists []spotify.MusicPlaylist, opts Options) []Pair {
+	keys := make([]map[string]bool, len(playlists))
+	// the playlists holding each key, for counting shared tracks without
+	// comparing every pair
+	holders := map[string][]int{}
+	for i, mp := range playlists {
+		keys[i] = map[string]bool{}
+		for _, track := range mp.Tracks {
+			key := trackKey(track)
+			if keys[i][key] {
+				continue
+			}
+			keys[i][key] = true
+			holders[key] = append(holders[key], i)
+		}
+	}
+	shared := map[[2]int]int{}
+	for _, held := range holders {
+		for a := 0; a < len(held); a++ {
+			for b := a + 1; b < len(held); b++ {
+				shared[[2]int{held[a], held[b]}]++
+			}
+		}
+	}
+
+	var pairs []Pair
+	for ids, n := range shared {
+		a, b := playlists[ids[0]], playlists[ids[1]]
+		// the same playlist read from two dumps
+		if a.IntegrationID == b.IntegrationID {
+			continue
+		}
+		sizeA, sizeB := len(keys[ids[0]]), len(keys[ids[1]])
+		if sizeA < opts.MinTracks || sizeB < opts.MinTracks || sizeA == 0 || sizeB == 0 {
+			continue
+		}
+		overlap := float64(n) / float64(min(sizeA, sizeB))
+		if overlap < opts.Threshold {
+			continue
+		}
+		if keeps(b, a, opts.UserID) {
+			a, b = b, a
+		}
+		pairs = append(pairs, Pair{
+			Keep:       side(a),
+			Merge:      side(b),
+			Shared:     n,
+			Overlap:    overlap,
+			Similarity: float64(n) / float64(sizeA+sizeB-n),
+			Missing:    missing(a, b),
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Overlap != pairs[j].Overlap {
+			return pairs[i].Overlap > pairs[j].Overlap
+		}
+		if pairs[i].Shared != pairs[j].Shared {
+			return pairs[i].Shared > pairs[j].Shared
+		}
+		return pairs[i].Keep.PlaylistID+pairs[i].Merge.PlaylistID < pairs[j].Keep.PlaylistID+pairs[j].Merge.PlaylistID
+	})
+	return pairs
+}
+
+// trackKey identifies a track by its ISRC, so the versions of a
+// recording on different albums meet, else by its ID, or by its name when
+// it is a local file.
+func trackKey(track spotify.MusicTrack) string {
+	switch {
+	case track.ISRC != "":
+		return "isrc:" + track.ISRC
+	case track.IntegrationID != "":
+		return "id:" + track.IntegrationID
+	}
+	return "local:" + track.Artists + "\x00" + track.Name
+}
+
+// keeps reports whether playlist a is kept over b: the user's own over
+// one they follow, then the larger, then the more followed.
+func keeps(a, b spotify.MusicPlaylist, userID string) bool {
+	if ownA, ownB := owner(a) == userID, owner(b) == userID; userID != "" && ownA != ownB {
+		return ownA
+	}
+	if len(a.Tracks) != len(b.Tracks) {
+		return len(a.Tracks) > len(b.Tracks)
+	}
+	if a.Followers != b.Followers {
+		return a.Followers > b.Followers
+	}
+	return a.IntegrationID < b.IntegrationID
+}
+
+func owner(mp spotify.MusicPlaylist) string {
+	if mp.Owner == nil {
+		return ""
+	}
+	return mp.Owner.IntegrationID
+}
+
+func side(mp spotify.MusicPlaylist) Playlist {
+	return Playlist{PlaylistID: mp.IntegrationID, Name: mp.Name, OwnerID: owner(mp), Tracks: len(mp.Tracks)}
+}
+
+// missing returns the tracks of merge which keep lacks.
+func missing(keep, merge spotify.MusicPlaylist) []spotify.MusicTrack {
+	has := map[string]bool{}
+	for _, track := range keep.Tracks {
+		has[trackKey(track)] = true
+	}
+	var tracks []spotify.MusicTrack
+	for _, track := range merge.Tracks {
+		if key := trackKey(track); !has[key] {
+			has[key] = true
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
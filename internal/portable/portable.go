@@ -0,0 +1,119 @@
+// Package portable converts dumps into a service neutral exchange format.
+// Tracks carry their ISRC and albums their UPC, so playlists can be
+// imported into other streaming services, such as Deezer or Tidal, or
+// matched against local libraries without Spotify IDs.
+package portable
+
+import (
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// FormatName and Version identify documents in this format.
+const (
+	FormatName = "spdump-portable"
+	Version    = 1
+)
+
+// Document is a portable export.
+type Document struct {
+	Format    string     `json:"format"`
+	Version   int        `json:"version"`
+	Exported  time.Time  `json:"exported"`
+	Playlists []Playlist `json:"playlists"`
+}
+
+// Source is where an item came from on the originating service.
+type Source struct {
+	Service string `json:"service"`
+	ID      string `json:"id,omitempty"`
+	URI     string `json:"uri,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// Playlist is a portable playlist.
+type Playlist struct {
+	Name   string  `json:"name"`
+	Source Source  `json:"source"`
+	Tracks []Track `json:"tracks"`
+}
+
+// Track is a portable track or podcast episode.
+type Track struct {
+	Title      string     `json:"title"`
+	Artists    []string   `json:"artists,omitempty"`
+	Album      *Album     `json:"album,omitempty"`
+	Show       string     `json:"show,omitempty"`
+	ISRC       string     `json:"isrc,omitempty"`
+	DurationMS int        `json:"duration_ms,omitempty"`
+	AddedAt    *time.Time `json:"added_at,omitempty"`
+	Source     Source     `json:"source"`
+}
+
+// Album is the album of a portable track.
+type Album struct {
+	Title       string `json:"title"`
+	UPC         string `json:"upc,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+}
+
+// Convert returns the portable document for the playlists. upcs maps
+// album IDs to their UPC, as tracks in playlists don't carry it.
+func Convert(playlists []spotify.MusicPlaylist, upcs map[string]string, exported time.Time) Document {
+	doc := Document{
+		Format:    FormatName,
+		Version:   Version,
+		Exported:  exported.UTC(),
+		Playlists: make([]Playlist, 0, len(playlists)),
+	}
+	for _, mp := range playlists {
+		playlist := Playlist{
+			Name: mp.Name,
+			Source: Source{
+				Service: spotify.SourceSpotify,
+				ID:      mp.IntegrationID,
+				URI:     spotify.Resource{Type: spotify.TypePlaylist, ID: mp.IntegrationID}.URI(),
+			},
+			Tracks: make([]Track, 0, len(mp.Tracks)),
+		}
+		for _, mt := range mp.Tracks {
+			playlist.Tracks = append(playlist.Tracks, convertTrack(mt, upcs))
+		}
+		doc.Playlists = append(doc.Playlists, playlist)
+	}
+	return doc
+}
+
+// convertTrack returns the portable form of a track.
+func convertTrack(mt spotify.MusicTrack, upcs map[string]string) Track {
+	track := Track{
+		Title:      mt.Name,
+		Show:       mt.ShowName,
+		ISRC:       mt.ISRC,
+		DurationMS: mt.DurationMS,
+		AddedAt:    mt.AddedAt,
+		Source:     Source{Service: mt.Source, URL: mt.ExternalURL},
+	}
+	if mt.IntegrationID != "" {
+		track.Source.ID = mt.IntegrationID
+		track.Source.URI = mt.URI()
+	}
+
+	if len(mt.ArtistList) > 0 {
+		for _, artist := range mt.ArtistList {
+			track.Artists = append(track.Artists, artist.Name)
+		}
+	} else if mt.Artists != "" && mt.Type != spotify.TypeEpisode {
+		track.Artists = []string{mt.Artists}
+	}
+
+	if mt.AlbumName != "" {
+		track.Album = &Album{
+			Title:       mt.AlbumName,
+			UPC:         upcs[mt.AlbumID],
+			ReleaseDate: mt.AlbumReleaseDate,
+		}
+	}
+	return track
+}
@@ -0,0 +1,275 @@
+// Package purchase finds where to buy the albums of dumped tracks, so
+// that reports double as a shopping list: searches for the album on
+// Bandcamp and Qobuz, and on request its match in the iTunes Store with
+// its price in a market, from Apple's search API. An album the iTunes
+// Store doesn't sell in the market gets no iTunes link.
+package purchase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Stores linked to.
+const (
+	StoreITunes   = "itunes"
+	StoreBandcamp = "bandcamp"
+	StoreQobuz    = "qobuz"
+)
+
+// DefaultEndpoint is Apple's iTunes search API.
+const DefaultEndpoint = "https://itunes.apple.com/search"
+
+// lookupInterval spaces requests to the search API, which allows about 20
+// a minute.
+const lookupInterval = 3 * time.Second
+
+// maxFailures is how many lookups in a row may fail before the Finder
+// stops asking for the rest of the dump and only links searches.
+const maxFailures = 5
+
+// Finder finds the stores selling albums. Each album is looked up once.
+// A Finder is safe for concurrent use.
+type Finder struct {
+	// Country is the market availability and prices are for, a two
+	// letter code, US when empty.
+	Country string
+	// Lookup asks the iTunes Store for the album rather than only linking
+	// a search.
+	Lookup bool
+	// Endpoint is the iTunes search API, DefaultEndpoint when empty.
+	Endpoint string
+	// HTTP makes the requests, one with a 15 second timeout when nil.
+	HTTP *http.Client
+
+	mu       sync.Mutex
+	found    map[string][]spotify.PurchaseLink
+	next     time.Time
+	failures int
+}
+
+// Fill sets the purchase links of the tracks, best effort: an album which
+// can't be looked up only gets search links. Episodes and local files are
+// left as they are.
+func (o *Finder) Fill(ctx context.Context, tracks []spotify.MusicTrack) {
+	if o == nil {
+		return
+	}
+	for i := range tracks {
+		track := &tracks[i]
+		if track.AlbumName == "" || track.Type == spotify.TypeEpisode || track.Source == spotify.SourceLocal {
+			continue
+		}
+		track.Purchase = o.Album(ctx, track.AlbumID, albumArtist(*track), track.AlbumName)
+	}
+}
+
+// albumArtist returns the artist an album is filed under, the track's
+// first.
+func albumArtist(track spotify.MusicTrack) string {
+	if len(track.ArtistList) > 0 {
+		return track.ArtistList[0].Name
+	}
+	artist, _, _ := strings.Cut(track.Artists, ", ")
+	return artist
+}
+
+// Album returns the stores selling the album, identified by albumID or
+// when empty its artist and name.
+func (o *Finder) Album(ctx context.Context, albumID string, artist string, album string) []spotify.PurchaseLink {
+	key := albumID
+	if key == "" {
+		key = artist + "\x00" + album
+	}
+	o.mu.Lock()
+	links, ok := o.found[key]
+	o.mu.Unlock()
+	if ok {
+		return links
+	}
+
+	query := strings.TrimSpace(artist + " " + album)
+	if o.Lookup {
+		link, err := o.lookup(ctx, artist, album)
+		switch {
+		case err != nil:
+			links = append(links, o.searchITunes(query))
+		case link != nil:
+			links = append(links, *link)
+		}
+	} else {
+		links = append(links, o.searchITunes(query))
+	}
+	links = append(links,
+		spotify.PurchaseLink{Store: StoreBandcamp, URL: "https://bandcamp.com/search?item_type=a&q=" + url.QueryEscape(query)},
+		spotify.PurchaseLink{Store: StoreQobuz, URL: "https://www.qobuz.com/search?q=" + url.QueryEscape(query)},
+	)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.found == nil {
+		o.found = map[string][]spotify.PurchaseLink{}
+	}
+	o.found[key] = links
+	return links
+}
+
+// country returns the market looked up, lower case as the stores' URLs
+// have it.
+func (o *Finder) country() string {
+	if o.Country == "" || o.Country == "from_token" {
+		return "us"
+	}
+	return strings.ToLower(o.Country)
+}
+
+// searchITunes returns a search for the album in Apple's music store.
+func (o *Finder) searchITunes(query string) spotify.PurchaseLink {
+	return spotify.PurchaseLink{Store: StoreITunes, URL: "https://music.apple.com/" + o.country() + "/search?term=" + url.QueryEscape(query)}
+}
+
+// errGaveUp is returned once lookups failed too often.
+var errGaveUp = errors.New("purchase: gave up on iTunes lookups")
+
+// searchResults is the iTunes search API's answer.
+type searchResults struct {
+	Results []struct {
+		ArtistName        string  `json:"artistName"`
+		CollectionName    string  `json:"collectionName"`
+		CollectionViewURL string  `json:"collectionViewUrl"`
+		CollectionPrice   float64 `json:"collectionPrice"`
+		Currency          string  `json:"currency"`
+	} `json:"results"`
+}
+
+// lookup asks the iTunes Store for the album. It returns nil when the
+// store doesn't sell it in the market.
+func (o *Finder) lookup(ctx context.Context, artist string, album string) (*spotify.PurchaseLink, error) {
+	if err := o.wait(ctx); err != nil {
+		return nil, err
+	}
+	link, err := o.search(ctx, artist, album)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			o.failures++
+			slog.Debug("itunes lookup failed", "artist", artist, "album", album, "err", err)
+			if o.failures == maxFailures {
+				slog.Warn("giving up on itunes lookups after repeated failures, linking searches instead", "err", err)
+			}
+		}
+		return nil, err
+	}
+	o.failures = 0
+	return link, nil
+}
+
+// wait holds a lookup until the search API allows another.
+func (o *Finder) wait(ctx context.Context) error {
+	o.mu.Lock()
+	if o.failures >= maxFailures {
+		o.mu.Unlock()
+		return errGaveUp
+	}
+	now := time.Now()
+	at := o.next
+	if at.Before(now) {
+		at = now
+	}
+	o.next = at.Add(lookupInterval)
+	o.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// search queries the search API and picks the album by the artist out of
+// the results.
+func (o *Finder) search(ctx context.Context, artist string, album string) (*spotify.PurchaseLink, error) {
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	client := o.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	query := url.Values{
+		"term":    {artist + " " + album},
+		"entity":  {"album"},
+		"country": {o.country()},
+		"limit":   {"10"},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("itunes search: %s", resp.Status)
+	}
+	var results searchResults
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("itunes search: %w", err)
+	}
+
+	for _, result := range results.Results {
+		if !sameName(result.ArtistName, artist) || !sameTitle(result.CollectionName, album) {
+			continue
+		}
+		link := &spotify.PurchaseLink{Store: StoreITunes, URL: result.CollectionViewURL}
+		// albums only sold as tracks have no price of their own
+		if result.CollectionPrice > 0 {
+			link.Price = fmt.Sprintf("%.2f", result.CollectionPrice)
+			link.Currency = result.Currency
+		}
+		return link, nil
+	}
+	return nil, nil
+}
+
+// sameName reports whether two names are the same, ignoring case,
+// punctuation and spacing.
+func sameName(a string, b string) bool {
+	return fold(a) == fold(b)
+}
+
+// sameTitle reports whether two album titles are the same, either also
+// matching the other with a suffix such as " - Single" or " (Deluxe
+// Edition)" the stores add.
+func sameTitle(a string, b string) bool {
+	a, b = fold(a), fold(b)
+	return a != "" && b != "" && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a))
+}
+
+// fold keeps the letters and digits of s, lower cased.
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+// Package queue keeps tracks to be added to a playlist later in a local
+// file, so that scripts can collect them one at a time, offline, and add
+// them all in one batched write once the user is ready.
+//
+// The queue is a JSON file, one item per track, in the order they were
+// queued. It isn't locked: two commands queuing at the same moment may
+// lose one of their tracks.
+package queue
+
+import (
+	"time"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Item is a queued track or episode.
+type Item struct {
+	ID string
+	// Type is spotify.TypeEpisode for episodes, empty for tracks.
+	Type  string `json:",omitempty"`
+	Added time.Time
+}
+
+// URI returns the Spotify URI of the item.
+func (o Item) URI() string {
+	track := o.Track()
+	return track.URI()
+}
+
+// Track returns the item as a track to write back.
+func (o Item) Track() spotify.MusicTrack {
+	return spotify.MusicTrack{IntegrationID: o.ID, Type: o.Type}
+}
+
+// Queue is the queue of tracks to add.
+type Queue struct {
+	Path  string
+	items []Item
+	dirty bool
+}
+
+// Open reads the queue in the file at path, empty when there is no file
+// yet.
+func Open(path string) (*Queue, error) {
+	q := &Queue{Path: path}
+	if err := atomicfile.ReadJSON(path, &q.items); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Add queues the tracks at the end, leaving out those queued already, and
+// returns the number queued.
+func (o *Queue) Add(tracks ...spotify.MusicTrack) int {
+	queued := map[string]bool{}
+	for _, item := range o.items {
+		queued[item.URI()] = true
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	n := 0
+	for _, track := range tracks {
+		item := Item{ID: track.IntegrationID, Added: now}
+		if track.Type == spotify.TypeEpisode {
+			item.Type = spotify.TypeEpisode
+		}
+		if queued[item.URI()] {
+			continue
+		}
+		queued[item.URI()] = true
+		o.items = append(o.items, item)
+		n++
+	}
+	if n > 0 {
+		o.dirty = true
+	}
+	return n
+}
+
+// Items returns the queued tracks in the order they were queued.
+func (o *Queue) Items() []Item {
+	return append([]Item(nil), o.items...)
+}
+
+// Tracks returns the queued tracks, in order, to write back.
+func (o *Queue) Tracks() []spotify.MusicTrack {
+	tracks := make([]spotify.MusicTrack, len(o.items))
+	for i, item := range o.items {
+		tracks[i] = item.Track()
+	}
+	return tracks
+}
+
+// Clear empties the queue.
+func (o *Queue) Clear() {
+	if len(o.items) > 0 {
+		o.items = nil
+		o.dirty = true
+	}
+}
+
+// Save writes the queue back to its file if it changed, removing the file
+// once the queue is empty.
+func (o *Queue) Save() error {
+	if !o.dirty {
+		return nil
+	}
+	o.dirty = false
+	if len(o.items) == 0 {
+		return atomicfile.Remove(o.Path)
+	}
+	return atomicfile.WriteJSON(o.Path, o.items)
+}
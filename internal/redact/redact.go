@@ -0,0 +1,236 @@
+// Package redact applies redaction rules to dumps before they are written,
+// for archives kept under a data handling policy: a field named by a rule
+// is dropped, or replaced by a keyed hash of it so that dumps can still be
+// compared and joined on it without holding the value itself.
+//
+// Rules name fields as "owner.id" or "added_by", see Fields. Hashes are
+// HMAC-SHA256 of the value under the key, cut to 32 hex digits. Without a
+// key they are plain SHA-256, which anyone guessing a value, such as a
+// user ID, can recompute.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Actions a rule takes on its field.
+const (
+	Drop = "drop"
+	Hash = "hash"
+)
+
+// Rule redacts a field.
+type Rule struct {
+	Field  string
+	Action string
+}
+
+// ParseRule parses a rule written field or field:action, the action
+// defaulting to Drop.
+func ParseRule(spec string) (Rule, error) {
+	name, action, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		action = Drop
+	}
+	rule := Rule{Field: canonical(name), Action: strings.ToLower(strings.TrimSpace(action))}
+	return rule, rule.check()
+}
+
+func (o Rule) check() error {
+	f, ok := fields[canonical(o.Field)]
+	if !ok {
+		return fmt.Errorf("can't redact unknown field %q, redactable fields are %s", o.Field, strings.Join(Fields(), ", "))
+	}
+	switch o.Action {
+	case Drop:
+	case Hash:
+		if !f.hashable {
+			return fmt.Errorf("can't hash %s, it isn't text, only drop it", o.Field)
+		}
+	default:
+		return fmt.Errorf("redaction of %s must be %s or %s, not %q", o.Field, Drop, Hash, o.Action)
+	}
+	return nil
+}
+
+// field is a field rules can name. Playlist fields are set for playlist
+// details, track fields for tracks and episodes. Text fields are passed
+// the redaction of a value, the rest are always dropped.
+type field struct {
+	hashable bool
+	playlist func(mp *spotify.MusicPlaylist, redact func(string) string)
+	track    func(mt *spotify.MusicTrack, redact func(string) string)
+}
+
+var fields = map[string]field{
+	"owner.id": {hashable: true, playlist: func(mp *spotify.MusicPlaylist, redact func(string) string) {
+		if mp.Owner != nil {
+			// the owner may be shared with other copies of the playlist
+			owner := *mp.Owner
+			owner.IntegrationID = redact(owner.IntegrationID)
+			mp.Owner = &owner
+		}
+	}},
+	"owner.name": {hashable: true, playlist: func(mp *spotify.MusicPlaylist, redact func(string) string) {
+		if mp.Owner != nil {
+			owner := *mp.Owner
+			owner.Name = redact(owner.Name)
+			mp.Owner = &owner
+		}
+	}},
+	"description": {hashable: true, playlist: func(mp *spotify.MusicPlaylist, redact func(string) string) {
+		mp.Description = redact(mp.Description)
+	}},
+	"followers": {playlist: func(mp *spotify.MusicPlaylist, _ func(string) string) {
+		mp.Followers = 0
+	}},
+	"playlist_art": {playlist: func(mp *spotify.MusicPlaylist, _ func(string) string) {
+		mp.PlaylistArt = nil
+	}},
+	"added_by": {hashable: true, track: func(mt *spotify.MusicTrack, redact func(string) string) {
+		mt.AddedBy = redact(mt.AddedBy)
+	}},
+	"added_at": {track: func(mt *spotify.MusicTrack, _ func(string) string) {
+		mt.AddedAt = nil
+	}},
+	"played_at": {track: func(mt *spotify.MusicTrack, _ func(string) string) {
+		mt.PlayedAt = nil
+	}},
+	"external_url": {hashable: true, track: func(mt *spotify.MusicTrack, redact func(string) string) {
+		mt.ExternalURL = redact(mt.ExternalURL)
+		if mt.ArtistList != nil {
+			// the artists may be shared with other copies of the track
+			artists := make([]spotify.MusicArtist, len(mt.ArtistList))
+			for i, artist := range mt.ArtistList {
+				artist.ExternalURL = redact(artist.ExternalURL)
+				artists[i] = artist
+			}
+			mt.ArtistList = artists
+		}
+	}},
+	"preview_url": {hashable: true, track: func(mt *spotify.MusicTrack, redact func(string) string) {
+		mt.PreviewURL = redact(mt.PreviewURL)
+		if mt.PreviewURL == "" {
+			mt.PreviewSource = ""
+		}
+	}},
+	"album_art": {track: func(mt *spotify.MusicTrack, _ func(string) string) {
+		mt.AlbumArt = nil
+	}},
+	"purchase": {track: func(mt *spotify.MusicTrack, _ func(string) string) {
+		mt.Purchase = nil
+	}},
+}
+
+// aliases are other names of fields, as they are written in the API.
+var aliases = map[string]string{
+	"added_by.id":   "added_by",
+	"owner":         "owner.id",
+	"external_urls": "external_url",
+}
+
+// canonical returns the name of the field a rule names.
+func canonical(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// Fields returns the names of the fields rules can name.
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rules are the redaction rules applied to a dump. Their methods are safe
+// to call on nil Rules, redacting nothing.
+type Rules struct {
+	rules []Rule
+	key   []byte
+}
+
+// New returns the rules, hashing under key. A later rule for a field
+// replaces an earlier one.
+func New(rules []Rule, key []byte) (*Rules, error) {
+	o := &Rules{key: key}
+	index := map[string]int{}
+	for _, rule := range rules {
+		if err := rule.check(); err != nil {
+			return nil, err
+		}
+		rule.Field = canonical(rule.Field)
+		if i, ok := index[rule.Field]; ok {
+			o.rules[i] = rule
+			continue
+		}
+		index[rule.Field] = len(o.rules)
+		o.rules = append(o.rules, rule)
+	}
+	return o, nil
+}
+
+// Playlist redacts the playlist's details. Its tracks are left to Track,
+// for dumps written a track at a time.
+func (o *Rules) Playlist(mp *spotify.MusicPlaylist) {
+	if o == nil {
+		return
+	}
+	for _, rule := range o.rules {
+		if f := fields[rule.Field]; f.playlist != nil {
+			f.playlist(mp, o.redaction(rule))
+		}
+	}
+}
+
+// Track redacts a track or episode.
+func (o *Rules) Track(mt *spotify.MusicTrack) {
+	if o == nil {
+		return
+	}
+	for _, rule := range o.rules {
+		if f := fields[rule.Field]; f.track != nil {
+			f.track(mt, o.redaction(rule))
+		}
+	}
+}
+
+// redaction returns what the rule replaces a value with. Empty values
+// stay empty, so that hashes don't make up values which weren't there.
+func (o *Rules) redaction(rule Rule) func(string) string {
+	if rule.Action == Drop {
+		return func(string) string { return "" }
+	}
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return o.hash(value)
+	}
+}
+
+// hash returns the keyed hash of the value.
+func (o *Rules) hash(value string) string {
+	var sum []byte
+	if len(o.key) > 0 {
+		mac := hmac.New(sha256.New, o.key)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		s := sha256.Sum256([]byte(value))
+		sum = s[:]
+	}
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,39 @@
+package redact_test
+
+import (
+	"testing"
+
+	"github.com/pyrat/spd/internal/redact"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+func TestSharedValues(t *testing.T) {
+	rules, err := redact.New([]redact.Rule{
+		{Field: "owner.id", Action: redact.Hash},
+		{Field: "external_urls", Action: redact.Drop},
+	}, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &spotify.MusicUser{Name: "Owner", IntegrationID: "owner"}
+	artists := []spotify.MusicArtist{{Name: "Artist", ExternalURL: "https://open.spotify.com/artist/a"}}
+	mp := spotify.MusicPlaylist{Owner: owner, Tracks: []spotify.MusicTrack{
+		{ExternalURL: "https://open.spotify.com/track/t", ArtistList: artists},
+	}}
+	rules.Playlist(&mp)
+	for i := range mp.Tracks {
+		rules.Track(&mp.Tracks[i])
+	}
+
+	if mp.Owner.IntegrationID == "owner" || len(mp.Owner.IntegrationID) != 32 {
+		t.Errorf("owner ID redacted to %q, want a hash", mp.Owner.IntegrationID)
+	}
+	if track := mp.Tracks[0]; track.ExternalURL != "" || track.ArtistList[0].ExternalURL != "" {
+		t.Errorf("external URLs %q and %q left", track.ExternalURL, track.ArtistList[0].ExternalURL)
+	}
+	// what other copies of the playlist share is left as it was
+	if owner.IntegrationID != "owner" || artists[0].ExternalURL == "" {
+		t.Errorf("redacted the shared owner %+v or artists %+v", owner, artists)
+	}
+}
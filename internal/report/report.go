@@ -0,0 +1,184 @@
+// Package report renders playlist dumps as human readable markdown or
+// html documents using Go templates, which users can replace.
+package report
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pyrat/spd/internal/locale"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Report formats.
+const (
+	Markdown = "markdown"
+	HTML     = "html"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Options controls how a report is rendered.
+type Options struct {
+	// Template is the path of a template replacing the built in one.
+	Template string
+	Locale   *locale.Locale
+	// Location is the time zone dates are displayed in.
+	Location *time.Location
+}
+
+// Data is what templates are executed with.
+type Data struct {
+	Playlists   []spotify.MusicPlaylist
+	GeneratedAt time.Time
+}
+
+// executor is satisfied by both text and html templates.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Render writes the playlists to w as a markdown or html document.
+func Render(w io.Writer, format string, playlists []spotify.MusicPlaylist, opts Options) error {
+	if opts.Locale == nil {
+		opts.Locale = locale.Default
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	tmpl, err := parse(format, opts)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, Data{
+		Playlists:   playlists,
+		GeneratedAt: time.Now().In(opts.Location),
+	})
+}
+
+// parse loads the template for format, from opts.Template when set.
+func parse(format string, opts Options) (executor, error) {
+	var name, src string
+	switch format {
+	case Markdown:
+		name = "markdown.tmpl"
+	case HTML:
+		name = "html.tmpl"
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+
+	if opts.Template != "" {
+		data, err := os.ReadFile(opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		name, src = filepath.Base(opts.Template), string(data)
+	} else {
+		data, err := templates.ReadFile("templates/" + name)
+		if err != nil {
+			return nil, err
+		}
+		src = string(data)
+	}
+
+	funcs := FuncMap(opts)
+	if format == HTML {
+		return htmltemplate.New(name).Funcs(htmltemplate.FuncMap(funcs)).Parse(src)
+	}
+	return texttemplate.New(name).Funcs(funcs).Parse(src)
+}
+
+// FuncMap returns the helpers available to report templates, for other
+// packages rendering dumps with templates of their own.
+// opts.Locale and opts.Location must be set.
+func FuncMap(opts Options) texttemplate.FuncMap {
+	l := opts.Locale
+	return texttemplate.FuncMap{
+		"t":      l.T,
+		"number": l.Number,
+		"duration": func(ms int) string {
+			if ms == 0 {
+				return ""
+			}
+			return l.Duration(time.Duration(ms) * time.Millisecond)
+		},
+		"totalDuration": func(mp spotify.MusicPlaylist) string {
+			total := 0
+			for _, track := range mp.Tracks {
+				total += track.DurationMS
+			}
+			return l.LongDuration(time.Duration(total) * time.Millisecond)
+		},
+		"date": func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return l.Date(t.In(opts.Location))
+		},
+		"cover": func(mp spotify.MusicPlaylist) string {
+			if len(mp.PlaylistArt) == 0 {
+				return ""
+			}
+			return mp.PlaylistArt[0].URL
+		},
+		"md":           escapeMarkdown,
+		"inc":          func(i int) int { return i + 1 },
+		"shoppingList": ShoppingList,
+	}
+}
+
+// ShoppingItem is an album to buy, with the stores selling it.
+type ShoppingItem struct {
+	Album  string
+	Artist string
+	Links  []spotify.PurchaseLink
+}
+
+// ShoppingList returns the albums of the playlists' tracks which have
+// purchase links, each once, in the order they first appear.
+func ShoppingList(playlists []spotify.MusicPlaylist) []ShoppingItem {
+	var items []ShoppingItem
+	seen := map[string]bool{}
+	for _, mp := range playlists {
+		for _, track := range mp.Tracks {
+			if len(track.Purchase) == 0 {
+				continue
+			}
+			key := track.AlbumID
+			if key == "" {
+				key = track.Artists + "\x00" + track.AlbumName
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			artist := track.Artists
+			if len(track.ArtistList) > 0 {
+				artist = track.ArtistList[0].Name
+			}
+			items = append(items, ShoppingItem{Album: track.AlbumName, Artist: artist, Links: track.Purchase})
+		}
+	}
+	return items
+}
+
+// markdownEscaper escapes the characters which would break a markdown table
+// cell or turn text into formatting.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "\n", " ",
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,168 @@
+// Package retries keeps the entities enrichment passes couldn't look up,
+// such as the artists of --genres or the albums of analyze --labels, in a
+// queue persisted between runs. Later passes fetch the ones queued along
+// with their own, so a few bad IDs are picked up again without rerunning
+// the whole pass, and entities found are taken off the queue.
+//
+// The queue is a JSON file, one item per entity. Its methods are safe to
+// call on a nil Queue, doing nothing, so that the queue can be left off.
+package retries
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+)
+
+// MaxAttempts is how many failed passes an entity is retried in. It stays
+// queued after, for `spdump retries` to list, but passes leave it be.
+const MaxAttempts = 5
+
+// AudioFeatures is the kind of the tracks whose audio features are
+// queued. Albums and artists are queued under the spotify package's
+// TypeAlbum and TypeArtist.
+const AudioFeatures = "audio-features"
+
+// Item is a queued entity.
+type Item struct {
+	Kind     string
+	ID       string
+	Attempts int
+	// Error is that of the last failed attempt.
+	Error       string
+	FirstFailed time.Time
+	LastFailed  time.Time
+}
+
+// Exhausted reports whether the item failed too often to be retried by
+// passes.
+func (o Item) Exhausted() bool {
+	return o.Attempts >= MaxAttempts
+}
+
+// Queue is the queue of entities to retry.
+type Queue struct {
+	Path string
+
+	mu    sync.Mutex
+	items map[string]*Item
+	dirty bool
+}
+
+// Open reads the queue in the file at path, empty when there is no file
+// yet.
+func Open(path string) (*Queue, error) {
+	q := &Queue{Path: path, items: map[string]*Item{}}
+	var items []Item
+	if err := atomicfile.ReadJSON(path, &items); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		q.items[key(items[i].Kind, items[i].ID)] = &items[i]
+	}
+	return q, nil
+}
+
+func key(kind string, id string) string {
+	return kind + ":" + id
+}
+
+// Failed queues an entity which couldn't be fetched, or counts another
+// failed attempt at one queued already.
+func (o *Queue) Failed(kind string, id string, err error) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Second)
+	item := o.items[key(kind, id)]
+	if item == nil {
+		item = &Item{Kind: kind, ID: id, FirstFailed: now}
+		o.items[key(kind, id)] = item
+	}
+	item.Attempts++
+	item.Error = err.Error()
+	item.LastFailed = now
+	o.dirty = true
+}
+
+// Done takes an entity fetched off the queue, if it was queued.
+func (o *Queue) Done(kind string, id string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.items[key(kind, id)]; ok {
+		delete(o.items, key(kind, id))
+		o.dirty = true
+	}
+}
+
+// Pending returns the IDs of the entities of the kind passes retry, those
+// not exhausted.
+func (o *Queue) Pending(kind string) []string {
+	var ids []string
+	for _, item := range o.Items() {
+		if item.Kind == kind && !item.Exhausted() {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
+
+// Items returns the queued entities by kind and ID.
+func (o *Queue) Items() []Item {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	items := make([]Item, 0, len(o.items))
+	for _, item := range o.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// Clear empties the queue.
+func (o *Queue) Clear() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.items) > 0 {
+		o.items = map[string]*Item{}
+		o.dirty = true
+	}
+}
+
+// Save writes the queue back to its file if it changed, removing the file
+// once the queue is empty.
+func (o *Queue) Save() error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	dirty := o.dirty
+	o.dirty = false
+	o.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	items := o.Items()
+	if len(items) == 0 {
+		return atomicfile.Remove(o.Path)
+	}
+	return atomicfile.WriteJSON(o.Path, items)
+}
@@ -0,0 +1,91 @@
+// Package sampler builds sampler playlists from an artist's discography:
+// a few tracks of every release, oldest first, to walk through how the
+// artist's sound changed.
+package sampler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pyrat/spd/internal/library"
+	"github.com/pyrat/spd/pkg/model"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Options shape a sampler.
+type Options struct {
+	// PerAlbum is the number of tracks taken from each release.
+	PerAlbum int
+	// Name is the name of the playlist, "<artist> sampler" when empty.
+	Name string
+}
+
+// Build returns a playlist of opts.PerAlbum tracks from each of the
+// albums, which must include their tracks, in order of release as
+// model.SortAlbumsChronological orders them. The artist's top tracks are
+// picked first, then the album's opening tracks.
+// Tracks the artist doesn't play on, as on compilations, and songs
+// already taken from an earlier release are skipped, so a single and its
+// album don't both contribute the same song.
+func Build(artist spotify.MusicArtist, albums []spotify.MusicAlbum, top []spotify.MusicTrack, opts Options) spotify.MusicPlaylist {
+	mp := spotify.MusicPlaylist{
+		Name:        opts.Name,
+		Description: fmt.Sprintf("%d tracks from each release of %s, oldest first.", opts.PerAlbum, artist.Name),
+	}
+	if mp.Name == "" {
+		mp.Name = artist.Name + " sampler"
+	}
+
+	popular := map[string]bool{}
+	for _, track := range top {
+		popular[library.NormalizeTitle(track.Name)] = true
+	}
+
+	ordered := make([]spotify.MusicAlbum, len(albums))
+	for i, album := range albums {
+		album.Tracks = append([]spotify.MusicTrack(nil), album.Tracks...)
+		ordered[i] = album
+	}
+	model.SortAlbumsChronological(ordered)
+
+	taken := map[string]bool{}
+	for _, album := range ordered {
+		var candidates []spotify.MusicTrack
+		for _, track := range album.Tracks {
+			if !plays(artist, track) || taken[library.NormalizeTitle(track.Name)] {
+				continue
+			}
+			candidates = append(candidates, track)
+		}
+		picks := append([]spotify.MusicTrack(nil), candidates...)
+		sort.SliceStable(picks, func(i, j int) bool {
+			return popular[library.NormalizeTitle(picks[i].Name)] && !popular[library.NormalizeTitle(picks[j].Name)]
+		})
+		picked := map[string]bool{}
+		for i := 0; i < len(picks) && i < opts.PerAlbum; i++ {
+			picked[picks[i].IntegrationID] = true
+		}
+		// the picks keep their order on the album
+		for _, track := range candidates {
+			if picked[track.IntegrationID] {
+				taken[library.NormalizeTitle(track.Name)] = true
+				mp.Tracks = append(mp.Tracks, track)
+			}
+		}
+	}
+	return mp
+}
+
+// plays reports whether the artist is among the track's artists. Tracks
+// without a list of artists are taken to be the artist's.
+func plays(artist spotify.MusicArtist, track spotify.MusicTrack) bool {
+	if len(track.ArtistList) == 0 {
+		return true
+	}
+	for _, a := range track.ArtistList {
+		if a.IntegrationID == artist.IntegrationID {
+			return true
+		}
+	}
+	return false
+}
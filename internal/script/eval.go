@@ -0,0 +1,537 @@
+package script
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type node interface {
+	eval(vars Vars) (interface{}, error)
+}
+
+type literal struct{ value interface{} }
+
+func (o literal) eval(vars Vars) (interface{}, error) { return o.value, nil }
+
+type variable string
+
+func (o variable) eval(vars Vars) (interface{}, error) {
+	v, ok := vars[string(o)]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %s", string(o))
+	}
+	return v, nil
+}
+
+type listLiteral []node
+
+func (o listLiteral) eval(vars Vars) (interface{}, error) {
+	list := make([]interface{}, len(o))
+	for i, item := range o {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+type ternary [3]node
+
+func (o ternary) eval(vars Vars) (interface{}, error) {
+	cond, err := o[0].eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return o[1].eval(vars)
+	}
+	return o[2].eval(vars)
+}
+
+type unary struct {
+	op string
+	node
+}
+
+func (o unary) eval(vars Vars) (interface{}, error) {
+	v, err := o.node.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if o.op == "!" {
+		return !truthy(v), nil
+	}
+	switch v := v.(type) {
+	case int64:
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+	return nil, fmt.Errorf("can't negate %s", typeName(v))
+}
+
+type index [2]node
+
+func (o index) eval(vars Vars) (interface{}, error) {
+	v, err := o[0].eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	i, err := o[1].eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]interface{})
+	n, isInt := i.(int64)
+	if !ok || !isInt {
+		return nil, fmt.Errorf("can't index %s with %s", typeName(v), typeName(i))
+	}
+	if n < 0 {
+		n += int64(len(list))
+	}
+	// out of range is null, as tracks have lists of any length
+	if n < 0 || n >= int64(len(list)) {
+		return nil, nil
+	}
+	return list[n], nil
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (o binary) eval(vars Vars) (interface{}, error) {
+	left, err := o.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	// || and && only evaluate what they need
+	switch o.op {
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := o.right.eval(vars)
+		return truthy(right), err
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := o.right.eval(vars)
+		return truthy(right), err
+	}
+	right, err := o.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "<", "<=", ">", ">=":
+		if left == nil || right == nil {
+			return false, nil
+		}
+		cmp, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch o.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+	case "+":
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := right.([]interface{}); ok {
+				return append(append([]interface{}{}, l...), r...), nil
+			}
+		}
+	}
+	return arithmetic(o.op, left, right)
+}
+
+// arithmetic applies + - * / % to numbers, integers staying integers.
+// Null stays null.
+func arithmetic(op string, left interface{}, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	l, lInt := left.(int64)
+	r, rInt := right.(int64)
+	if lInt && rInt {
+		switch op {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/", "%":
+			if r == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				return l / r, nil
+			}
+			return l % r, nil
+		}
+	}
+	lf, lok := toFloat(left)
+	rf, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("can't %s %s and %s", op, typeName(left), typeName(right))
+	}
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		return lf / rf, nil
+	}
+	return math.Mod(lf, rf), nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func equal(left interface{}, right interface{}) bool {
+	if lf, ok := toFloat(left); ok {
+		rf, ok := toFloat(right)
+		return ok && lf == rf
+	}
+	l, lok := left.([]interface{})
+	r, rok := right.([]interface{})
+	if lok || rok {
+		if !lok || !rok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return left == right
+}
+
+func compare(left interface{}, right interface{}) (int, error) {
+	if lf, ok := toFloat(left); ok {
+		if rf, ok := toFloat(right); ok {
+			switch {
+			case lf < rf:
+				return -1, nil
+			case lf > rf:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	}
+	return 0, fmt.Errorf("can't compare %s and %s", typeName(left), typeName(right))
+}
+
+// contains reports whether a list holds item, or a string item as a
+// substring, ignoring case.
+func contains(container interface{}, item interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, v := range c {
+			if equal(v, item) {
+				return true, nil
+			}
+			if s, ok := v.(string); ok {
+				if i, ok := item.(string); ok && strings.EqualFold(s, i) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case string:
+		if i, ok := item.(string); ok {
+			return strings.Contains(strings.ToLower(c), strings.ToLower(i)), nil
+		}
+	case nil:
+		return false, nil
+	}
+	return nil, fmt.Errorf("can't look for %s in %s", typeName(item), typeName(container))
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+type call struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (o call) eval(vars Vars) (interface{}, error) {
+	args := make([]interface{}, len(o.args))
+	for i, arg := range o.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := o.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", o.name, err)
+	}
+	return v, nil
+}
+
+// function is a function expressions can call, taking args arguments, any
+// number when negative.
+type function struct {
+	args int
+	call func(args []interface{}) (interface{}, error)
+}
+
+// stringFunc adapts a function of strings, taking anything else as its
+// Format.
+func stringFunc(fn func(args []string) interface{}) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		strs := make([]string, len(args))
+		for i, arg := range args {
+			strs[i] = Format(arg)
+		}
+		return fn(strs), nil
+	}
+}
+
+// funcs are the functions expressions can call: lower, upper, trim,
+// contains, startsWith, endsWith, matches (a regular expression, ignoring
+// case), replace, split, join, len, string, int, float, round, min, max
+// and default (its first argument, or the second when that is null or
+// empty).
+var funcs = map[string]function{
+	"lower":      {1, stringFunc(func(a []string) interface{} { return strings.ToLower(a[0]) })},
+	"upper":      {1, stringFunc(func(a []string) interface{} { return strings.ToUpper(a[0]) })},
+	"trim":       {1, stringFunc(func(a []string) interface{} { return strings.TrimSpace(a[0]) })},
+	"startsWith": {2, stringFunc(func(a []string) interface{} { return strings.HasPrefix(a[0], a[1]) })},
+	"endsWith":   {2, stringFunc(func(a []string) interface{} { return strings.HasSuffix(a[0], a[1]) })},
+	"replace":    {3, stringFunc(func(a []string) interface{} { return strings.ReplaceAll(a[0], a[1], a[2]) })},
+	"split": {2, stringFunc(func(a []string) interface{} {
+		var list []interface{}
+		for _, part := range strings.Split(a[0], a[1]) {
+			list = append(list, part)
+		}
+		return list
+	})},
+	"contains": {2, func(args []interface{}) (interface{}, error) { return contains(args[0], args[1]) }},
+	"matches": {2, func(args []interface{}) (interface{}, error) {
+		re, err := compileRegexp(Format(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(Format(args[0])), nil
+	}},
+	"join": {2, func(args []interface{}) (interface{}, error) {
+		list, ok := args[0].([]interface{})
+		if !ok {
+			return Format(args[0]), nil
+		}
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = Format(item)
+		}
+		return strings.Join(parts, Format(args[1])), nil
+	}},
+	"len": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return int64(len([]rune(v))), nil
+		case []interface{}:
+			return int64(len(v)), nil
+		case nil:
+			return int64(0), nil
+		}
+		return nil, fmt.Errorf("no length of %s", typeName(args[0]))
+	}},
+	"string": {1, func(args []interface{}) (interface{}, error) { return Format(args[0]), nil }},
+	"int": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(v), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", v)
+			}
+			return int64(n), nil
+		}
+		return nil, fmt.Errorf("can't convert %s", typeName(args[0]))
+	}},
+	"float": {1, func(args []interface{}) (interface{}, error) {
+		if f, ok := toFloat(args[0]); ok {
+			return f, nil
+		}
+		if s, ok := args[0].(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", s)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("can't convert %s", typeName(args[0]))
+	}},
+	"round": {-1, func(args []interface{}) (interface{}, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("takes a number and optionally the decimals")
+		}
+		f, ok := toFloat(args[0])
+		if !ok {
+			return nil, fmt.Errorf("can't round %s", typeName(args[0]))
+		}
+		if len(args) == 1 {
+			return int64(math.Round(f)), nil
+		}
+		decimals, ok := args[1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("decimals must be an int")
+		}
+		scale := math.Pow(10, float64(decimals))
+		return math.Round(f*scale) / scale, nil
+	}},
+	"min": {-1, extreme(-1)},
+	"max": {-1, extreme(1)},
+	"default": {2, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return args[1], nil
+		case string:
+			if v == "" {
+				return args[1], nil
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				return args[1], nil
+			}
+		}
+		return args[0], nil
+	}},
+}
+
+// extreme returns min, for sign -1, or max, for 1, of its arguments or
+// of a list.
+func extreme(sign int) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) == 1 {
+			if list, ok := args[0].([]interface{}); ok {
+				args = list
+			}
+		}
+		var best interface{}
+		for _, arg := range args {
+			if best == nil {
+				best = arg
+				continue
+			}
+			cmp, err := compare(arg, best)
+			if err != nil {
+				return nil, err
+			}
+			if cmp*sign > 0 {
+				best = arg
+			}
+		}
+		return best, nil
+	}
+}
+
+// compileRegexp compiles a pattern case insensitively. A constant pattern
+// is compiled once, by Compile, into a match; one from a variable every
+// time, rather than filling a cache with whatever the data holds.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// match is matches with a constant pattern.
+type match struct {
+	subject node
+	re      *regexp.Regexp
+}
+
+func (o match) eval(vars Vars) (interface{}, error) {
+	v, err := o.subject.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return o.re.MatchString(Format(v)), nil
+}
@@ -0,0 +1,392 @@
+// Package script evaluates small expressions over tracks, written in
+// config files and flags, so filters and computed fields can go beyond
+// package filter without recompiling spdump. The language borrows CEL's
+// syntax:
+//
+//	year >= 2000 && !explicit
+//	"rock" in genres || matches(artist, "^The ")
+//	string(year / 10 * 10) + "s"
+//	duration > 600 ? "long" : "short"
+//
+// Values are strings, integers, floats, booleans, lists and null. It has
+// the operators || && ! == != < <= > >= in + - * / %, the conditional
+// c ? a : b, indexing list[i] and list literals [a, b]. An integer
+// divided by an integer is truncated; mixed with a float it becomes one.
+// Arithmetic on null, such as the year of a track without a release
+// date, is null, and null is neither less nor greater than anything.
+// The functions are listed in funcs. The variables of a track are listed
+// in TrackVars.
+//
+// An expression is compiled once and can be evaluated concurrently.
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Vars are the variables an expression is evaluated with.
+type Vars map[string]interface{}
+
+// Program is a compiled expression.
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression.
+func Compile(source string) (*Program, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.conditional()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+func (o *Program) String() string {
+	return o.source
+}
+
+// Eval evaluates the expression with vars. An unknown variable is an
+// error.
+func (o *Program) Eval(vars Vars) (interface{}, error) {
+	v, err := o.root.eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", o.source, err)
+	}
+	return v, nil
+}
+
+// Bool evaluates the expression as a condition: false, null, zero, an
+// empty string and an empty list are false, anything else true.
+func (o *Program) Bool(vars Vars) (bool, error) {
+	v, err := o.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// Format renders a value the way it is written into text output: null as
+// nothing, floats without trailing zeros, lists joined by ", ".
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = Format(item)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+// token is a lexed literal, name, operator or punctuation.
+type token struct {
+	kind string
+	text string
+}
+
+// Kinds of tokens.
+const (
+	tokenName   = "name"
+	tokenString = "string"
+	tokenNumber = "number"
+	tokenOp     = "op"
+)
+
+// operators are the operators and punctuation, longest first.
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "?", ":", "(", ")", "[", "]", ","}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	rest := source
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			return tokens, nil
+		}
+		c := rest[0]
+		switch {
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != c; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+					switch rest[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(rest[i])
+					}
+					continue
+				}
+				b.WriteByte(rest[i])
+			}
+			if i == len(rest) {
+				return nil, fmt.Errorf("unterminated string %s", rest)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String()})
+			rest = rest[i+1:]
+			continue
+		case c >= '0' && c <= '9':
+			end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+			if end < 0 {
+				end = len(rest)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: rest[:end]})
+			rest = rest[end:]
+			continue
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := strings.IndexFunc(rest, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+			if end < 0 {
+				end = len(rest)
+			}
+			tokens = append(tokens, token{kind: tokenName, text: rest[:end]})
+			rest = rest[end:]
+			continue
+		}
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				tokens = append(tokens, token{kind: tokenOp, text: op})
+				rest = rest[len(op):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q", rest[:1])
+		}
+	}
+}
+
+// parser is a recursive descent parser over the tokens, one method per
+// level of precedence, loosest first.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (o *parser) peek() token {
+	if o.pos >= len(o.tokens) {
+		return token{}
+	}
+	return o.tokens[o.pos]
+}
+
+// op reports whether the next token is one of the operators, taking it if
+// so.
+func (o *parser) op(ops ...string) (string, bool) {
+	t := o.peek()
+	if t.kind != tokenOp && !(t.kind == tokenName && t.text == "in") {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			o.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (o *parser) expect(op string) error {
+	if _, ok := o.op(op); !ok {
+		if o.pos >= len(o.tokens) {
+			return fmt.Errorf("expected %s at the end", op)
+		}
+		return fmt.Errorf("expected %s, not %q", op, o.peek().text)
+	}
+	return nil
+}
+
+func (o *parser) conditional() (node, error) {
+	cond, err := o.or()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := o.op("?"); !ok {
+		return cond, nil
+	}
+	then, err := o.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := o.conditional()
+	if err != nil {
+		return nil, err
+	}
+	return ternary{cond, then, otherwise}, nil
+}
+
+// binary parses a left associative level of operators, each operand
+// parsed by next.
+func (o *parser) binary(next func() (node, error), ops ...string) (node, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := o.op(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op, left, right}
+	}
+}
+
+func (o *parser) or() (node, error)  { return o.binary(o.and, "||") }
+func (o *parser) and() (node, error) { return o.binary(o.compare, "&&") }
+func (o *parser) compare() (node, error) {
+	return o.binary(o.sum, "==", "!=", "<=", ">=", "<", ">", "in")
+}
+func (o *parser) sum() (node, error)     { return o.binary(o.product, "+", "-") }
+func (o *parser) product() (node, error) { return o.binary(o.unary, "*", "/", "%") }
+
+func (o *parser) unary() (node, error) {
+	if op, ok := o.op("!", "-"); ok {
+		n, err := o.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op, n}, nil
+	}
+	return o.postfix()
+}
+
+func (o *parser) postfix() (node, error) {
+	n, err := o.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := o.op("["); !ok {
+			return n, nil
+		}
+		i, err := o.conditional()
+		if err != nil {
+			return nil, err
+		}
+		if err := o.expect("]"); err != nil {
+			return nil, err
+		}
+		n = index{n, i}
+	}
+}
+
+func (o *parser) primary() (node, error) {
+	if o.pos >= len(o.tokens) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	t := o.tokens[o.pos]
+	o.pos++
+	switch t.kind {
+	case tokenString:
+		return literal{t.text}, nil
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return literal{i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", t.text)
+		}
+		return literal{f}, nil
+	case tokenName:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		if _, ok := o.op("("); !ok {
+			return variable(t.text), nil
+		}
+		fn, ok := funcs[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s", t.text)
+		}
+		args, err := o.list(")")
+		if err != nil {
+			return nil, err
+		}
+		if fn.args >= 0 && len(args) != fn.args {
+			return nil, fmt.Errorf("%s takes %d arguments, not %d", t.text, fn.args, len(args))
+		}
+		if t.text == "matches" {
+			// a constant pattern is compiled, and found wrong, once
+			if lit, ok := args[1].(literal); ok {
+				re, err := compileRegexp(Format(lit.value))
+				if err != nil {
+					return nil, fmt.Errorf("matches: %w", err)
+				}
+				return match{args[0], re}, nil
+			}
+		}
+		return call{t.text, fn, args}, nil
+	case tokenOp:
+		switch t.text {
+		case "(":
+			n, err := o.conditional()
+			if err != nil {
+				return nil, err
+			}
+			return n, o.expect(")")
+		case "[":
+			items, err := o.list("]")
+			if err != nil {
+				return nil, err
+			}
+			return listLiteral(items), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// list parses expressions separated by commas up to the closing token.
+func (o *parser) list(end string) ([]node, error) {
+	var items []node
+	if _, ok := o.op(end); ok {
+		return items, nil
+	}
+	for {
+		n, err := o.conditional()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+		if _, ok := o.op(","); ok {
+			continue
+		}
+		return items, o.expect(end)
+	}
+}
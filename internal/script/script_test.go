@@ -0,0 +1,216 @@
+package script
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	for source, want := range map[string][]token{
+		`year>=2000&&!explicit`: {
+			{tokenName, "year"}, {tokenOp, ">="}, {tokenNumber, "2000"}, {tokenOp, "&&"}, {tokenOp, "!"}, {tokenName, "explicit"},
+		},
+		`"a\"b\n" + 'c\'d'`: {{tokenString, "a\"b\n"}, {tokenOp, "+"}, {tokenString, "c'd"}},
+		`x_1 in [1.5, -2]`: {
+			{tokenName, "x_1"}, {tokenName, "in"}, {tokenOp, "["}, {tokenNumber, "1.5"}, {tokenOp, ","}, {tokenOp, "-"}, {tokenNumber, "2"}, {tokenOp, "]"},
+		},
+		"  \t\n": nil,
+	} {
+		got, err := lex(source)
+		if err != nil {
+			t.Errorf("%s: %v", source, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s lexed as %v, want %v", source, got, want)
+		}
+	}
+
+	for _, source := range []string{`"open`, `'open\'`, `a & b`, `a = b`, `#`} {
+		if _, err := lex(source); err == nil {
+			t.Errorf("lexed %s without an error", source)
+		}
+	}
+}
+
+// evalCase is an expression and its value, or a part of its error.
+type evalCase struct {
+	source string
+	want   interface{}
+	err    string
+}
+
+func runCases(t *testing.T, vars Vars, cases []evalCase) {
+	t.Helper()
+	for _, c := range cases {
+		p, err := Compile(c.source)
+		var got interface{}
+		if err == nil {
+			got, err = p.Eval(vars)
+		}
+		switch {
+		case c.err != "":
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got %#v, %v, want an error with %q", c.source, got, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", c.source, err)
+		case !reflect.DeepEqual(got, c.want):
+			t.Errorf("%s = %#v, want %#v", c.source, got, c.want)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	runCases(t, Vars{"t": true, "f": false, "list": []interface{}{int64(1), int64(2), int64(3)}}, []evalCase{
+		{source: `1 + 2 * 3`, want: int64(7)},
+		{source: `(1 + 2) * 3`, want: int64(9)},
+		{source: `10 - 4 - 3`, want: int64(3)},
+		{source: `20 / 2 / 5`, want: int64(2)},
+		{source: `7 % 4 * 2`, want: int64(6)},
+		{source: `-2 * 3`, want: int64(-6)},
+		{source: `--2`, want: int64(2)},
+		{source: `!f && f`, want: false},
+		{source: `t || f && f`, want: true},
+		{source: `1 + 1 == 2 && 3 > 2`, want: true},
+		{source: `1 < 2 == true`, want: true},
+		{source: `t ? 1 : f ? 2 : 3`, want: int64(1)},
+		{source: `f ? 1 : f ? 2 : 3`, want: int64(3)},
+		{source: `f || t ? "a" : "b"`, want: "a"},
+		{source: `list[1] + list[-1]`, want: int64(5)},
+		{source: `[1, 2][0] * 10`, want: int64(10)},
+		{source: `list[3]`, want: nil},
+		{source: `7 / 2`, want: int64(3)},
+		{source: `7 / 2.0`, want: 3.5},
+		{source: `7.5 % 2`, want: 1.5},
+		{source: `"a" + "b" + "c"`, want: "abc"},
+		{source: `[1] + [2]`, want: []interface{}{int64(1), int64(2)}},
+
+		{source: `1 +`, err: "unexpected end"},
+		{source: `(1 + 2`, err: "expected ) at the end"},
+		{source: `[1, 2`, err: "expected ] at the end"},
+		{source: `t ? 1`, err: "expected : at the end"},
+		{source: `1 2`, err: `unexpected "2"`},
+		{source: `)`, err: `unexpected ")"`},
+		{source: `nope(1)`, err: "unknown function nope"},
+		{source: `lower(1, 2)`, err: "lower takes 1 arguments, not 2"},
+		{source: `1.2.3`, err: `bad number "1.2.3"`},
+		{source: `missing`, err: "unknown variable missing"},
+		{source: `1 / 0`, err: "division by zero"},
+		{source: `1 % 0`, err: "division by zero"},
+		{source: `-"a"`, err: "can't negate string"},
+		{source: `"a" - 1`, err: "can't - string and int"},
+		{source: `"a" < 1`, err: "can't compare string and int"},
+		{source: `list["a"]`, err: "can't index list with string"},
+	})
+}
+
+func TestNull(t *testing.T) {
+	runCases(t, Vars{"year": nil, "genres": nil}, []evalCase{
+		{source: `year + 1`, want: nil},
+		{source: `1 - year`, want: nil},
+		{source: `year * 2`, want: nil},
+		{source: `year / 0`, want: nil},
+		{source: `year % 10`, want: nil},
+		{source: `year / 10 * 10`, want: nil},
+		{source: `year < 2000`, want: false},
+		{source: `year >= 2000`, want: false},
+		{source: `2000 > year`, want: false},
+		{source: `year == null`, want: true},
+		{source: `year != 0`, want: true},
+		{source: `null == 0`, want: false},
+		{source: `!year`, want: true},
+		{source: `year ? 1 : 2`, want: int64(2)},
+		{source: `"rock" in genres`, want: false},
+		{source: `-year`, err: "can't negate null"},
+	})
+}
+
+func TestIn(t *testing.T) {
+	runCases(t, Vars{"genres": []interface{}{"Rock", "jazz"}, "name": "Hello World"}, []evalCase{
+		{source: `"rock" in genres`, want: true},
+		{source: `"JAZZ" in genres`, want: true},
+		{source: `"pop" in genres`, want: false},
+		{source: `2 in [1, 2.0]`, want: true},
+		{source: `[1] in [[1], [2]]`, want: true},
+		{source: `"o w" in name`, want: true},
+		{source: `"x" in name`, want: false},
+		{source: `!("pop" in genres)`, want: true},
+		{source: `1 in name`, err: "can't look for int in string"},
+		{source: `"a" in 1`, err: "can't look for string in int"},
+	})
+}
+
+func TestFuncs(t *testing.T) {
+	list := func(items ...interface{}) []interface{} { return items }
+	cases := []evalCase{
+		{source: `lower("AbC")`, want: "abc"},
+		{source: `upper("AbC")`, want: "ABC"},
+		{source: `trim("  a b ")`, want: "a b"},
+		{source: `contains("Hello", "ELL")`, want: true},
+		{source: `contains(["a", "b"], "B")`, want: true},
+		{source: `startsWith(artist, "The ")`, want: true},
+		{source: `endsWith(artist, "and")`, want: true},
+		{source: `endsWith(artist, "AND")`, want: false},
+		{source: `matches(artist, "^the ")`, want: true},
+		{source: `matches(artist, "band$")`, want: true},
+		{source: `matches(artist, "^band")`, want: false},
+		{source: `matches(artist, artist)`, want: true},
+		{source: `matches(artist, "(")`, err: "matches: "},
+		{source: `matches(artist, empty + "(")`, err: "matches: "},
+		{source: `replace("a-b-c", "-", "+")`, want: "a+b+c"},
+		{source: `split("a,b", ",")`, want: list("a", "b")},
+		{source: `join(["a", 1, 2.5], "/")`, want: "a/1/2.5"},
+		{source: `join("a", "/")`, want: "a"},
+		{source: `len("héllo")`, want: int64(5)},
+		{source: `len([1, 2])`, want: int64(2)},
+		{source: `len(null)`, want: int64(0)},
+		{source: `len(1)`, err: "len: no length of int"},
+		{source: `string(1.50)`, want: "1.5"},
+		{source: `string(null)`, want: ""},
+		{source: `string(["a", "b"])`, want: "a, b"},
+		{source: `int(" 42 ")`, want: int64(42)},
+		{source: `int(2.9)`, want: int64(2)},
+		{source: `int(true)`, want: int64(1)},
+		{source: `int("x")`, err: `int: "x" is not a number`},
+		{source: `int(null)`, err: "int: can't convert null"},
+		{source: `float(n)`, want: 3.0},
+		{source: `float("2.5")`, want: 2.5},
+		{source: `float("x")`, err: `float: "x" is not a number`},
+		{source: `round(2.5)`, want: int64(3)},
+		{source: `round(2.345, 2)`, want: 2.35},
+		{source: `round("a")`, err: "round: can't round string"},
+		{source: `round(1, 2.0)`, err: "round: decimals must be an int"},
+		{source: `round()`, err: "round: takes a number"},
+		{source: `min(3, 1, 2)`, want: int64(1)},
+		{source: `max([1, 2.5, 2])`, want: 2.5},
+		{source: `max("a", "b")`, want: "b"},
+		{source: `min()`, want: nil},
+		{source: `min(1, "a")`, err: "min: can't compare string and int"},
+		{source: `default(empty, "x")`, want: "x"},
+		{source: `default(none, "x")`, want: "x"},
+		{source: `default(null, 1)`, want: int64(1)},
+		{source: `default(0, 1)`, want: int64(0)},
+		{source: `default(artist, "x")`, want: "The Band"},
+	}
+
+	runCases(t, Vars{"artist": "The Band", "empty": "", "none": list(), "n": int64(3)}, cases)
+
+	// a constant pattern is compiled with the expression
+	if p, err := Compile(`matches(artist, "^the ")`); err != nil {
+		t.Fatal(err)
+	} else if _, ok := p.root.(match); !ok {
+		t.Errorf("constant pattern compiled into %T", p.root)
+	}
+
+	for name := range funcs {
+		tested := false
+		for _, c := range cases {
+			tested = tested || strings.HasPrefix(c.source, name+"(")
+		}
+		if !tested {
+			t.Errorf("no test of %s", name)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package script
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// TrackVars returns the variables of a track at position, from 1, in a
+// playlist:
+//
+//	name, artist (all artists as one string), artists (a list), album,
+//	show, id, isrc, type, source, unavailable, url, preview, added_by
+//	genres                   a list, with --genres
+//	language                 an ISO 639-1 code, with --language
+//	added, released          2023-01-01T10:00:00Z and 2023-01-01, or null
+//	year                     the release year, or null
+//	duration, duration_ms    the length in seconds and milliseconds
+//	explicit, playable, local
+//	position, playlist, playlist_id
+//
+// Fields computed before the expression are variables too, so later
+// fields can build on earlier ones.
+func TrackVars(mp spotify.MusicPlaylist, position int, track spotify.MusicTrack) Vars {
+	artists := make([]interface{}, 0, len(track.ArtistList))
+	for _, artist := range track.ArtistList {
+		artists = append(artists, artist.Name)
+	}
+	if len(artists) == 0 && track.Artists != "" {
+		for _, name := range strings.Split(track.Artists, ", ") {
+			artists = append(artists, name)
+		}
+	}
+	genres := make([]interface{}, len(track.Genres))
+	for i, genre := range track.Genres {
+		genres[i] = genre
+	}
+	trackType := track.Type
+	if trackType == "" {
+		trackType = spotify.TypeTrack
+	}
+
+	vars := Vars{
+		"name":        track.Name,
+		"artist":      track.Artists,
+		"artists":     artists,
+		"album":       track.AlbumName,
+		"show":        track.ShowName,
+		"id":          track.IntegrationID,
+		"isrc":        track.ISRC,
+		"type":        trackType,
+		"source":      track.Source,
+		"unavailable": track.Unavailable,
+		"url":         track.ExternalURL,
+		"preview":     track.PreviewURL,
+		"added_by":    track.AddedBy,
+		"genres":      genres,
+		"language":    track.Language,
+		"added":       nil,
+		"released":    nil,
+		"year":        nil,
+		"duration":    int64(track.DurationMS / 1000),
+		"duration_ms": int64(track.DurationMS),
+		"explicit":    track.Explicit,
+		"playable":    !track.Dead() && (track.IsPlayable == nil || *track.IsPlayable),
+		"local":       track.Source == spotify.SourceLocal,
+		"position":    int64(position),
+		"playlist":    mp.Name,
+		"playlist_id": mp.IntegrationID,
+	}
+	if track.AddedAt != nil {
+		vars["added"] = track.AddedAt.UTC().Format(time.RFC3339)
+	}
+	if released := firstNonEmpty(track.AlbumReleaseDate, track.ReleaseDate); released != "" {
+		vars["released"] = released
+	}
+	if track.Released != nil {
+		vars["year"] = int64(track.Released.Time().Year())
+	}
+	for name, value := range track.Computed {
+		vars[name] = value
+	}
+	return vars
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+// Package secret obtains credentials from external commands, such as a
+// password manager, so they never have to sit in config files.
+package secret
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Command runs command with sh and returns its output, trimmed of
+// surrounding whitespace. Its stderr is passed through, and its stdin too
+// when that is a terminal, so commands prompting for a passphrase still
+// work. Otherwise it gets no stdin, so it can't swallow a dump piped to
+// spdump.
+func Command(ctx context.Context, command string) (string, error) {
+	slog.Debug("running secret command", "command", command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	if isTerminal(os.Stdin) {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running %q: %w", command, err)
+	}
+	value := strings.TrimSpace(string(out))
+	if value == "" {
+		return "", fmt.Errorf("%q printed nothing", command)
+	}
+	return value, nil
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,36 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// IsSOPS reports whether data is a file encrypted by SOPS. Files SOPS
+// doesn't know the format of, such as TOML, are encrypted whole into a
+// JSON document with the encrypted data and a sops metadata key.
+func IsSOPS(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+	var doc struct {
+		SOPS json.RawMessage `json:"sops"`
+	}
+	return json.Unmarshal(data, &doc) == nil && len(doc.SOPS) > 0
+}
+
+// DecryptSOPS decrypts the SOPS encrypted file at path with the sops
+// binary, which takes care of the age, PGP or cloud KMS keys.
+func DecryptSOPS(ctx context.Context, path string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sops", "--decrypt", "--input-type", "binary", "--output-type", "binary", path)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s with sops: %w", path, err)
+	}
+	return out, nil
+}
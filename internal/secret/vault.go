@@ -0,0 +1,101 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from a HashiCorp Vault KV store over its HTTP API.
+type Vault struct {
+	// Address is the Vault server, e.g. https://vault.example.com:8200.
+	Address string
+	// Token authenticates the requests.
+	Token string
+	// Client makes the requests, one with a 15 second timeout when nil.
+	Client *http.Client
+}
+
+// VaultFromEnv returns a Vault configured the way the vault CLI is: the
+// address from VAULT_ADDR and the token from VAULT_TOKEN or ~/.vault-token.
+// address overrides VAULT_ADDR when set.
+func VaultFromEnv(address string) (*Vault, error) {
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("no vault address, set vault.address or VAULT_ADDR")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			data, _ := os.ReadFile(filepath.Join(home, ".vault-token"))
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("no vault token, set VAULT_TOKEN or log in with the vault cli")
+	}
+	return &Vault{Address: strings.TrimSuffix(address, "/"), Token: token}, nil
+}
+
+// Read returns a field of the secret at ref, written path#field, e.g.
+// secret/data/spotify#client_secret. Both KV version 1 and 2 paths work.
+func (o *Vault) Read(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("vault reference %q needs a #field", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", o.Address+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", o.Token)
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: reading %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	// KV version 2 nests the secret in data.data, version 1 in data.
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	data := secret.Data
+	if nested, ok := data["data"]; ok {
+		var v2 map[string]json.RawMessage
+		if json.Unmarshal(nested, &v2) == nil {
+			data = v2
+		}
+	}
+
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault: %s has no field %q", path, field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("vault: %s#%s is not a string", path, field)
+	}
+	return value, nil
+}
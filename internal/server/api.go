@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reloadInterval is how long the library is served before the archive
+// is read again to pick up new snapshots.
+const reloadInterval = 30 * time.Second
+
+// The read-only REST API over the archive:
+//
+//	GET /playlists          the playlists, without their tracks
+//	GET /playlists/{id}     a playlist with its tracks
+//	GET /tracks/{id}        a track and the playlists it appears in
+//	GET /search?q=&limit=   playlists and tracks matching the query
+
+// handlePlaylists serves /playlists and /playlists/{id}.
+func (o *Server) handlePlaylists(w http.ResponseWriter, r *http.Request) {
+	lib, ok := o.readLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/playlists"), "/")
+	if id == "" {
+		writeJSON(w, http.StatusOK, lib.summaries)
+		return
+	}
+	mp, ok := lib.playlists[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown playlist "+strconv.Quote(id))
+		return
+	}
+	writeJSON(w, http.StatusOK, mp)
+}
+
+// handleTrack serves /tracks/{id}.
+func (o *Server) handleTrack(w http.ResponseWriter, r *http.Request) {
+	lib, ok := o.readLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tracks/"), "/")
+	track, ok := lib.tracks[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown track "+strconv.Quote(id))
+		return
+	}
+	writeJSON(w, http.StatusOK, track)
+}
+
+// handleSearch serves /search?q=.
+func (o *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	lib, ok := o.readLibrary(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, lib.search(r.URL.Query().Get("q"), limit))
+}
+
+// readLibrary checks the request is a read and returns the library,
+// writing an error response when it can't.
+func (o *Server) readLibrary(w http.ResponseWriter, r *http.Request) (*library, bool) {
+	if !readOnly(w, r) {
+		return nil, false
+	}
+	lib, err := o.library()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return lib, true
+}
+
+// readOnly checks the request is a read, writing an error response when
+// it isn't.
+func readOnly(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "the API is read-only")
+		return false
+	}
+	return true
+}
+
+// library returns the library, reading the archive again when it was
+// loaded more than reloadInterval ago or invalidated by a refresh.
+func (o *Server) library() (*library, error) {
+	o.libMu.Lock()
+	defer o.libMu.Unlock()
+
+	if o.lib != nil && time.Since(o.lib.loadedAt) < reloadInterval {
+		return o.lib, nil
+	}
+	lib, err := loadLibrary(o.opts.Archive)
+	if err != nil {
+		return nil, err
+	}
+	o.lib = lib
+	return lib, nil
+}
+
+// invalidate makes the next API request read the archive again.
+func (o *Server) invalidate() {
+	o.libMu.Lock()
+	o.lib = nil
+	o.libMu.Unlock()
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pyrat/spd/internal/job"
+)
+
+// The progress of the jobs writing to the archive:
+//
+//	GET /jobs        the jobs, newest first, with their entities
+//	GET /jobs/{id}   a job
+//	GET /progress    a page following the jobs, refreshing itself
+
+// handleJobs serves /jobs and /jobs/{id}.
+func (o *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if !readOnly(w, r) {
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+	if id == "" {
+		jobs, err := o.opts.Jobs.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if jobs == nil {
+			jobs = []*job.Job{}
+		}
+		writeJSON(w, http.StatusOK, jobs)
+		return
+	}
+	j, err := o.opts.Jobs.Get(id)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "unknown job "+strconv.Quote(id))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// progressJobs is how many of the latest jobs the progress page shows.
+const progressJobs = 20
+
+// jobProgress is a job as shown on the progress page.
+type jobProgress struct {
+	*job.Job
+	Done, Failed, Total, Tracks int
+	Percent                     int
+}
+
+var progressPage = template.Must(template.New("progress").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>spdump jobs</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+progress { width: 20em; }
+.failed, .interrupted { color: #b00; }
+.done { color: #070; }
+</style>
+</head>
+<body>
+<h1>Jobs</h1>
+{{range .}}
+<h2>{{.Kind}} {{.Collection}} <span class="{{.State}}">{{.State}}</span></h2>
+<p>
+<progress max="{{.Total}}" value="{{.Done}}"></progress>
+{{.Done}}/{{.Total}} done ({{.Percent}}%), {{.Failed}} failed, {{.Tracks}} tracks.
+Started {{.StartedAt.Format "2006-01-02 15:04:05"}} UTC, updated {{.UpdatedAt.Format "15:04:05"}}.
+{{with .Error}}<br><span class="failed">{{.}}</span>{{end}}
+</p>
+{{if ne .State "done"}}
+<table>
+<tr><th>Playlist</th><th>State</th><th>Tracks</th></tr>
+{{range .Entities}}{{if ne .State "done"}}<tr><td>{{or .Name .ID}}</td><td class="{{.State}}">{{.State}}{{with .Error}}: {{.}}{{end}}</td><td>{{.Tracks}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}
+{{else}}
+<p>No jobs yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// handleProgress serves /progress, the latest jobs as a page reloading
+// itself every few seconds.
+func (o *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	if !readOnly(w, r) {
+		return
+	}
+	jobs, err := o.opts.Jobs.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(jobs) > progressJobs {
+		jobs = jobs[:progressJobs]
+	}
+
+	shown := make([]jobProgress, len(jobs))
+	for i, j := range jobs {
+		p := jobProgress{Job: j, Tracks: j.Tracks()}
+		p.Done, p.Failed, p.Total = j.Progress()
+		if p.Total > 0 {
+			p.Percent = 100 * p.Done / p.Total
+		}
+		shown[i] = p
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	progressPage.Execute(w, shown)
+}
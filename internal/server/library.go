@@ -0,0 +1,147 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// PlaylistSummary describes a playlist in the library listing.
+type PlaylistSummary struct {
+	ID         string
+	Name       string
+	Tracks     int
+	Collection string
+	SnapshotAt time.Time
+}
+
+// PlaylistRef refers to a playlist a track appears in.
+type PlaylistRef struct {
+	ID   string
+	Name string
+}
+
+// TrackEntry is a track with the playlists it appears in.
+type TrackEntry struct {
+	spotify.MusicTrack
+	Playlists []PlaylistRef
+}
+
+// library is an in memory index of the latest snapshot of every
+// collection in the archive.
+type library struct {
+	loadedAt  time.Time
+	summaries []PlaylistSummary
+	playlists map[string]spotify.MusicPlaylist
+	tracks    map[string]*TrackEntry
+	// trackOrder keeps search results stable.
+	trackOrder []string
+}
+
+// loadLibrary reads the archive into a library. A playlist archived in
+// several collections is taken from the newest snapshot.
+func loadLibrary(arc *archive.Archive) (*library, error) {
+	snapshots, err := arc.LatestSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+
+	lib := &library{
+		loadedAt:  time.Now(),
+		playlists: map[string]spotify.MusicPlaylist{},
+		tracks:    map[string]*TrackEntry{},
+	}
+	for _, snapshot := range snapshots {
+		for _, entry := range snapshot.Playlists {
+			if _, ok := lib.playlists[entry.ID]; ok {
+				continue
+			}
+			mp, err := snapshot.ReadPlaylist(entry)
+			if err != nil {
+				return nil, err
+			}
+			lib.add(mp, snapshot)
+		}
+	}
+
+	sort.Slice(lib.summaries, func(i, j int) bool {
+		return strings.ToLower(lib.summaries[i].Name) < strings.ToLower(lib.summaries[j].Name)
+	})
+	return lib, nil
+}
+
+// add indexes a playlist and its tracks.
+func (o *library) add(mp spotify.MusicPlaylist, snapshot archive.Snapshot) {
+	o.playlists[mp.IntegrationID] = mp
+	o.summaries = append(o.summaries, PlaylistSummary{
+		ID:         mp.IntegrationID,
+		Name:       mp.Name,
+		Tracks:     len(mp.Tracks),
+		Collection: snapshot.Collection,
+		SnapshotAt: snapshot.CreatedAt,
+	})
+
+	ref := PlaylistRef{ID: mp.IntegrationID, Name: mp.Name}
+	for _, track := range mp.Tracks {
+		if track.IntegrationID == "" {
+			continue
+		}
+		entry, ok := o.tracks[track.IntegrationID]
+		if !ok {
+			entry = &TrackEntry{MusicTrack: track}
+			entry.AddedAt = nil
+			o.tracks[track.IntegrationID] = entry
+			o.trackOrder = append(o.trackOrder, track.IntegrationID)
+		}
+		if n := len(entry.Playlists); n == 0 || entry.Playlists[n-1] != ref {
+			entry.Playlists = append(entry.Playlists, ref)
+		}
+	}
+}
+
+// SearchResult holds the playlists and tracks matching a search.
+type SearchResult struct {
+	Playlists []PlaylistSummary
+	Tracks    []TrackEntry
+}
+
+// search returns up to limit playlists and tracks matching every term of
+// the query, case insensitively. Tracks match on name, artists and album.
+func (o *library) search(query string, limit int) SearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+	matches := func(fields ...string) bool {
+		text := strings.ToLower(strings.Join(fields, " "))
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+		return true
+	}
+
+	result := SearchResult{Playlists: []PlaylistSummary{}, Tracks: []TrackEntry{}}
+	if len(terms) == 0 {
+		return result
+	}
+	for _, summary := range o.summaries {
+		if len(result.Playlists) < limit && matches(summary.Name) {
+			result.Playlists = append(result.Playlists, summary)
+		}
+	}
+	for _, id := range o.trackOrder {
+		if len(result.Tracks) >= limit {
+			break
+		}
+		track := o.tracks[id]
+		if matches(track.Name, track.Artists, track.AlbumName, track.ShowName) {
+			result.Tracks = append(result.Tracks, *track)
+		}
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+// Package server implements spdump's long running serve mode: a read-only
+// REST API over the archive, webhooks triggering refreshes into it and the
+// progress of the jobs writing to it.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/job"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Archive is served read-only over the REST API when set.
+	Archive *archive.Archive
+	// Refresh dumps the playlist with the given ID. It is called in the
+	// background for every accepted webhook.
+	Refresh func(ctx context.Context, playlistID string) error
+	// Playlists maps names webhooks may use to playlist IDs. Webhooks may
+	// also name a playlist by its ID, URI or link.
+	Playlists map[string]string
+	// Secret is the shared secret webhooks must present. Webhooks are
+	// disabled without one.
+	Secret string
+	// MinInterval is the least time between two refreshes of a playlist.
+	MinInterval time.Duration
+	// MaxRefreshes is the most webhook refreshes of all playlists
+	// together within MinInterval, no limit when 0.
+	MaxRefreshes int
+	// Jobs is the store of the jobs writing to the archive, whose
+	// progress is served when set.
+	Jobs *job.Store
+}
+
+// Server serves the REST API and the webhooks. It must be created with
+// New.
+type Server struct {
+	opts    Options
+	mux     *http.ServeMux
+	limiter *limiter
+
+	libMu sync.Mutex
+	lib   *library
+
+	// ctx is the context of background refreshes, cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a Server for the options.
+func New(opts Options) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		opts:    opts,
+		mux:     http.NewServeMux(),
+		limiter: newLimiter(opts.MinInterval, opts.MaxRefreshes),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if opts.Archive != nil {
+		s.mux.HandleFunc("/playlists", s.handlePlaylists)
+		s.mux.HandleFunc("/playlists/", s.handlePlaylists)
+		s.mux.HandleFunc("/tracks/", s.handleTrack)
+		s.mux.HandleFunc("/search", s.handleSearch)
+	}
+	if opts.Jobs != nil {
+		s.mux.HandleFunc("/jobs", s.handleJobs)
+		s.mux.HandleFunc("/jobs/", s.handleJobs)
+		s.mux.HandleFunc("/progress", s.handleProgress)
+	}
+	if opts.Secret != "" && opts.Refresh != nil {
+		s.mux.HandleFunc("/hooks/refresh/", s.handleRefresh)
+	}
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (o *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mux.ServeHTTP(w, r)
+}
+
+// Close cancels the refreshes in flight and waits for them to return.
+func (o *Server) Close() {
+	o.cancel()
+	o.wg.Wait()
+}
+
+// background runs fn outside of the request, tracked for Close.
+func (o *Server) background(name string, fn func(ctx context.Context) error) {
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if err := fn(o.ctx); err != nil {
+			slog.Error("background job failed", "job", name, "err", err)
+		}
+	}()
+}
+
+// writeJSON writes v as the response body with the status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
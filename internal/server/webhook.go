@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// handleRefresh triggers a refresh of the playlist named in the path:
+//
+//	POST /hooks/refresh/<name>
+//
+// The shared secret is taken from the X-Webhook-Secret header, a bearer
+// token or, for services which can't set headers, the secret query
+// parameter.
+func (o *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "webhooks must be POSTed")
+		return
+	}
+	if !o.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "missing or wrong webhook secret")
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/hooks/refresh/")
+	playlistID, ok := o.resolve(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown playlist "+strconv.Quote(name))
+		return
+	}
+
+	now := time.Now()
+	if wait, global := o.limiter.reserve(playlistID, now); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter(wait)))
+		if global {
+			writeError(w, http.StatusTooManyRequests, "too many playlists were refreshed recently")
+		} else {
+			writeError(w, http.StatusTooManyRequests, "playlist was refreshed recently")
+		}
+		return
+	}
+
+	slog.Info("webhook refresh", "playlist", playlistID, "name", name, "remote", r.RemoteAddr)
+	o.background("refresh "+playlistID, func(ctx context.Context) error {
+		if err := o.opts.Refresh(ctx, playlistID); err != nil {
+			// a failed refresh doesn't hold the playlist off retrying
+			o.limiter.release(playlistID, now)
+			return err
+		}
+		o.invalidate()
+		return nil
+	})
+	writeJSON(w, http.StatusAccepted, map[string]string{"playlist": playlistID, "status": "accepted"})
+}
+
+// authorized reports whether the request carries the shared secret.
+func (o *Server) authorized(r *http.Request) bool {
+	given := r.Header.Get("X-Webhook-Secret")
+	if given == "" {
+		given = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if given == "" {
+		given = r.URL.Query().Get("secret")
+	}
+	return o.opts.Secret != "" && subtle.ConstantTimeCompare([]byte(given), []byte(o.opts.Secret)) == 1
+}
+
+// resolve maps a webhook's playlist name to the playlist ID.
+func (o *Server) resolve(name string) (string, bool) {
+	if id, ok := o.opts.Playlists[name]; ok {
+		name = id
+	}
+	id, err := spotify.ParseID(name, spotify.TypePlaylist)
+	return id, err == nil && id != ""
+}
+
+// retryAfter returns the whole seconds to wait out wait, rounded up so a
+// caller retrying then isn't turned away again, and at least 1.
+func retryAfter(wait time.Duration) int {
+	return max(int(math.Ceil(wait.Seconds())), 1)
+}
+
+// limiter allows one action per key every interval and, when burst is
+// set, at most burst actions on all keys together within an interval.
+type limiter struct {
+	interval time.Duration
+	burst    int
+
+	mu   sync.Mutex
+	last map[string]time.Time
+	// recent are the times of the latest actions on any key, oldest
+	// first, those within an interval when burst is set.
+	recent []time.Time
+}
+
+func newLimiter(interval time.Duration, burst int) *limiter {
+	return &limiter{interval: interval, burst: burst, last: map[string]time.Time{}}
+}
+
+// reserve records an action on key at now, or returns how long to wait
+// when the last one was less than an interval ago, or burst actions were,
+// which global reports.
+func (o *limiter) reserve(key string, now time.Time) (wait time.Duration, global bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if last, ok := o.last[key]; ok {
+		if wait := last.Add(o.interval).Sub(now); wait > 0 {
+			return wait, false
+		}
+	}
+	if o.burst > 0 {
+		for len(o.recent) > 0 && !o.recent[0].Add(o.interval).After(now) {
+			o.recent = o.recent[1:]
+		}
+		if len(o.recent) >= o.burst {
+			return o.recent[0].Add(o.interval).Sub(now), true
+		}
+		o.recent = append(o.recent, now)
+	}
+	// keys past their interval are forgotten, so they can't pile up
+	for k, last := range o.last {
+		if !last.Add(o.interval).After(now) {
+			delete(o.last, k)
+		}
+	}
+	o.last[key] = now
+	return 0, false
+}
+
+// release gives back the action reserved on key at, as if it wasn't taken.
+func (o *limiter) release(key string, at time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if last, ok := o.last[key]; ok && last.Equal(at) {
+		delete(o.last, key)
+	}
+	for i, t := range o.recent {
+		if t.Equal(at) {
+			o.recent = append(o.recent[:i:i], o.recent[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLimiter(time.Minute, 2)
+
+	if wait, _ := l.reserve("a", start); wait != 0 {
+		t.Fatalf("first refresh of a waits %s", wait)
+	}
+	if wait, global := l.reserve("a", start.Add(20*time.Second)); wait != 40*time.Second || global {
+		t.Errorf("second refresh of a waits %s (global %v), want 40s for a", wait, global)
+	}
+	if wait, _ := l.reserve("b", start.Add(30*time.Second)); wait != 0 {
+		t.Errorf("first refresh of b waits %s", wait)
+	}
+	if wait, global := l.reserve("c", start.Add(45*time.Second)); wait != 15*time.Second || !global {
+		t.Errorf("third playlist within a minute waits %s (global %v), want 15s for all", wait, global)
+	}
+
+	// a failed refresh gives its slot back
+	l.release("b", start.Add(30*time.Second))
+	if wait, _ := l.reserve("b", start.Add(50*time.Second)); wait != 0 {
+		t.Errorf("refresh of b after a failed one waits %s", wait)
+	}
+	if wait, _ := l.reserve("a", start.Add(time.Minute)); wait != 0 {
+		t.Errorf("refresh of a a minute later waits %s", wait)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for wait, want := range map[time.Duration]int{
+		time.Millisecond:                      1,
+		400 * time.Millisecond:                1,
+		time.Second:                           1,
+		time.Second + time.Millisecond:        2,
+		59*time.Second + 600*time.Millisecond: 60,
+	} {
+		if got := retryAfter(wait); got != want {
+			t.Errorf("Retry-After of %s is %d, want %d", wait, got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// launchdLabel returns the launchd label of a service.
+func launchdLabel(name string) string {
+	return "com.github.pyrat.spd." + name
+}
+
+// launchdPlistPath returns the path of the agent's property list.
+func launchdPlistPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel(name)+".plist"), nil
+}
+
+// launchdDomain is the launchd domain of the user's agents.
+func launchdDomain() string {
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+// xmlString escapes s for a <string> element.
+func xmlString(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return "<string>" + b.String() + "</string>"
+}
+
+// launchdPlist returns the property list of a launchd agent kept running
+// from login, restarted when it fails, logging to its log file.
+func launchdPlist(c Config) string {
+	var args strings.Builder
+	for _, arg := range append([]string{c.Executable}, c.Args...) {
+		fmt.Fprintf(&args, "\t\t%s\n", xmlString(arg))
+	}
+	log := xmlString(c.LogFile())
+
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	` + xmlString(launchdLabel(c.Name)) + `
+	<key>ProgramArguments</key>
+	<array>
+` + args.String() + `	</array>
+	<key>WorkingDirectory</key>
+	` + xmlString(c.Dir) + `
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>30</integer>
+	<key>ProcessType</key>
+	<string>Background</string>
+	<key>StandardOutPath</key>
+	` + log + `
+	<key>StandardErrorPath</key>
+	` + log + `
+</dict>
+</plist>
+`
+}
+
+func installLaunchd(c Config) (string, error) {
+	path, err := launchdPlistPath(c.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(launchdPlist(c)), 0o644); err != nil {
+		return "", err
+	}
+	// launchd creates the log file but not its directory
+	if err := os.MkdirAll(filepath.Dir(c.LogFile()), 0o755); err != nil {
+		return path, err
+	}
+	_, err = run("launchctl", "bootstrap", launchdDomain(), path)
+	return path, err
+}
+
+func uninstallLaunchd(name string) error {
+	path, err := launchdPlistPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	if _, err := run("launchctl", "bootout", launchdDomain()+"/"+launchdLabel(name)); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func statusLaunchd(name string) (string, error) {
+	path, err := launchdPlistPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", ErrNotInstalled
+	}
+	out, err := run("launchctl", "print", launchdDomain()+"/"+launchdLabel(name))
+	if err != nil {
+		return "installed in " + path + " but not loaded\n", nil
+	}
+	return out, nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package service
+
+// Run runs the daemon in the foreground, launchd and systemd need nothing
+// more from it.
+func Run(name string, run func() error, stop func()) error {
+	return run()
+}
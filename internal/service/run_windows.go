@@ -0,0 +1,146 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+)
+
+// Service types, states, accepted controls and controls of the service
+// control manager.
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped     = 1
+	serviceRunning     = 4
+	serviceStopPending = 3
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	errorServiceSpecificError = 1066
+)
+
+// serviceStatus is SERVICE_STATUS.
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// serviceTableEntry is SERVICE_TABLE_ENTRYW.
+type serviceTableEntry struct {
+	ServiceName *uint16
+	ServiceProc uintptr
+}
+
+// the service of the process, the callbacks from the service manager
+// can't carry it
+var current struct {
+	mu     sync.Mutex
+	name   *uint16
+	run    func() error
+	stop   func()
+	handle uintptr
+	status serviceStatus
+	err    error
+}
+
+// Run runs the daemon as the Windows service name, as started by the
+// service manager: run is the daemon, stop makes it return when the
+// service is stopped or Windows shuts down.
+func Run(name string, run func() error, stop func()) error {
+	n, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	current.name, current.run, current.stop = n, run, stop
+	table := []serviceTableEntry{
+		{ServiceName: n, ServiceProc: syscall.NewCallback(serviceMain)},
+		{},
+	}
+
+	// the dispatcher connects this thread to the service manager and
+	// returns once the service stopped
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		return fmt.Errorf("not started by the service manager, install the service with spdump service install: %w", err)
+	}
+	runtime.KeepAlive(table)
+	return current.err
+}
+
+// serviceMain is the ServiceMain of the service, running the daemon.
+func serviceMain(argc uintptr, argv uintptr) uintptr {
+	handle, _, _ := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(current.name)), syscall.NewCallback(controlHandler), 0)
+	if handle == 0 {
+		return 0
+	}
+	current.mu.Lock()
+	current.handle = handle
+	current.mu.Unlock()
+
+	setStatus(serviceRunning, serviceAcceptStop|serviceAcceptShutdown, 0)
+	current.err = current.run()
+	exitCode := uint32(0)
+	if current.err != nil {
+		exitCode = 1
+	}
+	setStatus(serviceStopped, 0, exitCode)
+	return 0
+}
+
+// controlHandler is the HandlerEx of the service.
+func controlHandler(control uintptr, eventType uintptr, eventData uintptr, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		setStatus(serviceStopPending, 0, 0)
+		current.stop()
+	case serviceControlInterrogate:
+		current.mu.Lock()
+		status := current.status
+		current.mu.Unlock()
+		setStatus(status.CurrentState, status.ControlsAccepted, status.ServiceSpecificExitCode)
+	}
+	return 0
+}
+
+// setStatus reports the state of the service, exiting with a service
+// specific exit code when it isn't zero.
+func setStatus(state uint32, accepted uint32, exitCode uint32) {
+	current.mu.Lock()
+	defer current.mu.Unlock()
+	current.status = serviceStatus{
+		ServiceType:      serviceWin32OwnProcess,
+		CurrentState:     state,
+		ControlsAccepted: accepted,
+	}
+	if exitCode != 0 {
+		current.status.Win32ExitCode = errorServiceSpecificError
+		current.status.ServiceSpecificExitCode = exitCode
+	}
+	if state == serviceStopPending {
+		current.status.WaitHint = 30000
+	}
+	procSetServiceStatus.Call(current.handle, uintptr(unsafe.Pointer(&current.status)))
+}
@@ -0,0 +1,154 @@
+// Package service registers a long running spdump command, such as sync
+// --interval or serve, with the platform's service manager: a launchd
+// agent on macOS, a Windows service, or a systemd user unit on Linux and
+// the other unixes.
+//
+// Logs go where the platform keeps them: the journal under systemd,
+// ~/Library/Logs/spdump on macOS and %ProgramData%\spdump\logs on Windows.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Config describes a service.
+type Config struct {
+	// Name names the service, so that several can be installed. It is
+	// "spdump" for a single one.
+	Name string
+	// Executable is the absolute path of spdump.
+	Executable string
+	// Args is the command line of the daemon, e.g. sync --interval 6h.
+	Args []string
+	// Dir is the working directory of the daemon, where config.toml and
+	// the archive are.
+	Dir string
+	// LogDir is where the daemon's log goes, LogDir(Name) when empty.
+	// systemd keeps logs in the journal instead.
+	LogDir string
+}
+
+// nameRE matches the service names all platforms accept, which can't
+// name a path outside the directories of units, agents and logs.
+var nameRE = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+
+// CheckName checks name is a service name all platforms accept.
+func CheckName(name string) error {
+	if !nameRE.MatchString(name) {
+		return fmt.Errorf("invalid service name %q, use letters, digits, '-', '_', '.' and '@'", name)
+	}
+	return nil
+}
+
+// LogDir returns the directory a service logs to by default, empty under
+// systemd which keeps logs in the journal.
+func LogDir(name string) string {
+	switch runtime.GOOS {
+	case "darwin":
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, "Library", "Logs", "spdump")
+	case "windows":
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, "spdump", "logs")
+	}
+	return ""
+}
+
+// LogFile returns the file the service logs to, empty under systemd.
+func (o Config) LogFile() string {
+	dir := o.LogDir
+	if dir == "" {
+		dir = LogDir(o.Name)
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, o.Name+".log")
+}
+
+// Definition returns what Install registers, without registering it: the
+// systemd unit, the launchd property list or the sc.exe command line.
+func Definition(c Config) (string, error) {
+	if err := CheckName(c.Name); err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return launchdPlist(c), nil
+	case "windows":
+		return "sc.exe " + strings.Join(scCreateArgs(c), " "), nil
+	}
+	return systemdUnit(c), nil
+}
+
+// Install registers the service and starts it, returning where its
+// definition was written.
+func Install(c Config) (string, error) {
+	if err := CheckName(c.Name); err != nil {
+		return "", err
+	}
+	if log := c.LogFile(); log != "" {
+		if err := os.MkdirAll(filepath.Dir(log), 0o755); err != nil {
+			return "", err
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return installLaunchd(c)
+	case "windows":
+		return installWindows(c)
+	}
+	return installSystemd(c)
+}
+
+// Uninstall stops the service and removes it.
+func Uninstall(name string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return uninstallLaunchd(name)
+	case "windows":
+		return uninstallWindows(name)
+	}
+	return uninstallSystemd(name)
+}
+
+// Status returns the service manager's report on the service.
+func Status(name string) (string, error) {
+	if err := CheckName(name); err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return statusLaunchd(name)
+	case "windows":
+		return statusWindows(name)
+	}
+	return statusSystemd(name)
+}
+
+// ErrNotInstalled is returned by Uninstall and Status for a service that
+// isn't installed.
+var ErrNotInstalled = errors.New("service not installed")
+
+// run runs a service manager command, returning its output, which holds
+// the reason when it fails.
+func run(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
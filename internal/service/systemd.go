@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemdUnitPath returns the path of the user unit of a service.
+func systemdUnitPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", name+".service"), nil
+}
+
+// systemdQuote quotes an argument of ExecStart as systemd splits them.
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\$%;") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `$$`, `%`, `%%`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+// systemdUnit returns the user unit of a service. Its output goes to the
+// journal, read with journalctl --user -u <name>, or to the log file when
+// a log directory is given. It doesn't wait for network-online.target,
+// which the user manager can't see; a daemon started before the network
+// is up fails and is restarted 30 seconds later.
+func systemdUnit(c Config) string {
+	exec := []string{systemdQuote(c.Executable)}
+	for _, arg := range c.Args {
+		exec = append(exec, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=spdump %s\n\n", strings.ReplaceAll(strings.Join(c.Args, " "), "%", "%%"))
+	fmt.Fprintf(&b, "[Service]\n")
+	// paths aren't quoted, only specifiers escaped
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", strings.ReplaceAll(c.Dir, "%", "%%"))
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(exec, " "))
+	if log := c.LogFile(); log != "" {
+		log = strings.ReplaceAll(log, "%", "%%")
+		fmt.Fprintf(&b, "StandardOutput=append:%s\n", log)
+		fmt.Fprintf(&b, "StandardError=append:%s\n", log)
+	}
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=30\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=default.target\n")
+	return b.String()
+}
+
+func installSystemd(c Config) (string, error) {
+	path, err := systemdUnitPath(c.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(systemdUnit(c)), 0o644); err != nil {
+		return "", err
+	}
+	// systemd creates the log file but not its directory
+	if log := c.LogFile(); log != "" {
+		if err := os.MkdirAll(filepath.Dir(log), 0o755); err != nil {
+			return path, err
+		}
+	}
+	if _, err := run("systemctl", "--user", "daemon-reload"); err != nil {
+		return path, err
+	}
+	_, err = run("systemctl", "--user", "enable", "--now", c.Name+".service")
+	return path, err
+}
+
+func uninstallSystemd(name string) error {
+	path, err := systemdUnitPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	if _, err := run("systemctl", "--user", "disable", "--now", name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	_, err = run("systemctl", "--user", "daemon-reload")
+	return err
+}
+
+func statusSystemd(name string) (string, error) {
+	path, err := systemdUnitPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", ErrNotInstalled
+	}
+	// systemctl status exits non-zero for a stopped unit, which is
+	// still a status to report
+	out, _ := run("systemctl", "--user", "status", "--no-pager", name+".service")
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"strings"
+)
+
+// windowsQuote quotes an argument as CommandLineToArgvW splits them.
+func windowsQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; c {
+		case '\\':
+			slashes++
+			b.WriteByte(c)
+		case '"':
+			// the backslashes before a quote are doubled, then the
+			// quote escaped
+			for ; slashes > 0; slashes-- {
+				b.WriteByte('\\')
+			}
+			b.WriteString(`\"`)
+		default:
+			slashes = 0
+			b.WriteByte(c)
+		}
+	}
+	for ; slashes > 0; slashes-- {
+		b.WriteByte('\\')
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// windowsCommandLine returns the command line the service manager starts
+// the service with: spdump service run, which talks to the service
+// manager and runs the daemon in the service's directory, logging to its
+// log file.
+func windowsCommandLine(c Config) string {
+	logDir := c.LogDir
+	if logDir == "" {
+		logDir = LogDir(c.Name)
+	}
+	args := append([]string{c.Executable, "service", "run", "--name", c.Name, "--dir", c.Dir, "--log-dir", logDir, "--"}, c.Args...)
+	for i, arg := range args {
+		args[i] = windowsQuote(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// scCreateArgs returns the sc.exe arguments creating the service, started
+// at boot.
+func scCreateArgs(c Config) []string {
+	return []string{"create", c.Name, "binPath=", windowsCommandLine(c), "start=", "auto", "DisplayName=", "spdump " + c.Name}
+}
+
+func installWindows(c Config) (string, error) {
+	if _, err := run("sc.exe", scCreateArgs(c)...); err != nil {
+		return "", err
+	}
+	if _, err := run("sc.exe", "description", c.Name, "spdump "+strings.Join(c.Args, " ")); err != nil {
+		return "", err
+	}
+	// restart after 30 seconds when the daemon fails, as systemd and
+	// launchd do
+	if _, err := run("sc.exe", "failure", c.Name, "reset=", "86400", "actions=", "restart/30000"); err != nil {
+		return "", err
+	}
+	_, err := run("sc.exe", "start", c.Name)
+	return "service " + c.Name, err
+}
+
+// notInstalled reports whether sc.exe failed as the service doesn't
+// exist, error 1060.
+func notInstalled(out string) bool {
+	return strings.Contains(out, "1060")
+}
+
+func uninstallWindows(name string) error {
+	if out, err := run("sc.exe", "query", name); err != nil {
+		if notInstalled(out) {
+			return ErrNotInstalled
+		}
+		return err
+	}
+	// stopping fails for a service not running, which can be deleted
+	// all the same
+	run("sc.exe", "stop", name)
+	_, err := run("sc.exe", "delete", name)
+	return err
+}
+
+func statusWindows(name string) (string, error) {
+	out, err := run("sc.exe", "query", name)
+	if err != nil && notInstalled(out) {
+		return "", ErrNotInstalled
+	}
+	return out, err
+}
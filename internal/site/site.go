@@ -0,0 +1,162 @@
+// Package site renders playlist dumps as a static website: an index with
+// search, a page per playlist, robots.txt and a sitemap, ready for GitHub
+// Pages or any static host.
+package site
+
+import (
+	"embed"
+	"encoding/xml"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/archive"
+	"github.com/pyrat/spd/internal/locale"
+	"github.com/pyrat/spd/internal/report"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Options controls how the site is rendered.
+type Options struct {
+	// BaseURL is where the site will be published, used for the sitemap.
+	// Without it no sitemap is written.
+	BaseURL string
+	Title   string
+	Report  report.Options
+}
+
+// searchEntry is a track in the search index embedded in the index page.
+type searchEntry struct {
+	T string `json:"t"` // track name
+	A string `json:"a"` // artists
+	L string `json:"l"` // album
+	P string `json:"p"` // playlist page
+	N string `json:"n"` // playlist name
+}
+
+type indexData struct {
+	Title       string
+	Playlists   []spotify.MusicPlaylist
+	Search      []searchEntry
+	GeneratedAt time.Time
+}
+
+type playlistData struct {
+	Title       string
+	Playlist    spotify.MusicPlaylist
+	GeneratedAt time.Time
+}
+
+// PageName returns the file name of a playlist's page.
+func PageName(mp spotify.MusicPlaylist) string {
+	return "playlists/" + archive.CollectionName(mp.IntegrationID) + ".html"
+}
+
+// Generate writes the site for the playlists into dir.
+func Generate(dir string, playlists []spotify.MusicPlaylist, opts Options) error {
+	if opts.Title == "" {
+		opts.Title = "Playlists"
+	}
+	if opts.Report.Locale == nil {
+		opts.Report.Locale = locale.Default
+	}
+	if opts.Report.Location == nil {
+		opts.Report.Location = time.UTC
+	}
+
+	tmpl, err := template.New("").Funcs(template.FuncMap(report.FuncMap(opts.Report))).Funcs(template.FuncMap{
+		"page": PageName,
+	}).ParseFS(templates, "templates/*.html")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "playlists"), 0o755); err != nil {
+		return err
+	}
+
+	sorted := append([]spotify.MusicPlaylist(nil), playlists...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+
+	now := time.Now().In(opts.Report.Location)
+	index := indexData{Title: opts.Title, Playlists: sorted, GeneratedAt: now}
+	for _, mp := range sorted {
+		for _, track := range mp.Tracks {
+			index.Search = append(index.Search, searchEntry{
+				T: track.Name, A: track.Artists, L: track.AlbumName + track.ShowName, P: PageName(mp), N: mp.Name,
+			})
+		}
+
+		err := render(tmpl, "playlist.html", filepath.Join(dir, PageName(mp)), playlistData{
+			Title:       opts.Title,
+			Playlist:    mp,
+			GeneratedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := render(tmpl, "index.html", filepath.Join(dir, "index.html"), index); err != nil {
+		return err
+	}
+
+	robots := "User-agent: *\nAllow: /\n"
+	if opts.BaseURL != "" {
+		robots += "Sitemap: " + strings.TrimSuffix(opts.BaseURL, "/") + "/sitemap.xml\n"
+		if err := writeSitemap(filepath.Join(dir, "sitemap.xml"), opts.BaseURL, sorted, now); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "robots.txt"), []byte(robots), 0o644)
+}
+
+// render executes a template into a file.
+func render(tmpl *template.Template, name string, path string, data interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.ExecuteTemplate(f, name, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// writeSitemap lists every page of the site for search engines.
+func writeSitemap(path string, baseURL string, playlists []spotify.MusicPlaylist, now time.Time) error {
+	base := strings.TrimSuffix(baseURL, "/") + "/"
+	lastMod := now.Format("2006-01-02")
+
+	sm := sitemap{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	sm.URLs = append(sm.URLs, sitemapURL{Loc: base, LastMod: lastMod})
+	for _, mp := range playlists {
+		sm.URLs = append(sm.URLs, sitemapURL{Loc: base + PageName(mp), LastMod: lastMod})
+	}
+
+	data, err := xml.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0o644)
+}
@@ -0,0 +1,152 @@
+// Package staleness scores playlists by how neglected they look, to help
+// decide which ones to prune or refresh.
+package staleness
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// StaleAfter is the age of the last addition at which a playlist counts
+// as fully stale on the age component.
+const StaleAfter = 2 * 365 * 24 * time.Hour
+
+// Weights of the score components. Without play history the play weight is
+// shared out between the other two.
+const (
+	ageWeight         = 50.0
+	unavailableWeight = 30.0
+	playWeight        = 20.0
+)
+
+// Report is the staleness of a single playlist.
+type Report struct {
+	PlaylistID   string
+	Name         string
+	Tracks       int
+	LastModified *time.Time `json:",omitempty"`
+	// Unavailable is the fraction of tracks which can't be played,
+	// removed from the catalogue or not playable in the market.
+	Unavailable float64
+	// Plays is the number of recent plays of the playlist's tracks,
+	// nil when no play history was available.
+	Plays *int `json:",omitempty"`
+	// Score runs from 0 (fresh) to 100 (stale).
+	Score float64
+}
+
+// Score scores the playlists, most stale first. plays counts the recent
+// plays per track ID and may be nil when the history is unknown.
+func Score(playlists []spotify.MusicPlaylist, plays map[string]int, now time.Time) []Report {
+	scorer := NewScorer(plays, now)
+	for _, mp := range playlists {
+		scorer.AddPlaylist(mp)
+		for _, track := range mp.Tracks {
+			scorer.AddTrack(mp.IntegrationID, track)
+		}
+	}
+	return scorer.Reports()
+}
+
+// Scorer scores playlists a track at a time, so a dump can be streamed
+// through it, keeping counts per playlist rather than its tracks.
+type Scorer struct {
+	plays   map[string]int
+	now     time.Time
+	tallies []*tally
+	// index maps playlist IDs to their tallies, the last one added for a
+	// playlist found in several dumps.
+	index map[string]*tally
+}
+
+// tally counts the tracks of a playlist as they are added.
+type tally struct {
+	report      Report
+	unavailable int
+	played      int
+	playCount   int
+}
+
+// NewScorer returns a Scorer weighing in the recent plays per track ID,
+// nil when the history is unknown.
+func NewScorer(plays map[string]int, now time.Time) *Scorer {
+	return &Scorer{plays: plays, now: now, index: map[string]*tally{}}
+}
+
+// AddPlaylist starts scoring a playlist, its tracks are added with
+// AddTrack.
+func (o *Scorer) AddPlaylist(mp spotify.MusicPlaylist) {
+	t := &tally{report: Report{PlaylistID: mp.IntegrationID, Name: mp.Name}}
+	o.tallies = append(o.tallies, t)
+	o.index[mp.IntegrationID] = t
+}
+
+// AddTrack adds a track of the playlist with the given ID, added before.
+func (o *Scorer) AddTrack(playlistID string, track spotify.MusicTrack) {
+	t, ok := o.index[playlistID]
+	if !ok {
+		return
+	}
+	t.report.Tracks++
+	if track.AddedAt != nil && (t.report.LastModified == nil || track.AddedAt.After(*t.report.LastModified)) {
+		added := *track.AddedAt
+		t.report.LastModified = &added
+	}
+	if track.Source == spotify.SourceLocal {
+		return
+	}
+	if track.Dead() || track.IntegrationID == "" || (track.IsPlayable != nil && !*track.IsPlayable) {
+		t.unavailable++
+	}
+	if n := o.plays[track.IntegrationID]; n > 0 {
+		t.played++
+		t.playCount += n
+	}
+}
+
+// Reports returns the scores of the playlists added, most stale first.
+func (o *Scorer) Reports() []Report {
+	reports := make([]Report, 0, len(o.tallies))
+	for _, t := range o.tallies {
+		reports = append(reports, t.score(o.plays != nil, o.now))
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Score > reports[j].Score
+	})
+	return reports
+}
+
+func (o *tally) score(withPlays bool, now time.Time) Report {
+	report := o.report
+
+	age := 1.0
+	if report.LastModified != nil {
+		age = float64(now.Sub(*report.LastModified)) / float64(StaleAfter)
+		if age > 1 {
+			age = 1
+		} else if age < 0 {
+			age = 0
+		}
+	}
+
+	if report.Tracks > 0 {
+		report.Unavailable = float64(o.unavailable) / float64(report.Tracks)
+	}
+
+	if !withPlays {
+		total := ageWeight + unavailableWeight
+		report.Score = 100 * (age*ageWeight + report.Unavailable*unavailableWeight) / total
+		return report
+	}
+
+	playCount := o.playCount
+	report.Plays = &playCount
+	unplayed := 1.0
+	if report.Tracks > 0 {
+		unplayed = 1 - float64(o.played)/float64(report.Tracks)
+	}
+	report.Score = age*ageWeight + report.Unavailable*unavailableWeight + unplayed*playWeight
+	return report
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+)
+
+// Local stores objects as files below a directory.
+type Local struct {
+	Root string
+}
+
+// Put writes data to the file for key through a temporary file, so the
+// file is never seen half written. A file with the same content is left
+// alone.
+func (o Local) Put(ctx context.Context, key string, data []byte) (bool, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return false, err
+	}
+	name := filepath.Join(o.Root, filepath.FromSlash(key))
+	if existing, err := os.ReadFile(name); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return false, err
+	}
+	return true, atomicfile.WriteFile(name, data, 0o644)
+}
+
+// Location returns the path of the file for key.
+func (o Local) Location(key string) string {
+	return filepath.Join(o.Root, filepath.FromSlash(key))
+}
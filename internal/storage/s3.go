@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hashMeta is the user metadata header objects carry their SHA-256 in.
+const hashMeta = "x-amz-meta-sha256"
+
+// S3 stores objects in a bucket of Amazon S3 or an S3 compatible store
+// such as MinIO, Ceph or R2. Requests are signed with AWS signature
+// version 4.
+type S3 struct {
+	Bucket string
+	// Prefix is prepended to every key, e.g. "dumps/".
+	Prefix string
+	Region string
+	// Endpoint is the base URL of an S3 compatible store, addressed path
+	// style. Empty for Amazon S3.
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// NewS3FromEnv configures an S3 backend from the standard AWS variables:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION
+// (or AWS_DEFAULT_REGION, us-east-1 by default) and AWS_ENDPOINT_URL_S3
+// (or AWS_ENDPOINT_URL) for S3 compatible stores.
+func NewS3FromEnv(bucket string, prefix string) (*S3, error) {
+	s3 := &S3{
+		Bucket:          bucket,
+		Prefix:          prefix,
+		Region:          firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		Endpoint:        strings.TrimSuffix(firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"), "/"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s3.Region == "" {
+		s3.Region = "us-east-1"
+	}
+	if s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+		return nil, errors.New("s3 storage needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s3, nil
+}
+
+// Put uploads data under the prefixed key, unless the object already
+// exists with the same SHA-256.
+func (o *S3) Put(ctx context.Context, key string, data []byte) (bool, error) {
+	key, err := cleanKey(o.Prefix + key)
+	if err != nil {
+		return false, err
+	}
+	hash := Hash(data)
+
+	head, err := o.do(ctx, "HEAD", key, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	head.Body.Close()
+	switch head.StatusCode {
+	case http.StatusOK:
+		if head.Header.Get(hashMeta) == hash {
+			return false, nil
+		}
+	case http.StatusNotFound, http.StatusForbidden:
+		// missing objects are forbidden without s3:ListBucket
+	default:
+		return false, fmt.Errorf("s3 head s3://%s/%s: %s", o.Bucket, key, head.Status)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	resp, err := o.do(ctx, "PUT", key, data, map[string]string{
+		"Content-Type": contentType,
+		hashMeta:       hash,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("s3 put s3://%s/%s: %s: %s", o.Bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return true, nil
+}
+
+// Location returns the s3:// URL of key.
+func (o *S3) Location(key string) string {
+	return "s3://" + o.Bucket + "/" + o.Prefix + key
+}
+
+// objectURL returns the URL of an object. Amazon S3 is addressed virtual
+// host style unless the bucket name has dots, which break TLS.
+func (o *S3) objectURL(key string) string {
+	escaped := escapePath(key)
+	if o.Endpoint != "" {
+		return o.Endpoint + "/" + o.Bucket + "/" + escaped
+	}
+	if strings.Contains(o.Bucket, ".") {
+		return "https://s3." + o.Region + ".amazonaws.com/" + o.Bucket + "/" + escaped
+	}
+	return "https://" + o.Bucket + ".s3." + o.Region + ".amazonaws.com/" + escaped
+}
+
+// do sends a signed request for the object.
+func (o *S3) do(ctx context.Context, method string, key string, body []byte, headers map[string]string) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, o.objectURL(key), r)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	o.sign(req, Hash(body), time.Now().UTC())
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// sign adds the AWS signature version 4 headers to req.
+func (o *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if o.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", o.SessionToken)
+	}
+
+	// every header set is signed, along with the host
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	for name, value := range req.Header {
+		lower := strings.ToLower(name)
+		names = append(names, lower)
+		values[lower] = strings.TrimSpace(strings.Join(value, ","))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + o.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + Hash([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+o.SecretAccessKey), date)
+	key = hmacSHA256(key, o.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+o.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath escapes the segments of a key the way signature version 4
+// expects, everything but unreserved characters percent encoded.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+// Package storage writes dumps to where they are kept: a local directory
+// or an S3 compatible object store, so scheduled dumps can be archived
+// off the machine taking them.
+//
+// Objects are addressed by a slash separated key below the backend's
+// root. Every object is stored with the SHA-256 of its content, and
+// putting content an object already holds is skipped, so retried or
+// repeated uploads are idempotent.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Backend stores objects.
+type Backend interface {
+	// Put stores data under key. It returns false when the object already
+	// held the same content and nothing was written.
+	Put(ctx context.Context, key string, data []byte) (bool, error)
+	// Location describes where key is stored, for logs.
+	Location(key string) string
+}
+
+// Open returns the backend for a location: s3://bucket/prefix for an S3
+// bucket, configured from the environment (see NewS3FromEnv), and a
+// directory path or file:// URL otherwise.
+func Open(location string) (Backend, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// not a URL, or a windows drive letter
+		return Local{Root: location}, nil
+	}
+
+	switch u.Scheme {
+	case "file":
+		return Local{Root: u.Path}, nil
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("no bucket in %q, expected s3://bucket/prefix", location)
+		}
+		return NewS3FromEnv(u.Host, strings.TrimPrefix(u.Path, "/"))
+	}
+	return nil, fmt.Errorf("unsupported storage %q, expected a directory or s3://bucket/prefix", location)
+}
+
+// Split splits a location into the location of its directory and the
+// object key within it. A location ending in a slash, or a bare bucket,
+// has an empty key.
+func Split(location string) (dir string, key string) {
+	if strings.HasSuffix(location, "/") {
+		return location, ""
+	}
+	i := strings.LastIndex(location, "/")
+	if i < 0 {
+		return "", location
+	}
+	if strings.HasSuffix(location[:i+1], "://") {
+		return location, ""
+	}
+	return location[:i+1], location[i+1:]
+}
+
+// Hash returns the hex SHA-256 of data, which objects are stored with.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cleanKey checks an object key and strips leading slashes.
+func cleanKey(key string) (string, error) {
+	key = strings.TrimLeft(key, "/")
+	if key == "" || path.Clean("/"+key) != "/"+key {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return key, nil
+}
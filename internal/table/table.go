@@ -0,0 +1,230 @@
+// Package table renders the output of list commands: as columns aligned
+// and cut to fit the terminal, or as CSV for spreadsheets.
+package table
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Output formats of list commands. JSON is written by the commands
+// themselves, from their own types rather than a table's cells.
+const (
+	Text = "table"
+	JSON = "json"
+	CSV  = "csv"
+)
+
+// Formats are the output formats, as --output takes them.
+var Formats = []string{Text, JSON, CSV}
+
+// ParseFormat checks an output format.
+func ParseFormat(format string) (string, error) {
+	for _, f := range Formats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q, expected %s", format, strings.Join(Formats, ", "))
+}
+
+// gap is the space between columns.
+const gap = 2
+
+// minWidth is the narrowest a column is cut to.
+const minWidth = 8
+
+// Table is rows of cells under column headings.
+type Table struct {
+	// Title is printed above a table written as text, when set.
+	Title   string
+	Columns []string
+	Rows    [][]string
+	// HeaderStyle styles the column headings written as text when set,
+	// e.g. coloring them, after they are aligned.
+	HeaderStyle func(string) string
+	// fixed are the columns never cut, such as IDs which are no use cut
+	// short.
+	fixed map[int]bool
+}
+
+// New returns an empty table with the columns.
+func New(columns ...string) *Table {
+	return &Table{Columns: columns, fixed: map[int]bool{}}
+}
+
+// Fixed keeps the columns from being cut to fit the terminal.
+func (o *Table) Fixed(columns ...string) *Table {
+	for _, column := range columns {
+		for i, c := range o.Columns {
+			if c == column {
+				o.fixed[i] = true
+			}
+		}
+	}
+	return o
+}
+
+// Add adds a row, formatting each cell with fmt's %v.
+func (o *Table) Add(cells ...interface{}) {
+	row := make([]string, len(cells))
+	for i, cell := range cells {
+		if s, ok := cell.(string); ok {
+			row[i] = s
+		} else {
+			row[i] = fmt.Sprint(cell)
+		}
+	}
+	o.Rows = append(o.Rows, row)
+}
+
+// WriteText writes the table as aligned columns. Given a width, the
+// widest columns which aren't fixed are cut until the table fits it,
+// ending with an ellipsis where cut.
+func (o *Table) WriteText(w io.Writer, width int) error {
+	widths := o.widths()
+	if width > 0 {
+		o.shrink(widths, width)
+	}
+
+	var b strings.Builder
+	if o.Title != "" {
+		b.WriteString(o.Title + "\n")
+	}
+	writeRow := func(row []string, style func(string) string) {
+		var line strings.Builder
+		for i, cell := range row {
+			if i >= len(widths) {
+				break
+			}
+			cell = cut(cell, widths[i])
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-Width(cell)+gap))
+			}
+		}
+		text := strings.TrimRight(line.String(), " ")
+		if style != nil {
+			text = style(text)
+		}
+		b.WriteString(text + "\n")
+	}
+	if len(o.Columns) > 0 {
+		writeRow(o.Columns, o.HeaderStyle)
+	}
+	for _, row := range o.Rows {
+		writeRow(row, nil)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// widths returns the width of every column, that of its widest cell.
+func (o *Table) widths() []int {
+	widths := make([]int, len(o.Columns))
+	for _, row := range append([][]string{o.Columns}, o.Rows...) {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], Width(cell))
+		}
+	}
+	return widths
+}
+
+// shrink cuts the widest column which isn't fixed a column at a time
+// until the table fits width, or none can be cut any further.
+func (o *Table) shrink(widths []int, width int) {
+	total := func() int {
+		sum := 0
+		for _, w := range widths {
+			sum += w
+		}
+		return sum + gap*(len(widths)-1)
+	}
+	for total() > width {
+		widest := -1
+		for i, w := range widths {
+			if !o.fixed[i] && w > minWidth && (widest < 0 || w > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			return
+		}
+		widths[widest]--
+	}
+}
+
+// WriteCSV writes the table as CSV, a header row and the rows, without
+// the title.
+func (o *Table) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if len(o.Columns) > 0 {
+		cw.Write(o.Columns)
+	}
+	for _, row := range o.Rows {
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Width returns the columns s takes in a terminal, two for wide east
+// Asian characters and emoji, none for combining marks.
+func Width(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// wide are the ranges of runes taking two columns.
+var wide = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo
+	{0x2e80, 0x303e},   // CJK radicals and punctuation
+	{0x3041, 0xa4cf},   // Kana, CJK ideographs, Yi
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe30, 0xfe4f},   // CJK compatibility forms
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x1f300, 0x1faff}, // emoji
+	{0x20000, 0x3fffd}, // CJK extensions
+}
+
+// runeWidth returns the columns a rune takes.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me) || r == 0x200d || (r >= 0xfe00 && r <= 0xfe0f) {
+		return 0
+	}
+	for _, span := range wide {
+		if r >= span[0] && r <= span[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// cut shortens s to width columns, ending it with an ellipsis.
+func cut(s string, width int) string {
+	if Width(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String() + "…"
+}
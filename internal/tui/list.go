@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// List is a scrolling list of items, narrowed down as a query is typed.
+type List struct {
+	Title string
+	// Items are the lines listed, Search is what the query is matched
+	// against for each of them, the line itself when nil.
+	Items  []string
+	Search []string
+	Query  string
+	// Searching is true while a query is being typed.
+	Searching bool
+
+	matches []int
+	cursor  int
+	offset  int
+}
+
+// NewList returns a list of the items.
+func NewList(title string, items []string, search []string) *List {
+	l := &List{Title: title, Items: items, Search: search}
+	l.filter()
+	return l
+}
+
+// filter recomputes the items matching the query, keeping the selected
+// one selected when it still matches.
+func (o *List) filter() {
+	selected, hadSelection := o.Selected()
+	query := strings.ToLower(o.Query)
+	o.matches = o.matches[:0]
+	for i, item := range o.Items {
+		text := item
+		if o.Search != nil {
+			text = o.Search[i]
+		}
+		if query == "" || strings.Contains(strings.ToLower(text), query) {
+			o.matches = append(o.matches, i)
+		}
+	}
+	o.cursor, o.offset = 0, 0
+	if hadSelection {
+		for k, i := range o.matches {
+			if i == selected {
+				o.cursor = k
+			}
+		}
+	}
+}
+
+// Selected returns the index in Items of the selected item, false when
+// nothing matches the query.
+func (o *List) Selected() (int, bool) {
+	if o.cursor >= len(o.matches) {
+		return 0, false
+	}
+	return o.matches[o.cursor], true
+}
+
+// Handle applies a key press, reporting whether the list used it: moving
+// the selection, or editing the query while searching. / starts a search
+// and Escape clears it.
+func (o *List) Handle(key Key, page int) bool {
+	if o.Searching {
+		switch {
+		case key.Name == KeyEnter:
+			o.Searching = false
+		case key.Name == KeyEscape:
+			o.Searching = false
+			o.Query = ""
+			o.filter()
+		case key.Name == KeyBackspace:
+			if o.Query != "" {
+				runes := []rune(o.Query)
+				o.Query = string(runes[:len(runes)-1])
+				o.filter()
+			}
+		case key.Rune >= ' ':
+			o.Query += string(key.Rune)
+			o.filter()
+		default:
+			return o.move(key, page)
+		}
+		return true
+	}
+
+	switch {
+	case key.Rune == '/':
+		o.Searching = true
+	case key.Name == KeyEscape && o.Query != "":
+		o.Query = ""
+		o.filter()
+	case key.Rune == 'j':
+		return o.move(Key{Name: KeyDown}, page)
+	case key.Rune == 'k':
+		return o.move(Key{Name: KeyUp}, page)
+	default:
+		return o.move(key, page)
+	}
+	return true
+}
+
+// move moves the selection for the arrow and paging keys.
+func (o *List) move(key Key, page int) bool {
+	switch key.Name {
+	case KeyUp:
+		o.cursor--
+	case KeyDown:
+		o.cursor++
+	case KeyPageUp:
+		o.cursor -= page
+	case KeyPageDown:
+		o.cursor += page
+	case KeyHome:
+		o.cursor = 0
+	case KeyEnd:
+		o.cursor = len(o.matches) - 1
+	default:
+		return false
+	}
+	o.cursor = max(min(o.cursor, len(o.matches)-1), 0)
+	return true
+}
+
+// Lines renders the list in rows lines: the title, the visible items with
+// the selected one highlighted, and a status line.
+func (o *List) Lines(rows int, status string) []string {
+	visible := max(rows-2, 1)
+	if o.cursor < o.offset {
+		o.offset = o.cursor
+	}
+	if o.cursor >= o.offset+visible {
+		o.offset = o.cursor - visible + 1
+	}
+
+	title := o.Title
+	if o.Query != "" || o.Searching {
+		title += fmt.Sprintf("  /%s", o.Query)
+		if o.Searching {
+			title += "_"
+		}
+	}
+	lines := []string{"\x1b[1m" + title + "\x1b[0m"}
+	for k := o.offset; k < len(o.matches) && k < o.offset+visible; k++ {
+		line := "  " + o.Items[o.matches[k]]
+		if k == o.cursor {
+			line = "\x1b[7m> " + o.Items[o.matches[k]] + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < visible+1 {
+		lines = append(lines, "")
+	}
+	position := fmt.Sprintf("%d/%d", min(o.cursor+1, len(o.matches)), len(o.matches))
+	return append(lines, "\x1b[2m"+position+"  "+status+"\x1b[0m")
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("the terminal UI is only supported on unix systems")
+
+// makeRaw can't put the terminal into raw mode on this platform.
+func makeRaw(tty *os.File) (func() error, error) {
+	return nil, errUnsupported
+}
+
+// size can't tell the size of the terminal on this platform.
+func size(tty *os.File) (int, int, error) {
+	return 0, 0, errUnsupported
+}
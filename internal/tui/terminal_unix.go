@@ -0,0 +1,50 @@
+//go:build unix
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// stty runs stty on the terminal, returning its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// makeRaw puts the terminal into raw mode, returning the function
+// restoring the mode it was in.
+func makeRaw(tty *os.File) (func() error, error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := stty(tty, saved)
+		return err
+	}, nil
+}
+
+// size returns the rows and columns of the terminal.
+func size(tty *os.File) (int, int, error) {
+	out, err := stty(tty, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil {
+		return 0, 0, fmt.Errorf("stty size: %q: %w", out, err)
+	}
+	return rows, cols, nil
+}
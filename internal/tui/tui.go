@@ -0,0 +1,200 @@
+// Package tui is a minimal terminal UI: a raw mode terminal reading keys
+// and a searchable list drawn with ANSI escapes, enough to browse dumps
+// without pulling in a UI toolkit.
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Names of the special keys.
+const (
+	KeyUp        = "up"
+	KeyDown      = "down"
+	KeyLeft      = "left"
+	KeyRight     = "right"
+	KeyEnter     = "enter"
+	KeyBackspace = "backspace"
+	KeyEscape    = "esc"
+	KeyPageUp    = "pgup"
+	KeyPageDown  = "pgdown"
+	KeyHome      = "home"
+	KeyEnd       = "end"
+	KeyInterrupt = "ctrl-c"
+)
+
+// Key is a key pressed, a Rune typed or a special key Name.
+type Key struct {
+	Rune rune
+	Name string
+}
+
+// escapes are the escape sequences of the special keys.
+var escapes = map[string]string{
+	"[A": KeyUp, "[B": KeyDown, "[C": KeyRight, "[D": KeyLeft,
+	"OA": KeyUp, "OB": KeyDown, "OC": KeyRight, "OD": KeyLeft,
+	"[5~": KeyPageUp, "[6~": KeyPageDown,
+	"[H": KeyHome, "[F": KeyEnd, "[1~": KeyHome, "[4~": KeyEnd,
+}
+
+// Terminal is the controlling terminal in raw mode, drawn on an
+// alternate screen so the shell's is left as it was.
+type Terminal struct {
+	Rows int
+	Cols int
+
+	tty     *os.File
+	out     *bufio.Writer
+	restore func() error
+	pending []byte
+}
+
+// Open puts the controlling terminal into raw mode and switches to the
+// alternate screen. Close must be called to restore it.
+func Open() (*Terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal: %w", err)
+	}
+	restore, err := makeRaw(tty)
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	t := &Terminal{tty: tty, out: bufio.NewWriter(tty), restore: restore}
+	if err := t.Resize(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	return t, t.out.Flush()
+}
+
+// Close leaves the alternate screen and restores the terminal's mode.
+func (o *Terminal) Close() error {
+	o.out.WriteString("\x1b[?25h\x1b[?1049l")
+	o.out.Flush()
+	err := o.restore()
+	o.tty.Close()
+	return err
+}
+
+// Resize reads the terminal's size again.
+func (o *Terminal) Resize() error {
+	rows, cols, err := size(o.tty)
+	if err != nil {
+		return err
+	}
+	o.Rows, o.Cols = max(rows, 3), max(cols, 10)
+	return nil
+}
+
+// Size returns the rows and columns of the terminal f is, e.g. stdout
+// for output sized to the terminal.
+func Size(f *os.File) (int, int, error) {
+	return size(f)
+}
+
+// ReadKey waits for a key press. Keys arriving together, e.g. pasted
+// text, are returned one by one.
+func (o *Terminal) ReadKey() (Key, error) {
+	if len(o.pending) == 0 {
+		buf := make([]byte, 64)
+		n, err := o.tty.Read(buf)
+		if err != nil {
+			return Key{}, err
+		}
+		o.pending = buf[:n]
+	}
+	b := o.pending
+	key, n := Key{}, 1
+	switch {
+	case b[0] == 3:
+		key.Name = KeyInterrupt
+	case b[0] == '\r' || b[0] == '\n':
+		key.Name = KeyEnter
+	case b[0] == 127 || b[0] == 8:
+		key.Name = KeyBackspace
+	case b[0] == 0x1b:
+		key.Name = KeyEscape
+		for seq, name := range escapes {
+			if strings.HasPrefix(string(b[1:]), seq) {
+				key.Name, n = name, 1+len(seq)
+				break
+			}
+		}
+	default:
+		key.Rune, n = utf8.DecodeRune(b)
+	}
+	o.pending = b[n:]
+	return key, nil
+}
+
+// Draw replaces the screen with the lines, cut to the terminal's width.
+func (o *Terminal) Draw(lines []string) error {
+	o.out.WriteString("\x1b[H\x1b[2J")
+	for i, line := range lines {
+		if i >= o.Rows {
+			break
+		}
+		if i > 0 {
+			o.out.WriteString("\r\n")
+		}
+		o.out.WriteString(fit(line, o.Cols))
+	}
+	return o.out.Flush()
+}
+
+// fit cuts a line to width columns, ANSI escapes taking none.
+func fit(line string, width int) string {
+	var b strings.Builder
+	cols := 0
+	escape := false
+	for _, r := range line {
+		switch {
+		case r == 0x1b:
+			escape = true
+		case escape:
+			if r >= '@' && r <= '~' && r != '[' {
+				escape = false
+			}
+		default:
+			if cols == width {
+				b.WriteString("\x1b[0m")
+				return b.String()
+			}
+			cols++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// OpenURL opens the URL with the system's opener, e.g. a preview in the
+// browser or media player.
+func OpenURL(url string) error {
+	if url == "" {
+		return errors.New("nothing to open")
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
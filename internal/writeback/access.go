@@ -0,0 +1,99 @@
+package writeback
+
+import (
+	"fmt"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Access is what a user may change about a playlist. The owner may change
+// anything, collaborators of a collaborative playlist only its tracks,
+// and nobody else anything.
+type Access struct {
+	PlaylistID    string
+	Name          string
+	OwnerID       string `json:",omitempty"`
+	Owned         bool
+	Public        *bool `json:",omitempty"`
+	Collaborative bool
+	// Ops are the kinds of ops which may be applied to the playlist.
+	Ops []Kind
+	// Details tells whether the name, description, visibility and cover
+	// may be changed.
+	Details bool
+}
+
+// trackKinds are the ops changing the tracks of an existing playlist.
+var trackKinds = []Kind{Replace, Add, Remove, Reorder}
+
+// AccessOf returns what the user with the ID may change about a playlist
+// as listed by the API.
+func AccessOf(playlist spotify.SpotifyPlaylist, userID string) Access {
+	access := Access{
+		PlaylistID:    playlist.IntegrationID,
+		Name:          playlist.Name,
+		OwnerID:       playlist.Owner.IntegrationID,
+		Owned:         playlist.Owner.IntegrationID != "" && playlist.Owner.IntegrationID == userID,
+		Public:        playlist.Public,
+		Collaborative: playlist.Collaborative,
+	}
+	if access.Owned || access.Collaborative {
+		access.Ops = trackKinds
+	}
+	access.Details = access.Owned
+	return access
+}
+
+// Allows reports whether an op of the kind may be applied.
+func (o Access) Allows(kind Kind) bool {
+	switch kind {
+	case Visibility:
+		return o.Details
+	case Unfollow:
+		return true
+	}
+	for _, allowed := range o.Ops {
+		if allowed == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Denied is an op which can't be applied, and why.
+type Denied struct {
+	Op     Op
+	Reason string
+}
+
+func (o Denied) String() string {
+	return fmt.Sprintf("%s: %s", o.Op, o.Reason)
+}
+
+// Check splits the ops into those the user's access to the playlists,
+// keyed by playlist ID, allows and those it doesn't, so they can be
+// reported up front rather than fail halfway through applying a plan.
+// Creating a playlist is always allowed, as is unfollowing one in the
+// library, changing its visibility only to the owner.
+func Check(ops []Op, access map[string]Access) ([]Op, []Denied) {
+	var allowed []Op
+	var denied []Denied
+	for _, op := range ops {
+		if op.Kind == Create {
+			allowed = append(allowed, op)
+			continue
+		}
+		a, ok := access[op.PlaylistID]
+		switch {
+		case !ok:
+			denied = append(denied, Denied{op, "playlist isn't in the library"})
+		case op.Kind == Visibility && !a.Allows(op.Kind):
+			denied = append(denied, Denied{op, "playlist is owned by " + a.OwnerID})
+		case !a.Allows(op.Kind):
+			denied = append(denied, Denied{op, "playlist is owned by " + a.OwnerID + " and not collaborative"})
+		default:
+			allowed = append(allowed, op)
+		}
+	}
+	return allowed, denied
+}
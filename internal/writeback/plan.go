@@ -0,0 +1,197 @@
+package writeback
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// MaxMoves is the most moves a Reorder planned by Plan makes, a playlist
+// shuffled beyond that has its tracks replaced instead.
+const MaxMoves = 200
+
+// Plan returns the ops turning a playlist as it is into an edited version
+// of it, e.g. a dump edited by hand: removing the tracks no longer there,
+// appending the new ones and moving the tracks into the edited order, so
+// that the tracks kept keep when they were added. Local files and tracks
+// gone from Spotify can't be added, they are left out of the new order.
+//
+// A playlist whose edit can't be made that way, because a track occurs a
+// different number of times, a local file is removed or it takes more
+// than MaxMoves moves, is replaced whole instead.
+func Plan(current spotify.MusicPlaylist, edited spotify.MusicPlaylist) []Op {
+	replace := []Op{{Kind: Replace, PlaylistID: current.IntegrationID, Name: edited.Name, Tracks: edited.Tracks}}
+
+	currentCount := map[string]int{}
+	for _, track := range current.Tracks {
+		currentCount[trackKey(track)]++
+	}
+	editedCount := map[string]int{}
+	for _, track := range edited.Tracks {
+		editedCount[trackKey(track)]++
+	}
+
+	var removed, added []spotify.MusicTrack
+	seen := map[string]bool{}
+	var order []spotify.MusicTrack
+	for _, track := range current.Tracks {
+		key := trackKey(track)
+		n, kept := editedCount[key]
+		switch {
+		case kept && n != currentCount[key]:
+			return replace
+		case kept:
+			order = append(order, track)
+		case !writable(track):
+			// can't be removed by URI
+			return replace
+		case !seen[key]:
+			seen[key] = true
+			removed = append(removed, track)
+		}
+	}
+	var target []spotify.MusicTrack
+	for _, track := range edited.Tracks {
+		_, kept := currentCount[trackKey(track)]
+		if !kept && !writable(track) {
+			continue
+		}
+		if !kept {
+			added = append(added, track)
+			order = append(order, track)
+		}
+		target = append(target, track)
+	}
+
+	var ops []Op
+	if len(removed) > 0 {
+		ops = append(ops, Op{Kind: Remove, PlaylistID: current.IntegrationID, Name: edited.Name, Tracks: removed})
+	}
+	if len(added) > 0 {
+		ops = append(ops, Op{Kind: Add, PlaylistID: current.IntegrationID, Name: edited.Name, Tracks: added})
+	}
+	moves, ok := planMoves(order, target)
+	if !ok {
+		return replace
+	}
+	if len(moves) > 0 {
+		ops = append(ops, Op{Kind: Reorder, PlaylistID: current.IntegrationID, Name: edited.Name, Moves: moves})
+	}
+	return ops
+}
+
+// writable reports whether a track can be added or removed through the
+// API.
+func writable(track spotify.MusicTrack) bool {
+	return track.Source != spotify.SourceLocal && track.IntegrationID != ""
+}
+
+// trackKey identifies a track within a playlist, by URI or, for tracks
+// without one, by name.
+func trackKey(track spotify.MusicTrack) string {
+	if writable(track) {
+		return track.URI()
+	}
+	return fmt.Sprintf("unwritable:%s\x00%s", track.Name, track.Artists)
+}
+
+// planMoves returns the moves putting the tracks of order, holding the
+// same tracks as target, into target's order, or false when it takes more
+// than MaxMoves. The longest run of tracks already in order stays put,
+// every other track is moved right after the track preceding it in
+// target, in target order.
+func planMoves(order []spotify.MusicTrack, target []spotify.MusicTrack) ([]Move, bool) {
+	// repeats of a track are told apart by occurrence
+	ids := func(tracks []spotify.MusicTrack) []string {
+		occurrences := map[string]int{}
+		out := make([]string, len(tracks))
+		for i, track := range tracks {
+			key := trackKey(track)
+			occurrences[key]++
+			out[i] = fmt.Sprintf("%s#%d", key, occurrences[key])
+		}
+		return out
+	}
+	current, wanted := ids(order), ids(target)
+	if len(current) != len(wanted) {
+		return nil, false
+	}
+	targetIndex := make(map[string]int, len(wanted))
+	for i, id := range wanted {
+		targetIndex[id] = i
+	}
+	positions := make([]int, len(current))
+	for i, id := range current {
+		positions[i] = targetIndex[id]
+	}
+	stays := longestIncreasing(positions)
+	if len(current)-len(stays) > MaxMoves {
+		return nil, false
+	}
+
+	var moves []Move
+	for i, id := range wanted {
+		if stays[i] {
+			continue
+		}
+		from := indexOf(current, id)
+		before := 0
+		if i > 0 {
+			before = indexOf(current, wanted[i-1]) + 1
+		}
+		if from == before {
+			// already right after its predecessor
+			continue
+		}
+		moves = append(moves, Move{From: from, Before: before})
+
+		// make the move on the IDs for the next ones
+		current = append(current[:from], current[from+1:]...)
+		if before > from {
+			before--
+		}
+		current = append(current[:before], append([]string{id}, current[before:]...)...)
+	}
+	return moves, true
+}
+
+// longestIncreasing returns the values of the longest increasing
+// subsequence of positions.
+func longestIncreasing(positions []int) map[int]bool {
+	// tails[k] is the index in positions ending the best run of k+1
+	var tails []int
+	previous := make([]int, len(positions))
+	for i, p := range positions {
+		k := sort.Search(len(tails), func(k int) bool { return positions[tails[k]] >= p })
+		if k > 0 {
+			previous[i] = tails[k-1]
+		} else {
+			previous[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	stays := map[int]bool{}
+	if len(tails) == 0 {
+		return stays
+	}
+	for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+		stays[positions[i]] = true
+	}
+	return stays
+}
+
+// indexOf returns the index of id in ids, -1 when it isn't there.
+func indexOf(ids []string, id string) int {
+	for i, other := range ids {
+		if other == id {
+			return i
+		}
+	}
+	return -1
+}
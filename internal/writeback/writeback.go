@@ -0,0 +1,191 @@
+// Package writeback describes changes to a user's playlists as a list of
+// operations, so bulk commands can print, simulate or apply the same plan.
+package writeback
+
+import (
+	"fmt"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// Kind is the kind of change an Op makes.
+type Kind string
+
+// The operations a plan is made of.
+const (
+	// Replace replaces all tracks of an existing playlist.
+	Replace Kind = "replace"
+	// Create creates a new playlist holding the tracks.
+	Create Kind = "create"
+	// Add appends the tracks to an existing playlist.
+	Add Kind = "add"
+	// Remove removes every occurrence of the tracks from a playlist.
+	Remove Kind = "remove"
+	// Reorder moves tracks within a playlist, keeping when they were
+	// added.
+	Reorder Kind = "reorder"
+	// Visibility makes a playlist public or private and collaborative or
+	// not, as Public and Collaborative say.
+	Visibility Kind = "visibility"
+	// Unfollow removes a playlist from the user's library.
+	Unfollow Kind = "unfollow"
+)
+
+// Op is a single change to a playlist.
+type Op struct {
+	Kind Kind
+	// PlaylistID is the playlist changed, empty for Create.
+	PlaylistID string `json:",omitempty"`
+	Name       string
+	Public     bool `json:",omitempty"`
+	// Collaborative is whether a Visibility op makes the playlist
+	// collaborative.
+	Collaborative bool                 `json:",omitempty"`
+	Tracks        []spotify.MusicTrack `json:",omitempty"`
+	// Moves are the moves of a Reorder, made in order.
+	Moves []Move `json:",omitempty"`
+}
+
+// Move moves a track of a playlist to another position, both counting
+// from 0 in the playlist as it is before the move. Before is the
+// position the track is put in front of, the length of the playlist to
+// put it last.
+type Move struct {
+	From   int
+	Before int
+}
+
+// apply makes the move on tracks.
+func (o Move) apply(tracks []spotify.MusicTrack) []spotify.MusicTrack {
+	if o.From < 0 || o.From >= len(tracks) || o.Before < 0 || o.Before > len(tracks) {
+		return tracks
+	}
+	track := tracks[o.From]
+	moved := append(append([]spotify.MusicTrack(nil), tracks[:o.From]...), tracks[o.From+1:]...)
+	before := o.Before
+	if before > o.From {
+		before--
+	}
+	moved = append(moved[:before], append([]spotify.MusicTrack{track}, moved[before:]...)...)
+	return moved
+}
+
+// URIs returns the URIs of the op's tracks which can be written through
+// the API and the number of local files left out.
+func (o Op) URIs() ([]string, int) {
+	return URIs(o.Tracks)
+}
+
+// URIs returns the URIs of the tracks which can be written through the
+// API and the number of local files left out. Tracks without an ID are
+// left out too.
+func URIs(tracks []spotify.MusicTrack) ([]string, int) {
+	var uris []string
+	local := 0
+	for _, track := range tracks {
+		if track.Source == spotify.SourceLocal {
+			local++
+			continue
+		}
+		if track.IntegrationID == "" {
+			continue
+		}
+		uris = append(uris, track.URI())
+	}
+	return uris, local
+}
+
+func (o Op) String() string {
+	switch o.Kind {
+	case Create:
+		return fmt.Sprintf("create %q with %d tracks", o.Name, len(o.Tracks))
+	case Replace:
+		return fmt.Sprintf("replace the tracks of %q (%s) with %d tracks", o.Name, o.PlaylistID, len(o.Tracks))
+	case Reorder:
+		return fmt.Sprintf("reorder %q (%s) in %d moves", o.Name, o.PlaylistID, len(o.Moves))
+	case Unfollow:
+		return fmt.Sprintf("unfollow %q (%s)", o.Name, o.PlaylistID)
+	case Visibility:
+		return fmt.Sprintf("make %q (%s) %s", o.Name, o.PlaylistID, VisibilityName(o.Public, o.Collaborative))
+	}
+	return fmt.Sprintf("%s %d tracks to %q (%s)", o.Kind, len(o.Tracks), o.Name, o.PlaylistID)
+}
+
+// VisibilityName names the visibility of a playlist: public, private or
+// collaborative, which is private too.
+func VisibilityName(public bool, collaborative bool) string {
+	switch {
+	case collaborative:
+		return "collaborative"
+	case public:
+		return "public"
+	}
+	return "private"
+}
+
+// Simulate applies the ops to a copy of playlists and returns the
+// resulting playlists, leaving playlists itself untouched. Unfollowed
+// playlists are left out. Created
+// playlists are given placeholder IDs, simulated-1 and so on.
+func Simulate(playlists []spotify.MusicPlaylist, ops []Op) []spotify.MusicPlaylist {
+	result := make([]spotify.MusicPlaylist, len(playlists))
+	index := map[string]int{}
+	for i, mp := range playlists {
+		mp.Tracks = append([]spotify.MusicTrack(nil), mp.Tracks...)
+		result[i] = mp
+		index[mp.IntegrationID] = i
+	}
+
+	created := 0
+	unfollowed := map[string]bool{}
+	for _, op := range ops {
+		i, ok := index[op.PlaylistID]
+		if op.Kind == Create || !ok {
+			created++
+			id := fmt.Sprintf("simulated-%d", created)
+			result = append(result, spotify.MusicPlaylist{Name: op.Name, IntegrationID: id})
+			i = len(result) - 1
+			index[id] = i
+		}
+
+		mp := &result[i]
+		switch op.Kind {
+		case Create, Replace:
+			mp.Tracks = append([]spotify.MusicTrack(nil), op.Tracks...)
+		case Add:
+			mp.Tracks = append(mp.Tracks, op.Tracks...)
+		case Remove:
+			remove := map[string]bool{}
+			for _, track := range op.Tracks {
+				remove[track.URI()] = true
+			}
+			kept := mp.Tracks[:0]
+			for _, track := range mp.Tracks {
+				if !remove[track.URI()] {
+					kept = append(kept, track)
+				}
+			}
+			mp.Tracks = kept
+		case Reorder:
+			for _, move := range op.Moves {
+				mp.Tracks = move.apply(mp.Tracks)
+			}
+		case Visibility:
+			public := op.Public
+			mp.Public = &public
+			mp.Collaborative = op.Collaborative
+		case Unfollow:
+			unfollowed[mp.IntegrationID] = true
+		}
+		// the playlist changed, so its old version no longer applies
+		mp.SnapshotID = ""
+	}
+
+	kept := result[:0]
+	for _, mp := range result {
+		if !unfollowed[mp.IntegrationID] {
+			kept = append(kept, mp)
+		}
+	}
+	return kept
+}
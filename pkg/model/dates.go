@@ -0,0 +1,98 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precisions of a release date, as spotify's release_date_precision
+// gives them.
+const (
+	PrecisionYear  = "year"
+	PrecisionMonth = "month"
+	PrecisionDay   = "day"
+)
+
+// ReleaseDate is a parsed release date, known to the year, month or day.
+// Month and Day are 0 below their precision.
+type ReleaseDate struct {
+	Year      int
+	Month     int `json:",omitempty"`
+	Day       int `json:",omitempty"`
+	Precision string
+}
+
+// ParseReleaseDate parses a release date as spotify sends it: 1981,
+// 1981-12 or 1981-12-15. precision may be empty, it is then taken from the
+// date itself. It returns nil for an empty date, or spotify's 0000 for an
+// unknown one, and for anything it can't parse or which doesn't exist,
+// such as 1981-02-31.
+func ParseReleaseDate(date string, precision string) *ReleaseDate {
+	parts := strings.Split(date, "-")
+	if date == "" || len(parts) > 3 {
+		return nil
+	}
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil
+		}
+		numbers[i] = n
+	}
+
+	rd := &ReleaseDate{Year: numbers[0], Precision: PrecisionYear}
+	if len(numbers) > 1 && precision != PrecisionYear {
+		rd.Month = numbers[1]
+		rd.Precision = PrecisionMonth
+	}
+	if len(numbers) > 2 && precision != PrecisionYear && precision != PrecisionMonth {
+		rd.Day = numbers[2]
+		rd.Precision = PrecisionDay
+	}
+	if rd.Year == 0 || rd.Month > 12 || (rd.Precision != PrecisionYear && rd.Month == 0) {
+		return nil
+	}
+	// a day must exist in its month, 1981-02-31 doesn't
+	if rd.Precision == PrecisionDay {
+		if _, err := time.Parse("2006-01-02", rd.String()); err != nil {
+			return nil
+		}
+	}
+	return rd
+}
+
+// Time returns the first instant of the release date, in UTC.
+func (o ReleaseDate) Time() time.Time {
+	month, day := time.Month(max(o.Month, 1)), max(o.Day, 1)
+	return time.Date(o.Year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// String formats the date to its precision, as spotify does.
+func (o ReleaseDate) String() string {
+	switch o.Precision {
+	case PrecisionDay:
+		return fmt.Sprintf("%04d-%02d-%02d", o.Year, o.Month, o.Day)
+	case PrecisionMonth:
+		return fmt.Sprintf("%04d-%02d", o.Year, o.Month)
+	}
+	return fmt.Sprintf("%04d", o.Year)
+}
+
+// FormatDuration formats a track length as m:ss, or h:mm:ss past an hour.
+// Zero formats as an empty string.
+func FormatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d%time.Hour) / int(time.Minute)
+	s := int(d%time.Minute) / int(time.Second)
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
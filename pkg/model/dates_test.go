@@ -0,0 +1,58 @@
+package model
+
+import "testing"
+
+func TestParseReleaseDate(t *testing.T) {
+	tests := []struct {
+		date      string
+		precision string
+		want      string // the parsed date's String, empty for nil
+		wantPrec  string
+	}{
+		// year precision
+		{"1981", PrecisionYear, "1981", PrecisionYear},
+		{"1981", "", "1981", PrecisionYear},
+		{"1981-12-15", PrecisionYear, "1981", PrecisionYear},
+		// year-month precision
+		{"1981-12", PrecisionMonth, "1981-12", PrecisionMonth},
+		{"1981-02", "", "1981-02", PrecisionMonth},
+		{"1981-12-15", PrecisionMonth, "1981-12", PrecisionMonth},
+		// full dates
+		{"1981-12-15", PrecisionDay, "1981-12-15", PrecisionDay},
+		{"1981-12-15", "", "1981-12-15", PrecisionDay},
+		{"2000-02-29", PrecisionDay, "2000-02-29", PrecisionDay},
+		// a precision finer than the date falls back to the date's
+		{"1981", PrecisionDay, "1981", PrecisionYear},
+		{"1981-12", PrecisionDay, "1981-12", PrecisionMonth},
+		// invalid
+		{"", "", "", ""},
+		{"0000", PrecisionYear, "", ""},
+		{"1981-02-31", PrecisionDay, "", ""},
+		{"1900-02-29", PrecisionDay, "", ""},
+		{"1981-04-31", "", "", ""},
+		{"1981-13", PrecisionMonth, "", ""},
+		{"1981-00", PrecisionMonth, "", ""},
+		{"1981-12-00", PrecisionDay, "", ""},
+		{"1981-12-32", PrecisionDay, "", ""},
+		{"1981-12-15-01", "", "", ""},
+		{"1981-xx", "", "", ""},
+		{"-1981", "", "", ""},
+		{"nineteen", PrecisionYear, "", ""},
+	}
+	for _, tt := range tests {
+		rd := ParseReleaseDate(tt.date, tt.precision)
+		if tt.want == "" {
+			if rd != nil {
+				t.Errorf("ParseReleaseDate(%q, %q) = %v, want nil", tt.date, tt.precision, rd)
+			}
+			continue
+		}
+		if rd == nil {
+			t.Errorf("ParseReleaseDate(%q, %q) = nil, want %s", tt.date, tt.precision, tt.want)
+			continue
+		}
+		if rd.String() != tt.want || rd.Precision != tt.wantPrec {
+			t.Errorf("ParseReleaseDate(%q, %q) = %s to the %s, want %s to the %s", tt.date, tt.precision, rd, rd.Precision, tt.want, tt.wantPrec)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package model holds the types of the playlists, tracks and albums
+// spdump writes, for programs reading its dumps back:
+//
+//	var playlist model.MusicPlaylist
+//	if err := json.Unmarshal(data, &playlist); err != nil {
+//		return err
+//	}
+//	for _, track := range playlist.Tracks {
+//		fmt.Println(track.Name, track.Artists, track.Length())
+//	}
+//
+// It only depends on the standard library, unlike the API client in
+// package spotify, whose MusicX types are aliases of these.
+package model
+
+import "time"
+
+// Types of a MusicTrack.
+const (
+	TypeTrack   = "track"
+	TypeEpisode = "episode"
+)
+
+// Sources of dumped tracks.
+const (
+	SourceSpotify = "spotify"
+	// SourceLocal marks a local file the playlist owner added from their
+	// own library, which cannot be played or added by anyone else.
+	SourceLocal = "local"
+)
+
+// Reasons a track is unavailable, besides the restriction reasons Spotify
+// reports itself: "market", "product" or "explicit".
+const (
+	// UnavailableNotPlayable is a track not playable in the requested
+	// market without Spotify saying why.
+	UnavailableNotPlayable = "not_playable"
+	// UnavailableNoMarkets is a track available in no market at all.
+	UnavailableNoMarkets = "no_markets"
+	// UnavailableRemoved is a playlist item whose track was taken off
+	// Spotify altogether.
+	UnavailableRemoved = "removed"
+)
+
+// PreviewFromEmbed marks a PreviewURL found in the embed player rather
+// than given by the API.
+const PreviewFromEmbed = "embed"
+
+// Image is a cover art image in one of its sizes.
+type Image struct {
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+	URL    string `json:"url"`
+}
+
+// MusicTrack stores the spotify result in a format which can be easily Marshaled.
+// Episodes have Type "episode" and carry their show and release date.
+type MusicTrack struct {
+	Type       string `json:",omitempty"`
+	Name       string
+	PreviewURL string
+	// PreviewSource is "embed" when PreviewURL was found in the embed
+	// player, best effort, rather than given by the API.
+	PreviewSource    string `json:",omitempty"`
+	AlbumName        string
+	AlbumID          string `json:",omitempty"`
+	AlbumArt         []Image
+	AlbumReleaseDate string
+	ShowName         string `json:",omitempty"`
+	ReleaseDate      string `json:",omitempty"`
+	DurationMS       int    `json:",omitempty"`
+	// Duration is DurationMS formatted as m:ss, or h:mm:ss past an hour.
+	Duration string `json:",omitempty"`
+	// Released is the release date of the album, or the episode, parsed
+	// from the raw AlbumReleaseDate or ReleaseDate.
+	Released *ReleaseDate `json:",omitempty"`
+	// DiscNumber and TrackNumber are where the track is on its album,
+	// counting from 1.
+	DiscNumber    int    `json:",omitempty"`
+	TrackNumber   int    `json:",omitempty"`
+	ISRC          string `json:",omitempty"`
+	IntegrationID string
+	Source        string
+	ExternalURL   string
+	Artists       string
+	ArtistList    []MusicArtist `json:",omitempty"`
+	AddedAt       *time.Time    `json:",omitempty"`
+	IsPlayable    *bool         `json:",omitempty"`
+	Explicit      bool          `json:",omitempty"`
+	// AddedBy is the ID of the user who added the track to the
+	// playlist, kept for collaborative playlists.
+	AddedBy string `json:",omitempty"`
+	// PlayedAt is when the track was played, in listening history.
+	PlayedAt *time.Time `json:",omitempty"`
+	// Unavailable is why the track is greyed out, e.g. "market" or
+	// "removed", empty when it plays. See the Unavailable constants.
+	Unavailable string `json:",omitempty"`
+	// LinkedFrom is the ID of the track originally added, when Spotify
+	// relinked it to the playable version in IntegrationID.
+	LinkedFrom string `json:",omitempty"`
+	// Genres are the genres of the track's artists, only looked up on
+	// request as Spotify keeps genres on artists.
+	Genres []string `json:",omitempty"`
+	// Language is the ISO 639-1 code of the language the track is
+	// guessed to be in from its title, only guessed on request.
+	Language string `json:",omitempty"`
+	// Purchase are the stores selling the track's album, only looked up
+	// on request.
+	Purchase []PurchaseLink `json:",omitempty"`
+	// Computed are the fields computed by expressions in the config,
+	// by name.
+	Computed map[string]interface{} `json:",omitempty"`
+}
+
+// PurchaseLink is a store selling an album: a match in its catalog, or a
+// search for the album when the store wasn't asked.
+type PurchaseLink struct {
+	Store string
+	URL   string
+	// Price and Currency are what the album costs in the market looked
+	// up, e.g. "9.99" and "USD", when the store was asked.
+	Price    string `json:",omitempty"`
+	Currency string `json:",omitempty"`
+}
+
+// MusicAlbum stores details of Albums for further browsing.
+type MusicAlbum struct {
+	Name          string
+	AlbumArt      []Image
+	ReleaseDate   string
+	Released      *ReleaseDate  `json:",omitempty"`
+	AlbumGroup    string        `json:",omitempty"`
+	Artists       []MusicArtist `json:",omitempty"`
+	Tracks        []MusicTrack  `json:",omitempty"`
+	IntegrationID string
+}
+
+// MusicPlaylist stores details of Playlist for further browsing.
+type MusicPlaylist struct {
+	Name          string
+	Description   string     `json:",omitempty"`
+	Owner         *MusicUser `json:",omitempty"`
+	Public        *bool      `json:",omitempty"`
+	Collaborative bool       `json:",omitempty"`
+	Followers     int        `json:",omitempty"`
+	PlaylistArt   []Image
+	Tracks        []MusicTrack `json:",omitempty"`
+	IntegrationID string
+	// SnapshotID is the version of the playlist, it changes whenever
+	// the playlist is modified.
+	SnapshotID string `json:",omitempty"`
+	// Genres counts the tracks of each genre, when genres were looked
+	// up.
+	Genres map[string]int `json:",omitempty"`
+}
+
+// MusicUser describes the owner of a playlist.
+type MusicUser struct {
+	Name          string `json:",omitempty"`
+	IntegrationID string
+}
+
+// MusicArtist describes a music artist in a generic way.
+type MusicArtist struct {
+	Name          string
+	IntegrationID string
+	Genres        []string `json:",omitempty"`
+	ArtistArt     []Image  `json:",omitempty"`
+	ExternalURL   string   `json:",omitempty"`
+}
+
+// URI returns the spotify URI of the track or episode.
+func (o *MusicTrack) URI() string {
+	if o.Type == TypeEpisode {
+		return "spotify:episode:" + o.IntegrationID
+	}
+	return "spotify:track:" + o.IntegrationID
+}
+
+// Dead reports whether the track can't be played on Spotify, so would
+// be lost for good along with the account.
+func (o *MusicTrack) Dead() bool {
+	return o.Unavailable != ""
+}
+
+// Length returns the length of the track.
+func (o MusicTrack) Length() time.Duration {
+	return time.Duration(o.DurationMS) * time.Millisecond
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// Compare orders release dates, returning -1, 0 or 1. Dates are compared
+// as far as both are known, and when they agree that far the less
+// precise one comes first: 1997 before 1997-05 before 1997-05-21, the way
+// an album announced for a year is listed ahead of that year's dated
+// releases.
+func (o ReleaseDate) Compare(other ReleaseDate) int {
+	for _, pair := range [][2]int{{o.Year, other.Year}, {o.Month, other.Month}, {o.Day, other.Day}} {
+		switch {
+		case pair[0] < pair[1]:
+			return -1
+		case pair[0] > pair[1]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// releaseOf returns the parsed release date of a track, parsing the raw
+// one when Released wasn't filled in, nil when it is unknown.
+func releaseOf(track MusicTrack) *ReleaseDate {
+	if track.Released != nil {
+		return track.Released
+	}
+	if track.AlbumReleaseDate != "" {
+		return ParseReleaseDate(track.AlbumReleaseDate, "")
+	}
+	return ParseReleaseDate(track.ReleaseDate, "")
+}
+
+// compareReleases orders release dates with unknown ones last.
+func compareReleases(a, b *ReleaseDate) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+// ChronologicalLess reports whether track a comes before b in release
+// order: by the release date of their albums, then keeping the tracks of
+// an album together, by disc and track number.
+func ChronologicalLess(a, b MusicTrack) bool {
+	if c := compareReleases(releaseOf(a), releaseOf(b)); c != 0 {
+		return c < 0
+	}
+	if a.AlbumID != b.AlbumID {
+		return strings.ToLower(a.AlbumName)+a.AlbumID < strings.ToLower(b.AlbumName)+b.AlbumID
+	}
+	if a.DiscNumber != b.DiscNumber {
+		return a.DiscNumber < b.DiscNumber
+	}
+	return a.TrackNumber < b.TrackNumber
+}
+
+// SortChronological sorts tracks in release order, see
+// ChronologicalLess. Tracks alike keep their order.
+func SortChronological(tracks []MusicTrack) {
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return ChronologicalLess(tracks[i], tracks[j])
+	})
+}
+
+// SortAlbumsChronological sorts albums oldest first, and the tracks of
+// each by disc and track number. Albums released alike keep their order.
+func SortAlbumsChronological(albums []MusicAlbum) {
+	sort.SliceStable(albums, func(i, j int) bool {
+		a, b := albums[i].Released, albums[j].Released
+		if a == nil {
+			a = ParseReleaseDate(albums[i].ReleaseDate, "")
+		}
+		if b == nil {
+			b = ParseReleaseDate(albums[j].ReleaseDate, "")
+		}
+		return compareReleases(a, b) < 0
+	})
+	for _, album := range albums {
+		SortChronological(album.Tracks)
+	}
+}
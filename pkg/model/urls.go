@@ -0,0 +1,62 @@
+package model
+
+import (
+	"net/url"
+	"strings"
+)
+
+// spotifyHosts are the hosts which serve the same content as open.spotify.com.
+var spotifyHosts = map[string]bool{
+	"open.spotify.com": true,
+	"play.spotify.com": true,
+	"www.spotify.com":  true,
+}
+
+// NormalizeURL rewrites a spotify link to its canonical open.spotify.com
+// form, dropping locale prefixes such as /intl-de/ and, unless keepQuery is
+// set, the query string which carries the si= share tracking token.
+// Links to other hosts only have their query stripped.
+func NormalizeURL(raw string, keepQuery bool) string {
+	if raw == "" {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	if spotifyHosts[strings.ToLower(u.Host)] {
+		u.Scheme = "https"
+		u.Host = "open.spotify.com"
+		u.Path = stripLocalePrefix(u.Path)
+	}
+
+	if !keepQuery {
+		u.RawQuery = ""
+	}
+	u.Fragment = ""
+
+	return u.String()
+}
+
+// stripLocalePrefix removes the /intl-xx/ segment spotify adds to shared links.
+func stripLocalePrefix(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) == 2 && strings.HasPrefix(parts[0], "intl-") {
+		return "/" + parts[1]
+	}
+	return path
+}
+
+// NormalizeURLs normalizes the external URLs of every track in the playlist.
+func (o *MusicPlaylist) NormalizeURLs(keepQuery bool) {
+	for i := range o.Tracks {
+		o.Tracks[i].NormalizeURLs(keepQuery)
+	}
+}
+
+// NormalizeURLs normalizes the external URL of the track.
+func (o *MusicTrack) NormalizeURLs(keepQuery bool) {
+	o.ExternalURL = NormalizeURL(o.ExternalURL, keepQuery)
+}
@@ -0,0 +1,306 @@
+// Package pipeline transforms dumped playlists with a list of named,
+// configurable steps, the way spdump enriches and filters what it dumps.
+// Steps are registered by name, so a program can add its own and compose
+// them with the built in ones, or with those spdump adds itself:
+//
+//	pipeline.Register("uppercase", func(config pipeline.Config) (pipeline.Step, error) {
+//		return pipeline.StepFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+//			mp.Name = strings.ToUpper(mp.Name)
+//			return nil
+//		}), nil
+//	})
+//	p, err := pipeline.Build([]pipeline.Spec{{Name: "dedupe"}, {Name: "uppercase"}})
+//	if err != nil {
+//		return err
+//	}
+//	err = p.Apply(ctx, &playlist)
+//
+// A step is written as a Spec, its name and its configuration, e.g.
+// "sort:by=added,reverse" from a flag or config file.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// Step transforms a playlist in place: its tracks, which it may reorder,
+// drop or enrich, or the playlist itself.
+type Step interface {
+	Apply(ctx context.Context, mp *model.MusicPlaylist) error
+}
+
+// StepFunc adapts a function to a Step.
+type StepFunc func(ctx context.Context, mp *model.MusicPlaylist) error
+
+// Apply calls the function.
+func (o StepFunc) Apply(ctx context.Context, mp *model.MusicPlaylist) error {
+	return o(ctx, mp)
+}
+
+// PerTrack is implemented by steps which may treat every track on its
+// own, so that running them on a playlist a track at a time, as dumps
+// streamed track by track do, gives the tracks running them on the whole
+// playlist would. Steps without it, such as sort and limit, look at the
+// whole playlist.
+type PerTrack interface {
+	Step
+	PerTrack() bool
+}
+
+// TrackFunc adapts a function treating every track of a playlist on its
+// own to a Step which is PerTrack.
+type TrackFunc func(ctx context.Context, mp *model.MusicPlaylist) error
+
+// Apply calls the function.
+func (o TrackFunc) Apply(ctx context.Context, mp *model.MusicPlaylist) error {
+	return o(ctx, mp)
+}
+
+// PerTrack reports true.
+func (o TrackFunc) PerTrack() bool {
+	return true
+}
+
+// IsPerTrack reports whether step may run on a track at a time, see
+// PerTrack.
+func IsPerTrack(step Step) bool {
+	p, ok := step.(PerTrack)
+	return ok && p.PerTrack()
+}
+
+// Config is the configuration of a step, its settings by name.
+type Config map[string]string
+
+// String returns the setting key, or def when unset.
+func (o Config) String(key string, def string) string {
+	if value, ok := o[key]; ok {
+		return value
+	}
+	return def
+}
+
+// Int returns the setting key as a number, or def when unset.
+func (o Config) Int(key string, def int) (int, error) {
+	value, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a number", key, value)
+	}
+	return n, nil
+}
+
+// Bool returns the setting key as a boolean, or def when unset. A key
+// given without a value is true.
+func (o Config) Bool(key string, def bool) (bool, error) {
+	value, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	if value == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %q is not true or false", key, value)
+	}
+	return b, nil
+}
+
+// Factory builds a step from its configuration.
+type Factory func(config Config) (Step, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a step available by name. It panics when the name is
+// taken, as registering twice is a programming error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic("pipeline: step " + name + " registered twice")
+	}
+	factories[name] = factory
+}
+
+// Names returns the names of the registered steps, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the step registered as name.
+func New(name string, config Config) (Step, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown pipeline step %q, known are %s", name, strings.Join(Names(), ", "))
+	}
+	step, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline step %s: %w", name, err)
+	}
+	return step, nil
+}
+
+// Spec names a step and configures it.
+type Spec struct {
+	Name   string
+	Config Config
+}
+
+// ParseSpec parses a step written as its name, optionally followed by a
+// colon and its settings separated by commas, a setting given without a
+// value being true:
+//
+//	dedupe
+//	sort:by=added,reverse
+//	filter:expr=year >= 2020
+//
+// A part which is neither a setting nor a bare word continues the value
+// before it, so values may hold commas.
+func ParseSpec(s string) (Spec, error) {
+	name, settings, _ := strings.Cut(s, ":")
+	spec := Spec{Name: strings.TrimSpace(name)}
+	if spec.Name == "" {
+		return spec, fmt.Errorf("pipeline step %q has no name", s)
+	}
+	if strings.TrimSpace(settings) == "" {
+		return spec, nil
+	}
+	spec.Config = Config{}
+	last := ""
+	for _, part := range strings.Split(settings, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		switch {
+		case ok && strings.TrimSpace(key) != "":
+			last = strings.TrimSpace(key)
+			spec.Config[last] = strings.TrimSpace(value)
+		case !ok && isWord(part):
+			last = ""
+			spec.Config[part] = ""
+		case last != "":
+			spec.Config[last] += "," + part
+		default:
+			return spec, fmt.Errorf("pipeline step %q: %q is not a setting", s, part)
+		}
+	}
+	return spec, nil
+}
+
+// isWord reports whether s is a bare setting name, such as reverse.
+func isWord(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// String writes the spec back as ParseSpec reads it.
+func (o Spec) String() string {
+	if len(o.Config) == 0 {
+		return o.Name
+	}
+	keys := make([]string, 0, len(o.Config))
+	for key := range o.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if value := o.Config[key]; value != "" {
+			keys[i] = key + "=" + value
+		}
+	}
+	return o.Name + ":" + strings.Join(keys, ",")
+}
+
+// Named is a step of a pipeline with the name it is reported by.
+type Named struct {
+	Name string
+	Step
+}
+
+// Pipeline is a list of steps applied one after another.
+type Pipeline []Named
+
+// Build builds the registered steps the specs name, in order.
+func Build(specs []Spec) (Pipeline, error) {
+	var p Pipeline
+	for _, spec := range specs {
+		step, err := New(spec.Name, spec.Config)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, Named{Name: spec.Name, Step: step})
+	}
+	return p, nil
+}
+
+// Apply runs the steps on the playlist, stopping at the first failing.
+func (o Pipeline) Apply(ctx context.Context, mp *model.MusicPlaylist) error {
+	for _, step := range o {
+		if err := step.Apply(ctx, mp); err != nil {
+			return fmt.Errorf("pipeline step %s: %w", step.Name, err)
+		}
+	}
+	return nil
+}
+
+// PerTrack reports whether every step may run on a track at a time.
+func (o Pipeline) PerTrack() bool {
+	return o.CheckPerTrack() == nil
+}
+
+// CheckPerTrack returns an error naming the first step which can't run on
+// a track at a time, see PerTrack.
+func (o Pipeline) CheckPerTrack() error {
+	for _, step := range o {
+		if !IsPerTrack(step.Step) {
+			return fmt.Errorf("pipeline step %s looks at the whole playlist and can't run on one track at a time", step.Name)
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the steps, in order.
+func (o Pipeline) Names() []string {
+	names := make([]string, len(o))
+	for i, step := range o {
+		names[i] = step.Name
+	}
+	return names
+}
+
+// Fields returns the computed fields the steps add, in order, see
+// Fielder.
+func (o Pipeline) Fields() []string {
+	var fields []string
+	for _, step := range o {
+		if fielder, ok := step.Step.(Fielder); ok {
+			fields = append(fields, fielder.Fields()...)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,276 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pyrat/spd/internal/filter"
+	"github.com/pyrat/spd/internal/script"
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// The steps every pipeline can use:
+//
+//	filter:expr=...        keep the tracks matching a filter expression
+//	dedupe:by=id|isrc|name keep the first of tracks alike, by ID by default
+//	sort:by=...,reverse    order the tracks by name, artist, album, added,
+//	                       released, duration or chronological (release
+//	                       date, then disc and track number)
+//	limit:n=...            keep the first n tracks
+//	exec:command=...       pipe the playlist as JSON through a command
+//	where:expr=...         keep the tracks an expression is true for
+//	compute:field=...,expr=...
+//	                       set a computed field of the tracks
+//
+// The expressions of where and compute are those of package script. The
+// filter, where and compute steps are PerTrack.
+func init() {
+	Register("filter", newFilter)
+	Register("where", newWhere)
+	Register("compute", newCompute)
+	Register("dedupe", newDedupe)
+	Register("sort", newSort)
+	Register("limit", newLimit)
+	Register("exec", newExec)
+}
+
+func newFilter(config Config) (Step, error) {
+	expr := config.String("expr", "")
+	if expr == "" {
+		return nil, errors.New("needs expr, a filter expression")
+	}
+	return Filter(expr)
+}
+
+// Filter returns a step keeping the tracks matching a filter expression,
+// such as artist =~ "Radiohead" and added_after 2023-01-01.
+func Filter(expr string) (Step, error) {
+	f, err := filter.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return TrackFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+		mp.Tracks = f.Tracks(mp.Tracks)
+		return nil
+	}), nil
+}
+
+func newWhere(config Config) (Step, error) {
+	expr := config.String("expr", "")
+	if expr == "" {
+		return nil, errors.New("needs expr, an expression")
+	}
+	return Where(expr)
+}
+
+// Where returns a step keeping the tracks an expression is true for, such
+// as year >= 2000 && !explicit.
+func Where(expr string) (Step, error) {
+	program, err := script.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return TrackFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+		var kept []model.MusicTrack
+		for i, track := range mp.Tracks {
+			ok, err := program.Bool(script.TrackVars(*mp, i+1, track))
+			if err != nil {
+				return fmt.Errorf("track %q: %w", track.Name, err)
+			}
+			if ok {
+				kept = append(kept, track)
+			}
+		}
+		mp.Tracks = kept
+		return nil
+	}), nil
+}
+
+// Fielder is implemented by steps adding computed fields to the tracks,
+// so outputs with columns can make room for them.
+type Fielder interface {
+	Fields() []string
+}
+
+func newCompute(config Config) (Step, error) {
+	field, expr := config.String("field", ""), config.String("expr", "")
+	if field == "" || expr == "" {
+		return nil, errors.New("needs field, the name of the computed field, and expr, an expression")
+	}
+	return Compute(field, expr)
+}
+
+// Compute returns a step setting the computed field of every track to
+// what an expression evaluates to, such as string(year / 10 * 10) + "s".
+func Compute(field string, expr string) (Step, error) {
+	program, err := script.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return compute{field: field, program: program}, nil
+}
+
+type compute struct {
+	field   string
+	program *script.Program
+}
+
+func (o compute) Apply(ctx context.Context, mp *model.MusicPlaylist) error {
+	for i := range mp.Tracks {
+		track := &mp.Tracks[i]
+		value, err := o.program.Eval(script.TrackVars(*mp, i+1, *track))
+		if err != nil {
+			return fmt.Errorf("track %q: %w", track.Name, err)
+		}
+		if track.Computed == nil {
+			track.Computed = map[string]interface{}{}
+		}
+		track.Computed[o.field] = value
+	}
+	return nil
+}
+
+func (o compute) PerTrack() bool {
+	return true
+}
+
+func (o compute) Fields() []string {
+	return []string{o.field}
+}
+
+func newDedupe(config Config) (Step, error) {
+	var key func(track model.MusicTrack) string
+	switch by := config.String("by", "id"); by {
+	case "id":
+		key = func(track model.MusicTrack) string { return track.IntegrationID }
+	case "isrc":
+		key = func(track model.MusicTrack) string { return firstNonEmpty(track.ISRC, track.IntegrationID) }
+	case "name":
+		key = func(track model.MusicTrack) string {
+			return strings.ToLower(track.Artists) + "\x00" + strings.ToLower(track.Name)
+		}
+	default:
+		return nil, fmt.Errorf("by: unknown %q, one of id, isrc or name", by)
+	}
+	return StepFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+		seen := map[string]bool{}
+		kept := mp.Tracks[:0]
+		for _, track := range mp.Tracks {
+			k := key(track)
+			// local files have no ID to tell them apart
+			if k != "" && seen[k] {
+				continue
+			}
+			seen[k] = true
+			kept = append(kept, track)
+		}
+		mp.Tracks = kept
+		return nil
+	}), nil
+}
+
+func newSort(config Config) (Step, error) {
+	var less func(a, b model.MusicTrack) bool
+	switch by := config.String("by", ""); by {
+	case "name":
+		less = func(a, b model.MusicTrack) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "artist":
+		less = func(a, b model.MusicTrack) bool { return strings.ToLower(a.Artists) < strings.ToLower(b.Artists) }
+	case "album":
+		less = func(a, b model.MusicTrack) bool { return strings.ToLower(a.AlbumName) < strings.ToLower(b.AlbumName) }
+	case "added":
+		less = func(a, b model.MusicTrack) bool {
+			return a.AddedAt != nil && (b.AddedAt == nil || a.AddedAt.Before(*b.AddedAt))
+		}
+	case "released":
+		less = func(a, b model.MusicTrack) bool {
+			return firstNonEmpty(a.AlbumReleaseDate, a.ReleaseDate) < firstNonEmpty(b.AlbumReleaseDate, b.ReleaseDate)
+		}
+	case "duration":
+		less = func(a, b model.MusicTrack) bool { return a.DurationMS < b.DurationMS }
+	case "chronological":
+		less = model.ChronologicalLess
+	default:
+		return nil, fmt.Errorf("by: unknown %q, one of name, artist, album, added, released, duration or chronological", by)
+	}
+	reverse, err := config.Bool("reverse", false)
+	if err != nil {
+		return nil, err
+	}
+	return StepFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+		sort.SliceStable(mp.Tracks, func(i, j int) bool {
+			if reverse {
+				return less(mp.Tracks[j], mp.Tracks[i])
+			}
+			return less(mp.Tracks[i], mp.Tracks[j])
+		})
+		return nil
+	}), nil
+}
+
+func newLimit(config Config) (Step, error) {
+	n, err := config.Int("n", -1)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.New("needs n, the number of tracks kept")
+	}
+	return StepFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+		if len(mp.Tracks) > n {
+			mp.Tracks = mp.Tracks[:n]
+		}
+		return nil
+	}), nil
+}
+
+func newExec(config Config) (Step, error) {
+	command := config.String("command", "")
+	if command == "" {
+		return nil, errors.New("needs command, run with sh")
+	}
+	return Exec(command), nil
+}
+
+// Exec returns a step running command with sh, the playlist as JSON on
+// its stdin, and taking the playlist it prints as JSON on stdout in its
+// place. Its stderr is passed through.
+func Exec(command string) Step {
+	return StepFunc(func(ctx context.Context, mp *model.MusicPlaylist) error {
+		in, err := json.Marshal(mp)
+		if err != nil {
+			return err
+		}
+		slog.Debug("running pipeline command", "command", command, "playlist", mp.IntegrationID)
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(in)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("running %q: %w", command, err)
+		}
+		var result model.MusicPlaylist
+		if err := json.Unmarshal(out, &result); err != nil {
+			return fmt.Errorf("%q printed no playlist: %w", command, err)
+		}
+		*mp = result
+		return nil
+	})
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package spotify
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// slowFactor is how many times slower than the fastest response seen a
+// response may be before the API counts as struggling.
+const slowFactor = 3
+
+// Adaptive limits how many requests a Client has in flight, finding the
+// most the API allows rather than relying on a fixed guess. The limit
+// grows by one for every limit requests answered quickly, halves on a
+// 429 and shrinks a little on a response much slower than the fastest
+// seen (additive increase, multiplicative decrease). A 429's Retry-After
+// holds back every request, not just the one retried.
+//
+// An Adaptive is shared by everything using the client, see
+// WithAdaptiveConcurrency.
+type Adaptive struct {
+	min, max int
+
+	mu       sync.Mutex
+	changed  chan struct{}
+	limit    float64
+	inFlight int
+	fastest  time.Duration
+	pause    time.Time
+}
+
+// NewAdaptive returns a limiter starting at min requests in flight and
+// never going beyond max.
+func NewAdaptive(min int, max int) *Adaptive {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &Adaptive{min: min, max: max, limit: float64(min), changed: make(chan struct{})}
+}
+
+// WithAdaptiveConcurrency limits the client's requests in flight with a.
+func WithAdaptiveConcurrency(a *Adaptive) Option {
+	return func(o *Client) {
+		o.adaptive = a
+	}
+}
+
+// Limit returns the current limit of requests in flight.
+func (o *Adaptive) Limit() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int(o.limit)
+}
+
+// acquire waits until a request may be made.
+func (o *Adaptive) acquire(ctx context.Context) error {
+	for {
+		o.mu.Lock()
+		wait := time.Until(o.pause)
+		if wait <= 0 && o.inFlight < int(o.limit) {
+			o.inFlight++
+			o.mu.Unlock()
+			return nil
+		}
+		changed := o.changed
+		o.mu.Unlock()
+
+		var t *time.Timer
+		var timer <-chan time.Time
+		if wait > 0 {
+			t = time.NewTimer(wait)
+			timer = t.C
+		}
+		select {
+		case <-changed:
+		case <-timer:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// release records how a request made after acquire went, adjusting the
+// limit. status is 0 when the request failed without a response.
+func (o *Adaptive) release(latency time.Duration, status int, retryAfter time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.inFlight--
+
+	previous := int(o.limit)
+	switch {
+	case status == http.StatusTooManyRequests:
+		o.limit = max(float64(o.min), o.limit/2)
+		if until := time.Now().Add(retryAfter); until.After(o.pause) {
+			o.pause = until
+		}
+	case status == 0 || status >= 500:
+		o.limit = max(float64(o.min), o.limit*0.75)
+	case o.fastest > 0 && latency > o.fastest*slowFactor:
+		o.limit = max(float64(o.min), o.limit*0.9)
+	default:
+		o.limit = min(float64(o.max), o.limit+1/o.limit)
+	}
+	if status >= 200 && status < 300 && (o.fastest == 0 || latency < o.fastest) {
+		o.fastest = latency
+	}
+	if int(o.limit) != previous {
+		slog.Debug("adapted spotify concurrency", "limit", int(o.limit), "status", status, "latency", latency)
+	}
+
+	// wake up the waiters to check the new limit
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
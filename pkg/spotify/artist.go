@@ -0,0 +1,134 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// AlbumGroups are the album groups of an artist's discography.
+var AlbumGroups = []string{"album", "single", "compilation", "appears_on"}
+
+// ArtistFromID hits the Spotify API to get Artist information.
+func (o *Client) ArtistFromID(ctx context.Context, ID string) (SpotifyArtist, error) {
+	artist := SpotifyArtist{}
+	endpoint, err := o.resourceEndpoint(TypeArtist, ID)
+	if err != nil {
+		return artist, err
+	}
+	err = o.apiRequest(ctx, "GET", endpoint, nil, &artist)
+	return artist, err
+}
+
+// ArtistAlbums pages through the artist's discography in the given album
+// groups, all of AlbumGroups when groups is empty. The albums come without
+// their tracks, use AlbumFromID to get those.
+func (o *Client) ArtistAlbums(ctx context.Context, ID string, groups []string) ([]SpotifyAlbum, error) {
+	endpoint, err := o.resourceEndpoint(TypeArtist, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		groups = AlbumGroups
+	}
+
+	query := url.Values{}
+	query.Set("include_groups", strings.Join(groups, ","))
+	query.Set("limit", "50")
+
+	var albums []SpotifyAlbum
+	next := endpoint + "/albums?" + query.Encode()
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return albums, err
+		}
+		page := SpotifyAlbumsResult{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return albums, err
+		}
+		albums = append(albums, page.Items...)
+		next = page.Next
+	}
+	return albums, nil
+}
+
+// ArtistTopTracks hits the Spotify API to get the artist's most popular
+// tracks in the market, an ISO 3166-1 alpha-2 country code.
+func (o *Client) ArtistTopTracks(ctx context.Context, ID string, market string) ([]SpotifyTrack, error) {
+	result := struct {
+		Tracks []SpotifyTrack `json:"tracks"`
+	}{}
+	endpoint, err := o.resourceEndpoint(TypeArtist, ID)
+	if err != nil {
+		return nil, err
+	}
+	err = o.apiRequest(ctx, "GET", endpoint+"/top-tracks?market="+url.QueryEscape(market), nil, &result)
+	return result.Tracks, err
+}
+
+// ConvertToMusicArtist converts a SpotifyArtist struct to a MusicArtist struct
+func ConvertToMusicArtist(sa SpotifyArtist) MusicArtist {
+	return MusicArtist{
+		Name:          sa.Name,
+		IntegrationID: sa.IntegrationID,
+		Genres:        sa.Genres,
+		ArtistArt:     sa.Images,
+		ExternalURL:   sa.ExternalURL.Spotify,
+	}
+}
+
+// ConvertToMusicAlbum converts a SpotifyAlbum struct to a MusicAlbum struct.
+// Album tracks don't repeat the album details, they are filled in here.
+func ConvertToMusicAlbum(sa SpotifyAlbum) MusicAlbum {
+	album := MusicAlbum{
+		Name:          sa.Name,
+		AlbumArt:      sa.Images,
+		ReleaseDate:   sa.ReleaseDate,
+		Released:      ParseReleaseDate(sa.ReleaseDate, sa.ReleaseDatePrecision),
+		AlbumGroup:    sa.AlbumGroup,
+		IntegrationID: sa.IntegrationID,
+	}
+
+	for _, artist := range sa.Artists {
+		album.Artists = append(album.Artists, MusicArtist{
+			Name:          artist.Name,
+			IntegrationID: artist.IntegrationID,
+		})
+	}
+
+	for _, track := range sa.TracksCollection.Items {
+		if track.Album.IntegrationID == "" {
+			track.Album = sa
+			track.Album.TracksCollection = SpotifyTracksResult{}
+		}
+		album.Tracks = append(album.Tracks, ConvertToMusicTrack(track))
+	}
+
+	return album
+}
+
+// MaxArtistsPerRequest is the most artists fetched by one request.
+const MaxArtistsPerRequest = 50
+
+// ArtistsFromIDs hits the Spotify API to get several artists, in batches
+// of MaxArtistsPerRequest. Artists Spotify doesn't know are left out.
+func (o *Client) ArtistsFromIDs(ctx context.Context, IDs []string) ([]SpotifyArtist, error) {
+	var artists []SpotifyArtist
+	for _, batch := range Chunk(IDs, MaxArtistsPerRequest) {
+		result := struct {
+			Artists []*SpotifyArtist `json:"artists"`
+		}{}
+		endpoint := o.endpoint("/artists") + "?ids=" + url.QueryEscape(strings.Join(batch, ","))
+		if err := o.apiRequest(ctx, "GET", endpoint, nil, &result); err != nil {
+			return artists, err
+		}
+		for _, artist := range result.Artists {
+			if artist != nil {
+				artists = append(artists, *artist)
+			}
+		}
+	}
+	return artists, nil
+}
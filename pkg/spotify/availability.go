@@ -0,0 +1,47 @@
+package spotify
+
+import "github.com/pyrat/spd/pkg/model"
+
+// SpotifyLinkedTrack is the track a market relinked track stands in for.
+type SpotifyLinkedTrack struct {
+	IntegrationID string `json:"id"`
+	URI           string `json:"uri"`
+}
+
+// SpotifyRestrictions tells why a track can't be played: "market",
+// "product" or "explicit".
+type SpotifyRestrictions struct {
+	Reason string `json:"reason"`
+}
+
+// Reasons a track is unavailable, besides the restriction reasons Spotify
+// reports itself.
+const (
+	// UnavailableNotPlayable is a track not playable in the requested
+	// market without Spotify saying why.
+	UnavailableNotPlayable = model.UnavailableNotPlayable
+	// UnavailableNoMarkets is a track available in no market at all.
+	UnavailableNoMarkets = model.UnavailableNoMarkets
+	// UnavailableRemoved is a playlist item whose track was taken off
+	// Spotify altogether.
+	UnavailableRemoved = model.UnavailableRemoved
+)
+
+// unavailableReason returns why a track is greyed out, empty when it
+// can be played. Local files are never unavailable, they play from the
+// owner's disk.
+func unavailableReason(st SpotifyTrack) string {
+	switch {
+	case st.IsLocal:
+		return ""
+	case st.IntegrationID == "":
+		return UnavailableRemoved
+	case st.Restrictions != nil && st.Restrictions.Reason != "":
+		return st.Restrictions.Reason
+	case st.IsPlayable != nil && !*st.IsPlayable:
+		return UnavailableNotPlayable
+	case st.AvailableMarkets != nil && len(st.AvailableMarkets) == 0:
+		return UnavailableNoMarkets
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// FetchBatches fetches the entities with the IDs size at a time, fetch
+// returning the IDs of those it got back. It carries on past entities
+// which can't be fetched: a batch which fails is split in two and each
+// half tried again, down to single IDs, so that a bad ID only loses
+// itself. failed is told of each ID which failed on its own, and of those
+// a batch came back without with ErrNotFound.
+//
+// Errors failing every request alike, a cancelled context, a revoked
+// token or rate limiting that outlasted the retries, are returned instead
+// and the rest of the IDs left unfetched.
+func FetchBatches(ctx context.Context, IDs []string, size int, fetch func(ctx context.Context, batch []string) ([]string, error), failed func(ID string, err error)) error {
+	for _, batch := range Chunk(IDs, size) {
+		if err := fetchBisecting(ctx, batch, fetch, failed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchBisecting fetches a batch, halving it when it fails.
+func fetchBisecting(ctx context.Context, batch []string, fetch func(ctx context.Context, batch []string) ([]string, error), failed func(ID string, err error)) error {
+	found, err := fetch(ctx, batch)
+	switch {
+	case err == nil:
+		got := make(map[string]bool, len(found))
+		for _, id := range found {
+			got[id] = true
+		}
+		for _, id := range batch {
+			if !got[id] {
+				failed(id, ErrNotFound)
+			}
+		}
+		return nil
+	case !isolatable(err):
+		return err
+	case len(batch) == 1:
+		failed(batch[0], err)
+		return nil
+	}
+	half := len(batch) / 2
+	if err := fetchBisecting(ctx, batch[:half], fetch, failed); err != nil {
+		return err
+	}
+	return fetchBisecting(ctx, batch[half:], fetch, failed)
+}
+
+// isolatable reports whether a failed batch may be down to some of its
+// IDs: Spotify rejects a whole batch over one malformed ID, and fails
+// some with server errors when they hold a broken entity.
+func isolatable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode >= 500
+}
@@ -0,0 +1,80 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// SpotifyCategory describes a browse category such as Focus or Chill.
+type SpotifyCategory struct {
+	Name          string `json:"name"`
+	IntegrationID string `json:"id"`
+}
+
+// SpotifyCategoriesResult is a container struct for categories parsing.
+type SpotifyCategoriesResult struct {
+	Items []SpotifyCategory `json:"items"`
+	Next  string            `json:"next"`
+}
+
+// Categories pages through the browse categories available in the market,
+// all markets when empty.
+func (o *Client) Categories(ctx context.Context, market string) ([]SpotifyCategory, error) {
+	params := url.Values{}
+	params.Set("limit", "50")
+	if market != "" {
+		params.Set("country", market)
+	}
+
+	var categories []SpotifyCategory
+	next := o.endpoint("/browse/categories") + "?" + params.Encode()
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return categories, err
+		}
+		page := struct {
+			Categories SpotifyCategoriesResult `json:"categories"`
+		}{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return categories, err
+		}
+		categories = append(categories, page.Categories.Items...)
+		next = page.Categories.Next
+	}
+	return categories, nil
+}
+
+// CategoryPlaylists pages through the editorial playlists of a browse
+// category. Only the playlist details are included, not their tracks.
+func (o *Client) CategoryPlaylists(ctx context.Context, categoryID string, market string) ([]SpotifyPlaylist, error) {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(50))
+	if market != "" {
+		params.Set("country", market)
+	}
+
+	var playlists []SpotifyPlaylist
+	next := o.endpoint("/browse/categories/"+url.PathEscape(categoryID)+"/playlists") + "?" + params.Encode()
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return playlists, err
+		}
+		page := struct {
+			Playlists SpotifyPlaylistsResult `json:"playlists"`
+		}{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return playlists, err
+		}
+		// spotify pads the list with nulls for playlists it won't show
+		for _, playlist := range page.Playlists.Items {
+			if playlist.IntegrationID != "" {
+				playlists = append(playlists, playlist)
+			}
+		}
+		next = page.Playlists.Next
+	}
+	return playlists, nil
+}
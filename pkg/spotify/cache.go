@@ -0,0 +1,120 @@
+package spotify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/internal/atomicfile"
+)
+
+// diskCache stores GET responses on disk keyed by request URL, which holds
+// the ID of the track, album or playlist requested, along with their ETag.
+// Cached responses are revalidated with If-None-Match, so a cache hit still
+// costs a request but not the payload, and never serves stale data. Those
+// the API allowed to be reused for a while with Cache-Control max-age are
+// served without a request until they expire, and no-store responses are
+// never cached.
+type diskCache struct {
+	dir string
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag"`
+	// Expires is when the response goes stale, per its max-age, nil
+	// when it must always be revalidated.
+	Expires *time.Time      `json:"expires,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// fresh reports whether the entry may be used without revalidating it.
+func (o cacheEntry) fresh() bool {
+	return o.Expires != nil && time.Now().Before(*o.Expires)
+}
+
+// WithCache caches API responses in dir, see diskCache.
+func WithCache(dir string) Option {
+	return func(o *Client) {
+		o.cache = &diskCache{dir: dir}
+	}
+}
+
+// path returns the file an URL is cached in.
+func (o *diskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(o.dir, name[:2], name+".json")
+}
+
+// get returns the cached response for url.
+func (o *diskCache) get(url string) (cacheEntry, bool) {
+	entry := cacheEntry{}
+	data, err := os.ReadFile(o.path(url))
+	if err != nil {
+		return entry, false
+	}
+	if json.Unmarshal(data, &entry) != nil || entry.URL != url || (entry.ETag == "" && !entry.fresh()) {
+		return entry, false
+	}
+	return entry, true
+}
+
+// put caches a response. Responses neither revalidatable with an ETag nor
+// fresh for a while, marked no-store, or with a body that isn't JSON are
+// skipped.
+func (o *diskCache) put(url string, header http.Header, body []byte) error {
+	entry := cacheEntry{URL: url, ETag: header.Get("ETag"), Body: body}
+	maxAge, noStore := cacheControl(header.Get("Cache-Control"))
+	if maxAge > 0 {
+		expires := time.Now().Add(maxAge)
+		entry.Expires = &expires
+	}
+	if noStore || (entry.ETag == "" && entry.Expires == nil) || !json.Valid(body) {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := o.path(url)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// written whole so concurrent readers never see half an entry
+	return atomicfile.WriteFile(path, data, 0o600)
+}
+
+// cacheControl reads the max-age and no-store directives of a
+// Cache-Control header. no-cache zeroes max-age, the response must then
+// be revalidated every time.
+func cacheControl(header string) (maxAge time.Duration, noStore bool) {
+	noCache := false
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			noStore = true
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		maxAge = 0
+	}
+	return maxAge, noStore
+}
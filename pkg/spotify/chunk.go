@@ -0,0 +1,222 @@
+package spotify
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxTracksPerRequest is the maximum number of tracks Spotify accepts
+	// in a single playlist modification request.
+	MaxTracksPerRequest = 100
+
+	// MaxPlaylistTracks is the maximum number of tracks a playlist can hold.
+	MaxPlaylistTracks = 10000
+)
+
+// BatchError reports a playlist modification which failed part way
+// through. Applied is the number of URIs written before the failing batch,
+// so callers can resume from uris[Applied:].
+type BatchError struct {
+	PlaylistID string
+	Applied    int
+	Err        error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("playlist %s: failed after %d tracks: %s", e.PlaylistID, e.Applied, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Chunk splits uris into consecutive slices of at most size items.
+func Chunk(uris []string, size int) [][]string {
+	var chunks [][]string
+	for start := 0; start < len(uris); start += size {
+		end := start + size
+		if end > len(uris) {
+			end = len(uris)
+		}
+		chunks = append(chunks, uris[start:end])
+	}
+	return chunks
+}
+
+// SplitPlaylists splits uris into the track lists of as many playlists as
+// are needed to stay under MaxPlaylistTracks. The first list is for the
+// original playlist, the rest are for continuation playlists.
+func SplitPlaylists(uris []string) [][]string {
+	if len(uris) == 0 {
+		return [][]string{nil}
+	}
+	return Chunk(uris, MaxPlaylistTracks)
+}
+
+// ContinuationName returns the name of the n-th playlist holding the
+// overflow of a playlist too big for a single one, n starting at 0.
+func ContinuationName(name string, n int) string {
+	if n == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s (%d)", name, n+1)
+}
+
+// AddTracksToPlaylist appends the track URIs to the playlist, batched
+// MaxTracksPerRequest at a time. A failure part way through is returned
+// as a *BatchError.
+func (o *Client) AddTracksToPlaylist(ctx context.Context, playlistID string, uris []string) error {
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return err
+	}
+	uris, err = trackURIs(uris)
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	for _, batch := range Chunk(uris, MaxTracksPerRequest) {
+		payload := map[string]interface{}{
+			"uris": batch,
+		}
+		if err := o.apiRequest(ctx, "POST", endpoint+"/tracks", payload, nil); err != nil {
+			return &BatchError{PlaylistID: playlistID, Applied: applied, Err: err}
+		}
+		applied += len(batch)
+	}
+	return nil
+}
+
+// ReplacePlaylistTracks replaces all tracks of the playlist with the
+// track URIs, in order. An empty list clears the playlist. A failure part
+// way through is returned as a *BatchError.
+func (o *Client) ReplacePlaylistTracks(ctx context.Context, playlistID string, uris []string) error {
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return err
+	}
+	uris, err = trackURIs(uris)
+	if err != nil {
+		return err
+	}
+
+	// the first batch replaces the playlist, the rest are appended
+	first := uris
+	if len(first) > MaxTracksPerRequest {
+		first = first[:MaxTracksPerRequest]
+	}
+	payload := map[string]interface{}{
+		"uris": first,
+	}
+	if err := o.apiRequest(ctx, "PUT", endpoint+"/tracks", payload, nil); err != nil {
+		return &BatchError{PlaylistID: playlistID, Err: err}
+	}
+
+	if err := o.AddTracksToPlaylist(ctx, playlistID, uris[len(first):]); err != nil {
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			batchErr.Applied += len(first)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveTracksFromPlaylist removes every occurrence of the track URIs from
+// the playlist, batched MaxTracksPerRequest at a time. A failure part way
+// through is returned as a *BatchError.
+func (o *Client) RemoveTracksFromPlaylist(ctx context.Context, playlistID string, uris []string) error {
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return err
+	}
+	uris, err = trackURIs(uris)
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	for _, batch := range Chunk(uris, MaxTracksPerRequest) {
+		tracks := make([]map[string]string, 0, len(batch))
+		for _, uri := range batch {
+			tracks = append(tracks, map[string]string{"uri": uri})
+		}
+		payload := map[string]interface{}{
+			"tracks": tracks,
+		}
+		if err := o.apiRequest(ctx, "DELETE", endpoint+"/tracks", payload, nil); err != nil {
+			return &BatchError{PlaylistID: playlistID, Applied: applied, Err: err}
+		}
+		applied += len(batch)
+	}
+	return nil
+}
+
+// ReorderPlaylist moves the length items starting at rangeStart to just
+// before the item at insertBefore, positions counting from 0 in the
+// playlist as it is before the move. snapshotID, when set, is the
+// playlist version the positions refer to. It returns the snapshot ID of
+// the reordered playlist.
+func (o *Client) ReorderPlaylist(ctx context.Context, playlistID string, rangeStart int, insertBefore int, length int, snapshotID string) (string, error) {
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return "", err
+	}
+	payload := map[string]interface{}{
+		"range_start":   rangeStart,
+		"insert_before": insertBefore,
+		"range_length":  length,
+	}
+	if snapshotID != "" {
+		payload["snapshot_id"] = snapshotID
+	}
+	result := struct {
+		SnapshotID string `json:"snapshot_id"`
+	}{}
+	err = o.apiRequest(ctx, "PUT", endpoint+"/tracks", payload, &result)
+	return result.SnapshotID, err
+}
+
+// MaxCoverImageSize is the largest base64 encoded JPEG spotify accepts as
+// a playlist cover.
+const MaxCoverImageSize = 256 * 1024
+
+// UploadPlaylistCover replaces the playlist's cover image with the JPEG.
+// The token needs the ugc-image-upload scope.
+func (o *Client) UploadPlaylistCover(ctx context.Context, playlistID string, jpeg []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(jpeg)
+	if len(encoded) > MaxCoverImageSize {
+		return fmt.Errorf("cover image is %d bytes encoded, spotify accepts at most %d", len(encoded), MaxCoverImageSize)
+	}
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return err
+	}
+	return o.rawRequest(ctx, "PUT", endpoint+"/images", "image/jpeg", []byte(encoded), nil)
+}
+
+// trackURIs converts track IDs, URIs or links into track URIs. Episode
+// URIs are passed through as playlists can hold those too.
+func trackURIs(inputs []string) ([]string, error) {
+	uris := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		resource, err := ParseResource(input)
+		if err != nil {
+			return nil, err
+		}
+		if resource.Type == TypeEpisode {
+			uris = append(uris, resource.URI())
+			continue
+		}
+		uri, err := ParseURI(input, TypeTrack)
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, uri)
+	}
+	return uris, nil
+}
@@ -0,0 +1,327 @@
+package spotify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// api is a fake Spotify serving a token endpoint at /api/token and the
+// handlers under /v1, checking every API request carries the token.
+type api struct {
+	*httptest.Server
+	mux *http.ServeMux
+	// tokens counts the token requests, requests the API requests.
+	tokens   atomic.Int64
+	requests atomic.Int64
+	// token answers the token requests, a fresh token for an hour by
+	// default.
+	token func(w http.ResponseWriter, r *http.Request)
+}
+
+func newAPI(t *testing.T) *api {
+	t.Helper()
+	a := &api{mux: http.NewServeMux()}
+	a.token = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "token-" + strconv.FormatInt(a.tokens.Load(), 10), "expires_in": 3600})
+	}
+	a.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/token" {
+			a.tokens.Add(1)
+			if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+				return
+			}
+			a.token(w, r)
+			return
+		}
+		a.requests.Add(1)
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") {
+			writeJSON(w, http.StatusUnauthorized, apiError(http.StatusUnauthorized, "No token provided"))
+			return
+		}
+		a.mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(a.Close)
+	return a
+}
+
+// client returns a client of the fake API with the client credentials.
+func (o *api) client(t *testing.T, opts ...spotify.Option) *spotify.Client {
+	t.Helper()
+	opts = append([]spotify.Option{spotify.WithBaseURL(o.URL + "/v1"), spotify.WithAuthURL(o.URL + "/api/token")}, opts...)
+	sp, err := spotify.NewClient(context.Background(), "id", "secret", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sp
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func apiError(status int, message string) map[string]interface{} {
+	return map[string]interface{}{"error": map[string]interface{}{"status": status, "message": message}}
+}
+
+// servePlaylist serves a playlist of n tracks at /v1/playlists/<id>,
+// paged limit tracks at a time by offset like the API.
+func (o *api) servePlaylist(id string, n int, limit int) {
+	page := func(r *http.Request, offset int) spotify.SpotifyPlaylistTracks {
+		tracks := spotify.SpotifyPlaylistTracks{Total: n}
+		for i := offset; i < min(offset+limit, n); i++ {
+			tracks.Items = append(tracks.Items, spotify.SpotifyPlaylistTrack{Track: spotify.SpotifyTrack{IntegrationID: fmt.Sprintf("track%d", i), Name: fmt.Sprintf("Track %d", i)}})
+		}
+		if offset+limit < n {
+			tracks.Next = fmt.Sprintf("%s/v1/playlists/%s/tracks?offset=%d&limit=%d", o.URL, id, offset+limit, limit)
+		}
+		return tracks
+	}
+	o.mux.HandleFunc("/v1/playlists/"+id, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotify.SpotifyPlaylist{IntegrationID: id, Name: "Playlist " + id, TracksCollection: page(r, 0)})
+	})
+	o.mux.HandleFunc("/v1/playlists/"+id+"/tracks", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		writeJSON(w, http.StatusOK, page(r, offset))
+	})
+}
+
+func TestClientCredentialsTokenReused(t *testing.T) {
+	a := newAPI(t)
+	a.mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotify.SpotifyUser{IntegrationID: "me"})
+	})
+	sp := a.client(t)
+	for i := 0; i < 3; i++ {
+		if _, err := sp.CurrentUser(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := a.tokens.Load(); n != 1 {
+		t.Errorf("requested %d tokens, want 1 reused for every request", n)
+	}
+}
+
+func TestClientCredentialsRejected(t *testing.T) {
+	a := newAPI(t)
+	_, err := spotify.NewClient(context.Background(), "id", "wrong", spotify.WithBaseURL(a.URL+"/v1"), spotify.WithAuthURL(a.URL+"/api/token"))
+	if err == nil {
+		t.Fatal("NewClient succeeded with bad credentials")
+	}
+	if !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("error %q doesn't carry the accounts service's error", err)
+	}
+}
+
+func TestRefreshTokenRotated(t *testing.T) {
+	a := newAPI(t)
+	var refreshTokens []string
+	a.token = func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+		refreshTokens = append(refreshTokens, r.Form.Get("refresh_token"))
+		// expiring within the margin, so every request refreshes it
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token":  "token-" + strconv.Itoa(len(refreshTokens)),
+			"refresh_token": "refresh-" + strconv.Itoa(len(refreshTokens)),
+			"expires_in":    30,
+		})
+	}
+	a.mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotify.SpotifyUser{IntegrationID: "me"})
+	})
+
+	sp := a.client(t, spotify.WithTokenProvider(&spotify.RefreshToken{ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh-0", AuthURL: a.URL + "/api/token"}))
+	for i := 0; i < 2; i++ {
+		if _, err := sp.CurrentUser(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"refresh-0", "refresh-1", "refresh-2"}
+	if strings.Join(refreshTokens, ",") != strings.Join(want, ",") {
+		t.Errorf("refreshed with %v, want %v, each new refresh token used for the next", refreshTokens, want)
+	}
+}
+
+func TestPlaylistPaging(t *testing.T) {
+	for _, prefetch := range []int{0, 4} {
+		t.Run(fmt.Sprintf("prefetch %d", prefetch), func(t *testing.T) {
+			a := newAPI(t)
+			a.servePlaylist("big", 250, 100)
+			a.servePlaylist("empty", 0, 100)
+			sp := a.client(t, spotify.WithPagePrefetch(prefetch))
+
+			playlist, err := sp.PlaylistFromID(context.Background(), "big")
+			if err != nil {
+				t.Fatal(err)
+			}
+			items := playlist.TracksCollection.Items
+			if len(items) != 250 {
+				t.Fatalf("got %d tracks, want 250", len(items))
+			}
+			for i, item := range items {
+				if want := fmt.Sprintf("track%d", i); item.Track.IntegrationID != want {
+					t.Fatalf("track %d is %s, want %s in order", i, item.Track.IntegrationID, want)
+				}
+			}
+			if requests := a.requests.Load(); requests != 3 {
+				t.Errorf("made %d requests, want 3", requests)
+			}
+
+			playlist, err = sp.PlaylistFromID(context.Background(), "empty")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(playlist.TracksCollection.Items) != 0 {
+				t.Errorf("got %d tracks of an empty playlist", len(playlist.TracksCollection.Items))
+			}
+		})
+	}
+}
+
+func TestPlaylistTracksPages(t *testing.T) {
+	a := newAPI(t)
+	a.servePlaylist("big", 250, 100)
+	sp := a.client(t)
+
+	var sizes []int
+	err := sp.PlaylistTracks(context.Background(), "big", func(page spotify.SpotifyPlaylistTracks) error {
+		sizes = append(sizes, len(page.Items))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(sizes) != "[100 100 50]" {
+		t.Errorf("got pages of %v tracks, want [100 100 50]", sizes)
+	}
+}
+
+func TestPagingRejectsBadNextLinks(t *testing.T) {
+	a := newAPI(t)
+	a.mux.HandleFunc("/v1/playlists/loop", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotify.SpotifyPlaylist{TracksCollection: spotify.SpotifyPlaylistTracks{Total: 2, Next: a.URL + "/v1/playlists/loop/tracks?offset=1&limit=1"}})
+	})
+	a.mux.HandleFunc("/v1/playlists/loop/tracks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotify.SpotifyPlaylistTracks{Total: 2, Next: a.URL + "/v1/playlists/loop/tracks?offset=1&limit=1"})
+	})
+	a.mux.HandleFunc("/v1/playlists/away", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotify.SpotifyPlaylist{TracksCollection: spotify.SpotifyPlaylistTracks{Total: 2, Next: "https://example.com/steal?offset=1&limit=1"}})
+	})
+	sp := a.client(t)
+
+	for id, want := range map[string]string{"loop": "already fetched", "away": "off the API"} {
+		_, err := sp.PlaylistFromID(context.Background(), id)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("paging %s: got error %v, want one saying %q", id, err, want)
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	a := newAPI(t)
+	a.mux.HandleFunc("/v1/playlists/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, apiError(http.StatusNotFound, "Resource not found"))
+	})
+	a.mux.HandleFunc("/v1/playlists/private", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	a.mux.HandleFunc("/v1/playlists/garbled", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":`))
+	})
+	sp := a.client(t)
+
+	_, err := sp.PlaylistFromID(context.Background(), "missing")
+	if !errors.Is(err, spotify.ErrNotFound) || spotify.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("got %v, want a not found error", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "Resource not found") {
+		t.Errorf("error %v lacks Spotify's message", err)
+	}
+
+	_, err = sp.PlaylistFromID(context.Background(), "private")
+	if !errors.Is(err, spotify.ErrForbidden) {
+		t.Errorf("got %v, want a forbidden error", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), http.StatusText(http.StatusForbidden)) {
+		t.Errorf("error %v of an empty body lacks the status text", err)
+	}
+
+	_, err = sp.PlaylistFromID(context.Background(), "garbled")
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("got %v, want an invalid JSON error", err)
+	}
+	if requests := a.requests.Load(); requests != 3 {
+		t.Errorf("made %d requests, want 3 as client errors aren't retried", requests)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for retries")
+	}
+	a := newAPI(t)
+	var reads, writes atomic.Int64
+	a.mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		switch reads.Add(1) {
+		case 1:
+			writeJSON(w, http.StatusInternalServerError, apiError(http.StatusInternalServerError, "Server error"))
+		case 2:
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, http.StatusTooManyRequests, apiError(http.StatusTooManyRequests, "API rate limit exceeded"))
+		default:
+			writeJSON(w, http.StatusOK, spotify.SpotifyUser{IntegrationID: "me"})
+		}
+	})
+	a.mux.HandleFunc("/v1/users/me/playlists", func(w http.ResponseWriter, r *http.Request) {
+		writes.Add(1)
+		writeJSON(w, http.StatusBadGateway, apiError(http.StatusBadGateway, "Bad gateway"))
+	})
+	sp := a.client(t)
+
+	user, err := sp.CurrentUser(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.IntegrationID != "me" || reads.Load() != 3 {
+		t.Errorf("got user %q after %d requests, want me after a server error and a rate limit", user.IntegrationID, reads.Load())
+	}
+	if stats := sp.Stats(); stats.Retries != 2 {
+		t.Errorf("counted %d retries, want 2", stats.Retries)
+	}
+
+	// a write failing on the server may have been applied
+	_, err = sp.CreatePlaylist(context.Background(), "me", "new", false)
+	if spotify.StatusCode(err) != http.StatusBadGateway || writes.Load() != 1 {
+		t.Errorf("got %v after %d requests, want the write's error without a retry", err, writes.Load())
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	a := newAPI(t)
+	a.mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusTooManyRequests, apiError(http.StatusTooManyRequests, "API rate limit exceeded"))
+	})
+	sp := a.client(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sp.CurrentUser(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+}
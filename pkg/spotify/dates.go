@@ -0,0 +1,41 @@
+package spotify
+
+import (
+	"time"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// Precisions of a release date, as spotify's release_date_precision
+// gives them.
+const (
+	PrecisionYear  = model.PrecisionYear
+	PrecisionMonth = model.PrecisionMonth
+	PrecisionDay   = model.PrecisionDay
+)
+
+// ReleaseDate is a parsed release date, known to the year, month or day.
+type ReleaseDate = model.ReleaseDate
+
+// ParseReleaseDate parses a release date as spotify sends it, see
+// model.ParseReleaseDate.
+func ParseReleaseDate(date string, precision string) *ReleaseDate {
+	return model.ParseReleaseDate(date, precision)
+}
+
+// FormatDuration formats a track length as m:ss, or h:mm:ss past an hour.
+func FormatDuration(d time.Duration) string {
+	return model.FormatDuration(d)
+}
+
+// SortChronological sorts tracks in release order, then by disc and track
+// number, see model.SortChronological.
+func SortChronological(tracks []MusicTrack) {
+	model.SortChronological(tracks)
+}
+
+// SortAlbumsChronological sorts albums oldest first, see
+// model.SortAlbumsChronological.
+func SortAlbumsChronological(albums []MusicAlbum) {
+	model.SortAlbumsChronological(albums)
+}
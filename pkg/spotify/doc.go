@@ -0,0 +1,27 @@
+// Package spotify is a client for the Spotify Web API, the one spdump is
+// built on. It can be imported by other tools:
+//
+//	client, err := spotify.NewClient(ctx, clientID, clientSecret,
+//		spotify.WithMarket("GB"),
+//		spotify.WithCache(".cache"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	playlist, err := client.PlaylistFromID(ctx, "37i9dQZF1DXcBWIGoYBM5M")
+//	if errors.Is(err, spotify.ErrNotFound) {
+//		...
+//	}
+//
+// Every call takes a context bounding its requests, retries included.
+// Options configure the HTTP client or its timeout, proxy and TLS settings,
+// a deadline for every call, endpoints, market, response cache and
+// where tokens come from, see Option and TokenProvider. Failed requests
+// return an *APIError, which errors.Is matches against ErrUnauthorized,
+// ErrForbidden, ErrNotFound and ErrRateLimited.
+//
+// The SpotifyX types mirror the API's JSON, the MusicX types are the
+// flattened form spdump writes, see ConvertToMusicPlaylist. They are
+// aliases of the types in package model, which reads dumps back without
+// this package's dependencies.
+package spotify
@@ -0,0 +1,128 @@
+package spotify
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// additionalTypes asks for podcast episodes in playlists to be returned as
+// episodes rather than squeezed into track objects.
+const additionalTypes = "additional_types=track,episode"
+
+// SpotifyShow describes a spotify podcast show.
+type SpotifyShow struct {
+	Name          string              `json:"name"`
+	Publisher     string              `json:"publisher"`
+	IntegrationID string              `json:"id"`
+	URI           string              `json:"uri"`
+	Images        []SpotifyAlbumImage `json:"images"`
+	ExternalURL   SpotifyExternalURL  `json:"external_urls"`
+}
+
+// SpotifyEpisode describes a spotify podcast episode.
+type SpotifyEpisode struct {
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	AudioPreviewURL string `json:"audio_preview_url"`
+	URI             string `json:"uri"`
+	IntegrationID   string `json:"id"`
+	DurationMS      int    `json:"duration_ms"`
+	ReleaseDate     string `json:"release_date"`
+	// ReleaseDatePrecision is how precise ReleaseDate is: year, month or
+	// day.
+	ReleaseDatePrecision string              `json:"release_date_precision"`
+	Images               []SpotifyAlbumImage `json:"images"`
+	ExternalURL          SpotifyExternalURL  `json:"external_urls"`
+	IsPlayable           *bool               `json:"is_playable"`
+	Explicit             bool                `json:"explicit"`
+	Show                 SpotifyShow         `json:"show"`
+}
+
+// UnmarshalJSON decodes a playlist item, which holds either a track or,
+// going by its type, a podcast episode.
+func (o *SpotifyPlaylistTrack) UnmarshalJSON(data []byte) error {
+	var item struct {
+		Track   json.RawMessage `json:"track"`
+		AddedAt json.RawMessage `json:"added_at"`
+		AddedBy *SpotifyUser    `json:"added_by"`
+	}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+
+	*o = SpotifyPlaylistTrack{}
+	if item.AddedBy != nil {
+		o.AddedBy = *item.AddedBy
+	}
+	if len(item.AddedAt) > 0 && string(item.AddedAt) != "null" {
+		if err := json.Unmarshal(item.AddedAt, &o.AddedAt); err != nil {
+			return err
+		}
+	}
+	if len(item.Track) == 0 || string(item.Track) == "null" {
+		return nil
+	}
+
+	var kind struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(item.Track, &kind); err != nil {
+		return err
+	}
+	if kind.Type == TypeEpisode {
+		o.Episode = &SpotifyEpisode{}
+		return json.Unmarshal(item.Track, o.Episode)
+	}
+	return json.Unmarshal(item.Track, &o.Track)
+}
+
+// MarshalJSON encodes a playlist item the way the API does, so items
+// holding an episode survive a round trip.
+func (o SpotifyPlaylistTrack) MarshalJSON() ([]byte, error) {
+	var track interface{} = o.Track
+	if o.Episode != nil {
+		episode := struct {
+			Type string `json:"type"`
+			*SpotifyEpisode
+		}{TypeEpisode, o.Episode}
+		track = episode
+	}
+	return json.Marshal(struct {
+		Track   interface{} `json:"track"`
+		AddedAt interface{} `json:"added_at"`
+		AddedBy SpotifyUser `json:"added_by"`
+	}{track, o.AddedAt, o.AddedBy})
+}
+
+// ConvertToMusicEpisode converts a SpotifyEpisode struct to a MusicTrack
+// struct. The show takes the place of the album and its publisher that
+// of the artists.
+func ConvertToMusicEpisode(se SpotifyEpisode) MusicTrack {
+	return MusicTrack{
+		Type:          TypeEpisode,
+		Name:          se.Name,
+		PreviewURL:    se.AudioPreviewURL,
+		ShowName:      se.Show.Name,
+		AlbumArt:      se.Images,
+		ReleaseDate:   se.ReleaseDate,
+		DurationMS:    se.DurationMS,
+		Duration:      FormatDuration(time.Duration(se.DurationMS) * time.Millisecond),
+		Released:      ParseReleaseDate(se.ReleaseDate, se.ReleaseDatePrecision),
+		IntegrationID: se.IntegrationID,
+		IsPlayable:    se.IsPlayable,
+		Explicit:      se.Explicit,
+		Source:        SourceSpotify,
+		ExternalURL:   se.ExternalURL.Spotify,
+		Artists:       se.Show.Publisher,
+		Unavailable:   episodeUnavailable(se),
+	}
+}
+
+// episodeUnavailable returns why an episode can't be played, empty when
+// it can.
+func episodeUnavailable(se SpotifyEpisode) string {
+	if se.IsPlayable != nil && !*se.IsPlayable {
+		return UnavailableNotPlayable
+	}
+	return ""
+}
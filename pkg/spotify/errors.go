@@ -0,0 +1,113 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is returned when Spotify answers a request with an error status.
+// It carries the status and message from Spotify's error payload, and for
+// 429 responses how long to wait before retrying.
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+// Errors an *APIError matches with errors.Is, by its status code.
+var (
+	ErrUnauthorized = errors.New("spotify: unauthorized")
+	ErrForbidden    = errors.New("spotify: forbidden")
+	ErrNotFound     = errors.New("spotify: not found")
+	ErrRateLimited  = errors.New("spotify: rate limited")
+)
+
+// Is reports whether the error's status matches one of the Err values,
+// so callers can check errors.Is(err, spotify.ErrNotFound).
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("spotify api error %d", e.StatusCode)
+	}
+	return fmt.Sprintf("spotify api error %d: %s", e.StatusCode, e.Message)
+}
+
+// spotifyErrorResponse covers both error payloads spotify sends, the web
+// API's {"error":{"status":..,"message":..}} and the accounts service's
+// {"error":"..","error_description":".."}.
+type spotifyErrorResponse struct {
+	Error            json.RawMessage `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+type spotifyErrorObject struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// newAPIError builds an APIError from an error response and its body.
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+	}
+
+	apiErr.RetryAfter = retryAfter(resp)
+
+	errResp := spotifyErrorResponse{}
+	if json.Unmarshal(body, &errResp) != nil || len(errResp.Error) == 0 {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+		return apiErr
+	}
+
+	errObj := spotifyErrorObject{}
+	if json.Unmarshal(errResp.Error, &errObj) == nil {
+		apiErr.Message = errObj.Message
+		return apiErr
+	}
+
+	var code string
+	if json.Unmarshal(errResp.Error, &code) == nil {
+		apiErr.Message = code
+		if errResp.ErrorDescription != "" {
+			apiErr.Message += ": " + errResp.ErrorDescription
+		}
+	}
+	return apiErr
+}
+
+// StatusCode returns the HTTP status of an APIError anywhere in err's
+// chain, or 0 when err did not come from a Spotify error response.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// retryAfter returns how long the Retry-After header of a response asks
+// to wait, 0 without one or with a negative one, at most a day.
+func retryAfter(resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		// checked before multiplying, which could overflow
+		return time.Duration(min(seconds, 24*60*60)) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// MaxAudioFeaturesPerRequest is the most tracks whose audio features are
+// fetched by one request.
+const MaxAudioFeaturesPerRequest = 100
+
+// SpotifyAudioFeatures are the audio analysis values of a track, all but
+// tempo (BPM) and loudness (dB) running from 0 to 1.
+type SpotifyAudioFeatures struct {
+	IntegrationID    string  `json:"id"`
+	Danceability     float64 `json:"danceability"`
+	Energy           float64 `json:"energy"`
+	Valence          float64 `json:"valence"`
+	Acousticness     float64 `json:"acousticness"`
+	Instrumentalness float64 `json:"instrumentalness"`
+	Speechiness      float64 `json:"speechiness"`
+	Liveness         float64 `json:"liveness"`
+	Tempo            float64 `json:"tempo"`
+	Loudness         float64 `json:"loudness"`
+}
+
+// AudioFeatures hits the Spotify API to get the audio features of several
+// tracks, in batches of MaxAudioFeaturesPerRequest. Tracks without any
+// are left out. Spotify only grants this endpoint to some applications.
+func (o *Client) AudioFeatures(ctx context.Context, IDs []string) ([]SpotifyAudioFeatures, error) {
+	var features []SpotifyAudioFeatures
+	for _, batch := range Chunk(IDs, MaxAudioFeaturesPerRequest) {
+		result := struct {
+			AudioFeatures []*SpotifyAudioFeatures `json:"audio_features"`
+		}{}
+		endpoint := o.endpoint("/audio-features") + "?ids=" + url.QueryEscape(strings.Join(batch, ","))
+		if err := o.apiRequest(ctx, "GET", endpoint, nil, &result); err != nil {
+			return features, err
+		}
+		for _, feature := range result.AudioFeatures {
+			if feature != nil {
+				features = append(features, *feature)
+			}
+		}
+	}
+	return features, nil
+}
@@ -0,0 +1,116 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// FuzzPager checks a paging loop never follows a link off the API nor
+// one it followed before, whatever links a response holds.
+func FuzzPager(f *testing.F) {
+	f.Add("https://api.spotify.com/v1/playlists/x/tracks?offset=100&limit=100", "https://api.spotify.com/v1/playlists/x/tracks?offset=200&limit=100")
+	f.Add("https://api.spotify.com/v1/me/tracks?offset=0", "https://api.spotify.com/v1/me/tracks?offset=0")
+	f.Add("https://api.spotify.com.evil.example/v1/me", "http://api.spotify.com/v1/me")
+	f.Add("", "https://api.spotify.com/v1")
+	f.Fuzz(func(t *testing.T, first string, second string) {
+		pages := (&Client{}).newPager()
+		accepted := map[string]bool{}
+		for _, next := range []string{first, second, first} {
+			err := pages.visit(next)
+			if err != nil {
+				continue
+			}
+			if !strings.HasPrefix(next, DefaultBaseURL+"/") {
+				t.Fatalf("followed %q off the API", next)
+			}
+			if accepted[next] {
+				t.Fatalf("followed %q twice", next)
+			}
+			accepted[next] = true
+		}
+	})
+}
+
+// FuzzDecodePage checks any page of playlist tracks decodes and converts
+// without panicking.
+func FuzzDecodePage(f *testing.F) {
+	f.Add([]byte(`{"items":[{"track":{"id":"4uLU6hMCjMI75M1A2tKUQC","name":"Song","duration_ms":213000,"artists":[{"id":"a","name":"Artist"}],"album":{"id":"b","name":"Album","release_date":"1981-02","release_date_precision":"month"}},"added_at":"2024-01-02T15:04:05Z"}],"next":null,"total":1}`))
+	f.Add([]byte(`{"items":[{"track":null,"episode":{"id":"e","name":"Episode","release_date":"2024","release_date_precision":"year"}}],"total":-5}`))
+	f.Add([]byte(`{"items":[{"track":{"is_local":true,"uri":"spotify:local:a:b:c:12","duration_ms":-1}}]}`))
+	f.Add([]byte(`{"items":null,"next":"","total":9223372036854775807}`))
+	f.Add([]byte(`[]`))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		page := SpotifyPlaylistTracks{}
+		if decodeResponse(body, &page) != nil {
+			return
+		}
+		mp := ConvertToMusicPlaylist(SpotifyPlaylist{TracksCollection: page})
+		if len(mp.Tracks) > len(page.Items) {
+			t.Fatalf("converted %d items into %d tracks", len(page.Items), len(mp.Tracks))
+		}
+	})
+}
+
+// FuzzAPIError checks any error response gives an error with its status
+// and a wait between zero and a day.
+func FuzzAPIError(f *testing.F) {
+	f.Add(404, []byte(`{"error":{"status":404,"message":"Not found."}}`), "")
+	f.Add(400, []byte(`{"error":"invalid_client","error_description":"Invalid client"}`), "")
+	f.Add(429, []byte(``), "30")
+	f.Add(429, []byte(`{"error":null}`), "-1")
+	f.Add(429, []byte(`{"error":{}}`), "99999999999999999")
+	f.Fuzz(func(t *testing.T, status int, body []byte, retry string) {
+		resp := &http.Response{StatusCode: status, Header: http.Header{"Retry-After": {retry}}}
+		err := newAPIError(resp, body)
+		if err.StatusCode != status {
+			t.Fatalf("got status %d, want %d", err.StatusCode, status)
+		}
+		if err.RetryAfter < 0 || err.RetryAfter > 24*time.Hour {
+			t.Fatalf("wait of %s out of bounds", err.RetryAfter)
+		}
+		_ = err.Error()
+	})
+}
+
+// FuzzPrefetch checks paging a playlist stops, with a bounded number of
+// requests, whatever the pages say its total and next links are. {{base}}
+// in a page stands for the API's URL.
+func FuzzPrefetch(f *testing.F) {
+	f.Add(`{"items":[{"track":{"id":"a"}}],"next":"{{base}}/playlists/x/tracks?offset=1&limit=1","total":3}`, 4)
+	f.Add(`{"items":[],"next":"{{base}}/playlists/x/tracks?offset=0&limit=0","total":100}`, 4)
+	f.Add(`{"items":[],"next":"{{base}}/playlists/x/tracks?offset=-5&limit=1","total":1000000000}`, 8)
+	f.Add(`{"items":[],"next":"https://elsewhere.example/steal?offset=1&limit=1","total":2}`, 2)
+	f.Add(`{"next":"{{base}}/playlists/x/tracks?offset=1&limit=1","total":2}`, 0)
+	f.Fuzz(func(t *testing.T, body string, prefetch int) {
+		var requests atomic.Int64
+		var base string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Write([]byte(strings.ReplaceAll(body, "{{base}}", base)))
+		}))
+		defer srv.Close()
+		base = srv.URL + "/v1"
+
+		first := SpotifyPlaylistTracks{}
+		if json.Unmarshal([]byte(strings.ReplaceAll(body, "{{base}}", base)), &first) != nil {
+			return
+		}
+		sp := NewClientWithToken("token", WithBaseURL(base), WithPagePrefetch(prefetch%16))
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		sp.remainingItems(ctx, first)
+		if ctx.Err() != nil {
+			t.Fatal("paging didn't stop")
+		}
+		// every page prefetched, then the same page once more by its link
+		if n := requests.Load(); n > maxPrefetchPages+2 {
+			t.Fatalf("made %d requests", n)
+		}
+	})
+}
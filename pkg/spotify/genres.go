@@ -0,0 +1,51 @@
+package spotify
+
+import (
+	"context"
+)
+
+// TrackGenres fills in the genres of the tracks from those of their
+// artists, fetching the artists not fetched yet in as few requests as it
+// can. A track's genres are deduplicated and in the order its artists
+// list them.
+func (o *Planner) TrackGenres(ctx context.Context, tracks []MusicTrack) error {
+	o.AddTrackArtists(tracks)
+	if err := o.Fetch(ctx); err != nil {
+		return err
+	}
+	for i := range tracks {
+		tracks[i].Genres = nil
+		seen := map[string]bool{}
+		for _, listed := range tracks[i].ArtistList {
+			artist, ok := o.Artist(listed.IntegrationID)
+			if !ok {
+				continue
+			}
+			for _, genre := range artist.Genres {
+				if !seen[genre] {
+					seen[genre] = true
+					tracks[i].Genres = append(tracks[i].Genres, genre)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// PlaylistGenres fills in the genres of the playlist's tracks, as
+// TrackGenres does, and the playlist's count of tracks per genre.
+func (o *Planner) PlaylistGenres(ctx context.Context, mp *MusicPlaylist) error {
+	if err := o.TrackGenres(ctx, mp.Tracks); err != nil {
+		return err
+	}
+	mp.Genres = nil
+	for _, track := range mp.Tracks {
+		for _, genre := range track.Genres {
+			if mp.Genres == nil {
+				mp.Genres = map[string]int{}
+			}
+			mp.Genres[genre]++
+		}
+	}
+	return nil
+}
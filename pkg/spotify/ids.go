@@ -0,0 +1,122 @@
+package spotify
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// Resource types which can be referred to by ID.
+const (
+	TypeTrack    = model.TypeTrack
+	TypeAlbum    = "album"
+	TypeArtist   = "artist"
+	TypePlaylist = "playlist"
+	TypeShow     = "show"
+	TypeEpisode  = model.TypeEpisode
+	TypeUser     = "user"
+)
+
+// Resource is a spotify object identified by its type and ID.
+type Resource struct {
+	Type string
+	ID   string
+}
+
+// URI returns the spotify URI of the resource, e.g. spotify:track:{id}.
+func (o Resource) URI() string {
+	return "spotify:" + o.Type + ":" + o.ID
+}
+
+// URL returns the open.spotify.com link of the resource, e.g.
+// https://open.spotify.com/track/{id}.
+func (o Resource) URL() string {
+	return "https://open.spotify.com/" + o.Type + "/" + o.ID
+}
+
+// ParseResource extracts the type and ID from a spotify URI such as
+// spotify:playlist:{id} or a link such as
+// https://open.spotify.com/playlist/{id}?si=... Bare IDs have no type.
+func ParseResource(input string) (Resource, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return Resource{}, fmt.Errorf("empty spotify id")
+	}
+
+	if strings.HasPrefix(input, "spotify:") {
+		// spotify:{type}:{id}, or the legacy spotify:user:{user}:playlist:{id}
+		parts := strings.Split(input, ":")
+		if len(parts) < 3 || parts[len(parts)-1] == "" {
+			return Resource{}, fmt.Errorf("invalid spotify uri %q", input)
+		}
+		return Resource{Type: parts[len(parts)-2], ID: parts[len(parts)-1]}, nil
+	}
+
+	if strings.Contains(input, "/") {
+		if !strings.Contains(input, "://") {
+			input = "https://" + input
+		}
+		u, err := url.Parse(input)
+		if err != nil {
+			return Resource{}, fmt.Errorf("invalid spotify link %q: %w", input, err)
+		}
+
+		// /{type}/{id}, possibly behind /intl-xx/, /embed/ or /user/{user}/
+		var parts []string
+		for _, part := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+			if part != "" && part != "embed" && !strings.HasPrefix(part, "intl-") {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) < 2 {
+			return Resource{}, fmt.Errorf("invalid spotify link %q", input)
+		}
+		return Resource{Type: parts[len(parts)-2], ID: parts[len(parts)-1]}, nil
+	}
+
+	return Resource{ID: input}, nil
+}
+
+// ParseID returns the ID of a resource of type want given as a bare ID, URI
+// or link, and fails when the URI or link refers to another type, e.g. an
+// album link passed where a playlist is expected.
+func ParseID(input string, want string) (string, error) {
+	resource, err := ParseResource(input)
+	if err != nil {
+		return "", err
+	}
+	if resource.Type != "" && resource.Type != want {
+		return "", fmt.Errorf("%q refers to a spotify %s, expected %s %s", input, resource.Type, article(want), want)
+	}
+	return resource.ID, nil
+}
+
+// ParseURI returns the spotify URI of a resource of type want given as a
+// bare ID, URI or link.
+func ParseURI(input string, want string) (string, error) {
+	id, err := ParseID(input, want)
+	if err != nil {
+		return "", err
+	}
+	return Resource{Type: want, ID: id}.URI(), nil
+}
+
+// resourceEndpoint returns the API URL of a resource of type kind, e.g.
+// {base}/playlists/{id}, given as a bare ID, URI or link.
+func (o *Client) resourceEndpoint(kind string, input string) (string, error) {
+	id, err := ParseID(input, kind)
+	if err != nil {
+		return "", err
+	}
+	return o.endpoint("/" + kind + "s/" + url.PathEscape(id)), nil
+}
+
+// article returns the indefinite article for word.
+func article(word string) string {
+	if word != "" && strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
@@ -0,0 +1,166 @@
+package spotify
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the root of the Spotify Web API.
+	DefaultBaseURL = "https://api.spotify.com/v1"
+	// DefaultAuthURL is the Spotify accounts token endpoint.
+	DefaultAuthURL = "https://accounts.spotify.com/api/token"
+	// DefaultTimeout bounds each HTTP request, retries excluded.
+	DefaultTimeout = 15 * time.Second
+)
+
+// defaultHTTPClient is shared by the clients with no transport options.
+var defaultHTTPClient = &http.Client{Timeout: DefaultTimeout}
+
+// Option configures a Client, see NewClient.
+type Option func(*Client)
+
+// WithHTTPClient makes all API requests through the given client. The
+// transport options, WithTimeout, WithProxy and WithTLSConfig, are then
+// left to it.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Client) {
+		o.httpClient = client
+	}
+}
+
+// WithBaseURL points the API requests at another server, for example
+// a mock server in tests.
+func WithBaseURL(baseURL string) Option {
+	return func(o *Client) {
+		o.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithAuthURL points the token requests at another server.
+func WithAuthURL(authURL string) Option {
+	return func(o *Client) {
+		o.authURL = authURL
+	}
+}
+
+// WithTimeout bounds each HTTP request, DefaultTimeout by default. A
+// whole call, retries included, is bounded by its context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *Client) {
+		o.timeout = timeout
+	}
+}
+
+// WithDeadline makes every call fail once deadline has passed, bounding a
+// whole dump made of many calls as if each context had that deadline.
+func WithDeadline(deadline time.Time) Option {
+	return func(o *Client) {
+		o.deadline = deadline
+	}
+}
+
+// WithProxy sends the requests through the HTTP proxy at proxyURL, e.g.
+// http://proxy.example.com:3128, instead of the one in $HTTPS_PROXY.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(o *Client) {
+		o.proxy = proxyURL
+	}
+}
+
+// WithTLSConfig makes the requests with a TLS configuration of their own,
+// e.g. trusting the CA of an intercepting corporate proxy.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *Client) {
+		o.tlsConfig = config
+	}
+}
+
+// WithMarket requests every resource for the market, an ISO 3166-1
+// alpha-2 country code or "from_token" for the user's own. Spotify then
+// reports availability, relinks tracks to playable versions and returns
+// previews for that region. Calls given a market of their own keep it.
+func WithMarket(market string) Option {
+	return func(o *Client) {
+		o.market = market
+	}
+}
+
+// marketEndpoint adds the client's market to a GET endpoint lacking one.
+func (o *Client) marketEndpoint(endpoint string) string {
+	if o.market == "" || !strings.HasPrefix(endpoint, o.endpoint("/")) {
+		return endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	q := u.Query()
+	if q.Has("market") {
+		return endpoint
+	}
+	q.Set("market", o.market)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// setupHTTPClient builds the http client every request of the Client goes
+// through, once its options are applied, so connections are reused.
+func (o *Client) setupHTTPClient() {
+	if o.httpClient != nil {
+		return
+	}
+	if o.timeout == 0 && o.proxy == nil && o.tlsConfig == nil {
+		o.httpClient = defaultHTTPClient
+		return
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.proxy != nil {
+		transport.Proxy = http.ProxyURL(o.proxy)
+	}
+	if o.tlsConfig != nil {
+		transport.TLSClientConfig = o.tlsConfig
+	}
+	timeout := o.timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	o.httpClient = &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// client returns the http client for API requests.
+func (o *Client) client() *http.Client {
+	if o.httpClient == nil {
+		return defaultHTTPClient
+	}
+	return o.httpClient
+}
+
+// withDeadline bounds ctx by the client's deadline, if any.
+func (o *Client) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, o.deadline)
+}
+
+// endpoint returns the full URL of an API path such as /tracks/{id}.
+func (o *Client) endpoint(path string) string {
+	if o.baseURL == "" {
+		return DefaultBaseURL + path
+	}
+	return o.baseURL + path
+}
+
+// authEndpoint returns the URL of the token endpoint.
+func (o *Client) authEndpoint() string {
+	if o.authURL == "" {
+		return DefaultAuthURL
+	}
+	return o.authURL
+}
@@ -0,0 +1,30 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pager guards a paging loop against malformed responses: a next link
+// back to a page already fetched would be paged through forever, and one
+// off the API would hand the token over to another host.
+type pager struct {
+	base string
+	seen map[string]bool
+}
+
+func (o *Client) newPager() *pager {
+	return &pager{base: o.endpoint("/"), seen: map[string]bool{}}
+}
+
+// visit checks the link to a page before it is fetched.
+func (o *pager) visit(next string) error {
+	if !strings.HasPrefix(next, o.base) {
+		return fmt.Errorf("spotify returned a next page off the API: %q", next)
+	}
+	if o.seen[next] {
+		return fmt.Errorf("spotify returned a next page already fetched: %s", next)
+	}
+	o.seen[next] = true
+	return nil
+}
@@ -0,0 +1,222 @@
+package spotify
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// Planner fetches the albums and artists a whole job needs in as few
+// requests as it can. IDs are collected first, from every playlist of the
+// job, so each entity is fetched once however many tracks share it, and
+// then fetched in full batches. Batches are cut from the sorted IDs, so
+// the same set of entities makes the same requests from run to run and
+// a response cache (see WithCache) answers them.
+//
+// A Planner is safe for concurrent use. Entities fetched once are kept,
+// later Fetch calls only get the ones added since.
+type Planner struct {
+	sp *Client
+
+	mu             sync.Mutex
+	albums         map[string]*SpotifyAlbum
+	artists        map[string]*SpotifyArtist
+	pendingAlbums  []string
+	pendingArtists []string
+	requests       int
+	// failed is told of the entities which couldn't be fetched, when
+	// fetches carry on past them.
+	failed func(kind string, id string, err error)
+}
+
+// NewPlanner returns an empty Planner fetching through the client.
+func (o *Client) NewPlanner() *Planner {
+	return &Planner{
+		sp:      o,
+		albums:  map[string]*SpotifyAlbum{},
+		artists: map[string]*SpotifyArtist{},
+	}
+}
+
+// Tolerate makes Fetch carry on past albums and artists it can't fetch,
+// as FetchBatches does, telling failed of each with its kind, TypeAlbum
+// or TypeArtist. They are left out as if Spotify didn't know them.
+func (o *Planner) Tolerate(failed func(kind string, id string, err error)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failed = failed
+}
+
+// AddAlbums plans to fetch the albums.
+func (o *Planner) AddAlbums(IDs ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range IDs {
+		if _, ok := o.albums[id]; !ok && id != "" {
+			o.albums[id] = nil
+			o.pendingAlbums = append(o.pendingAlbums, id)
+		}
+	}
+}
+
+// AddArtists plans to fetch the artists.
+func (o *Planner) AddArtists(IDs ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range IDs {
+		if _, ok := o.artists[id]; !ok && id != "" {
+			o.artists[id] = nil
+			o.pendingArtists = append(o.pendingArtists, id)
+		}
+	}
+}
+
+// AddTrackAlbums plans to fetch the albums of the tracks. Local files and
+// episodes have none.
+func (o *Planner) AddTrackAlbums(tracks []MusicTrack) {
+	for _, track := range tracks {
+		if track.Source != SourceLocal {
+			o.AddAlbums(track.AlbumID)
+		}
+	}
+}
+
+// AddTrackArtists plans to fetch the artists of the tracks, as listed in
+// their ArtistList.
+func (o *Planner) AddTrackArtists(tracks []MusicTrack) {
+	for _, track := range tracks {
+		if track.Source == SourceLocal {
+			continue
+		}
+		for _, artist := range track.ArtistList {
+			o.AddArtists(artist.IntegrationID)
+		}
+	}
+}
+
+// Fetch fetches the albums and artists added since the last call.
+func (o *Planner) Fetch(ctx context.Context) error {
+	o.mu.Lock()
+	albumIDs, artistIDs := o.pendingAlbums, o.pendingArtists
+	o.pendingAlbums, o.pendingArtists = nil, nil
+	o.mu.Unlock()
+	sort.Strings(albumIDs)
+	sort.Strings(artistIDs)
+
+	o.mu.Lock()
+	failed := o.failed
+	o.mu.Unlock()
+
+	fetchAlbums := func(ctx context.Context, IDs []string) ([]string, error) {
+		albums, err := o.sp.AlbumsFromIDs(ctx, IDs)
+		o.count("albums", len(IDs), MaxAlbumsPerRequest)
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		found := make([]string, len(albums))
+		for i := range albums {
+			o.albums[albums[i].IntegrationID] = &albums[i]
+			found[i] = albums[i].IntegrationID
+		}
+		return found, err
+	}
+	fetchArtists := func(ctx context.Context, IDs []string) ([]string, error) {
+		artists, err := o.sp.ArtistsFromIDs(ctx, IDs)
+		o.count("artists", len(IDs), MaxArtistsPerRequest)
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		found := make([]string, len(artists))
+		for i := range artists {
+			o.artists[artists[i].IntegrationID] = &artists[i]
+			found[i] = artists[i].IntegrationID
+		}
+		return found, err
+	}
+
+	if failed == nil {
+		if _, err := fetchAlbums(ctx, albumIDs); err != nil {
+			o.requeue(albumIDs, artistIDs)
+			return err
+		}
+		if _, err := fetchArtists(ctx, artistIDs); err != nil {
+			o.requeue(nil, artistIDs)
+			return err
+		}
+		return nil
+	}
+
+	err := FetchBatches(ctx, albumIDs, MaxAlbumsPerRequest, fetchAlbums, func(id string, err error) {
+		failed(TypeAlbum, id, err)
+	})
+	if err != nil {
+		o.requeue(albumIDs, artistIDs)
+		return err
+	}
+	err = FetchBatches(ctx, artistIDs, MaxArtistsPerRequest, fetchArtists, func(id string, err error) {
+		failed(TypeArtist, id, err)
+	})
+	if err != nil {
+		o.requeue(nil, artistIDs)
+		return err
+	}
+	return nil
+}
+
+// count adds the batched requests made for n entities of a kind.
+func (o *Planner) count(kind string, n int, batch int) {
+	if n == 0 {
+		return
+	}
+	requests := (n + batch - 1) / batch
+	o.mu.Lock()
+	o.requests += requests
+	o.mu.Unlock()
+	slog.Debug("fetched planned entities", "kind", kind, "entities", n, "requests", requests)
+}
+
+// requeue plans the entities again after a failed fetch, so a retried
+// Fetch picks them up.
+func (o *Planner) requeue(albumIDs []string, artistIDs []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range albumIDs {
+		if o.albums[id] == nil {
+			o.pendingAlbums = append(o.pendingAlbums, id)
+		}
+	}
+	for _, id := range artistIDs {
+		if o.artists[id] == nil {
+			o.pendingArtists = append(o.pendingArtists, id)
+		}
+	}
+}
+
+// Album returns a fetched album, false when it wasn't fetched or Spotify
+// doesn't know it.
+func (o *Planner) Album(ID string) (SpotifyAlbum, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if album := o.albums[ID]; album != nil {
+		return *album, true
+	}
+	return SpotifyAlbum{}, false
+}
+
+// Artist returns a fetched artist, false when it wasn't fetched or
+// Spotify doesn't know it.
+func (o *Planner) Artist(ID string) (SpotifyArtist, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if artist := o.artists[ID]; artist != nil {
+		return *artist, true
+	}
+	return SpotifyArtist{}, false
+}
+
+// Requests returns how many requests the fetches made, against one per
+// entity when fetching them track by track.
+func (o *Planner) Requests() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests
+}
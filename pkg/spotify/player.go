@@ -0,0 +1,153 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SpotifyPlayHistory is a track the user played and when.
+type SpotifyPlayHistory struct {
+	Track    SpotifyTrack `json:"track"`
+	PlayedAt time.Time    `json:"played_at"`
+}
+
+// SpotifyPlayHistoryResult is a container struct for recently played parsing.
+type SpotifyPlayHistoryResult struct {
+	Items []SpotifyPlayHistory `json:"items"`
+	Next  string               `json:"next"`
+}
+
+// RecentlyPlayed hits the Spotify API to get the tracks the user played
+// most recently, at most 50. The token needs the user-read-recently-played
+// scope.
+func (o *Client) RecentlyPlayed(ctx context.Context, limit int) ([]SpotifyPlayHistory, error) {
+	result := SpotifyPlayHistoryResult{}
+	endpoint := o.endpoint("/me/player/recently-played") + "?limit=" + strconv.Itoa(limit)
+	err := o.apiRequest(ctx, "GET", endpoint, nil, &result)
+	return result.Items, err
+}
+
+// PlayHistory pages through the user's listening history played after
+// the time, as far back as Spotify keeps it when after is zero, newest
+// first. Spotify only keeps the last 50 plays or so, archiving the
+// history means fetching it regularly. The token needs the
+// user-read-recently-played scope.
+func (o *Client) PlayHistory(ctx context.Context, after time.Time) ([]SpotifyPlayHistory, error) {
+	query := url.Values{}
+	query.Set("limit", "50")
+	if !after.IsZero() {
+		query.Set("after", strconv.FormatInt(after.UnixMilli(), 10))
+	}
+
+	var history []SpotifyPlayHistory
+	next := o.endpoint("/me/player/recently-played") + "?" + query.Encode()
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return history, err
+		}
+		page := SpotifyPlayHistoryResult{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return history, err
+		}
+		for _, item := range page.Items {
+			if item.PlayedAt.After(after) {
+				history = append(history, item)
+			}
+		}
+		// paging forward from after would repeat the same page
+		if !after.IsZero() {
+			break
+		}
+		next = page.Next
+	}
+	return history, nil
+}
+
+// ConvertToMusicPlay converts a played track to a MusicTrack with the
+// time it was played.
+func ConvertToMusicPlay(item SpotifyPlayHistory) MusicTrack {
+	musicTrack := ConvertToMusicTrack(item.Track)
+	musicTrack.PlayedAt = NormalizeTime(item.PlayedAt)
+	return musicTrack
+}
+
+// Time ranges of the user's top items.
+const (
+	// TimeRangeShort is about the last four weeks.
+	TimeRangeShort = "short_term"
+	// TimeRangeMedium is about the last six months.
+	TimeRangeMedium = "medium_term"
+	// TimeRangeLong is about the last year.
+	TimeRangeLong = "long_term"
+)
+
+// MaxTopItems is the most top items Spotify lists.
+const MaxTopItems = 99
+
+// TopTracks pages through the user's most listened tracks over the time
+// range, at most limit of them, the most listened first. The token needs
+// the user-top-read scope.
+func (o *Client) TopTracks(ctx context.Context, timeRange string, limit int) ([]SpotifyTrack, error) {
+	var tracks []SpotifyTrack
+	err := o.topItems(ctx, "tracks", timeRange, limit, func(next string) (string, int, error) {
+		page := SpotifyTracksResult{}
+		err := o.apiRequest(ctx, "GET", next, nil, &page)
+		tracks = append(tracks, page.Items...)
+		return page.Next, len(tracks), err
+	})
+	if limit > 0 && len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, err
+}
+
+// TopArtists pages through the user's most listened artists over the time
+// range, at most limit of them, the most listened first. The token needs
+// the user-top-read scope.
+func (o *Client) TopArtists(ctx context.Context, timeRange string, limit int) ([]SpotifyArtist, error) {
+	var artists []SpotifyArtist
+	err := o.topItems(ctx, "artists", timeRange, limit, func(next string) (string, int, error) {
+		page := struct {
+			Items []SpotifyArtist `json:"items"`
+			Next  string          `json:"next"`
+		}{}
+		err := o.apiRequest(ctx, "GET", next, nil, &page)
+		artists = append(artists, page.Items...)
+		return page.Next, len(artists), err
+	})
+	if limit > 0 && len(artists) > limit {
+		artists = artists[:limit]
+	}
+	return artists, err
+}
+
+// topItems pages through /me/top/{kind}, fetchPage requesting a page and
+// returning the next link and how many items there are so far.
+func (o *Client) topItems(ctx context.Context, kind string, timeRange string, limit int, fetchPage func(next string) (string, int, error)) error {
+	if limit <= 0 || limit > MaxTopItems {
+		limit = MaxTopItems
+	}
+	query := url.Values{}
+	query.Set("time_range", timeRange)
+	query.Set("limit", strconv.Itoa(min(limit, 50)))
+
+	next := o.endpoint("/me/top/"+kind) + "?" + query.Encode()
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return err
+		}
+		var n int
+		var err error
+		if next, n, err = fetchPage(next); err != nil {
+			return err
+		}
+		if n >= limit {
+			break
+		}
+	}
+	return nil
+}
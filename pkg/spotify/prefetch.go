@@ -0,0 +1,100 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// WithPagePrefetch fetches up to n pages of a playlist's tracks at once.
+// The first page tells how many tracks the playlist has, the others are
+// then requested by offset in parallel and put back in order, which cuts
+// the time a playlist of thousands of tracks takes several times. The
+// requests still go through the client's adaptive limiter, if any. Pages
+// are fetched one after another when n is below 2, the default.
+func WithPagePrefetch(n int) Option {
+	return func(o *Client) {
+		o.prefetch = n
+	}
+}
+
+// maxPrefetchPages is the most pages prefetched, so a bogus total can't
+// queue up millions of requests. Any further pages are fetched one by one.
+const maxPrefetchPages = 1000
+
+// remainingItems fetches the pages of a playlist's tracks following the
+// first one, returning all of the items.
+func (o *Client) remainingItems(ctx context.Context, first SpotifyPlaylistTracks) ([]SpotifyPlaylistTrack, error) {
+	items := first.Items
+	next := first.Next
+	if next != "" && o.prefetch > 1 {
+		var err error
+		if items, next, err = o.prefetchPages(ctx, first); err != nil {
+			return items, err
+		}
+	}
+
+	// the rest, or every page without prefetching
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return items, err
+		}
+		page := SpotifyPlaylistTracks{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return items, err
+		}
+		items = append(items, page.Items...)
+		next = page.Next
+	}
+	return items, nil
+}
+
+// prefetchPages fetches the pages up to the total the first page reports
+// in parallel. It returns the items and the next link of the last page,
+// set when the playlist grew meanwhile or the link can't be paged by
+// offset.
+func (o *Client) prefetchPages(ctx context.Context, first SpotifyPlaylistTracks) ([]SpotifyPlaylistTrack, string, error) {
+	u, err := url.Parse(first.Next)
+	if err != nil || o.newPager().visit(first.Next) != nil {
+		// paged one by one, which reports the bad link
+		return first.Items, first.Next, nil
+	}
+	q := u.Query()
+	offset, errOffset := strconv.Atoi(q.Get("offset"))
+	limit, errLimit := strconv.Atoi(q.Get("limit"))
+	if errOffset != nil || errLimit != nil || limit <= 0 {
+		return first.Items, first.Next, nil
+	}
+
+	var endpoints []string
+	for ; offset < first.Total && len(endpoints) < maxPrefetchPages; offset += limit {
+		q.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = q.Encode()
+		endpoints = append(endpoints, u.String())
+	}
+	if len(endpoints) == 0 {
+		return first.Items, first.Next, nil
+	}
+
+	pages := make([]SpotifyPlaylistTracks, len(endpoints))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(o.prefetch)
+	for i, endpoint := range endpoints {
+		i, endpoint := i, endpoint
+		g.Go(func() error {
+			return o.apiRequest(gctx, "GET", endpoint, nil, &pages[i])
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return first.Items, "", err
+	}
+
+	items := first.Items
+	for _, page := range pages {
+		items = append(items, page.Items...)
+	}
+	return items, pages[len(pages)-1].Next, nil
+}
@@ -0,0 +1,76 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// DefaultEmbedURL is the root of the public embed player pages.
+const DefaultEmbedURL = "https://open.spotify.com/embed"
+
+// PreviewFromEmbed marks a PreviewURL found by EmbedPreview rather than
+// given by the API.
+const PreviewFromEmbed = model.PreviewFromEmbed
+
+// maxEmbedPage is the most of an embed page read looking for a preview.
+const maxEmbedPage = 4 << 20
+
+// embedPreview finds the preview in the page data of the embed player.
+var embedPreview = regexp.MustCompile(`"audioPreview"\s*:\s*\{\s*"url"\s*:\s*("(?:[^"\\]|\\.)*")`)
+
+// WithEmbedURL points the embed player lookups of EmbedPreview at another
+// server.
+func WithEmbedURL(embedURL string) Option {
+	return func(o *Client) {
+		o.embedURL = embedURL
+	}
+}
+
+// EmbedPreview looks up the preview of a track in the public embed player,
+// which often has one when the API gives none to client credentials. It is
+// best effort: the page isn't a documented API and may change, a track
+// without a preview there returns an empty URL and no error.
+func (o *Client) EmbedPreview(ctx context.Context, trackID string) (string, error) {
+	base := o.embedURL
+	if base == "" {
+		base = DefaultEmbedURL
+	}
+	ctx, cancel := o.withDeadline(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/track/"+url.PathEscape(trackID), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", nil
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("embed page of %s: %s", trackID, resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxEmbedPage))
+	if err != nil {
+		return "", err
+	}
+	match := embedPreview.FindSubmatch(page)
+	if match == nil {
+		return "", nil
+	}
+	var preview string
+	if err := json.Unmarshal(match[1], &preview); err != nil {
+		return "", fmt.Errorf("embed page of %s: %w", trackID, err)
+	}
+	return preview, nil
+}
@@ -0,0 +1,117 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxProxyBody caps the request bodies a Proxy forwards, a playlist cover
+// upload being the largest.
+const maxProxyBody = 4 << 20
+
+// ProxyOptions configures a Proxy.
+type ProxyOptions struct {
+	// AllowWrites forwards requests changing the account, such as
+	// POST /v1/playlists/{id}/tracks, as well as GET and HEAD ones.
+	AllowWrites bool
+}
+
+// Proxy returns a handler forwarding Web API requests, such as
+// GET /v1/tracks/{id}, to Spotify through the client: with its token
+// rather than the caller's, answered from its cache when it has one, and
+// paced and retried like the client's own requests. Tools sharing a
+// client through the proxy share its token, its cache and its rate.
+//
+// Successful answers are passed on with their body, 204 when empty. An
+// error answer is passed on with its status and Retry-After, with a body
+// in Spotify's error format. Only the body of a response is forwarded,
+// not its headers, so callers can't revalidate by ETag themselves; the
+// client's cache does. Links to the API in a body, such as the next page,
+// are rewritten to point at the proxy.
+//
+// Only GET and HEAD requests are forwarded, others are answered 405,
+// unless opts.AllowWrites is set.
+func (o *Client) Proxy(opts ProxyOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !opts.AllowWrites {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeProxyError(w, &APIError{StatusCode: http.StatusMethodNotAllowed, Message: r.Method + " isn't forwarded, the proxy is read only"})
+			return
+		}
+		path, ok := strings.CutPrefix(r.URL.Path, "/v1/")
+		if !ok {
+			writeProxyError(w, &APIError{StatusCode: http.StatusNotFound, Message: "not a Web API path, they start with /v1/"})
+			return
+		}
+		endpoint := o.endpoint("/" + path)
+		if r.URL.RawQuery != "" {
+			endpoint += "?" + r.URL.RawQuery
+		}
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxProxyBody))
+			if err != nil {
+				writeProxyError(w, &APIError{StatusCode: http.StatusRequestEntityTooLarge, Message: err.Error()})
+				return
+			}
+			if len(body) == 0 {
+				body = nil
+			}
+		}
+
+		var out json.RawMessage
+		err := o.rawRequest(r.Context(), r.Method, endpoint, r.Header.Get("Content-Type"), body, &out)
+		var apiErr *APIError
+		switch {
+		case errors.As(err, &apiErr):
+			writeProxyError(w, apiErr)
+			return
+		case errors.Is(err, context.Canceled) && r.Context().Err() != nil:
+			// the caller went away
+			return
+		case err != nil:
+			slog.Warn("proxy request failed", "method", r.Method, "url", endpoint, "err", err)
+			writeProxyError(w, &APIError{StatusCode: http.StatusBadGateway, Message: err.Error()})
+			return
+		}
+		if len(out) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(o.proxyLinks(r, out))
+	})
+}
+
+// proxyLinks points the API links in a response, such as the next page,
+// at the proxy, so callers paging through results stay behind it.
+func (o *Client) proxyLinks(r *http.Request, body []byte) []byte {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return bytes.ReplaceAll(body, []byte(`"`+o.endpoint("/")), []byte(`"`+scheme+"://"+r.Host+"/v1/"))
+}
+
+// writeProxyError answers a proxied request with an error as Spotify
+// would.
+func writeProxyError(w http.ResponseWriter, apiErr *APIError) {
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(apiErr.StatusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": spotifyErrorObject{Status: apiErr.StatusCode, Message: apiErr.Message},
+	})
+}
@@ -0,0 +1,47 @@
+package spotify_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+func TestProxyReadOnly(t *testing.T) {
+	a := newAPI(t)
+	a.mux.HandleFunc("/v1/playlists/x/tracks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"snapshot_id": r.Method})
+	})
+	sp := a.client(t)
+
+	for _, tc := range []struct {
+		method      string
+		allowWrites bool
+		want        int
+	}{
+		{"GET", false, http.StatusOK},
+		{"HEAD", false, http.StatusNoContent},
+		{"POST", false, http.StatusMethodNotAllowed},
+		{"DELETE", false, http.StatusMethodNotAllowed},
+		{"PUT", false, http.StatusMethodNotAllowed},
+		{"POST", true, http.StatusOK},
+		{"DELETE", true, http.StatusOK},
+	} {
+		before := a.requests.Load()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.method, "/v1/playlists/x/tracks", strings.NewReader(`{"uris":[]}`))
+		sp.Proxy(spotify.ProxyOptions{AllowWrites: tc.allowWrites}).ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s with writes allowed %v: got %d, want %d", tc.method, tc.allowWrites, w.Code, tc.want)
+		}
+		forwarded := a.requests.Load() != before
+		if forwarded != (tc.want != http.StatusMethodNotAllowed) {
+			t.Errorf("%s with writes allowed %v: forwarded %v", tc.method, tc.allowWrites, forwarded)
+		}
+		if tc.want == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("%s refused without an Allow header", tc.method)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package spotify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pacer spaces a client's requests evenly, so however many callers share
+// the client it never sends more than its rate. Requests answered from
+// the cache without revalidating aren't paced.
+type pacer struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// WithRateLimit sends at most perSecond requests a second, spread evenly.
+// It is off when perSecond isn't positive.
+func WithRateLimit(perSecond float64) Option {
+	return func(o *Client) {
+		if perSecond <= 0 {
+			o.pacer = nil
+			return
+		}
+		o.pacer = &pacer{interval: time.Duration(float64(time.Second) / perSecond)}
+	}
+}
+
+// wait holds a request until its turn.
+func (o *pacer) wait(ctx context.Context) error {
+	o.mu.Lock()
+	now := time.Now()
+	at := o.next
+	if at.Before(now) {
+		at = now
+	}
+	o.next = at.Add(o.interval)
+	o.mu.Unlock()
+
+	return sleep(ctx, time.Until(at))
+}
@@ -0,0 +1,63 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SearchTypes are the item types which can be searched for.
+var SearchTypes = []string{"album", "artist", "playlist", "track"}
+
+// SpotifyArtistsResult is a container struct for artist search results.
+type SpotifyArtistsResult struct {
+	Items []SpotifyArtist `json:"items"`
+}
+
+// SpotifySearchResult holds the results of a search, one collection per
+// searched type.
+type SpotifySearchResult struct {
+	Albums    SpotifyAlbumsResult    `json:"albums"`
+	Artists   SpotifyArtistsResult   `json:"artists"`
+	Playlists SpotifyPlaylistsResult `json:"playlists"`
+	Tracks    SpotifyTracksResult    `json:"tracks"`
+}
+
+// SearchOptions narrows down a search.
+type SearchOptions struct {
+	// Types to search for, all of SearchTypes when empty.
+	Types []string
+	// Market is an ISO 3166-1 alpha-2 country code, results are only
+	// returned when available there.
+	Market string
+	// Limit is the number of results per type, 1 to 50.
+	Limit  int
+	Offset int
+}
+
+// Search hits the Spotify API to search for items matching query.
+func (o *Client) Search(ctx context.Context, query string, opts SearchOptions) (SpotifySearchResult, error) {
+	result := SpotifySearchResult{}
+
+	types := opts.Types
+	if len(types) == 0 {
+		types = SearchTypes
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", strings.Join(types, ","))
+	if opts.Market != "" {
+		params.Set("market", opts.Market)
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		params.Set("offset", strconv.Itoa(opts.Offset))
+	}
+
+	err := o.apiRequest(ctx, "GET", o.endpoint("/search")+"?"+params.Encode(), nil, &result)
+	return result, err
+}
@@ -0,0 +1,693 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyrat/spd/pkg/model"
+)
+
+// Client makes requests against the Spotify Web API. Token is a static
+// access token, used when there is no TokenProvider.
+type Client struct {
+	Token        string
+	ClientID     string
+	ClientSecret string
+
+	httpClient *http.Client
+	timeout    time.Duration
+	deadline   time.Time
+	proxy      *url.URL
+	tlsConfig  *tls.Config
+	baseURL    string
+	authURL    string
+	cache      *diskCache
+	market     string
+	tokens     TokenProvider
+	counters   counters
+	adaptive   *Adaptive
+	pacer      *pacer
+	prefetch   int
+	embedURL   string
+}
+
+// SpotifyPlaylistTracks is a container struct for playlist tracks parsing.
+// Next holds the URL of the following page, empty on the last page.
+type SpotifyPlaylistTracks struct {
+	Items []SpotifyPlaylistTrack `json:"items"`
+	Next  string                 `json:"next"`
+	Total int                    `json:"total"`
+}
+
+// SpotifyPlaylistTrack is a container struct for playlist tracks parsing.
+// Episode is set instead of Track when the item is a podcast episode.
+type SpotifyPlaylistTrack struct {
+	Track   SpotifyTrack
+	Episode *SpotifyEpisode
+	AddedAt time.Time
+	// AddedBy is the user who added the item, only its ID is filled in.
+	AddedBy SpotifyUser
+}
+
+// SpotifyAlbumsResult is also a container struct
+type SpotifyAlbumsResult struct {
+	Items []SpotifyAlbum `json:"items"`
+	Next  string         `json:"next"`
+}
+
+// SpotifyPlaylistsResult is also a container struct
+type SpotifyPlaylistsResult struct {
+	Items []SpotifyPlaylist `json:"items"`
+	Next  string            `json:"next"`
+}
+
+// SpotifyTrack describes a spotify track.
+type SpotifyTrack struct {
+	Album         SpotifyAlbum       `json:"album"`
+	Name          string             `json:"name"`
+	PreviewURL    string             `json:"preview_url"`
+	TrackURI      string             `json:"uri"`
+	IntegrationID string             `json:"id"`
+	DurationMS    int                `json:"duration_ms"`
+	ExternalURL   SpotifyExternalURL `json:"external_urls"`
+	Artists       []SpotifyArtist    `json:"artists"`
+	IsPlayable    *bool              `json:"is_playable"`
+	IsLocal       bool               `json:"is_local"`
+	Explicit      bool               `json:"explicit"`
+	DiscNumber    int                `json:"disc_number"`
+	TrackNumber   int                `json:"track_number"`
+	// AvailableMarkets is only filled in when no market is requested,
+	// IsPlayable only when one is.
+	AvailableMarkets []string           `json:"available_markets"`
+	ExternalIDs      SpotifyExternalIDs `json:"external_ids"`
+	Type             string             `json:"type"`
+	// LinkedFrom is the track as added to the playlist when Spotify
+	// relinked it to a version playable in the requested market.
+	LinkedFrom *SpotifyLinkedTrack `json:"linked_from,omitempty"`
+	// Restrictions tells why a track isn't playable in the market.
+	Restrictions *SpotifyRestrictions `json:"restrictions,omitempty"`
+}
+
+// ImageURLs Returns a space separated list of image urls in decreasing size.
+func (o *SpotifyTrack) ImageURLs() (urls string) {
+	for _, image := range o.Album.Images {
+		urls += image.URL + " "
+	}
+	return strings.TrimSpace(urls)
+}
+
+// CombineArtists combines the artists to fit in a db field for DB
+func (o *SpotifyTrack) CombineArtists() (artists string) {
+	var artistNames []string
+	for _, artist := range o.Artists {
+		artistNames = append(artistNames, artist.Name)
+	}
+	return strings.Join(artistNames, ", ")
+}
+
+// SpotifyAlbum describes a spotify album.
+type SpotifyAlbum struct {
+	Name          string              `json:"name"`
+	Images        []SpotifyAlbumImage `json:"images"`
+	URI           string              `json:"uri"`
+	ExternalURL   SpotifyExternalURL  `json:"external_urls"`
+	IntegrationID string              `json:"id"`
+	ReleaseDate   string              `json:"release_date"`
+	// ReleaseDatePrecision is how precise ReleaseDate is: year, month or
+	// day.
+	ReleaseDatePrecision string              `json:"release_date_precision"`
+	AlbumType            string              `json:"album_type"`
+	AlbumGroup           string              `json:"album_group"`
+	Artists              []SpotifyArtist     `json:"artists"`
+	TracksCollection     SpotifyTracksResult `json:"tracks"`
+	// ExternalIDs and Label are only included in full album objects,
+	// not in the album of a track.
+	ExternalIDs SpotifyExternalIDs `json:"external_ids"`
+	Label       string             `json:"label"`
+}
+
+// ImageURLs Returns a space separated list of image urls in decreasing size.
+func (o *SpotifyAlbum) ImageURLs() (urls string) {
+	for _, image := range o.Images {
+		urls += image.URL + " "
+	}
+	return strings.TrimSpace(urls)
+}
+
+// SpotifyAlbumImage describes an album cover in one of its sizes.
+type SpotifyAlbumImage = model.Image
+
+// SpotifyPlaylist describes a spotify playlist.
+type SpotifyPlaylist struct {
+	Name             string                 `json:"name"`
+	Images           []SpotifyPlaylistImage `json:"images"`
+	URI              string                 `json:"uri"`
+	ExternalURL      SpotifyExternalURL     `json:"external_urls"`
+	IntegrationID    string                 `json:"id"`
+	SnapshotID       string                 `json:"snapshot_id"`
+	TracksCollection SpotifyPlaylistTracks  `json:"tracks"`
+	Description      string                 `json:"description"`
+	Owner            SpotifyUser            `json:"owner"`
+	Public           *bool                  `json:"public"`
+	Collaborative    bool                   `json:"collaborative"`
+	// Followers is only included in full playlist objects.
+	Followers SpotifyFollowers `json:"followers"`
+}
+
+// SpotifyFollowers counts the followers of a playlist or artist.
+type SpotifyFollowers struct {
+	Total int `json:"total"`
+}
+
+// SpotifyPlaylistImage describes a playlist cover in one of its sizes.
+type SpotifyPlaylistImage = model.Image
+
+// SpotifyExternalURL describes a spotify external url.
+type SpotifyExternalURL struct {
+	Spotify string `json:"spotify"`
+}
+
+// SpotifyExternalIDs holds the industry codes of a track or album,
+// which identify it on other services too.
+type SpotifyExternalIDs struct {
+	ISRC string `json:"isrc"`
+	EAN  string `json:"ean"`
+	UPC  string `json:"upc"`
+}
+
+// SpotifyArtist describes a spotify artist.
+type SpotifyArtist struct {
+	Name          string              `json:"name"`
+	IntegrationID string              `json:"id"`
+	URI           string              `json:"uri"`
+	Genres        []string            `json:"genres"`
+	Images        []SpotifyAlbumImage `json:"images"`
+	Popularity    int                 `json:"popularity"`
+	ExternalURL   SpotifyExternalURL  `json:"external_urls"`
+}
+
+// SpotifyUser describes a spotify user profile.
+type SpotifyUser struct {
+	DisplayName   string `json:"display_name"`
+	IntegrationID string `json:"id"`
+}
+
+// MusicTrack is a track or episode as spdump writes it, see package model
+// for the MusicX types.
+type MusicTrack = model.MusicTrack
+
+// MusicAlbum is an album as spdump writes it.
+type MusicAlbum = model.MusicAlbum
+
+// MusicPlaylist is a playlist as spdump writes it.
+type MusicPlaylist = model.MusicPlaylist
+
+// MusicUser describes the owner of a playlist.
+type MusicUser = model.MusicUser
+
+// MusicArtist describes a music artist in a generic way.
+type MusicArtist = model.MusicArtist
+
+// PurchaseLink is a store selling an album.
+type PurchaseLink = model.PurchaseLink
+
+// NewClient initialises a Client. Unless WithTokenProvider is passed,
+// tokens are requested with the client credentials, and the first one
+// right away, bounded by ctx, so bad credentials fail early.
+func NewClient(ctx context.Context, clientID string, clientSecret string, opts ...Option) (*Client, error) {
+	sp := &Client{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}
+	for _, opt := range opts {
+		opt(sp)
+	}
+	sp.setupHTTPClient()
+	if refresh, ok := sp.tokens.(*RefreshToken); ok && refresh.Client == nil {
+		refresh.Client = sp.client()
+	}
+	if sp.tokens == nil {
+		sp.tokens = &ClientCredentials{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			AuthURL:      sp.authEndpoint(),
+			Client:       sp.client(),
+		}
+	}
+
+	if _, err := sp.getToken(ctx); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// NewClientWithToken initialises a Client from an existing user
+// access token. Endpoints which act on a user's account (creating playlists,
+// adding tracks) cannot be used with a client credentials token.
+func NewClientWithToken(token string, opts ...Option) *Client {
+	sp := &Client{
+		Token: token,
+	}
+	for _, opt := range opts {
+		opt(sp)
+	}
+	sp.setupHTTPClient()
+	return sp
+}
+
+// getToken gets the token for Spotify API access from the token provider,
+// or the static Token when there is none.
+func (o *Client) getToken(ctx context.Context) (string, error) {
+	if o.tokens != nil {
+		return o.tokens.Token(ctx)
+	}
+	return StaticToken(o.Token).Token(ctx)
+}
+
+// TrackFromID hits the Spotify API to get Track information.
+func (o *Client) TrackFromID(ctx context.Context, ID string) (SpotifyTrack, error) {
+	st := SpotifyTrack{}
+	endpoint, err := o.resourceEndpoint(TypeTrack, ID)
+	if err != nil {
+		return st, err
+	}
+	err = o.apiRequest(ctx, "GET", endpoint, nil, &st)
+	return st, err
+}
+
+// AlbumFromID hits the Spotify API to get Album information.
+func (o *Client) AlbumFromID(ctx context.Context, ID string) (SpotifyAlbum, error) {
+	album := SpotifyAlbum{}
+	endpoint, err := o.resourceEndpoint(TypeAlbum, ID)
+	if err != nil {
+		return album, err
+	}
+	if err := o.apiRequest(ctx, "GET", endpoint, nil, &album); err != nil {
+		return album, err
+	}
+
+	// long albums only embed the first page of tracks
+	next := album.TracksCollection.Next
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return album, err
+		}
+		page := SpotifyTracksResult{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return album, err
+		}
+		album.TracksCollection.Items = append(album.TracksCollection.Items, page.Items...)
+		next = page.Next
+	}
+	album.TracksCollection.Next = ""
+
+	return album, nil
+}
+
+// MaxAlbumsPerRequest is the most albums fetched by one request.
+const MaxAlbumsPerRequest = 20
+
+// AlbumsFromIDs hits the Spotify API to get several albums, in batches of
+// MaxAlbumsPerRequest. Albums Spotify doesn't know are left out. The
+// album tracks aren't paginated, only the first page is included.
+func (o *Client) AlbumsFromIDs(ctx context.Context, IDs []string) ([]SpotifyAlbum, error) {
+	var albums []SpotifyAlbum
+	for _, batch := range Chunk(IDs, MaxAlbumsPerRequest) {
+		result := struct {
+			Albums []*SpotifyAlbum `json:"albums"`
+		}{}
+		endpoint := o.endpoint("/albums") + "?ids=" + url.QueryEscape(strings.Join(batch, ","))
+		if err := o.apiRequest(ctx, "GET", endpoint, nil, &result); err != nil {
+			return albums, err
+		}
+		for _, album := range result.Albums {
+			if album != nil {
+				albums = append(albums, *album)
+			}
+		}
+	}
+	return albums, nil
+}
+
+// PlaylistFromID hits the Spotify API to get Playlist information with
+// all of its tracks.
+func (o *Client) PlaylistFromID(ctx context.Context, ID string) (SpotifyPlaylist, error) {
+	playlist := SpotifyPlaylist{}
+	endpoint, err := o.resourceEndpoint(TypePlaylist, ID)
+	if err != nil {
+		return playlist, err
+	}
+	if err := o.apiRequest(ctx, "GET", endpoint+"?"+additionalTypes, nil, &playlist); err != nil {
+		return playlist, err
+	}
+
+	// the playlist only embeds the first page of tracks, follow
+	// the next links to collect the rest.
+	items, err := o.remainingItems(ctx, playlist.TracksCollection)
+	if err != nil {
+		return playlist, err
+	}
+	playlist.TracksCollection.Items = items
+	playlist.TracksCollection.Next = ""
+
+	return playlist, nil
+}
+
+// MaxPageSize is the most playlist items fetched by one request.
+const MaxPageSize = 100
+
+// PlaylistSummaryFromID hits the Spotify API to get Playlist information
+// without any of its tracks, only their number in TracksCollection.Total.
+func (o *Client) PlaylistSummaryFromID(ctx context.Context, ID string) (SpotifyPlaylist, error) {
+	playlist := SpotifyPlaylist{}
+	endpoint, err := o.resourceEndpoint(TypePlaylist, ID)
+	if err != nil {
+		return playlist, err
+	}
+	err = o.apiRequest(ctx, "GET", endpoint+"?fields=name,images,uri,external_urls,id,snapshot_id,description,owner,public,collaborative,followers,tracks.total", nil, &playlist)
+	return playlist, err
+}
+
+// PlaylistTracks pages through the tracks of a playlist calling fn with each
+// page as it arrives, so huge playlists never have to be held in memory.
+func (o *Client) PlaylistTracks(ctx context.Context, ID string, fn func(page SpotifyPlaylistTracks) error) error {
+	endpoint, err := o.resourceEndpoint(TypePlaylist, ID)
+	if err != nil {
+		return err
+	}
+
+	next := endpoint + "/tracks?limit=" + strconv.Itoa(MaxPageSize) + "&" + additionalTypes
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return err
+		}
+		page := SpotifyPlaylistTracks{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		next = page.Next
+	}
+	return nil
+}
+
+// CurrentUser hits the Spotify API to get the profile of the user owning the token.
+func (o *Client) CurrentUser(ctx context.Context) (SpotifyUser, error) {
+	user := SpotifyUser{}
+	err := o.apiRequest(ctx, "GET", o.endpoint("/me"), nil, &user)
+	return user, err
+}
+
+// CreatePlaylist creates a new playlist in the given user's account.
+func (o *Client) CreatePlaylist(ctx context.Context, userID string, name string, public bool) (SpotifyPlaylist, error) {
+	playlist := SpotifyPlaylist{}
+	endpoint, err := o.resourceEndpoint(TypeUser, userID)
+	if err != nil {
+		return playlist, err
+	}
+	payload := map[string]interface{}{
+		"name":   name,
+		"public": public,
+	}
+	err = o.apiRequest(ctx, "POST", endpoint+"/playlists", payload, &playlist)
+	return playlist, err
+}
+
+// SetPlaylistVisibility makes the playlist public or private and
+// collaborative or not. Only private playlists can be collaborative.
+func (o *Client) SetPlaylistVisibility(ctx context.Context, playlistID string, public bool, collaborative bool) error {
+	if public && collaborative {
+		return errors.New("a collaborative playlist can't be public")
+	}
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"public":        public,
+		"collaborative": collaborative,
+	}
+	return o.apiRequest(ctx, "PUT", endpoint, payload, nil)
+}
+
+// apiRequest makes an authorised request against the Spotify API, encoding
+// payload as the JSON body when set and decoding the response into out.
+func (o *Client) apiRequest(ctx context.Context, method string, endpoint string, payload interface{}, out interface{}) error {
+	if payload == nil {
+		return o.rawRequest(ctx, method, endpoint, "", nil, out)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return o.rawRequest(ctx, method, endpoint, "application/json", encoded, out)
+}
+
+// rawRequest makes an authorised request against the Spotify API with
+// a body of the given content type, decoding the response into out.
+// Rate limited and failed requests are retried up to maxRetries times.
+func (o *Client) rawRequest(ctx context.Context, method string, endpoint string, contentType string, reqBody []byte, out interface{}) error {
+	if method == "GET" {
+		endpoint = o.marketEndpoint(endpoint)
+	}
+	ctx, cancel := o.withDeadline(ctx)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		err := o.doRequest(ctx, method, endpoint, contentType, reqBody, out)
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !retryable(method, apiErr.StatusCode) || attempt == maxRetries {
+			return err
+		}
+
+		wait := retryWait(apiErr, attempt)
+		slog.Warn("retrying spotify request", "method", method, "url", endpoint, "status", apiErr.StatusCode, "wait", wait)
+		o.counters.retries.Add(1)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// doRequest makes a single attempt of rawRequest.
+func (o *Client) doRequest(ctx context.Context, method string, endpoint string, contentType string, reqBody []byte, out interface{}) error {
+	cached, isCached := cacheEntry{}, false
+	if o.cache != nil && method == "GET" {
+		if cached, isCached = o.cache.get(endpoint); isCached && cached.fresh() {
+			slog.Debug("spotify response still fresh, using cache", "url", endpoint)
+			o.counters.cacheHits.Add(1)
+			o.counters.cacheFresh.Add(1)
+			return decodeResponse(cached.Body, out)
+		}
+	}
+
+	var body io.Reader
+	if reqBody != nil {
+		body = bytes.NewReader(reqBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	// Always get the token before making the request
+	// to avoid making a request with an expired token.
+	token, err := o.getToken(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			o.counters.errors.Add(1)
+		}
+		return err
+	}
+
+	req.Header.Add("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
+	}
+
+	if isCached && cached.ETag != "" {
+		req.Header.Add("If-None-Match", cached.ETag)
+	}
+
+	if o.pacer != nil {
+		if err := o.pacer.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if o.adaptive != nil {
+		if err := o.adaptive.acquire(ctx); err != nil {
+			return err
+		}
+	}
+	o.counters.requests.Add(1)
+	o.counters.bytesSent.Add(int64(len(reqBody)))
+	slog.Debug("spotify request", "method", method, "url", endpoint)
+	start := time.Now()
+	resp, err := o.client().Do(req)
+	if err != nil {
+		if o.adaptive != nil {
+			o.adaptive.release(time.Since(start), 0, 0)
+		}
+		if ctx.Err() == nil {
+			o.counters.errors.Add(1)
+		}
+		return fmt.Errorf("error making call to spotify : %s %s: %w", method, endpoint, err)
+	}
+
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	o.counters.bytesReceived.Add(int64(len(respBody)))
+	if o.adaptive != nil {
+		o.adaptive.release(time.Since(start), resp.StatusCode, retryAfter(resp))
+	}
+
+	if resp.StatusCode == http.StatusNotModified && isCached && cached.ETag != "" {
+		slog.Debug("spotify response not modified, using cache", "url", endpoint)
+		o.counters.cacheHits.Add(1)
+		respBody = cached.Body
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		slog.Debug("spotify error response", "method", method, "url", endpoint, "status", resp.StatusCode, "body", string(respBody))
+		if resp.StatusCode != http.StatusTooManyRequests {
+			o.counters.errors.Add(1)
+		}
+		return newAPIError(resp, respBody)
+	} else if o.cache != nil && method == "GET" {
+		if err := o.cache.put(endpoint, resp.Header, respBody); err != nil {
+			slog.Warn("unable to cache spotify response", "url", endpoint, "err", err)
+		}
+	}
+
+	return decodeResponse(respBody, out)
+}
+
+// decodeResponse decodes a response body into out, if any.
+func decodeResponse(body []byte, out interface{}) error {
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid JSON response from spotify: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertToMusicPlaylist converts a SpotifyPlaylist struct to a MusicPlaylist struct
+func ConvertToMusicPlaylist(sp SpotifyPlaylist) MusicPlaylist {
+	playlist := MusicPlaylist{
+		Name:          sp.Name,
+		Description:   html.UnescapeString(sp.Description),
+		Public:        sp.Public,
+		Collaborative: sp.Collaborative,
+		Followers:     sp.Followers.Total,
+		IntegrationID: sp.IntegrationID,
+		SnapshotID:    sp.SnapshotID,
+		PlaylistArt:   sp.Images,
+	}
+	if sp.Owner.IntegrationID != "" {
+		playlist.Owner = &MusicUser{Name: sp.Owner.DisplayName, IntegrationID: sp.Owner.IntegrationID}
+	}
+
+	if len(sp.TracksCollection.Items) > 0 {
+		for _, item := range sp.TracksCollection.Items {
+			playlist.Tracks = append(playlist.Tracks, ConvertToMusicPlaylistTrack(item))
+		}
+	}
+
+	return playlist
+}
+
+// ConvertToMusicPlaylistTrack converts a SpotifyPlaylistTrack struct to a
+// MusicTrack struct, keeping when the track was added to the playlist.
+func ConvertToMusicPlaylistTrack(item SpotifyPlaylistTrack) MusicTrack {
+	var musicTrack MusicTrack
+	if item.Episode != nil {
+		musicTrack = ConvertToMusicEpisode(*item.Episode)
+	} else {
+		musicTrack = ConvertToMusicTrack(item.Track)
+	}
+	musicTrack.AddedAt = NormalizeTime(item.AddedAt)
+	musicTrack.AddedBy = item.AddedBy.IntegrationID
+	return musicTrack
+}
+
+// Sources of dumped tracks.
+const (
+	SourceSpotify = model.SourceSpotify
+	// SourceLocal marks a local file the playlist owner added from their
+	// own library, which cannot be played or added by anyone else.
+	SourceLocal = model.SourceLocal
+)
+
+// ConvertToMusicTrack converts a SpotifyTrack struct to a MusicTrack struct
+func ConvertToMusicTrack(st SpotifyTrack) MusicTrack {
+	musicTrack := MusicTrack{
+		Name:             st.Name,
+		PreviewURL:       st.PreviewURL,
+		AlbumName:        st.Album.Name,
+		AlbumID:          st.Album.IntegrationID,
+		AlbumArt:         st.Album.Images,
+		AlbumReleaseDate: st.Album.ReleaseDate,
+		DurationMS:       st.DurationMS,
+		Duration:         FormatDuration(time.Duration(st.DurationMS) * time.Millisecond),
+		Released:         ParseReleaseDate(st.Album.ReleaseDate, st.Album.ReleaseDatePrecision),
+		ISRC:             st.ExternalIDs.ISRC,
+		DiscNumber:       st.DiscNumber,
+		TrackNumber:      st.TrackNumber,
+		IntegrationID:    st.IntegrationID,
+		IsPlayable:       st.IsPlayable,
+		Explicit:         st.Explicit,
+		Source:           SourceSpotify,
+		ExternalURL:      st.ExternalURL.Spotify,
+		Unavailable:      unavailableReason(st),
+	}
+	if st.LinkedFrom != nil && st.LinkedFrom.IntegrationID != st.IntegrationID {
+		musicTrack.LinkedFrom = st.LinkedFrom.IntegrationID
+	}
+
+	// local files only carry the names from the user's file tags,
+	// there are no IDs, links or artwork to keep.
+	if st.IsLocal {
+		musicTrack.Source = SourceLocal
+	}
+
+	var artistNames []string
+
+	for _, artist := range st.Artists {
+		artistNames = append(artistNames, artist.Name)
+		musicTrack.ArtistList = append(musicTrack.ArtistList, MusicArtist{
+			Name:          artist.Name,
+			IntegrationID: artist.IntegrationID,
+		})
+	}
+
+	musicTrack.Artists = strings.Join(artistNames, ", ")
+
+	return musicTrack
+}
+
+// SpotifyTracksResult is just a container struct.
+type SpotifyTracksResult struct {
+	Items []SpotifyTrack `json:"items"`
+	Next  string         `json:"next"`
+}
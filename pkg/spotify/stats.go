@@ -0,0 +1,115 @@
+package spotify
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// maxRetries is how often a rate limited or failed request is retried
+// before its error is returned.
+const maxRetries = 3
+
+// maxRetryWait caps how long a single retry waits, whatever Retry-After
+// asks for.
+const maxRetryWait = 30 * time.Second
+
+// Stats counts the requests made by a Client.
+type Stats struct {
+	// Requests is the number of API calls made, retries included.
+	Requests int64
+	// Retries is the number of calls repeated after a 429 or 5xx.
+	Retries int64
+	// Errors is the number of calls which failed, by error response or
+	// network error, or couldn't be made for want of a token. Rate
+	// limited calls aren't errors.
+	Errors int64
+	// CacheHits is the number of calls answered from the response cache,
+	// still fresh or after Spotify reported them not modified.
+	// CacheFresh is those of them answered without a request.
+	CacheHits  int64
+	CacheFresh int64
+	// BytesSent and BytesReceived count the request and response bodies.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// counters are the live counts behind Stats.
+type counters struct {
+	requests      atomic.Int64
+	retries       atomic.Int64
+	errors        atomic.Int64
+	cacheHits     atomic.Int64
+	cacheFresh    atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+}
+
+// Stats returns the number of requests made so far.
+func (o *Client) Stats() Stats {
+	return Stats{
+		Requests:      o.counters.requests.Load(),
+		Retries:       o.counters.retries.Load(),
+		Errors:        o.counters.errors.Load(),
+		CacheHits:     o.counters.cacheHits.Load(),
+		CacheFresh:    o.counters.cacheFresh.Load(),
+		BytesSent:     o.counters.bytesSent.Load(),
+		BytesReceived: o.counters.bytesReceived.Load(),
+	}
+}
+
+// Calls returns the number of API calls asked of the client, each counted
+// once however often it was retried, those the cache answered without a
+// request included.
+func (o Stats) Calls() int64 {
+	return o.Requests - o.Retries + o.CacheFresh
+}
+
+// CacheHitRate returns the share of the calls the cache answered, from 0
+// to 1, 0 when there were none.
+func (o Stats) CacheHitRate() float64 {
+	if calls := o.Calls(); calls > 0 {
+		return float64(o.CacheHits) / float64(calls)
+	}
+	return 0
+}
+
+// PlaylistRequests returns how many requests fetching a whole playlist of
+// tracks items takes, with PlaylistFromID or PlaylistTracks: one for every
+// page of MaxPageSize items, the first one even when it is empty.
+func PlaylistRequests(tracks int) int {
+	return max(1, (tracks+MaxPageSize-1)/MaxPageSize)
+}
+
+// retryable reports whether a request that failed with the status is worth
+// retrying. Server errors are only retried for reads, a failed write may
+// still have been applied.
+func retryable(method string, status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && method == "GET")
+}
+
+// retryWait returns how long to wait before the attempt'th retry of a
+// request that failed with err.
+func retryWait(err *APIError, attempt int) time.Duration {
+	wait := err.RetryAfter
+	if wait == 0 {
+		wait = time.Second << attempt
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+	return wait
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,26 @@
+package spotify
+
+import "time"
+
+// NormalizeTime converts t to UTC at second precision, so it marshals as a
+// plain RFC3339 timestamp. Zero times, which spotify sends as null or as the
+// unix epoch for tracks added before it recorded dates, come back as nil.
+func NormalizeTime(t time.Time) *time.Time {
+	if t.IsZero() || t.Unix() == 0 {
+		return nil
+	}
+	utc := t.UTC().Truncate(time.Second)
+	return &utc
+}
+
+// FormatTime formats t as RFC3339 in loc, or UTC when loc is nil. Nil
+// times format as an empty string.
+func FormatTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
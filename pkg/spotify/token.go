@@ -0,0 +1,212 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyrat/spd/internal/secret"
+)
+
+// TokenProvider supplies the access tokens API requests are authorised
+// with. Token is called before every request, so providers cache tokens
+// themselves and only fetch a new one when it is about to expire.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// WithTokenProvider makes all API requests get their token from p instead
+// of the client credentials passed to NewClient.
+func WithTokenProvider(p TokenProvider) Option {
+	return func(o *Client) {
+		o.tokens = p
+	}
+}
+
+// expiryMargin is how long before its expiry a token is replaced, so it
+// doesn't run out while a request is in flight.
+const expiryMargin = time.Minute
+
+// StaticToken is an access token obtained elsewhere, used as is.
+type StaticToken string
+
+// Token returns the token.
+func (o StaticToken) Token(ctx context.Context) (string, error) {
+	if o == "" {
+		return "", errors.New("no spotify access token")
+	}
+	return string(o), nil
+}
+
+// EnvToken reads the access token from the named environment variable on
+// every request, so a token rotated by a wrapping process is picked up.
+type EnvToken string
+
+// Token returns the current value of the variable.
+func (o EnvToken) Token(ctx context.Context) (string, error) {
+	token := os.Getenv(string(o))
+	if token == "" {
+		return "", fmt.Errorf("no spotify access token in $%s", string(o))
+	}
+	return token, nil
+}
+
+// ClientCredentials gets app tokens through the client credentials flow.
+// They can read public data but not act on a user's account.
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+	// AuthURL is the token endpoint, DefaultAuthURL when empty.
+	AuthURL string
+	// Client makes the token requests, http.DefaultClient when nil.
+	Client *http.Client
+
+	cache tokenCache
+}
+
+// Token returns a cached token or requests a new one.
+func (o *ClientCredentials) Token(ctx context.Context) (string, error) {
+	return o.cache.get(func() (string, time.Duration, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		resp, err := requestToken(ctx, o.Client, o.AuthURL, o.ClientID, o.ClientSecret, form)
+		return resp.AccessToken, resp.expiresIn(), err
+	})
+}
+
+// RefreshToken gets user tokens from a refresh token issued by the
+// authorization code flow, for the endpoints acting on a user's account.
+type RefreshToken struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	// AuthURL is the token endpoint, DefaultAuthURL when empty.
+	AuthURL string
+	// Client makes the token requests. When nil, NewClient sets it to
+	// its own http client, http.DefaultClient is used otherwise.
+	Client *http.Client
+
+	cache tokenCache
+}
+
+// Token returns a cached token or exchanges the refresh token for a new
+// one. Spotify may hand out a new refresh token along with it, which is
+// used from then on.
+func (o *RefreshToken) Token(ctx context.Context) (string, error) {
+	return o.cache.get(func() (string, time.Duration, error) {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {o.RefreshToken}}
+		resp, err := requestToken(ctx, o.Client, o.AuthURL, o.ClientID, o.ClientSecret, form)
+		if resp.RefreshToken != "" {
+			o.RefreshToken = resp.RefreshToken
+		}
+		return resp.AccessToken, resp.expiresIn(), err
+	})
+}
+
+// CommandToken runs an external command which prints an access token on
+// stdout, e.g. a client of a central secrets service. The command is run
+// by sh, with stdin only when spdump's is a terminal, and its token reused
+// for TTL.
+type CommandToken struct {
+	Command string
+	// TTL is how long a token is reused, 50 minutes when zero. Spotify
+	// tokens are valid for an hour.
+	TTL time.Duration
+
+	cache tokenCache
+}
+
+// Token returns a cached token or runs the command for a new one.
+func (o *CommandToken) Token(ctx context.Context) (string, error) {
+	return o.cache.get(func() (string, time.Duration, error) {
+		token, err := secret.Command(ctx, o.Command)
+		ttl := o.TTL
+		if ttl == 0 {
+			ttl = 50 * time.Minute
+		}
+		return token, ttl, err
+	})
+}
+
+// tokenCache holds a token until shortly before it expires.
+type tokenCache struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// get returns the cached token, or calls fetch for a new token and how
+// long it is valid for.
+func (o *tokenCache) get(fetch func() (string, time.Duration, error)) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.token != "" && time.Now().Before(o.expires) {
+		return o.token, nil
+	}
+	token, ttl, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	o.token, o.expires = token, time.Now().Add(ttl-expiryMargin)
+	return token, nil
+}
+
+// tokenResponse is the token endpoint's response.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// expiresIn returns how long the token is valid for, an hour when the
+// response doesn't say.
+func (o tokenResponse) expiresIn() time.Duration {
+	if o.ExpiresIn <= 0 {
+		return time.Hour
+	}
+	return time.Duration(o.ExpiresIn) * time.Second
+}
+
+// requestToken posts a token request to the accounts service.
+func requestToken(ctx context.Context, client *http.Client, authURL string, clientID string, clientSecret string, form url.Values) (tokenResponse, error) {
+	token := tokenResponse{}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if authURL == "" {
+		authURL = DefaultAuthURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", authURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token, err
+	}
+	req.SetBasicAuth(clientID, clientSecret)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	slog.Debug("requesting spotify access token", "url", authURL, "grant_type", form.Get("grant_type"))
+	resp, err := client.Do(req)
+	if err != nil {
+		return token, fmt.Errorf("spotify token error: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		return token, newAPIError(resp, body)
+	}
+	json.Unmarshal(body, &token)
+	if token.AccessToken == "" {
+		return token, errors.New("problems getting spotify access token from JSON")
+	}
+	return token, nil
+}
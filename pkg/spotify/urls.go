@@ -0,0 +1,9 @@
+package spotify
+
+import "github.com/pyrat/spd/pkg/model"
+
+// NormalizeURL rewrites a spotify link to its canonical open.spotify.com
+// form, see model.NormalizeURL.
+func NormalizeURL(raw string, keepQuery bool) string {
+	return model.NormalizeURL(raw, keepQuery)
+}
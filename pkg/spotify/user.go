@@ -0,0 +1,167 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// UserPlaylists pages through the playlists a user owns or follows, those
+// of the user owning the token when userID is empty. Other users' private
+// playlists aren't listed. Only the playlist details are included, not
+// their tracks.
+func (o *Client) UserPlaylists(ctx context.Context, userID string) ([]SpotifyPlaylist, error) {
+	next := o.endpoint("/me/playlists")
+	if userID != "" {
+		next = o.endpoint("/users/" + url.PathEscape(userID) + "/playlists")
+	}
+	next += "?limit=50"
+
+	var playlists []SpotifyPlaylist
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return playlists, err
+		}
+		page := SpotifyPlaylistsResult{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return playlists, err
+		}
+		for _, playlist := range page.Items {
+			if playlist.IntegrationID != "" {
+				playlists = append(playlists, playlist)
+			}
+		}
+		next = page.Next
+	}
+	return playlists, nil
+}
+
+// UserPublicPlaylists lists the public playlists of another user, those
+// they own only when owned is true. Spotify lists private and
+// collaborative playlists to their owner's own token, these are left out
+// so a dump only ever holds what the user shares with everyone.
+func (o *Client) UserPublicPlaylists(ctx context.Context, userID string, owned bool) ([]SpotifyPlaylist, error) {
+	listed, err := o.UserPlaylists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	playlists := listed[:0]
+	for _, playlist := range listed {
+		if playlist.Collaborative || (playlist.Public != nil && !*playlist.Public) {
+			continue
+		}
+		if owned && playlist.Owner.IntegrationID != userID {
+			continue
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, nil
+}
+
+// UnfollowPlaylist removes the playlist from the library of the user
+// owning the token. Unfollowing a playlist they own is how Spotify deletes
+// it. The token needs the playlist-modify scopes.
+func (o *Client) UnfollowPlaylist(ctx context.Context, playlistID string) error {
+	endpoint, err := o.resourceEndpoint(TypePlaylist, playlistID)
+	if err != nil {
+		return err
+	}
+	return o.apiRequest(ctx, "DELETE", endpoint+"/followers", nil, nil)
+}
+
+// FollowedArtists pages through the artists the user owning the token
+// follows. The token needs the user-follow-read scope.
+func (o *Client) FollowedArtists(ctx context.Context) ([]SpotifyArtist, error) {
+	next := o.endpoint("/me/following?type=artist&limit=50")
+
+	var artists []SpotifyArtist
+	pages := o.newPager()
+	for next != "" {
+		if err := pages.visit(next); err != nil {
+			return artists, err
+		}
+		// the artists are paged by cursor, next carries it
+		page := struct {
+			Artists struct {
+				Items []SpotifyArtist `json:"items"`
+				Next  string          `json:"next"`
+			} `json:"artists"`
+		}{}
+		if err := o.apiRequest(ctx, "GET", next, nil, &page); err != nil {
+			return artists, err
+		}
+		artists = append(artists, page.Artists.Items...)
+		next = page.Artists.Next
+	}
+	return artists, nil
+}
+
+// FollowedPlaylists lists the playlists the user owning the token follows
+// without owning them, private ones included with the
+// playlist-read-private scope.
+func (o *Client) FollowedPlaylists(ctx context.Context) ([]SpotifyPlaylist, error) {
+	user, err := o.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listed, err := o.UserPlaylists(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	playlists := listed[:0]
+	for _, playlist := range listed {
+		if playlist.Owner.IntegrationID != user.IntegrationID {
+			playlists = append(playlists, playlist)
+		}
+	}
+	return playlists, nil
+}
+
+// MaxSavedTracksPerRequest is the most tracks saved to or removed from
+// the user's library by one request.
+const MaxSavedTracksPerRequest = 50
+
+// SavedTracksError reports a change to the user's saved tracks which
+// failed part way through. Applied is the number of IDs changed before
+// the failing batch, so callers can resume from IDs[Applied:].
+type SavedTracksError struct {
+	Applied int
+	Err     error
+}
+
+func (e *SavedTracksError) Error() string {
+	return fmt.Sprintf("saved tracks: failed after %d tracks: %s", e.Applied, e.Err)
+}
+
+func (e *SavedTracksError) Unwrap() error {
+	return e.Err
+}
+
+// SaveTracks saves the tracks, given by ID, to the Liked Songs of the user
+// owning the token, batched MaxSavedTracksPerRequest at a time. The token
+// needs the user-library-modify scope.
+func (o *Client) SaveTracks(ctx context.Context, IDs []string) error {
+	return o.modifySavedTracks(ctx, "PUT", IDs)
+}
+
+// RemoveSavedTracks removes the tracks, given by ID, from the Liked Songs
+// of the user owning the token, batched MaxSavedTracksPerRequest at a
+// time. The token needs the user-library-modify scope.
+func (o *Client) RemoveSavedTracks(ctx context.Context, IDs []string) error {
+	return o.modifySavedTracks(ctx, "DELETE", IDs)
+}
+
+func (o *Client) modifySavedTracks(ctx context.Context, method string, IDs []string) error {
+	applied := 0
+	for _, batch := range Chunk(IDs, MaxSavedTracksPerRequest) {
+		payload := map[string]interface{}{
+			"ids": batch,
+		}
+		if err := o.apiRequest(ctx, method, o.endpoint("/me/tracks"), payload, nil); err != nil {
+			return &SavedTracksError{Applied: applied, Err: err}
+		}
+		applied += len(batch)
+	}
+	return nil
+}
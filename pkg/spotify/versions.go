@@ -0,0 +1,68 @@
+package spotify
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// versionMarker matches the "(Clean)", "[Explicit]" or " - Clean Version"
+// some releases add to the names of their edited or uncensored versions.
+var versionMarker = regexp.MustCompile(`(?i)\s*(\(|\[|- )(clean|explicit|censored|uncensored)( version| edit)?(\)|\])?\s*$`)
+
+// versionSlack is how far the durations of two versions of a recording
+// may differ.
+const versionSlack = 10000
+
+// OtherVersion looks for the clean version of an explicit track, or the
+// explicit version of a clean one when explicit is true: a track by the
+// same lead artist with the same name and about the same duration.
+// Spotify doesn't link the two, so they are paired up by searching.
+func (o *Client) OtherVersion(ctx context.Context, track MusicTrack, explicit bool) (MusicTrack, bool, error) {
+	if track.Type == TypeEpisode || track.Source == SourceLocal || track.Name == "" {
+		return MusicTrack{}, false, nil
+	}
+	name := versionName(track.Name)
+	artist := strings.SplitN(track.Artists, ", ", 2)[0]
+	if len(track.ArtistList) > 0 {
+		artist = track.ArtistList[0].Name
+	}
+
+	query := `track:"` + name + `"`
+	if artist != "" {
+		query += ` artist:"` + artist + `"`
+	}
+	result, err := o.Search(ctx, query, SearchOptions{Types: []string{"track"}, Limit: 20})
+	if err != nil {
+		return MusicTrack{}, false, err
+	}
+
+	for _, candidate := range result.Tracks.Items {
+		if candidate.Explicit != explicit || candidate.IntegrationID == track.IntegrationID {
+			continue
+		}
+		if !strings.EqualFold(versionName(candidate.Name), name) {
+			continue
+		}
+		if len(candidate.Artists) == 0 || (artist != "" && !strings.EqualFold(candidate.Artists[0].Name, artist)) {
+			continue
+		}
+		if track.DurationMS > 0 && abs(candidate.DurationMS-track.DurationMS) > versionSlack {
+			continue
+		}
+		return ConvertToMusicTrack(candidate), true, nil
+	}
+	return MusicTrack{}, false, nil
+}
+
+// versionName strips a clean/explicit marker from a track name.
+func versionName(name string) string {
+	return strings.TrimSpace(versionMarker.ReplaceAllString(name, ""))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,329 @@
+// Package stats summarises playlists for curators: duplicates, running
+// time, which artists, labels and decades dominate and what the tracks
+// sound like on average. These are the statistics of spdump analyze, and
+// applications embedding spdump can compute them from the tracks they
+// dump with an Accumulator, without reading the dumps back.
+package stats
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pyrat/spd/internal/library"
+	"github.com/pyrat/spd/pkg/pipeline"
+	"github.com/pyrat/spd/pkg/spotify"
+)
+
+// How a track was found to duplicate an earlier one.
+const (
+	MatchID          = "id"
+	MatchISRC        = "isrc"
+	MatchArtistTitle = "artist+title"
+)
+
+// Report is the analysis of a set of playlists.
+type Report struct {
+	Playlists  int
+	Tracks     int
+	DurationMS int64
+	Duplicates []Duplicate `json:",omitempty"`
+	Artists    []Count
+	// Labels is only filled in when the album labels were looked up.
+	Labels  []Count `json:",omitempty"`
+	Decades []Count
+	// AudioFeatures is only filled in when the features were looked up.
+	AudioFeatures *AudioFeatures `json:",omitempty"`
+}
+
+// Duplicate is a track occurring more than once.
+type Duplicate struct {
+	Occurrences []Occurrence
+}
+
+// Occurrence is a track at a position, from 1, in a playlist. MatchedBy
+// tells how it matched the first occurrence, empty for that one.
+type Occurrence struct {
+	PlaylistName  string
+	Position      int
+	Name          string
+	Artists       string
+	IntegrationID string `json:",omitempty"`
+	MatchedBy     string `json:",omitempty"`
+}
+
+// Count is how many tracks share a value.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// AudioFeatures are the average audio features of the tracks which have
+// them.
+type AudioFeatures struct {
+	Tracks int
+	spotify.SpotifyAudioFeatures
+}
+
+// Options carries the optional lookups. Labels maps album IDs to their
+// record label, Features track IDs to their audio features.
+type Options struct {
+	Labels   map[string]string
+	Features map[string]spotify.SpotifyAudioFeatures
+}
+
+// Accumulator builds a Report a playlist and a track at a time, so dumps
+// too big for memory can be streamed through it. It is safe for
+// concurrent use, and Report can be called at any time for the
+// statistics so far.
+type Accumulator struct {
+	mu       sync.Mutex
+	opts     Options
+	report   Report
+	artists  map[string]int
+	labels   map[string]int
+	decades  map[string]int
+	dupes    duplicates
+	features AudioFeatures
+	// seen are the IDs of the playlists counted by Step.
+	seen map[string]bool
+}
+
+// New returns an Accumulator looking up labels and audio features in
+// opts.
+func New(opts Options) *Accumulator {
+	return &Accumulator{
+		opts:    opts,
+		artists: map[string]int{},
+		labels:  map[string]int{},
+		decades: map[string]int{},
+		dupes:   duplicates{first: map[string]*firstOccurrence{}},
+		seen:    map[string]bool{},
+	}
+}
+
+// AddPlaylist counts a playlist, its tracks are added with AddTrack.
+func (o *Accumulator) AddPlaylist(mp spotify.MusicPlaylist) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.report.Playlists++
+}
+
+// AddTrack adds the track at a position, from 1, of the named playlist.
+func (o *Accumulator) AddTrack(playlist string, position int, track spotify.MusicTrack) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.report.Tracks++
+	o.report.DurationMS += int64(track.DurationMS)
+	for _, artist := range library.TrackArtists(track) {
+		o.artists[artist]++
+	}
+	if label := o.opts.Labels[track.AlbumID]; label != "" {
+		o.labels[label]++
+	}
+	o.decades[decade(firstNonEmpty(track.AlbumReleaseDate, track.ReleaseDate))]++
+	if f, ok := o.opts.Features[track.IntegrationID]; ok {
+		o.features.add(f)
+	}
+	o.dupes.add(playlist, position, track)
+}
+
+// Step returns a pipeline step adding the tracks going through it. A
+// playlist is counted once however many times it goes through, as it
+// does a track at a time with --format ndjson-tracks, and the positions
+// are those the tracks have when they reach the step.
+func (o *Accumulator) Step() pipeline.Step {
+	return pipeline.StepFunc(func(ctx context.Context, mp *spotify.MusicPlaylist) error {
+		o.mu.Lock()
+		if !o.seen[mp.IntegrationID] {
+			o.seen[mp.IntegrationID] = true
+			o.report.Playlists++
+		}
+		o.mu.Unlock()
+		for i, track := range mp.Tracks {
+			o.AddTrack(mp.Name, i+1, track)
+		}
+		return nil
+	})
+}
+
+// Report returns the statistics of everything added so far.
+func (o *Accumulator) Report() Report {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	report := o.report
+	report.Duplicates = o.dupes.list()
+	report.Artists = sortedCounts(o.artists, true)
+	if len(o.opts.Labels) > 0 {
+		report.Labels = sortedCounts(o.labels, true)
+	}
+	report.Decades = sortedCounts(o.decades, false)
+	if o.features.Tracks > 0 {
+		features := o.features
+		features.average()
+		report.AudioFeatures = &features
+	}
+	return report
+}
+
+// Analyze analyses the playlists as a whole, duplicates are looked for
+// across all of them.
+func Analyze(playlists []spotify.MusicPlaylist, opts Options) Report {
+	a := New(opts)
+	for _, mp := range playlists {
+		a.AddPlaylist(mp)
+		for i, track := range mp.Tracks {
+			a.AddTrack(mp.Name, i+1, track)
+		}
+	}
+	return a.Report()
+}
+
+// duplicates groups track occurrences by ID, ISRC or artist and title. It
+// keeps the first occurrence of each track under its keys, and the
+// occurrences of the tracks found more than once: a track is only kept
+// whole from its second occurrence on, so a streamed library costs its
+// distinct tracks' keys rather than every occurrence.
+type duplicates struct {
+	first map[string]*firstOccurrence
+	found []Duplicate
+	// order holds the seq of the first occurrence of each found.
+	order []int
+	// seen counts the tracks given a first occurrence.
+	seen int
+}
+
+// firstOccurrence is the first occurrence of a track, with the index in
+// found of its duplicate once there is a second.
+type firstOccurrence struct {
+	Occurrence
+	seq   int
+	group int
+}
+
+func (o *duplicates) add(playlist string, position int, track spotify.MusicTrack) {
+	keys := map[string]string{}
+	if track.IntegrationID != "" {
+		keys[MatchID] = MatchID + ":" + track.IntegrationID
+	}
+	if track.ISRC != "" {
+		keys[MatchISRC] = MatchISRC + ":" + track.ISRC
+	}
+	if artists := library.TrackArtists(track); len(artists) > 0 {
+		keys[MatchArtistTitle] = MatchArtistTitle + ":" + library.NormalizeArtist(artists[0]) + "\x00" + library.NormalizeTitle(track.Name)
+	}
+
+	occurrence := Occurrence{
+		PlaylistName:  playlist,
+		Position:      position,
+		Name:          track.Name,
+		Artists:       track.Artists,
+		IntegrationID: track.IntegrationID,
+	}
+	var first *firstOccurrence
+	for _, match := range []string{MatchID, MatchISRC, MatchArtistTitle} {
+		if f, ok := o.first[keys[match]]; ok && keys[match] != "" {
+			first = f
+			occurrence.MatchedBy = match
+			break
+		}
+	}
+	if first == nil {
+		first = &firstOccurrence{Occurrence: occurrence, seq: o.seen, group: -1}
+		o.seen++
+	} else {
+		// the second hit makes a duplicate of the first occurrence
+		if first.group < 0 {
+			first.group = len(o.found)
+			o.found = append(o.found, Duplicate{Occurrences: []Occurrence{first.Occurrence}})
+			o.order = append(o.order, first.seq)
+		}
+		o.found[first.group].Occurrences = append(o.found[first.group].Occurrences, occurrence)
+	}
+	for _, key := range keys {
+		if _, ok := o.first[key]; !ok {
+			o.first[key] = first
+		}
+	}
+}
+
+// list returns the tracks occurring more than once, in the order of their
+// first occurrences.
+func (o *duplicates) list() []Duplicate {
+	groups := make([]int, len(o.found))
+	for i := range groups {
+		groups[i] = i
+	}
+	sort.Slice(groups, func(i, j int) bool { return o.order[groups[i]] < o.order[groups[j]] })
+	var list []Duplicate
+	for _, group := range groups {
+		// copied, as the report outlives the lock
+		list = append(list, Duplicate{Occurrences: append([]Occurrence(nil), o.found[group].Occurrences...)})
+	}
+	return list
+}
+
+func (o *AudioFeatures) add(f spotify.SpotifyAudioFeatures) {
+	o.Tracks++
+	o.Danceability += f.Danceability
+	o.Energy += f.Energy
+	o.Valence += f.Valence
+	o.Acousticness += f.Acousticness
+	o.Instrumentalness += f.Instrumentalness
+	o.Speechiness += f.Speechiness
+	o.Liveness += f.Liveness
+	o.Tempo += f.Tempo
+	o.Loudness += f.Loudness
+}
+
+func (o *AudioFeatures) average() {
+	n := float64(o.Tracks)
+	o.Danceability /= n
+	o.Energy /= n
+	o.Valence /= n
+	o.Acousticness /= n
+	o.Instrumentalness /= n
+	o.Speechiness /= n
+	o.Liveness /= n
+	o.Tempo /= n
+	o.Loudness /= n
+}
+
+// decade returns the decade of a release date such as 1994-03-01 or
+// 1994, e.g. "1990s".
+func decade(date string) string {
+	if len(date) < 4 {
+		return "unknown"
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil || year == 0 {
+		return "unknown"
+	}
+	return strconv.Itoa(year/10*10) + "s"
+}
+
+// sortedCounts returns the counts, most frequent first when byCount and
+// otherwise by name.
+func sortedCounts(counts map[string]int, byCount bool) []Count {
+	list := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, Count{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if byCount && list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
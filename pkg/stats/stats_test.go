@@ -0,0 +1,135 @@
+package stats_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/pyrat/spd/pkg/spotify"
+	"github.com/pyrat/spd/pkg/stats"
+)
+
+func TestDuplicates(t *testing.T) {
+	playlists := []spotify.MusicPlaylist{
+		{Name: "a", Tracks: []spotify.MusicTrack{
+			{Name: "One", Artists: "X", IntegrationID: "1", ISRC: "I1"},
+			{Name: "Two", Artists: "Y", IntegrationID: "2"},
+			{Name: "Three", Artists: "Z", IntegrationID: "3"},
+		}},
+		{Name: "b", Tracks: []spotify.MusicTrack{
+			{Name: "Three", Artists: "Z", IntegrationID: "3"},
+			{Name: "One (Remastered)", Artists: "X", IntegrationID: "1b", ISRC: "I1"},
+			{Name: "two", Artists: "y", IntegrationID: "2b"},
+			{Name: "One", Artists: "X", IntegrationID: "1"},
+			{Name: "Four", Artists: "W", IntegrationID: "4"},
+		}},
+	}
+	got := stats.Analyze(playlists, stats.Options{}).Duplicates
+	want := []stats.Duplicate{
+		{Occurrences: []stats.Occurrence{
+			{PlaylistName: "a", Position: 1, Name: "One", Artists: "X", IntegrationID: "1"},
+			{PlaylistName: "b", Position: 2, Name: "One (Remastered)", Artists: "X", IntegrationID: "1b", MatchedBy: stats.MatchISRC},
+			{PlaylistName: "b", Position: 4, Name: "One", Artists: "X", IntegrationID: "1", MatchedBy: stats.MatchID},
+		}},
+		{Occurrences: []stats.Occurrence{
+			{PlaylistName: "a", Position: 2, Name: "Two", Artists: "Y", IntegrationID: "2"},
+			{PlaylistName: "b", Position: 3, Name: "two", Artists: "y", IntegrationID: "2b", MatchedBy: stats.MatchArtistTitle},
+		}},
+		{Occurrences: []stats.Occurrence{
+			{PlaylistName: "a", Position: 3, Name: "Three", Artists: "Z", IntegrationID: "3"},
+			{PlaylistName: "b", Position: 1, Name: "Three", Artists: "Z", IntegrationID: "3", MatchedBy: stats.MatchID},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got duplicates\n%+v\nwant, in the order of their first occurrences,\n%+v", got, want)
+	}
+}
+
+func TestAccumulatorConcurrent(t *testing.T) {
+	// tracks only duplicate others copied from the same one, so whichever
+	// order they are added in groups them alike
+	pool := make([]spotify.MusicTrack, 30)
+	for i := range pool {
+		pool[i] = spotify.MusicTrack{
+			Name:             fmt.Sprintf("Track %d", i),
+			Artists:          fmt.Sprintf("Artist %d", i%7),
+			IntegrationID:    fmt.Sprintf("id%d", i),
+			ISRC:             fmt.Sprintf("ISRC%d", i),
+			AlbumID:          fmt.Sprintf("album%d", i%5),
+			AlbumReleaseDate: fmt.Sprintf("%d-01-01", 1960+i*2),
+			DurationMS:       1000 * (i + 1),
+		}
+	}
+	var playlists []spotify.MusicPlaylist
+	for p := 0; p < 8; p++ {
+		mp := spotify.MusicPlaylist{Name: fmt.Sprintf("playlist %d", p), IntegrationID: fmt.Sprintf("p%d", p)}
+		for i := 0; i < 20; i++ {
+			mp.Tracks = append(mp.Tracks, pool[(p*7+i*3)%len(pool)])
+		}
+		playlists = append(playlists, mp)
+	}
+	opts := stats.Options{Labels: map[string]string{"album0": "A", "album1": "B", "album2": "B"}}
+	want := stats.Analyze(playlists, opts)
+
+	a := stats.New(opts)
+	step := a.Step()
+	var wg sync.WaitGroup
+	for p, mp := range playlists {
+		wg.Add(1)
+		go func(p int, mp spotify.MusicPlaylist) {
+			defer wg.Done()
+			// half the playlists go through the step, the others a
+			// track at a time, reporting along the way
+			if p%2 == 0 {
+				if err := step.Apply(context.Background(), &mp); err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			a.AddPlaylist(mp)
+			for i, track := range mp.Tracks {
+				a.AddTrack(mp.Name, i+1, track)
+				a.Report()
+			}
+		}(p, mp)
+	}
+	wg.Wait()
+	got := a.Report()
+
+	if got.Playlists != want.Playlists || got.Tracks != want.Tracks || got.DurationMS != want.DurationMS {
+		t.Errorf("counted %d playlists, %d tracks and %dms, want %d, %d and %dms",
+			got.Playlists, got.Tracks, got.DurationMS, want.Playlists, want.Tracks, want.DurationMS)
+	}
+	for name, counts := range map[string][2][]stats.Count{
+		"artists": {got.Artists, want.Artists},
+		"labels":  {got.Labels, want.Labels},
+		"decades": {got.Decades, want.Decades},
+	} {
+		if !reflect.DeepEqual(counts[0], counts[1]) {
+			t.Errorf("got %s %v, want %v", name, counts[0], counts[1])
+		}
+	}
+	if groups, wantGroups := occurrences(got.Duplicates), occurrences(want.Duplicates); !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("got duplicates\n%v\nwant\n%v", groups, wantGroups)
+	}
+}
+
+// occurrences returns the playlists and positions of each duplicate,
+// sorted, as which occurrence comes first depends on the order they were
+// added in.
+func occurrences(duplicates []stats.Duplicate) [][]string {
+	var groups [][]string
+	for _, d := range duplicates {
+		var group []string
+		for _, o := range d.Occurrences {
+			group = append(group, fmt.Sprintf("%s:%d", o.PlaylistName, o.Position))
+		}
+		sort.Strings(group)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}